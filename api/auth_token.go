@@ -173,4 +173,6 @@ type TokenCreateRequest struct {
 	NoDefaultPolicy bool              `json:"no_default_policy,omitempty"`
 	DisplayName     string            `json:"display_name"`
 	NumUses         int               `json:"num_uses"`
+	Type            string            `json:"type,omitempty"`
+	BoundCIDRs      []string          `json:"bound_cidrs,omitempty"`
 }
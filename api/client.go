@@ -26,6 +26,7 @@ const EnvVaultClientCert = "VAULT_CLIENT_CERT"
 const EnvVaultClientKey = "VAULT_CLIENT_KEY"
 const EnvVaultInsecure = "VAULT_SKIP_VERIFY"
 const EnvVaultTLSServerName = "VAULT_TLS_SERVER_NAME"
+const EnvVaultNamespace = "VAULT_NAMESPACE"
 
 var (
 	errRedirect = errors.New("redirect")
@@ -161,16 +162,25 @@ func (c *Config) ReadEnvironment() error {
 // Client is the client to the Vault API. Create a client with
 // NewClient.
 type Client struct {
-	addr   *url.URL
-	config *Config
-	token  string
+	addr               *url.URL
+	config             *Config
+	token              string
+	namespace          string
+	wrappingLookupFunc WrappingLookupFunc
 }
 
+// WrappingLookupFunc is a function that, given an HTTP verb and a path,
+// returns an optional string duration to be used for response wrapping (e.g.
+// "15s", or simply "15"). The path will not begin with "/v1/" or "v1/" or "/",
+// however, end-of-path forward slashes are not trimmed, so must match your
+// called path precisely.
+type WrappingLookupFunc func(operation, path string) string
+
 // NewClient returns a new client for the given configuration.
 //
 // If the environment variable `VAULT_TOKEN` is present, the token will be
 // automatically added to the client. Otherwise, you must manually call
-// `SetToken()`.
+// `SetToken()`. The same goes for `VAULT_NAMESPACE` and `SetNamespace()`.
 func NewClient(c *Config) (*Client, error) {
 
 	u, err := url.Parse(c.Address)
@@ -203,6 +213,10 @@ func NewClient(c *Config) (*Client, error) {
 		client.SetToken(token)
 	}
 
+	if namespace := os.Getenv(EnvVaultNamespace); namespace != "" {
+		client.SetNamespace(namespace)
+	}
+
 	return client, nil
 }
 
@@ -223,6 +237,24 @@ func (c *Client) ClearToken() {
 	c.token = ""
 }
 
+// Namespace returns the namespace requests are made within. It will return
+// the empty string for the root namespace.
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// SetNamespace sets the namespace requests are made within, such as
+// "team-a/". Paths of requests are relative to the namespace.
+func (c *Client) SetNamespace(v string) {
+	c.namespace = v
+}
+
+// SetWrappingLookupFunc sets a lookup function that returns desired wrap TTLs
+// for a given operation and path
+func (c *Client) SetWrappingLookupFunc(lookupFunc WrappingLookupFunc) {
+	c.wrappingLookupFunc = lookupFunc
+}
+
 // NewRequest creates a new raw request object to query the Vault server
 // configured for this client. This is an advanced method and generally
 // doesn't need to be called externally.
@@ -235,9 +267,23 @@ func (c *Client) NewRequest(method, path string) *Request {
 			Path:   path,
 		},
 		ClientToken: c.token,
+		Namespace:   c.namespace,
 		Params:      make(map[string][]string),
 	}
 
+	if c.wrappingLookupFunc != nil {
+		var lookupPath string
+		switch {
+		case strings.HasPrefix(path, "/v1/"):
+			lookupPath = strings.TrimPrefix(path, "/v1/")
+		case strings.HasPrefix(path, "v1/"):
+			lookupPath = strings.TrimPrefix(path, "v1/")
+		default:
+			lookupPath = strings.TrimPrefix(path, "/")
+		}
+		req.WrapTTL = c.wrappingLookupFunc(method, lookupPath)
+	}
+
 	return req
 }
 
@@ -80,3 +80,28 @@ func (c *Logical) Delete(path string) (*Secret, error) {
 
 	return nil, nil
 }
+
+// Unwrap returns the response wrapped in the given response-wrapping token.
+// If the token is empty, the client's token is assumed to be the wrapping
+// token.
+func (c *Logical) Unwrap(wrappingToken string) (*Secret, error) {
+	r := c.c.NewRequest("PUT", "/v1/sys/wrapping/unwrap")
+	if wrappingToken != "" {
+		body := map[string]interface{}{
+			"token": wrappingToken,
+		}
+		if err := r.SetJSONBody(body); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := c.c.RawRequest(r)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSecret(resp.Body)
+}
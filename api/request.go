@@ -15,6 +15,8 @@ type Request struct {
 	URL         *url.URL
 	Params      url.Values
 	ClientToken string
+	Namespace   string
+	WrapTTL     string
 	Obj         interface{}
 	Body        io.Reader
 	BodySize    int64
@@ -62,5 +64,13 @@ func (r *Request) ToHTTP() (*http.Request, error) {
 		req.Header.Set("X-Vault-Token", r.ClientToken)
 	}
 
+	if len(r.Namespace) != 0 {
+		req.Header.Set("X-Vault-Namespace", r.Namespace)
+	}
+
+	if len(r.WrapTTL) != 0 {
+		req.Header.Set("X-Vault-Wrap-TTL", r.WrapTTL)
+	}
+
 	return req, nil
 }
@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"io"
+	"time"
 )
 
 // Secret is the structure returned for every secret within Vault.
@@ -23,12 +24,18 @@ type Secret struct {
 	// Auth, if non-nil, means that there was authentication information
 	// attached to this response.
 	Auth *SecretAuth `json:"auth,omitempty"`
+
+	// WrapInfo, if non-nil, means that the initial response was wrapped in the
+	// cubbyhole of the given token (which has a TTL of the given number of
+	// seconds)
+	WrapInfo *SecretWrapInfo `json:"wrap_info,omitempty"`
 }
 
 // SecretAuth is the structure containing auth information if we have it.
 type SecretAuth struct {
 	ClientToken string            `json:"client_token"`
 	Accessor    string            `json:"accessor"`
+	EntityID    string            `json:"entity_id"`
 	Policies    []string          `json:"policies"`
 	Metadata    map[string]string `json:"metadata"`
 
@@ -36,6 +43,15 @@ type SecretAuth struct {
 	Renewable     bool `json:"renewable"`
 }
 
+// SecretWrapInfo contains wrapping information if we have it.
+type SecretWrapInfo struct {
+	Token           string    `json:"token"`
+	TTL             int       `json:"ttl"`
+	CreationTime    time.Time `json:"creation_time"`
+	Accessor        string    `json:"accessor"`
+	WrappedAccessor string    `json:"wrapped_accessor"`
+}
+
 // ParseSecret is used to parse a secret value from JSON from an io.Reader.
 func ParseSecret(r io.Reader) (*Secret, error) {
 	// First decode the JSON into a map[string]interface{}
@@ -18,13 +18,15 @@ func (c *Sys) ListAuth() (map[string]*AuthMount, error) {
 }
 
 func (c *Sys) EnableAuth(path, authType, desc string) error {
-	body := map[string]string{
-		"type":        authType,
-		"description": desc,
-	}
+	return c.EnableAuthWithOptions(path, &EnableAuthOptions{
+		Type:        authType,
+		Description: desc,
+	})
+}
 
+func (c *Sys) EnableAuthWithOptions(path string, options *EnableAuthOptions) error {
 	r := c.c.NewRequest("POST", fmt.Sprintf("/v1/sys/auth/%s", path))
-	if err := r.SetJSONBody(body); err != nil {
+	if err := r.SetJSONBody(options); err != nil {
 		return err
 	}
 
@@ -50,7 +52,16 @@ func (c *Sys) DisableAuth(path string) error {
 // individually documentd because the map almost directly to the raw HTTP API
 // documentation. Please refer to that documentation for more details.
 
+type EnableAuthOptions struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	TokenType   string `json:"token_type,omitempty"`
+	PluginName  string `json:"plugin_name,omitempty"`
+}
+
 type AuthMount struct {
 	Type        string
 	Description string
+	TokenType   string `json:"token_type"`
+	PluginName  string `json:"plugin_name"`
 }
@@ -43,3 +43,48 @@ func (c *Sys) RevokeForce(id string) error {
 	}
 	return err
 }
+
+func (c *Sys) LookupLease(id string) (*Secret, error) {
+	r := c.c.NewRequest("PUT", "/v1/sys/leases/lookup")
+
+	body := map[string]interface{}{"lease_id": id}
+	if err := r.SetJSONBody(body); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.c.RawRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return ParseSecret(resp.Body)
+}
+
+func (c *Sys) ListLeases(prefix string) (*Secret, error) {
+	r := c.c.NewRequest("GET", "/v1/sys/leases/lookup/"+prefix)
+	r.Params.Set("list", "true")
+	resp, err := c.c.RawRequest(r)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if resp != nil && resp.StatusCode == 404 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSecret(resp.Body)
+}
+
+func (c *Sys) CountLeases() (*Secret, error) {
+	r := c.c.NewRequest("GET", "/v1/sys/leases/count")
+	resp, err := c.c.RawRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return ParseSecret(resp.Body)
+}
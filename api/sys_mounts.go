@@ -94,6 +94,7 @@ type MountInput struct {
 	Type        string           `json:"type" structs:"type"`
 	Description string           `json:"description" structs:"description"`
 	Config      MountConfigInput `json:"config" structs:"config"`
+	PluginName  string           `json:"plugin_name,omitempty" structs:"plugin_name,omitempty"`
 }
 
 type MountConfigInput struct {
@@ -105,6 +106,7 @@ type MountOutput struct {
 	Type        string            `json:"type" structs:"type"`
 	Description string            `json:"description" structs:"description"`
 	Config      MountConfigOutput `json:"config" structs:"config"`
+	PluginName  string            `json:"plugin_name" structs:"plugin_name"`
 }
 
 type MountConfigOutput struct {
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"fmt"
+)
+
+func (c *Sys) ListNamespaces() ([]string, error) {
+	r := c.c.NewRequest("LIST", "/v1/sys/namespaces")
+	resp, err := c.c.RawRequest(r)
+	if resp != nil {
+		defer resp.Body.Close()
+		if resp.StatusCode == 404 {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result listNamespacesResp
+	err = resp.DecodeJSON(&result)
+	return result.Keys, err
+}
+
+func (c *Sys) GetNamespace(name string) (*Namespace, error) {
+	r := c.c.NewRequest("GET", fmt.Sprintf("/v1/sys/namespaces/%s", name))
+	resp, err := c.c.RawRequest(r)
+	if resp != nil {
+		defer resp.Body.Close()
+		if resp.StatusCode == 404 {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result Namespace
+	err = resp.DecodeJSON(&result)
+	return &result, err
+}
+
+func (c *Sys) CreateNamespace(name string) (*Namespace, error) {
+	r := c.c.NewRequest("PUT", fmt.Sprintf("/v1/sys/namespaces/%s", name))
+	resp, err := c.c.RawRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result Namespace
+	err = resp.DecodeJSON(&result)
+	return &result, err
+}
+
+func (c *Sys) DeleteNamespace(name string) error {
+	r := c.c.NewRequest("DELETE", fmt.Sprintf("/v1/sys/namespaces/%s", name))
+	resp, err := c.c.RawRequest(r)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	return err
+}
+
+type Namespace struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+}
+
+type listNamespacesResp struct {
+	Keys []string `json:"keys"`
+}
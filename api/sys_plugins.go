@@ -0,0 +1,80 @@
+package api
+
+import (
+	"fmt"
+)
+
+func (c *Sys) ListPlugins() ([]string, error) {
+	r := c.c.NewRequest("LIST", "/v1/sys/plugins/catalog")
+	resp, err := c.c.RawRequest(r)
+	if resp != nil {
+		defer resp.Body.Close()
+		if resp.StatusCode == 404 {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result listPluginsResp
+	err = resp.DecodeJSON(&result)
+	return result.Keys, err
+}
+
+func (c *Sys) GetPlugin(name string) (*Plugin, error) {
+	r := c.c.NewRequest("GET", fmt.Sprintf("/v1/sys/plugins/catalog/%s", name))
+	resp, err := c.c.RawRequest(r)
+	if resp != nil {
+		defer resp.Body.Close()
+		if resp.StatusCode == 404 {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result Plugin
+	err = resp.DecodeJSON(&result)
+	return &result, err
+}
+
+func (c *Sys) RegisterPlugin(plugin *Plugin) error {
+	body := map[string]string{
+		"command": plugin.Command,
+		"sha_256": plugin.SHA256,
+	}
+
+	r := c.c.NewRequest("PUT", fmt.Sprintf("/v1/sys/plugins/catalog/%s", plugin.Name))
+	if err := r.SetJSONBody(body); err != nil {
+		return err
+	}
+
+	resp, err := c.c.RawRequest(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+func (c *Sys) DeregisterPlugin(name string) error {
+	r := c.c.NewRequest("DELETE", fmt.Sprintf("/v1/sys/plugins/catalog/%s", name))
+	resp, err := c.c.RawRequest(r)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	return err
+}
+
+type Plugin struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+	SHA256  string `json:"sha_256"`
+}
+
+type listPluginsResp struct {
+	Keys []string `json:"keys"`
+}
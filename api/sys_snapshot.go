@@ -0,0 +1,35 @@
+package api
+
+import (
+	"io"
+)
+
+// SnapshotSave writes a snapshot of the storage of the Vault server to w.
+func (c *Sys) SnapshotSave(w io.Writer) error {
+	r := c.c.NewRequest("GET", "/v1/sys/storage/snapshot")
+	resp, err := c.c.RawRequest(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// SnapshotRestore restores the snapshot read from snap onto the Vault
+// server, which seals itself afterwards. A snapshot taken with a different
+// keyring is only restored if force is set.
+func (c *Sys) SnapshotRestore(snap io.Reader, force bool) error {
+	r := c.c.NewRequest("POST", "/v1/sys/storage/snapshot")
+	r.Body = snap
+	if force {
+		r.Params.Set("force", "true")
+	}
+
+	resp, err := c.c.RawRequest(r)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	return err
+}
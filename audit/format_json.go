@@ -46,6 +46,7 @@ func (f *FormatJSON) FormatRequest(
 			Path:        req.Path,
 			Data:        req.Data,
 			RemoteAddr:  getRemoteAddr(req),
+			WrapTTL:     int(req.WrapTTL / time.Second),
 		},
 	})
 }
@@ -79,6 +80,17 @@ func (f *FormatJSON) FormatResponse(
 		}
 	}
 
+	var respWrapInfo *JSONWrapInfo
+	if resp.WrapInfo != nil {
+		respWrapInfo = &JSONWrapInfo{
+			TTL:             int(resp.WrapInfo.TTL / time.Second),
+			Token:           resp.WrapInfo.Token,
+			CreationTime:    resp.WrapInfo.CreationTime,
+			Accessor:        resp.WrapInfo.Accessor,
+			WrappedAccessor: resp.WrapInfo.WrappedAccessor,
+		}
+	}
+
 	var respSecret *JSONSecret
 	if resp.Secret != nil {
 		respSecret = &JSONSecret{
@@ -103,6 +115,7 @@ func (f *FormatJSON) FormatResponse(
 			Path:       req.Path,
 			Data:       req.Data,
 			RemoteAddr: getRemoteAddr(req),
+			WrapTTL:    int(req.WrapTTL / time.Second),
 		},
 
 		Response: JSONResponse{
@@ -110,6 +123,7 @@ func (f *FormatJSON) FormatResponse(
 			Secret:   respSecret,
 			Data:     resp.Data,
 			Redirect: resp.Redirect,
+			WrapInfo: respWrapInfo,
 		},
 	})
 }
@@ -139,6 +153,7 @@ type JSONRequest struct {
 	Path        string                 `json:"path"`
 	Data        map[string]interface{} `json:"data"`
 	RemoteAddr  string                 `json:"remote_address"`
+	WrapTTL     int                    `json:"wrap_ttl"`
 }
 
 type JSONResponse struct {
@@ -146,6 +161,7 @@ type JSONResponse struct {
 	Secret   *JSONSecret            `json:"secret,emitempty"`
 	Data     map[string]interface{} `json:"data"`
 	Redirect string                 `json:"redirect"`
+	WrapInfo *JSONWrapInfo          `json:"wrap_info,omitempty"`
 }
 
 type JSONAuth struct {
@@ -160,6 +176,14 @@ type JSONSecret struct {
 	LeaseID string `json:"lease_id"`
 }
 
+type JSONWrapInfo struct {
+	TTL             int       `json:"ttl"`
+	Token           string    `json:"token"`
+	CreationTime    time.Time `json:"creation_time"`
+	Accessor        string    `json:"accessor,omitempty"`
+	WrappedAccessor string    `json:"wrapped_accessor,omitempty"`
+}
+
 // getRemoteAddr safely gets the remote address avoiding a nil pointer
 func getRemoteAddr(req *logical.Request) string {
 	if req != nil && req.Connection != nil {
@@ -187,12 +187,23 @@ func (b *Backend) LogResponse(
 		if !b.hmacAccessor && resp != nil && resp.Auth != nil && resp.Auth.Accessor != "" {
 			accessor = resp.Auth.Accessor
 		}
+		var wrappingAccessor, wrappedAccessor string
+		if !b.hmacAccessor && resp != nil && resp.WrapInfo != nil {
+			wrappingAccessor = resp.WrapInfo.Accessor
+			wrappedAccessor = resp.WrapInfo.WrappedAccessor
+		}
 		if err := audit.Hash(b.salt, resp); err != nil {
 			return err
 		}
 		if accessor != "" {
 			resp.Auth.Accessor = accessor
 		}
+		if wrappingAccessor != "" {
+			resp.WrapInfo.Accessor = wrappingAccessor
+		}
+		if wrappedAccessor != "" {
+			resp.WrapInfo.WrappedAccessor = wrappedAccessor
+		}
 	}
 
 	var format audit.FormatJSON
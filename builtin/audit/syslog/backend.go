@@ -179,12 +179,23 @@ func (b *Backend) LogResponse(auth *logical.Auth, req *logical.Request,
 		if !b.hmacAccessor && resp != nil && resp.Auth != nil && resp.Auth.Accessor != "" {
 			accessor = resp.Auth.Accessor
 		}
+		var wrappingAccessor, wrappedAccessor string
+		if !b.hmacAccessor && resp != nil && resp.WrapInfo != nil {
+			wrappingAccessor = resp.WrapInfo.Accessor
+			wrappedAccessor = resp.WrapInfo.WrappedAccessor
+		}
 		if err := audit.Hash(b.salt, resp); err != nil {
 			return err
 		}
 		if accessor != "" {
 			resp.Auth.Accessor = accessor
 		}
+		if wrappingAccessor != "" {
+			resp.WrapInfo.Accessor = wrappingAccessor
+		}
+		if wrappedAccessor != "" {
+			resp.WrapInfo.WrappedAccessor = wrappedAccessor
+		}
 	}
 
 	// Encode the entry as JSON
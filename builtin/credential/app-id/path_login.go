@@ -98,6 +98,9 @@ func (b *backend) pathLogin(
 			DisplayName: displayName,
 			Policies:    policies,
 			Metadata:    metadata,
+			Alias: &logical.Alias{
+				Name: userId,
+			},
 			LeaseOptions: logical.LeaseOptions{
 				Renewable: true,
 			},
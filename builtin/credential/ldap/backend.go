@@ -84,27 +84,27 @@ func EscapeLDAPValue(input string) string {
 	return input
 }
 
-func (b *backend) Login(req *logical.Request, username string, password string) ([]string, *logical.Response, error) {
+func (b *backend) Login(req *logical.Request, username string, password string) ([]string, []string, *logical.Response, error) {
 
 	cfg, err := b.Config(req)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	if cfg == nil {
-		return nil, logical.ErrorResponse("ldap backend not configured"), nil
+		return nil, nil, logical.ErrorResponse("ldap backend not configured"), nil
 	}
 
 	c, err := cfg.DialLDAP()
 	if err != nil {
-		return nil, logical.ErrorResponse(err.Error()), nil
+		return nil, nil, logical.ErrorResponse(err.Error()), nil
 	}
 	if c == nil {
-		return nil, logical.ErrorResponse("invalid connection returned from LDAP dial"), nil
+		return nil, nil, logical.ErrorResponse("invalid connection returned from LDAP dial"), nil
 	}
 	binddn := ""
 	if cfg.DiscoverDN || (cfg.BindDN != "" && cfg.BindPassword != "") {
 		if err = c.Bind(cfg.BindDN, cfg.BindPassword); err != nil {
-			return nil, logical.ErrorResponse(fmt.Sprintf("LDAP bind (service) failed: %v", err)), nil
+			return nil, nil, logical.ErrorResponse(fmt.Sprintf("LDAP bind (service) failed: %v", err)), nil
 		}
 		sresult, err := c.Search(&ldap.SearchRequest{
 			BaseDN: cfg.UserDN,
@@ -112,10 +112,10 @@ func (b *backend) Login(req *logical.Request, username string, password string)
 			Filter: fmt.Sprintf("(%s=%s)", cfg.UserAttr, ldap.EscapeFilter(username)),
 		})
 		if err != nil {
-			return nil, logical.ErrorResponse(fmt.Sprintf("LDAP search for binddn failed: %v", err)), nil
+			return nil, nil, logical.ErrorResponse(fmt.Sprintf("LDAP search for binddn failed: %v", err)), nil
 		}
 		if len(sresult.Entries) != 1 {
-			return nil, logical.ErrorResponse("LDAP search for binddn 0 or not uniq"), nil
+			return nil, nil, logical.ErrorResponse("LDAP search for binddn 0 or not uniq"), nil
 		}
 		binddn = sresult.Entries[0].DN
 	} else {
@@ -126,7 +126,7 @@ func (b *backend) Login(req *logical.Request, username string, password string)
 		}
 	}
 	if err = c.Bind(binddn, password); err != nil {
-		return nil, logical.ErrorResponse(fmt.Sprintf("LDAP bind failed: %v", err)), nil
+		return nil, nil, logical.ErrorResponse(fmt.Sprintf("LDAP bind failed: %v", err)), nil
 	}
 
 	userdn := ""
@@ -138,7 +138,7 @@ func (b *backend) Login(req *logical.Request, username string, password string)
 			Filter: fmt.Sprintf("(userPrincipalName=%s)", ldap.EscapeFilter(binddn)),
 		})
 		if err != nil {
-			return nil, logical.ErrorResponse(fmt.Sprintf("LDAP search failed: %v", err)), nil
+			return nil, nil, logical.ErrorResponse(fmt.Sprintf("LDAP search failed: %v", err)), nil
 		}
 		for _, e := range sresult.Entries {
 			userdn = e.DN
@@ -168,7 +168,7 @@ func (b *backend) Login(req *logical.Request, username string, password string)
 			Filter: fmt.Sprintf("(|(memberUid=%s)(member=%s)(uniqueMember=%s))", ldap.EscapeFilter(username), ldap.EscapeFilter(userdn), ldap.EscapeFilter(userdn)),
 		})
 		if err != nil {
-			return nil, logical.ErrorResponse(fmt.Sprintf("LDAP search failed: %v", err)), nil
+			return nil, nil, logical.ErrorResponse(fmt.Sprintf("LDAP search failed: %v", err)), nil
 		}
 
 		for _, e := range sresult.Entries {
@@ -197,10 +197,10 @@ func (b *backend) Login(req *logical.Request, username string, password string)
 		}
 
 		resp.Data["error"] = errStr
-		return nil, resp, nil
+		return nil, nil, resp, nil
 	}
 
-	return policies, resp, nil
+	return policies, allgroups, resp, nil
 }
 
 const backendHelp = `
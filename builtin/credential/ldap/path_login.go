@@ -38,7 +38,7 @@ func (b *backend) pathLogin(
 	username := d.Get("username").(string)
 	password := d.Get("password").(string)
 
-	policies, resp, err := b.Login(req, username, password)
+	policies, groupNames, resp, err := b.Login(req, username, password)
 	// Handle an internal error
 	if err != nil {
 		return nil, err
@@ -54,6 +54,13 @@ func (b *backend) pathLogin(
 
 	sort.Strings(policies)
 
+	var groupAliases []*logical.Alias
+	for _, groupName := range groupNames {
+		groupAliases = append(groupAliases, &logical.Alias{
+			Name: groupName,
+		})
+	}
+
 	resp.Auth = &logical.Auth{
 		Policies: policies,
 		Metadata: map[string]string{
@@ -64,6 +71,10 @@ func (b *backend) pathLogin(
 			"password": password,
 		},
 		DisplayName: username,
+		Alias: &logical.Alias{
+			Name: username,
+		},
+		GroupAliases: groupAliases,
 		LeaseOptions: logical.LeaseOptions{
 			Renewable: true,
 		},
@@ -77,7 +88,7 @@ func (b *backend) pathLoginRenew(
 	username := req.Auth.Metadata["username"]
 	password := req.Auth.InternalData["password"].(string)
 
-	loginPolicies, resp, err := b.Login(req, username, password)
+	loginPolicies, _, resp, err := b.Login(req, username, password)
 	if len(loginPolicies) == 0 {
 		return resp, err
 	}
@@ -169,6 +169,12 @@ func Commands(metaPtr *meta.Meta) map[string]cli.CommandFactory {
 			}, nil
 		},
 
+		"unwrap": func() (cli.Command, error) {
+			return &command.UnwrapCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+
 		"list": func() (cli.Command, error) {
 			return &command.ListCommand{
 				Meta: *metaPtr,
@@ -211,6 +217,24 @@ func Commands(metaPtr *meta.Meta) map[string]cli.CommandFactory {
 			}, nil
 		},
 
+		"lease": func() (cli.Command, error) {
+			return &command.LeaseCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+
+		"lease list": func() (cli.Command, error) {
+			return &command.LeaseListCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+
+		"lease lookup": func() (cli.Command, error) {
+			return &command.LeaseLookupCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+
 		"seal": func() (cli.Command, error) {
 			return &command.SealCommand{
 				Meta: *metaPtr,
@@ -229,6 +253,36 @@ func Commands(metaPtr *meta.Meta) map[string]cli.CommandFactory {
 			}, nil
 		},
 
+		"operator": func() (cli.Command, error) {
+			return &command.OperatorCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+
+		"operator snapshot": func() (cli.Command, error) {
+			return &command.OperatorSnapshotCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+
+		"operator migrate": func() (cli.Command, error) {
+			return &command.MigrateCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+
+		"operator snapshot save": func() (cli.Command, error) {
+			return &command.SnapshotSaveCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+
+		"operator snapshot restore": func() (cli.Command, error) {
+			return &command.SnapshotRestoreCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+
 		"step-down": func() (cli.Command, error) {
 			return &command.StepDownCommand{
 				Meta: *metaPtr,
@@ -3,6 +3,7 @@ package cli
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/mitchellh/cli"
 )
@@ -28,8 +29,10 @@ func RunCustom(args []string, commands map[string]cli.CommandFactory) int {
 	// tedious, but we don't have a better way at the moment.
 	commandsInclude := make([]string, 0, len(commands))
 	for k, _ := range commands {
-		switch k {
-		case "token-disk":
+		switch {
+		case k == "token-disk":
+		case strings.Contains(k, " "):
+			// Nested commands are listed in the help of their parent
 		default:
 			commandsInclude = append(commandsInclude, k)
 		}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/meta"
 )
 
@@ -13,10 +14,12 @@ type AuthEnableCommand struct {
 }
 
 func (c *AuthEnableCommand) Run(args []string) int {
-	var description, path string
+	var description, path, tokenType, pluginName string
 	flags := c.Meta.FlagSet("auth-enable", meta.FlagSetDefault)
 	flags.StringVar(&description, "description", "", "")
 	flags.StringVar(&path, "path", "", "")
+	flags.StringVar(&tokenType, "token-type", "", "")
+	flags.StringVar(&pluginName, "plugin-name", "", "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := flags.Parse(args); err != nil {
 		return 1
@@ -44,7 +47,13 @@ func (c *AuthEnableCommand) Run(args []string) int {
 		return 2
 	}
 
-	if err := client.Sys().EnableAuth(path, authType, description); err != nil {
+	err = client.Sys().EnableAuthWithOptions(path, &api.EnableAuthOptions{
+		Type:        authType,
+		Description: description,
+		TokenType:   tokenType,
+		PluginName:  pluginName,
+	})
+	if err != nil {
 		c.Ui.Error(fmt.Sprintf(
 			"Error: %s", err))
 		return 2
@@ -82,6 +91,15 @@ Auth Enable Options:
                           to the type of the mount. This will make the auth
                           provider available at "/auth/<path>"
 
+  -token-type=<type>      Type of the tokens issued by the auth provider,
+                          either "service" or "batch". Batch tokens are not
+                          written to storage, but cannot be renewed, revoked
+                          on their own or create child tokens. This defaults
+                          to "service".
+
+  -plugin-name=<name>     Name of the catalog plugin serving the auth
+                          provider, for providers of type "plugin".
+
 `
 	return strings.TrimSpace(helpText)
 }
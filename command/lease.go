@@ -0,0 +1,33 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/hashicorp/vault/meta"
+	"github.com/mitchellh/cli"
+)
+
+// LeaseCommand groups the commands used to inspect the leases held by
+// Vault.
+type LeaseCommand struct {
+	meta.Meta
+}
+
+func (c *LeaseCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
+
+func (c *LeaseCommand) Synopsis() string {
+	return "Inspect the leases of secrets"
+}
+
+func (c *LeaseCommand) Help() string {
+	helpText := `
+Usage: vault lease <subcommand> [options] [args]
+
+  This command groups subcommands for inspecting the leases Vault holds on
+  the secrets it has issued. Leases are renewed with "vault renew" and
+  revoked with "vault revoke".
+`
+	return strings.TrimSpace(helpText)
+}
@@ -0,0 +1,85 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/meta"
+)
+
+// LeaseListCommand is a Command that lists the leases under a prefix.
+type LeaseListCommand struct {
+	meta.Meta
+}
+
+func (c *LeaseListCommand) Run(args []string) int {
+	var format string
+	flags := c.Meta.FlagSet("lease list", meta.FlagSetDefault)
+	flags.StringVar(&format, "format", "table", "")
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) > 1 {
+		flags.Usage()
+		c.Ui.Error("\nlease list expects at most one argument: the prefix to list")
+		return 1
+	}
+
+	var prefix string
+	if len(args) == 1 {
+		prefix = strings.TrimPrefix(args[0], "/")
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix = prefix + "/"
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Error initializing client: %s", err))
+		return 2
+	}
+
+	secret, err := client.Sys().ListLeases(prefix)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Error listing leases under '%s': %s", prefix, err))
+		return 1
+	}
+	if secret == nil || secret.Data["keys"] == nil {
+		c.Ui.Error("No leases found")
+		return 0
+	}
+
+	return OutputList(c.Ui, format, secret)
+}
+
+func (c *LeaseListCommand) Synopsis() string {
+	return "List the leases under a prefix"
+}
+
+func (c *LeaseListCommand) Help() string {
+	helpText := `
+Usage: vault lease list [options] [prefix]
+
+  List the leases Vault holds under the given prefix of lease IDs.
+
+  Lease IDs begin with the path the secret was read from, so listing
+  "postgresql/creds/readonly" shows the outstanding credentials of that role.
+  Entries ending in a slash are prefixes that can be listed in turn. Without
+  a prefix, the mounts holding leases are listed.
+
+  Listing leases requires sudo capability on "sys/leases/lookup".
+
+General Options:
+` + meta.GeneralOptionsUsage() + `
+Lease List Options:
+
+  -format=table           The format for output. By default it is a whitespace-
+                          delimited table. This can also be json or yaml.
+`
+	return strings.TrimSpace(helpText)
+}
@@ -0,0 +1,101 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/meta"
+	"github.com/hashicorp/vault/vault"
+	"github.com/mitchellh/cli"
+)
+
+func TestLeaseList(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := http.TestServer(t, core)
+	defer ln.Close()
+
+	ui := new(cli.MockUi)
+	c := &LeaseListCommand{
+		Meta: meta.Meta{
+			ClientToken: token,
+			Ui:          ui,
+		},
+	}
+
+	client := testClient(t, addr, token)
+	_, err := client.Logical().Write("secret/foo", map[string]interface{}{
+		"key":   "value",
+		"lease": "1m",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	secret, err := client.Logical().Read("secret/foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	args := []string{
+		"-address", addr,
+		"secret/foo",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	id := strings.TrimPrefix(secret.LeaseID, "secret/foo/")
+	if !strings.Contains(ui.OutputWriter.String(), id) {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+}
+
+func TestLeaseLookup(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := http.TestServer(t, core)
+	defer ln.Close()
+
+	ui := new(cli.MockUi)
+	c := &LeaseLookupCommand{
+		Meta: meta.Meta{
+			ClientToken: token,
+			Ui:          ui,
+		},
+	}
+
+	client := testClient(t, addr, token)
+	_, err := client.Logical().Write("secret/foo", map[string]interface{}{
+		"key":   "value",
+		"lease": "1m",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	secret, err := client.Logical().Read("secret/foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	args := []string{
+		"-address", addr,
+		secret.LeaseID,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "expire_time") {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+
+	// Unknown leases are an error
+	ui = new(cli.MockUi)
+	c.Meta.Ui = ui
+	args = []string{
+		"-address", addr,
+		"secret/foo/nope",
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+}
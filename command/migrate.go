@@ -0,0 +1,446 @@
+package command
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/logutils"
+	"github.com/hashicorp/vault/command/server"
+	"github.com/hashicorp/vault/meta"
+	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/vault"
+)
+
+// migrateKeyringPath is the storage key holding the keyring. It is copied
+// last so that an interrupted migration does not leave a destination that
+// looks initialized.
+const migrateKeyringPath = "core/keyring"
+
+// migrateLockPath is the storage key of the HA lock held by the active
+// Vault server
+const migrateLockPath = "core/lock"
+
+// MigrateCommand is a Command that copies the storage of Vault from one
+// physical backend to another.
+type MigrateCommand struct {
+	meta.Meta
+}
+
+func (c *MigrateCommand) Run(args []string) int {
+	var configPath, logLevel string
+	var parallel int
+	var dryRun, force, resume, verify bool
+	flags := c.Meta.FlagSet("operator migrate", meta.FlagSetNone)
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+	flags.StringVar(&configPath, "config", "", "")
+	flags.StringVar(&logLevel, "log-level", "warn", "")
+	flags.IntVar(&parallel, "parallel", physical.DefaultParallelOperations, "")
+	flags.BoolVar(&dryRun, "dry-run", false, "")
+	flags.BoolVar(&force, "force", false, "")
+	flags.BoolVar(&resume, "resume", false, "")
+	flags.BoolVar(&verify, "verify", true, "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if configPath == "" {
+		c.Ui.Error("A migration configuration must be specified with -config")
+		flags.Usage()
+		return 1
+	}
+	if parallel < 1 {
+		c.Ui.Error("-parallel must be at least 1")
+		return 1
+	}
+
+	config, err := server.LoadMigrateConfig(configPath)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Error loading configuration from %s: %s", configPath, err))
+		return 1
+	}
+
+	logger := log.New(&logutils.LevelFilter{
+		Levels: []logutils.LogLevel{
+			"TRACE", "DEBUG", "INFO", "WARN", "ERR"},
+		MinLevel: logutils.LogLevel(strings.ToUpper(logLevel)),
+		Writer:   os.Stderr,
+	}, "", log.LstdFlags)
+
+	source, err := physical.NewBackend(
+		config.Source.Type, logger, config.Source.Config)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Error initializing source backend of type %s: %s",
+			config.Source.Type, err))
+		return 1
+	}
+	dest, err := physical.NewBackend(
+		config.Destination.Type, logger, config.Destination.Config)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Error initializing destination backend of type %s: %s",
+			config.Destination.Type, err))
+		return 1
+	}
+
+	// A Vault server holding the HA lock may still be writing to the source
+	if !force {
+		if err := migrateCheckLock(source); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error checking the source: %s", err))
+			return 1
+		}
+	}
+
+	// Backends managing their own cluster only accept writes once a
+	// leader has been elected
+	if pm, ok := dest.(physical.PeerManager); ok && !dryRun {
+		if err := migrateWaitLeader(pm, 30*time.Second); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error waiting for the destination: %s", err))
+			return 1
+		}
+	}
+
+	existing, err := dest.Get(migrateKeyringPath)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading destination: %s", err))
+		return 1
+	}
+	if existing != nil && !resume {
+		c.Ui.Error("The destination already contains Vault data. Use -resume\n" +
+			"to continue a previous migration into it.")
+		return 1
+	}
+
+	keys, err := migrateListKeys(source, "")
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error listing source keys: %s", err))
+		return 1
+	}
+
+	// Keys removed from the source since a previous migration are removed
+	// from the destination as well
+	var stale []string
+	if existing != nil {
+		destKeys, err := migrateListKeys(dest, "")
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error listing destination keys: %s", err))
+			return 1
+		}
+		stale = migrateStaleKeys(keys, destKeys)
+	}
+
+	if dryRun {
+		for _, key := range keys {
+			c.Ui.Output(key)
+		}
+		for _, key := range stale {
+			c.Ui.Output(fmt.Sprintf("%s (delete)", key))
+		}
+		c.Ui.Output(fmt.Sprintf("\n%d keys would be migrated", len(keys)))
+		if len(stale) > 0 {
+			c.Ui.Output(fmt.Sprintf("%d keys would be deleted", len(stale)))
+		}
+		return 0
+	}
+
+	m := &migrator{
+		source: source,
+		dest:   dest,
+		resume: resume,
+		verify: verify,
+		pool:   physical.NewPermitPool(parallel),
+	}
+	if err := m.migrate(keys, stale); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error migrating storage: %s", err))
+		c.Ui.Error("\nThe migration can be continued with -resume.")
+		return 1
+	}
+
+	c.Ui.Output(fmt.Sprintf(
+		"Migrated %d keys from %s to %s (%d already up to date, %d deleted)",
+		m.copied, config.Source.Type, config.Destination.Type, m.skipped,
+		m.deleted))
+	return 0
+}
+
+// migrator copies entries between two physical backends
+type migrator struct {
+	source physical.Backend
+	dest   physical.Backend
+	resume bool
+	verify bool
+	pool   *physical.PermitPool
+
+	l       sync.Mutex
+	copied  int
+	skipped int
+	deleted int
+}
+
+// migrate copies the given keys and deletes the stale ones from the
+// destination. The keyring is copied once everything else has succeeded.
+func (m *migrator) migrate(keys, stale []string) error {
+	var last []string
+	var wg sync.WaitGroup
+	var errLock sync.Mutex
+	var result error
+	for _, key := range keys {
+		if key == migrateKeyringPath {
+			last = append(last, key)
+			continue
+		}
+
+		// Acquire the permit before starting the goroutine, so that no
+		// more goroutines than permits are running at once
+		m.pool.Acquire()
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			defer m.pool.Release()
+
+			if err := m.copyKey(key); err != nil {
+				errLock.Lock()
+				result = multierror.Append(result, fmt.Errorf("%s: %s", key, err))
+				errLock.Unlock()
+			}
+		}(key)
+	}
+	for _, key := range stale {
+		m.pool.Acquire()
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			defer m.pool.Release()
+
+			if err := m.deleteKey(key); err != nil {
+				errLock.Lock()
+				result = multierror.Append(result, fmt.Errorf("%s: %s", key, err))
+				errLock.Unlock()
+			}
+		}(key)
+	}
+	wg.Wait()
+	if result != nil {
+		return result
+	}
+
+	for _, key := range last {
+		if err := m.copyKey(key); err != nil {
+			return fmt.Errorf("%s: %s", key, err)
+		}
+	}
+	return nil
+}
+
+// copyKey copies a single entry, verifying the written value if requested
+func (m *migrator) copyKey(key string) error {
+	entry, err := m.source.Get(key)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		// Removed since it was listed, and so from a previous migration
+		if m.resume {
+			return m.deleteKey(key)
+		}
+		return nil
+	}
+	sum := sha256.Sum256(entry.Value)
+
+	if m.resume {
+		existing, err := m.dest.Get(key)
+		if err != nil {
+			return err
+		}
+		if existing != nil && sha256.Sum256(existing.Value) == sum {
+			m.l.Lock()
+			m.skipped++
+			m.l.Unlock()
+			return nil
+		}
+	}
+
+	if err := m.dest.Put(entry); err != nil {
+		return err
+	}
+
+	if m.verify {
+		written, err := m.dest.Get(key)
+		if err != nil {
+			return err
+		}
+		if written == nil {
+			return fmt.Errorf("missing from the destination after writing")
+		}
+		if sha256.Sum256(written.Value) != sum {
+			return fmt.Errorf("hash mismatch after writing")
+		}
+	}
+
+	m.l.Lock()
+	m.copied++
+	m.l.Unlock()
+	return nil
+}
+
+// deleteKey removes an entry which is no longer in the source from the
+// destination
+func (m *migrator) deleteKey(key string) error {
+	if err := m.dest.Delete(key); err != nil {
+		return err
+	}
+
+	m.l.Lock()
+	m.deleted++
+	m.l.Unlock()
+	return nil
+}
+
+// migrateCheckLock returns an error if the HA lock of the backend is held,
+// meaning that a Vault server may still be writing to it. Backends without
+// HA support cannot be checked.
+func migrateCheckLock(b physical.Backend) error {
+	ha, ok := b.(physical.HABackend)
+	if !ok {
+		return nil
+	}
+	lock, err := ha.LockWith(migrateLockPath, "migrate")
+	if err != nil {
+		return err
+	}
+	held, _, err := lock.Value()
+	if err != nil {
+		return err
+	}
+	if held {
+		return fmt.Errorf("the HA lock is held, so a Vault server may be " +
+			"using the source. Stop it, or use -force if the lock is stale")
+	}
+	return nil
+}
+
+// migrateStaleKeys returns the destination keys missing from the source
+func migrateStaleKeys(sourceKeys, destKeys []string) []string {
+	present := make(map[string]struct{}, len(sourceKeys))
+	for _, key := range sourceKeys {
+		present[key] = struct{}{}
+	}
+
+	var stale []string
+	for _, key := range destKeys {
+		if _, ok := present[key]; !ok {
+			stale = append(stale, key)
+		}
+	}
+	return stale
+}
+
+// migrateListKeys returns every key of the backend under the prefix in
+// lexical order, leaving out the keys tied to running nodes
+func migrateListKeys(b physical.Backend, prefix string) ([]string, error) {
+	keys, err := b.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+
+	var result []string
+	for _, key := range keys {
+		full := prefix + key
+		if strings.HasSuffix(key, "/") {
+			sub, err := migrateListKeys(b, full)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, sub...)
+			continue
+		}
+		if vault.IsLocalStorageKey(full) {
+			continue
+		}
+		result = append(result, full)
+	}
+	return result, nil
+}
+
+// migrateWaitLeader waits until the backend knows of a cluster leader
+func migrateWaitLeader(pm physical.PeerManager, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for pm.Leader() == "" {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("no leader elected after %s", timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return nil
+}
+
+func (c *MigrateCommand) Synopsis() string {
+	return "Migrate the storage of Vault to another backend"
+}
+
+func (c *MigrateCommand) Help() string {
+	helpText := `
+Usage: vault operator migrate [options]
+
+  Copy all the data stored by Vault from one physical backend to another.
+
+  This command talks to the backends directly and must be run while no
+  Vault server is using the source, to avoid missing writes. It refuses to
+  run while the HA lock of the source is held, unless -force is given; a
+  server without HA support holds no lock and cannot be detected. The data is
+  copied as stored, so it remains encrypted and the destination is unsealed
+  with the same keys as the source.
+
+  The source and destination are configured in an HCL file using the same
+  options as the server "backend" block:
+
+      source "file" {
+        path = "/var/lib/vault"
+      }
+
+      destination "consul" {
+        address = "127.0.0.1:8500"
+        path    = "vault"
+      }
+
+  The migration refuses to write to a destination that already contains
+  Vault data unless -resume is given. The keyring is copied last, once every
+  other key was copied successfully.
+
+Migrate Options:
+
+  -config=<path>          Path to the migration configuration file. Required.
+
+  -dry-run                List the keys that would be migrated without
+                          writing anything to the destination.
+
+  -force                  Migrate even though the HA lock of the source is
+                          held, for instance when left by a server which
+                          did not shut down cleanly.
+
+  -log-level=warn         Log level of the backends. Supported values (in
+                          order of detail) are "trace", "debug", "info",
+                          "warn", and "err".
+
+  -parallel=128           Maximum number of keys copied concurrently.
+
+  -resume                 Continue a previous migration. Keys already
+                          present in the destination with the same value
+                          are not written again, and keys deleted from the
+                          source since are deleted from the destination.
+
+  -verify=true            Read every key back from the destination after
+                          writing it and compare the hash of its value with
+                          the source.
+`
+	return strings.TrimSpace(helpText)
+}
@@ -0,0 +1,167 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/meta"
+	"github.com/hashicorp/vault/physical"
+	"github.com/mitchellh/cli"
+)
+
+func TestMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srcPath := filepath.Join(dir, "src")
+	dstPath := filepath.Join(dir, "dst")
+	configPath := filepath.Join(dir, "migrate.hcl")
+	config := fmt.Sprintf(`
+source "file" {
+	path = "%s"
+}
+
+destination "file" {
+	path = "%s"
+}
+`, srcPath, dstPath)
+	if err := ioutil.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	src, err := physical.NewBackend("file", logger, map[string]string{"path": srcPath})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	dst, err := physical.NewBackend("file", logger, map[string]string{"path": dstPath})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	entries := map[string]string{
+		"core/keyring":       "keyring",
+		"core/mounts":        "mounts",
+		"logical/abc/foo":    "foo",
+		"logical/abc/bar":    "bar",
+		"sys/token/id/token": "token",
+	}
+	for k, v := range entries {
+		if err := src.Put(&physical.Entry{Key: k, Value: []byte(v)}); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	// Node local keys are not migrated
+	if err := src.Put(&physical.Entry{Key: "core/lock", Value: []byte("lock")}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	run := func(args ...string) (int, *cli.MockUi) {
+		ui := new(cli.MockUi)
+		c := &MigrateCommand{
+			Meta: meta.Meta{
+				Ui: ui,
+			},
+		}
+		return c.Run(append([]string{"-config", configPath}, args...)), ui
+	}
+
+	// A dry run only lists the keys
+	code, ui := run("-dry-run")
+	if code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	output := ui.OutputWriter.String()
+	if !strings.Contains(output, "logical/abc/foo") || strings.Contains(output, "core/lock") {
+		t.Fatalf("bad: %s", output)
+	}
+	if keys, _ := dst.List(""); len(keys) != 0 {
+		t.Fatalf("dry run wrote to the destination: %v", keys)
+	}
+
+	code, ui = run()
+	if code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	for k, v := range entries {
+		entry, err := dst.Get(k)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if entry == nil || string(entry.Value) != v {
+			t.Fatalf("bad %s: %#v", k, entry)
+		}
+	}
+	if entry, _ := dst.Get("core/lock"); entry != nil {
+		t.Fatalf("node local key migrated: %#v", entry)
+	}
+
+	// Migrating again requires resuming
+	if code, _ := run(); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+
+	// Resuming only copies the changed keys, and deletes the removed ones
+	if err := src.Put(&physical.Entry{Key: "logical/abc/foo", Value: []byte("changed")}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := src.Delete("logical/abc/bar"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	code, ui = run("-resume")
+	if code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "Migrated 1 keys") ||
+		!strings.Contains(ui.OutputWriter.String(), "1 deleted") {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+	entry, err := dst.Get("logical/abc/foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if entry == nil || string(entry.Value) != "changed" {
+		t.Fatalf("bad: %#v", entry)
+	}
+	entry, err = dst.Get("logical/abc/bar")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if entry != nil {
+		t.Fatalf("deleted key still migrated: %#v", entry)
+	}
+}
+
+func TestMigrate_checkLock(t *testing.T) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	inm := physical.NewInmemHA(logger)
+	if err := migrateCheckLock(inm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	lock, err := inm.LockWith(migrateLockPath, "active")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := lock.Lock(nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := migrateCheckLock(inm); err == nil {
+		t.Fatalf("should fail while the lock is held")
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := migrateCheckLock(inm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
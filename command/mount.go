@@ -14,12 +14,13 @@ type MountCommand struct {
 }
 
 func (c *MountCommand) Run(args []string) int {
-	var description, path, defaultLeaseTTL, maxLeaseTTL string
+	var description, path, defaultLeaseTTL, maxLeaseTTL, pluginName string
 	flags := c.Meta.FlagSet("mount", meta.FlagSetDefault)
 	flags.StringVar(&description, "description", "", "")
 	flags.StringVar(&path, "path", "", "")
 	flags.StringVar(&defaultLeaseTTL, "default-lease-ttl", "", "")
 	flags.StringVar(&maxLeaseTTL, "max-lease-ttl", "", "")
+	flags.StringVar(&pluginName, "plugin-name", "", "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := flags.Parse(args); err != nil {
 		return 1
@@ -54,6 +55,7 @@ func (c *MountCommand) Run(args []string) int {
 			DefaultLeaseTTL: defaultLeaseTTL,
 			MaxLeaseTTL:     maxLeaseTTL,
 		},
+		PluginName: pluginName,
 	}
 
 	if err := client.Sys().Mount(path, mountInfo); err != nil {
@@ -102,6 +104,9 @@ Mount Options:
                                  the previously set value. Set to '0' to
                                  explicitly set it to use the global default.
 
+  -plugin-name=<name>            Name of the catalog plugin serving the
+                                 backend, for backends of type "plugin".
+
 `
 	return strings.TrimSpace(helpText)
 }
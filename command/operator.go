@@ -0,0 +1,55 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/hashicorp/vault/meta"
+	"github.com/mitchellh/cli"
+)
+
+// OperatorCommand groups the commands used to operate the Vault server
+// itself rather than the secrets it holds.
+type OperatorCommand struct {
+	meta.Meta
+}
+
+func (c *OperatorCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
+
+func (c *OperatorCommand) Synopsis() string {
+	return "Perform operator-specific tasks"
+}
+
+func (c *OperatorCommand) Help() string {
+	helpText := `
+Usage: vault operator <subcommand> [options] [args]
+
+  This command groups subcommands for operators of the Vault server, such as
+  taking snapshots of its storage.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// OperatorSnapshotCommand groups the storage snapshot commands.
+type OperatorSnapshotCommand struct {
+	meta.Meta
+}
+
+func (c *OperatorSnapshotCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
+
+func (c *OperatorSnapshotCommand) Synopsis() string {
+	return "Save and restore snapshots of the storage"
+}
+
+func (c *OperatorSnapshotCommand) Help() string {
+	helpText := `
+Usage: vault operator snapshot <subcommand> [options] [args]
+
+  This command groups subcommands for saving and restoring point-in-time
+  snapshots of the storage of the Vault server.
+`
+	return strings.TrimSpace(helpText)
+}
@@ -162,6 +162,45 @@ func (c *ServerCommand) Run(args []string) int {
 	info := make(map[string]string)
 
 	var seal vault.Seal = &vault.DefaultSeal{}
+	if config.Seal != nil {
+		if dev {
+			c.Ui.Error("A seal cannot be configured in dev mode")
+			return 1
+		}
+
+		seal, err = newSeal(config.Seal)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Error initializing seal of type %s: %s",
+				config.Seal.Type, err))
+			return 1
+		}
+		info["seal"] = config.Seal.Type
+		infoKeys = append(infoKeys, "seal")
+	}
+
+	// The previous seal is kept until Vault is unsealed with migration.
+	// Without a disabled seal block, a configured seal may be migrating
+	// from the default seal.
+	var oldSeal vault.Seal
+	if config.DisabledSeal != nil {
+		if dev {
+			c.Ui.Error("A seal cannot be configured in dev mode")
+			return 1
+		}
+
+		oldSeal, err = newSeal(config.DisabledSeal)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Error initializing disabled seal of type %s: %s",
+				config.DisabledSeal.Type, err))
+			return 1
+		}
+		info["disabled seal"] = config.DisabledSeal.Type
+		infoKeys = append(infoKeys, "disabled seal")
+	} else if config.Seal != nil {
+		oldSeal = &vault.DefaultSeal{}
+	}
 
 	// Ensure that the seal finalizer is called, even if using verify-only
 	defer func() {
@@ -169,6 +208,11 @@ func (c *ServerCommand) Run(args []string) int {
 		if err != nil {
 			c.Ui.Error(fmt.Sprintf("Error finalizing seals: %v", err))
 		}
+		if oldSeal != nil {
+			if err := oldSeal.Finalize(); err != nil {
+				c.Ui.Error(fmt.Sprintf("Error finalizing seals: %v", err))
+			}
+		}
 	}()
 
 	coreConfig := &vault.CoreConfig{
@@ -176,6 +220,7 @@ func (c *ServerCommand) Run(args []string) int {
 		AdvertiseAddr:      config.Backend.AdvertiseAddr,
 		HAPhysical:         nil,
 		Seal:               seal,
+		OldSeal:            oldSeal,
 		AuditBackends:      c.AuditBackends,
 		CredentialBackends: c.CredentialBackends,
 		LogicalBackends:    c.LogicalBackends,
@@ -184,6 +229,7 @@ func (c *ServerCommand) Run(args []string) int {
 		DisableMlock:       config.DisableMlock,
 		MaxLeaseTTL:        config.MaxLeaseTTL,
 		DefaultLeaseTTL:    config.DefaultLeaseTTL,
+		PluginDirectory:    config.PluginDirectory,
 	}
 
 	// Initialize the separate HA physical backend, if it exists
@@ -203,9 +249,11 @@ func (c *ServerCommand) Run(args []string) int {
 			return 1
 		}
 		coreConfig.AdvertiseAddr = config.HABackend.AdvertiseAddr
+		coreConfig.ClusterAddr = config.HABackend.ClusterAddr
 	} else {
 		if coreConfig.HAPhysical, ok = backend.(physical.HABackend); ok {
 			coreConfig.AdvertiseAddr = config.Backend.AdvertiseAddr
+			coreConfig.ClusterAddr = config.Backend.ClusterAddr
 		}
 	}
 
@@ -231,6 +279,27 @@ func (c *ServerCommand) Run(args []string) int {
 		}
 	}
 
+	if envCA := os.Getenv("VAULT_CLUSTER_ADDR"); envCA != "" {
+		coreConfig.ClusterAddr = envCA
+	}
+
+	// If no cluster address was given, derive it from the advertise
+	// address using the next port
+	if coreConfig.HAPhysical != nil && coreConfig.ClusterAddr == "" && coreConfig.AdvertiseAddr != "" {
+		clusterAddr, err := deriveClusterAddr(coreConfig.AdvertiseAddr)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error deriving cluster address: %s", err))
+		} else {
+			coreConfig.ClusterAddr = clusterAddr
+		}
+	}
+
+	// Plugins unwrap their TLS material through the advertised API, which
+	// in dev mode is the dev listener
+	if dev && coreConfig.AdvertiseAddr == "" {
+		coreConfig.AdvertiseAddr = "http://" + config.Listeners[0].Config["address"]
+	}
+
 	// Initialize the core
 	core, newCoreError := vault.NewCore(coreConfig)
 	if newCoreError != nil {
@@ -284,13 +353,15 @@ func (c *ServerCommand) Run(args []string) int {
 	if config.HABackend != nil {
 		info["HA backend"] = config.HABackend.Type
 		info["advertise address"] = coreConfig.AdvertiseAddr
-		infoKeys = append(infoKeys, "HA backend", "advertise address")
+		info["cluster address"] = coreConfig.ClusterAddr
+		infoKeys = append(infoKeys, "HA backend", "advertise address", "cluster address")
 	} else {
 		// If the backend supports HA, then note it
 		if coreConfig.HAPhysical != nil {
 			info["backend"] += " (HA available)"
 			info["advertise address"] = coreConfig.AdvertiseAddr
-			infoKeys = append(infoKeys, "advertise address")
+			info["cluster address"] = coreConfig.ClusterAddr
+			infoKeys = append(infoKeys, "advertise address", "cluster address")
 		}
 	}
 
@@ -307,6 +378,7 @@ func (c *ServerCommand) Run(args []string) int {
 
 	// Initialize the listeners
 	lns := make([]net.Listener, 0, len(config.Listeners))
+	clusterAddrs := make([]*net.TCPAddr, 0, len(config.Listeners))
 	for i, lnConfig := range config.Listeners {
 		ln, props, reloadFunc, err := server.NewListener(lnConfig.Type, lnConfig.Config)
 		if err != nil {
@@ -316,6 +388,20 @@ func (c *ServerCommand) Run(args []string) int {
 			return 1
 		}
 
+		// Determine where to listen for forwarded requests; by default
+		// this is the port after the listener's
+		if lnConfig.Type == "tcp" && coreConfig.HAPhysical != nil {
+			clusterAddr, err := listenerClusterAddr(ln, lnConfig.Config)
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf(
+					"Error resolving cluster address of listener %d: %s",
+					i+1, err))
+				return 1
+			}
+			props["cluster address"] = clusterAddr.String()
+			clusterAddrs = append(clusterAddrs, clusterAddr)
+		}
+
 		// Store the listener props for output later
 		key := fmt.Sprintf("listener %d", i+1)
 		propsList := make([]string, 0, len(props))
@@ -363,6 +449,11 @@ func (c *ServerCommand) Run(args []string) int {
 	// Initialize the HTTP server
 	server := &http.Server{}
 	server.Handler = vaulthttp.Handler(core)
+
+	// Standby nodes forward requests to the active node, which serves them
+	// with the same handler over the cluster listeners
+	core.SetClusterListenerAddrs(clusterAddrs)
+	core.SetClusterHandler(server.Handler)
 	for _, ln := range lns {
 		go server.Serve(ln)
 	}
@@ -403,6 +494,17 @@ func (c *ServerCommand) Run(args []string) int {
 	return 0
 }
 
+// newSeal creates the seal described by the seal block of the
+// configuration
+func newSeal(config *server.Seal) (vault.Seal, error) {
+	switch config.Type {
+	case "transit":
+		return vault.NewTransitSeal(config.Config)
+	default:
+		return nil, fmt.Errorf("unknown seal type %q", config.Type)
+	}
+}
+
 func (c *ServerCommand) enableDev(core *vault.Core, rootTokenID string) (*vault.InitResult, error) {
 	// Initialize it with a basic single key
 	init, err := core.Initialize(&vault.SealConfig{
@@ -471,6 +573,44 @@ func (c *ServerCommand) enableDev(core *vault.Core, rootTokenID string) (*vault.
 	return init, nil
 }
 
+// deriveClusterAddr computes a default cluster address from the advertise
+// address by using the next port
+func deriveClusterAddr(advertiseAddr string) (string, error) {
+	u, err := url.Parse(advertiseAddr)
+	if err != nil {
+		return "", err
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return "", err
+	}
+	nPort, err := strconv.Atoi(port)
+	if err != nil {
+		return "", err
+	}
+	u.Host = net.JoinHostPort(host, strconv.Itoa(nPort+1))
+	u.Scheme = "https"
+	u.Path = ""
+	return u.String(), nil
+}
+
+// listenerClusterAddr returns the address the cluster listener belonging to
+// the given listener binds to
+func listenerClusterAddr(ln net.Listener, config map[string]string) (*net.TCPAddr, error) {
+	if v, ok := config["cluster_address"]; ok {
+		return net.ResolveTCPAddr("tcp", v)
+	}
+
+	tcpAddr, ok := ln.Addr().(*net.TCPAddr)
+	if !ok {
+		return nil, fmt.Errorf("listener is not a TCP listener")
+	}
+	return &net.TCPAddr{
+		IP:   tcpAddr.IP,
+		Port: tcpAddr.Port + 1,
+	}, nil
+}
+
 // detectAdvertise is used to attempt advertise address detection
 func (c *ServerCommand) detectAdvertise(detect physical.AdvertiseDetect,
 	config *server.Config) (string, error) {
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,6 +24,11 @@ type Config struct {
 	Listeners []*Listener `hcl:"-"`
 	Backend   *Backend    `hcl:"-"`
 	HABackend *Backend    `hcl:"-"`
+	Seal      *Seal       `hcl:"-"`
+
+	// DisabledSeal is the seal previously in use, set while migrating
+	// away from it
+	DisabledSeal *Seal `hcl:"-"`
 
 	DisableCache bool `hcl:"disable_cache"`
 	DisableMlock bool `hcl:"disable_mlock"`
@@ -33,6 +39,8 @@ type Config struct {
 	MaxLeaseTTLRaw     string        `hcl:"max_lease_ttl"`
 	DefaultLeaseTTL    time.Duration `hcl:"-"`
 	DefaultLeaseTTLRaw string        `hcl:"default_lease_ttl"`
+
+	PluginDirectory string `hcl:"plugin_directory"`
 }
 
 // DevConfig is a Config that is used for dev mode of Vault.
@@ -76,6 +84,7 @@ func (l *Listener) GoString() string {
 type Backend struct {
 	Type          string
 	AdvertiseAddr string
+	ClusterAddr   string
 	Config        map[string]string
 }
 
@@ -83,6 +92,17 @@ func (b *Backend) GoString() string {
 	return fmt.Sprintf("*%#v", *b)
 }
 
+// Seal is the seal configuration for the server.
+type Seal struct {
+	Type     string
+	Disabled bool
+	Config   map[string]string
+}
+
+func (s *Seal) GoString() string {
+	return fmt.Sprintf("*%#v", *s)
+}
+
 // Telemetry is the telemetry configuration for the server
 type Telemetry struct {
 	StatsiteAddr string `hcl:"statsite_address"`
@@ -115,6 +135,16 @@ func (c *Config) Merge(c2 *Config) *Config {
 		result.HABackend = c2.HABackend
 	}
 
+	result.Seal = c.Seal
+	if c2.Seal != nil {
+		result.Seal = c2.Seal
+	}
+
+	result.DisabledSeal = c.DisabledSeal
+	if c2.DisabledSeal != nil {
+		result.DisabledSeal = c2.DisabledSeal
+	}
+
 	result.Telemetry = c.Telemetry
 	if c2.Telemetry != nil {
 		result.Telemetry = c2.Telemetry
@@ -142,6 +172,11 @@ func (c *Config) Merge(c2 *Config) *Config {
 		result.DefaultLeaseTTL = c2.DefaultLeaseTTL
 	}
 
+	result.PluginDirectory = c.PluginDirectory
+	if c2.PluginDirectory != "" {
+		result.PluginDirectory = c2.PluginDirectory
+	}
+
 	return result
 }
 
@@ -203,11 +238,13 @@ func ParseConfig(d string) (*Config, error) {
 		"backend",
 		"ha_backend",
 		"listener",
+		"seal",
 		"disable_cache",
 		"disable_mlock",
 		"telemetry",
 		"default_lease_ttl",
 		"max_lease_ttl",
+		"plugin_directory",
 
 		// TODO: Remove in 0.6.0
 		// Deprecated keys
@@ -255,6 +292,12 @@ func ParseConfig(d string) (*Config, error) {
 		}
 	}
 
+	if o := list.Filter("seal"); len(o.Items) > 0 {
+		if err := parseSeal(&result, o); err != nil {
+			return nil, fmt.Errorf("error parsing 'seal': %s", err)
+		}
+	}
+
 	if o := list.Filter("listener"); len(o.Items) > 0 {
 		if err := parseListeners(&result, o); err != nil {
 			return nil, fmt.Errorf("error parsing 'listener': %s", err)
@@ -372,14 +415,74 @@ func parseBackends(result *Config, list *ast.ObjectList) error {
 		delete(m, "advertise_addr")
 	}
 
+	// Pull out the cluster address since it's common to all backends
+	var clusterAddr string
+	if v, ok := m["cluster_addr"]; ok {
+		clusterAddr = v
+		delete(m, "cluster_addr")
+	}
+
 	result.Backend = &Backend{
 		AdvertiseAddr: advertiseAddr,
+		ClusterAddr:   clusterAddr,
 		Type:          strings.ToLower(key),
 		Config:        m,
 	}
 	return nil
 }
 
+func parseSeal(result *Config, list *ast.ObjectList) error {
+	if len(list.Items) > 2 {
+		return fmt.Errorf("at most two 'seal' blocks are permitted")
+	}
+
+	for _, item := range list.Items {
+		if len(item.Keys) == 0 {
+			return fmt.Errorf("'seal' block must specify the seal type")
+		}
+		key := item.Keys[0].Token.Value().(string)
+
+		var m map[string]string
+		if err := hcl.DecodeObject(&m, item.Val); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("seal.%s:", key))
+		}
+
+		// Pull out whether the seal is disabled, which marks the seal to
+		// migrate away from
+		var disabled bool
+		if v, ok := m["disabled"]; ok {
+			var err error
+			if disabled, err = strconv.ParseBool(v); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("seal.%s.disabled:", key))
+			}
+			delete(m, "disabled")
+		}
+
+		seal := &Seal{
+			Type:     strings.ToLower(key),
+			Disabled: disabled,
+			Config:   m,
+		}
+		if disabled {
+			if result.DisabledSeal != nil {
+				return fmt.Errorf("only one disabled 'seal' block is permitted")
+			}
+			result.DisabledSeal = seal
+		} else {
+			if result.Seal != nil {
+				return fmt.Errorf("only one enabled 'seal' block is permitted")
+			}
+			result.Seal = seal
+		}
+	}
+
+	if result.Seal != nil && result.DisabledSeal != nil &&
+		result.Seal.Type == result.DisabledSeal.Type {
+		return fmt.Errorf("the enabled and disabled 'seal' blocks must be of different types")
+	}
+	return nil
+}
+
 func parseHABackends(result *Config, list *ast.ObjectList) error {
 	if len(list.Items) > 1 {
 		return fmt.Errorf("only one 'ha_backend' block is permitted")
@@ -405,8 +508,16 @@ func parseHABackends(result *Config, list *ast.ObjectList) error {
 		delete(m, "advertise_addr")
 	}
 
+	// Pull out the cluster address since it's common to all backends
+	var clusterAddr string
+	if v, ok := m["cluster_addr"]; ok {
+		clusterAddr = v
+		delete(m, "cluster_addr")
+	}
+
 	result.HABackend = &Backend{
 		AdvertiseAddr: advertiseAddr,
+		ClusterAddr:   clusterAddr,
 		Type:          strings.ToLower(key),
 		Config:        m,
 	}
@@ -423,6 +534,7 @@ func parseListeners(result *Config, list *ast.ObjectList) error {
 
 		valid := []string{
 			"address",
+			"cluster_address",
 			"tls_disable",
 			"tls_cert_file",
 			"tls_key_file",
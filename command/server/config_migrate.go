@@ -0,0 +1,98 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+// MigrateConfig is the configuration for migrating the storage of Vault
+// from one physical backend to another.
+type MigrateConfig struct {
+	Source      *Backend `hcl:"-"`
+	Destination *Backend `hcl:"-"`
+}
+
+// LoadMigrateConfig loads the migration configuration from the given file.
+func LoadMigrateConfig(path string) (*MigrateConfig, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMigrateConfig(string(d))
+}
+
+func ParseMigrateConfig(d string) (*MigrateConfig, error) {
+	obj, err := hcl.Parse(d)
+	if err != nil {
+		return nil, err
+	}
+
+	list, ok := obj.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("error parsing: file doesn't contain a root object")
+	}
+
+	valid := []string{
+		"source",
+		"destination",
+	}
+	if err := checkHCLKeys(list, valid); err != nil {
+		return nil, err
+	}
+
+	var result MigrateConfig
+	for _, name := range valid {
+		o := list.Filter(name)
+		if len(o.Items) == 0 {
+			return nil, fmt.Errorf("missing '%s' block", name)
+		}
+
+		b, err := parseMigrateBackend(name, o)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing '%s': %s", name, err)
+		}
+
+		switch name {
+		case "source":
+			result.Source = b
+		case "destination":
+			result.Destination = b
+		}
+	}
+
+	if result.Source.Type == result.Destination.Type &&
+		result.Source.Config["path"] != "" &&
+		result.Source.Config["path"] == result.Destination.Config["path"] {
+		return nil, fmt.Errorf("source and destination must be different")
+	}
+
+	return &result, nil
+}
+
+func parseMigrateBackend(name string, list *ast.ObjectList) (*Backend, error) {
+	if len(list.Items) > 1 {
+		return nil, fmt.Errorf("only one '%s' block is permitted", name)
+	}
+
+	// Get our item
+	item := list.Items[0]
+	if len(item.Keys) == 0 {
+		return nil, fmt.Errorf("'%s' block must specify the backend type", name)
+	}
+	key := item.Keys[0].Token.Value().(string)
+
+	var m map[string]string
+	if err := hcl.DecodeObject(&m, item.Val); err != nil {
+		return nil, multierror.Prefix(err, fmt.Sprintf("%s.%s:", name, key))
+	}
+
+	return &Backend{
+		Type:   strings.ToLower(key),
+		Config: m,
+	}, nil
+}
@@ -34,6 +34,7 @@ func TestLoadConfigFile(t *testing.T) {
 		HABackend: &Backend{
 			Type:          "consul",
 			AdvertiseAddr: "snafu",
+			ClusterAddr:   "https://127.0.0.1:8201",
 			Config: map[string]string{
 				"bar": "baz",
 			},
@@ -52,6 +53,8 @@ func TestLoadConfigFile(t *testing.T) {
 		MaxLeaseTTLRaw:     "10h",
 		DefaultLeaseTTL:    10 * time.Hour,
 		DefaultLeaseTTLRaw: "10h",
+
+		PluginDirectory: "/etc/vault/plugins",
 	}
 	if !reflect.DeepEqual(config, expected) {
 		t.Fatalf("expected \n\n%#v\n\n to be \n\n%#v\n\n", config, expected)
@@ -247,3 +250,130 @@ telemetry {
 		t.Errorf("bad error: %q", err)
 	}
 }
+
+func TestParseMigrateConfig(t *testing.T) {
+	config, err := ParseMigrateConfig(strings.TrimSpace(`
+source "file" {
+	path = "/tmp/vault"
+}
+
+destination "consul" {
+	address = "127.0.0.1:8500"
+	path    = "vault"
+}
+`))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &MigrateConfig{
+		Source: &Backend{
+			Type: "file",
+			Config: map[string]string{
+				"path": "/tmp/vault",
+			},
+		},
+		Destination: &Backend{
+			Type: "consul",
+			Config: map[string]string{
+				"address": "127.0.0.1:8500",
+				"path":    "vault",
+			},
+		},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("expected \n\n%#v\n\n to be \n\n%#v\n\n", config, expected)
+	}
+}
+
+func TestParseMigrateConfig_bad(t *testing.T) {
+	cases := map[string]string{
+		`source "file" { path = "/tmp/a" }`: "missing 'destination' block",
+
+		`source "file" { path = "/tmp/a" }
+destination "file" { path = "/tmp/a" }`: "source and destination must be different",
+
+		`source "file" { path = "/tmp/a" }
+destination "file" { path = "/tmp/b" }
+backend "file" {}`: "invalid key 'backend'",
+	}
+
+	for input, expected := range cases {
+		_, err := ParseMigrateConfig(input)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("input %q: expected error %q, got: %v", input, expected, err)
+		}
+	}
+}
+
+func TestParseConfig_seal(t *testing.T) {
+	config, err := ParseConfig(strings.TrimSpace(`
+backend "file" {
+	path = "/tmp/vault"
+}
+
+seal "transit" {
+	address  = "https://vault.example.com:8200"
+	key_name = "unseal"
+}
+`))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Seal{
+		Type: "transit",
+		Config: map[string]string{
+			"address":  "https://vault.example.com:8200",
+			"key_name": "unseal",
+		},
+	}
+	if !reflect.DeepEqual(config.Seal, expected) {
+		t.Fatalf("expected \n\n%#v\n\n to be \n\n%#v\n\n", config.Seal, expected)
+	}
+}
+
+func TestParseConfig_sealDisabled(t *testing.T) {
+	config, err := ParseConfig(strings.TrimSpace(`
+backend "file" {
+	path = "/tmp/vault"
+}
+
+seal "transit" {
+	address  = "https://vault.example.com:8200"
+	key_name = "unseal"
+	disabled = "true"
+}
+`))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if config.Seal != nil {
+		t.Fatalf("bad: %#v", config.Seal)
+	}
+	expected := &Seal{
+		Type:     "transit",
+		Disabled: true,
+		Config: map[string]string{
+			"address":  "https://vault.example.com:8200",
+			"key_name": "unseal",
+		},
+	}
+	if !reflect.DeepEqual(config.DisabledSeal, expected) {
+		t.Fatalf("expected \n\n%#v\n\n to be \n\n%#v\n\n", config.DisabledSeal, expected)
+	}
+
+	_, err = ParseConfig(strings.TrimSpace(`
+seal "transit" {
+	key_name = "unseal"
+}
+
+seal "transit" {
+	key_name = "other"
+}
+`))
+	if err == nil || !strings.Contains(err.Error(), "only one enabled 'seal' block") {
+		t.Fatalf("bad: %v", err)
+	}
+}
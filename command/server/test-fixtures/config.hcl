@@ -15,7 +15,9 @@ backend "consul" {
 ha_backend "consul" {
     bar = "baz"
     advertise_addr = "snafu"
+    cluster_addr = "https://127.0.0.1:8201"
 }
 
 max_lease_ttl = "10h"
 default_lease_ttl = "10h"
+plugin_directory = "/etc/vault/plugins"
@@ -0,0 +1,85 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/vault/meta"
+)
+
+// SnapshotRestoreCommand is a Command that restores a storage snapshot.
+type SnapshotRestoreCommand struct {
+	meta.Meta
+}
+
+func (c *SnapshotRestoreCommand) Run(args []string) int {
+	var force bool
+	flags := c.Meta.FlagSet("operator snapshot restore", meta.FlagSetDefault)
+	flags.BoolVar(&force, "force", false, "")
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) != 1 {
+		flags.Usage()
+		c.Ui.Error("\nsnapshot restore expects one argument: the snapshot file")
+		return 1
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error opening snapshot file: %s", err))
+		return 1
+	}
+	defer f.Close()
+
+	client, err := c.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Error initializing client: %s", err))
+		return 2
+	}
+
+	if err := client.Sys().SnapshotRestore(f, force); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error restoring snapshot: %s", err))
+		return 1
+	}
+
+	c.Ui.Output("Snapshot restored. Vault is now sealed and must be unsealed\n" +
+		"with the unseal keys matching the snapshot.")
+	return 0
+}
+
+func (c *SnapshotRestoreCommand) Synopsis() string {
+	return "Restore a snapshot of the storage of the Vault server"
+}
+
+func (c *SnapshotRestoreCommand) Help() string {
+	helpText := `
+Usage: vault operator snapshot restore [options] <file>
+
+  Restore a snapshot taken with "vault operator snapshot save".
+
+  The snapshot is verified against its checksum manifest before anything is
+  written. All storage entries are then replaced with the ones from the
+  snapshot and Vault seals itself. It must be unsealed with the unseal keys
+  that were valid when the snapshot was taken.
+
+  A snapshot whose keyring cannot be decrypted with the current master key,
+  for instance one taken from another Vault or before a rekey, is refused
+  unless -force is given.
+
+  This requires a token with sudo privileges on sys/storage/snapshot.
+
+General Options:
+` + meta.GeneralOptionsUsage() + `
+Restore Options:
+
+  -force                  Restore the snapshot even if it was taken with a
+                          different keyring.
+`
+	return strings.TrimSpace(helpText)
+}
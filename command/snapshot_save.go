@@ -0,0 +1,91 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/vault/meta"
+)
+
+// SnapshotSaveCommand is a Command that saves a snapshot of the storage.
+type SnapshotSaveCommand struct {
+	meta.Meta
+}
+
+func (c *SnapshotSaveCommand) Run(args []string) int {
+	flags := c.Meta.FlagSet("operator snapshot save", meta.FlagSetDefault)
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) != 1 {
+		flags.Usage()
+		c.Ui.Error("\nsnapshot save expects one argument: the file to write")
+		return 1
+	}
+	path := args[0]
+
+	client, err := c.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Error initializing client: %s", err))
+		return 2
+	}
+
+	// Write to a temporary file first so that a failed save never leaves a
+	// truncated snapshot behind
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error creating snapshot file: %s", err))
+		return 1
+	}
+	defer os.Remove(f.Name())
+
+	if err := client.Sys().SnapshotSave(f); err != nil {
+		f.Close()
+		c.Ui.Error(fmt.Sprintf("Error saving snapshot: %s", err))
+		return 1
+	}
+	if err := f.Close(); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error writing snapshot file: %s", err))
+		return 1
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error writing snapshot file: %s", err))
+		return 1
+	}
+
+	c.Ui.Output(fmt.Sprintf("Snapshot saved to %s", path))
+	return 0
+}
+
+func (c *SnapshotSaveCommand) Synopsis() string {
+	return "Save a snapshot of the storage of the Vault server"
+}
+
+func (c *SnapshotSaveCommand) Help() string {
+	helpText := `
+Usage: vault operator snapshot save [options] <file>
+
+  Save a snapshot of the storage of the Vault server.
+
+  The snapshot contains every entry as stored by the physical backend, so
+  all data remains encrypted by the barrier and can only be read with the
+  unseal keys of the Vault it was taken from. The snapshot includes a
+  checksum manifest that is verified on restore. The checksum detects
+  corruption, not tampering, so keep snapshots where only trusted operators
+  can modify them.
+
+  Requests to Vault are still served while the snapshot is being taken, so
+  entries written meanwhile may or may not be part of it. This requires a
+  token with sudo privileges on sys/storage/snapshot.
+
+General Options:
+` + meta.GeneralOptionsUsage()
+	return strings.TrimSpace(helpText)
+}
@@ -0,0 +1,79 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/meta"
+	"github.com/hashicorp/vault/vault"
+	"github.com/mitchellh/cli"
+)
+
+func TestSnapshot(t *testing.T) {
+	core, key, token := vault.TestCoreUnsealed(t)
+	ln, addr := http.TestServer(t, core)
+	defer ln.Close()
+
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "backup.snap")
+
+	ui := new(cli.MockUi)
+	save := &SnapshotSaveCommand{
+		Meta: meta.Meta{
+			ClientToken: token,
+			Ui:          ui,
+		},
+	}
+	args := []string{"-address", addr, path}
+	if code := save.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Write something that the restore should roll back
+	client := testClient(t, addr, token)
+	if _, err := client.Logical().Write("secret/foo", map[string]interface{}{
+		"value": "bar",
+	}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui = new(cli.MockUi)
+	restore := &SnapshotRestoreCommand{
+		Meta: meta.Meta{
+			ClientToken: token,
+			Ui:          ui,
+		},
+	}
+	if code := restore.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	sealed, err := core.Sealed()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !sealed {
+		t.Fatal("should be sealed")
+	}
+	if _, err := core.Unseal(vault.TestKeyCopy(key)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	secret, err := client.Logical().Read("secret/foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if secret != nil {
+		t.Fatalf("secret written after the snapshot should be gone: %#v", secret)
+	}
+}
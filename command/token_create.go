@@ -17,11 +17,11 @@ type TokenCreateCommand struct {
 
 func (c *TokenCreateCommand) Run(args []string) int {
 	var format string
-	var id, displayName, lease, ttl, role string
+	var id, displayName, lease, ttl, role, tokenType string
 	var orphan, noDefaultPolicy bool
 	var metadata map[string]string
 	var numUses int
-	var policies []string
+	var policies, boundCIDRs []string
 	flags := c.Meta.FlagSet("mount", meta.FlagSetDefault)
 	flags.StringVar(&format, "format", "table", "")
 	flags.StringVar(&displayName, "display-name", "", "")
@@ -29,11 +29,13 @@ func (c *TokenCreateCommand) Run(args []string) int {
 	flags.StringVar(&lease, "lease", "", "")
 	flags.StringVar(&ttl, "ttl", "", "")
 	flags.StringVar(&role, "role", "", "")
+	flags.StringVar(&tokenType, "type", "", "")
 	flags.BoolVar(&orphan, "orphan", false, "")
 	flags.BoolVar(&noDefaultPolicy, "no-default-policy", false, "")
 	flags.IntVar(&numUses, "use-limit", 0, "")
 	flags.Var((*kvFlag.Flag)(&metadata), "metadata", "")
 	flags.Var((*sliceflag.StringFlag)(&policies), "policy", "")
+	flags.Var((*sliceflag.StringFlag)(&boundCIDRs), "bound-cidr", "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := flags.Parse(args); err != nil {
 		return 1
@@ -67,6 +69,8 @@ func (c *TokenCreateCommand) Run(args []string) int {
 		NoDefaultPolicy: noDefaultPolicy,
 		DisplayName:     displayName,
 		NumUses:         numUses,
+		Type:            tokenType,
+		BoundCIDRs:      boundCIDRs,
 	}
 
 	var secret *api.Secret
@@ -143,6 +147,17 @@ Token Options:
   -use-limit=5            The number of times this token can be used until
                           it is automatically revoked.
 
+  -bound-cidr="10.0.0.0/8"  A network the token can be used from. This can be
+                          specified multiple times. If the parent token or
+                          the role is bound to networks, these must lie
+                          within them.
+
+  -type=service           The type of the token, either "service" or "batch".
+                          Batch tokens are not written to storage, but cannot
+                          be renewed, revoked on their own or create child
+                          tokens. They are valid until their TTL runs out or
+                          their parent is revoked.
+
   -format=table           The format for output. By default it is a whitespace-
                           delimited table. This can also be json or yaml.
 
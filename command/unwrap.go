@@ -0,0 +1,86 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/meta"
+)
+
+// UnwrapCommand is a Command that fetches the response wrapped in a
+// response-wrapping token.
+type UnwrapCommand struct {
+	meta.Meta
+}
+
+func (c *UnwrapCommand) Run(args []string) int {
+	var format string
+	var flags *flag.FlagSet
+	flags = c.Meta.FlagSet("unwrap", meta.FlagSetDefault)
+	flags.StringVar(&format, "format", "table", "")
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	var wrappingToken string
+	args = flags.Args()
+	switch len(args) {
+	case 0:
+	case 1:
+		wrappingToken = args[0]
+	default:
+		c.Ui.Error("unwrap expects zero or one argument (the ID of the wrapping token)")
+		flags.Usage()
+		return 1
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Error initializing client: %s", err))
+		return 2
+	}
+
+	secret, err := client.Logical().Unwrap(wrappingToken)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Error unwrapping response: %s", err))
+		return 1
+	}
+	if secret == nil {
+		c.Ui.Error("Server gave empty response or secret returned was empty")
+		return 1
+	}
+
+	return OutputSecret(c.Ui, format, secret)
+}
+
+func (c *UnwrapCommand) Synopsis() string {
+	return "Unwrap a wrapped secret"
+}
+
+func (c *UnwrapCommand) Help() string {
+	helpText := `
+Usage: vault unwrap [options] <wrapping token ID>
+
+  Unwrap a wrapped secret.
+
+  Unwraps the data wrapped by the given token ID. The returned result is the
+  same as a 'read' operation on a non-wrapped secret. The wrapping token can
+  only be used once.
+
+  If no token ID is given, the data will be unwrapped from the same token used
+  for authentication.
+
+General Options:
+` + meta.GeneralOptionsUsage() + `
+Unwrap Options:
+
+  -format=table           The format for output. By default it is a whitespace-
+                          delimited table. This can also be json or yaml.
+
+`
+	return strings.TrimSpace(helpText)
+}
@@ -0,0 +1,54 @@
+package cidrutil
+
+import (
+	"fmt"
+	"net"
+)
+
+// ParseCIDRs parses a list of CIDR blocks
+func ParseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, raw := range cidrs {
+		_, ipNet, err := net.ParseCIDR(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR '%s': %v", raw, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// IPInCIDRs checks if the given IP address belongs to any of the
+// given CIDR blocks. Addresses which do not parse belong to none.
+func IPInCIDRs(addr string, cidrs []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range cidrs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Subset checks if every CIDR block of sub is contained in a CIDR block
+// of super
+func Subset(super, sub []*net.IPNet) bool {
+	for _, inner := range sub {
+		innerOnes, innerBits := inner.Mask.Size()
+		found := false
+		for _, outer := range super {
+			outerOnes, outerBits := outer.Mask.Size()
+			if innerBits == outerBits && innerOnes >= outerOnes && outer.Contains(inner.IP) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
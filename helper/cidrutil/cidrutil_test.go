@@ -0,0 +1,50 @@
+package cidrutil
+
+import "testing"
+
+func TestIPInCIDRs(t *testing.T) {
+	cidrs, err := ParseCIDRs([]string{"10.0.0.0/8", "192.168.1.0/24"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for addr, expected := range map[string]bool{
+		"10.1.2.3":    true,
+		"192.168.1.9": true,
+		"192.168.2.9": false,
+		"::1":         false,
+		"bogus":       false,
+	} {
+		if actual := IPInCIDRs(addr, cidrs); actual != expected {
+			t.Fatalf("%s: expected %v", addr, expected)
+		}
+	}
+
+	if _, err := ParseCIDRs([]string{"10.0.0.1"}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestSubset(t *testing.T) {
+	super, _ := ParseCIDRs([]string{"10.0.0.0/8", "192.168.1.0/24"})
+
+	for _, tc := range []struct {
+		sub      []string
+		expected bool
+	}{
+		{nil, true},
+		{[]string{"10.1.0.0/16"}, true},
+		{[]string{"10.0.0.0/8", "192.168.1.128/25"}, true},
+		{[]string{"0.0.0.0/0"}, false},
+		{[]string{"192.168.0.0/16"}, false},
+		{[]string{"10.1.0.0/16", "172.16.0.0/12"}, false},
+	} {
+		sub, err := ParseCIDRs(tc.sub)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if actual := Subset(super, sub); actual != tc.expected {
+			t.Fatalf("%v: expected %v", tc.sub, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package forwarding
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// Request is the structure used to transport an HTTP request received by a
+// standby node to the active node.
+type Request struct {
+	Method           string      `json:"method"`
+	URL              *url.URL    `json:"url"`
+	Header           http.Header `json:"header"`
+	Body             []byte      `json:"body"`
+	Host             string      `json:"host"`
+	RemoteAddr       string      `json:"remote_addr"`
+	PeerCertificates [][]byte    `json:"peer_certificates"`
+}
+
+// GenerateForwardedRequest generates a new http.Request that contains the
+// original requests's information in the body. It's useful to be able to
+// forward the original client request to another node. The returned request
+// is sent to the given address.
+func GenerateForwardedRequest(req *http.Request, addr string) (*http.Request, error) {
+	fq := Request{
+		Method:     req.Method,
+		URL:        req.URL,
+		Header:     req.Header,
+		Host:       req.Host,
+		RemoteAddr: req.RemoteAddr,
+	}
+
+	if req.TLS != nil && req.TLS.PeerCertificates != nil {
+		for _, cert := range req.TLS.PeerCertificates {
+			fq.PeerCertificates = append(fq.PeerCertificates, cert.Raw)
+		}
+	}
+
+	if req.Body != nil {
+		buf := bytes.NewBuffer(nil)
+		_, err := buf.ReadFrom(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		fq.Body = buf.Bytes()
+	}
+
+	newBody, err := json.Marshal(&fq)
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := http.NewRequest("POST", addr, bytes.NewBuffer(newBody))
+	if err != nil {
+		return nil, err
+	}
+	ret.Header.Set("Content-Type", "application/json")
+
+	return ret, nil
+}
+
+// ParseForwardedRequest generates a new http.Request that is comprised of the
+// values in the given request's body, which is assumed to have been created
+// by GenerateForwardedRequest.
+func ParseForwardedRequest(req *http.Request) (*http.Request, error) {
+	buf := bytes.NewBuffer(nil)
+	if _, err := buf.ReadFrom(req.Body); err != nil {
+		return nil, err
+	}
+
+	var fq Request
+	if err := json.Unmarshal(buf.Bytes(), &fq); err != nil {
+		return nil, err
+	}
+	if fq.URL == nil {
+		return nil, fmt.Errorf("forwarded request is missing the URL")
+	}
+
+	var body io.Reader = bytes.NewReader(fq.Body)
+	ret := &http.Request{
+		Method:     fq.Method,
+		URL:        fq.URL,
+		Header:     fq.Header,
+		Body:       ioutil.NopCloser(body),
+		Host:       fq.Host,
+		RemoteAddr: fq.RemoteAddr,
+		RequestURI: fq.URL.RequestURI(),
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+	}
+	if ret.Header == nil {
+		ret.Header = make(http.Header)
+	}
+	ret.ContentLength = int64(len(fq.Body))
+
+	if len(fq.PeerCertificates) > 0 {
+		ret.TLS = &tls.ConnectionState{
+			PeerCertificates: make([]*x509.Certificate, 0, len(fq.PeerCertificates)),
+		}
+		for _, certBytes := range fq.PeerCertificates {
+			cert, err := x509.ParseCertificate(certBytes)
+			if err != nil {
+				return nil, err
+			}
+			ret.TLS.PeerCertificates = append(ret.TLS.PeerCertificates, cert)
+		}
+	}
+
+	return ret, nil
+}
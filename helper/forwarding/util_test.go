@@ -0,0 +1,53 @@
+package forwarding
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestForwarding_RoundTrip(t *testing.T) {
+	req, err := http.NewRequest("PUT", "https://127.0.0.1:8200/v1/secret/foo?list=true", bytes.NewBufferString(`{"value":"bar"}`))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req.Header.Set("X-Vault-Token", "foobar")
+	req.RemoteAddr = "10.0.0.1:12345"
+
+	fwReq, err := GenerateForwardedRequest(req, "https://127.0.0.1:8201/cluster/local/forwarded-request")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if fwReq.Method != "POST" || fwReq.URL.Path != "/cluster/local/forwarded-request" {
+		t.Fatalf("bad: %#v", fwReq)
+	}
+
+	parsed, err := ParseForwardedRequest(fwReq)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if parsed.Method != "PUT" {
+		t.Fatalf("bad method: %s", parsed.Method)
+	}
+	if parsed.URL.Path != "/v1/secret/foo" || parsed.URL.RawQuery != "list=true" {
+		t.Fatalf("bad url: %#v", parsed.URL)
+	}
+	if parsed.RemoteAddr != req.RemoteAddr {
+		t.Fatalf("bad remote addr: %s", parsed.RemoteAddr)
+	}
+	if !reflect.DeepEqual(parsed.Header, req.Header) {
+		t.Fatalf("bad header: %#v", parsed.Header)
+	}
+	body, err := ioutil.ReadAll(parsed.Body)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(body) != `{"value":"bar"}` {
+		t.Fatalf("bad body: %s", body)
+	}
+	if parsed.TLS != nil {
+		t.Fatalf("expected no TLS state, got %#v", parsed.TLS)
+	}
+}
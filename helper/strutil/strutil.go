@@ -1,5 +1,7 @@
 package strutil
 
+import "strings"
+
 // StrListContains looks for a string in a list of strings.
 func StrListContains(haystack []string, needle string) bool {
 	for _, item := range haystack {
@@ -20,3 +22,37 @@ func StrListSubset(super, sub []string) bool {
 	}
 	return true
 }
+
+// GlobMatch checks if a string matches a pattern where '*' stands
+// for any sequence of characters, including an empty one
+func GlobMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+
+	// The first and last parts are anchored at the ends of the string
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(s, part)
+		if idx < 0 {
+			return false
+		}
+		s = s[idx+len(part):]
+	}
+	return len(s) >= len(last) && strings.HasSuffix(s, last)
+}
+
+// GlobListMatch checks if a string matches any of the given patterns
+func GlobListMatch(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if GlobMatch(pattern, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -47,3 +47,35 @@ func TestStrListSubset(t *testing.T) {
 		t.Fatalf("Bad")
 	}
 }
+
+func TestGlobMatch(t *testing.T) {
+	tcases := []struct {
+		pattern string
+		s       string
+		match   bool
+	}{
+		{"foo", "foo", true},
+		{"foo", "foobar", false},
+		{"*", "", true},
+		{"*", "foo", true},
+		{"*.internal", "web.internal", true},
+		{"*.internal", "web.internal.com", false},
+		{"web.*", "web.internal", true},
+		{"w*b*l", "web.internal", true},
+		{"w*x*l", "web.internal", false},
+		{"ab*ba", "aba", false},
+		{"ab*ba", "abba", true},
+	}
+	for _, tc := range tcases {
+		if match := GlobMatch(tc.pattern, tc.s); match != tc.match {
+			t.Fatalf("bad: %#v: %v", tc, match)
+		}
+	}
+
+	if !GlobListMatch([]string{"foo", "b*"}, "bar") {
+		t.Fatalf("Bad")
+	}
+	if GlobListMatch(nil, "bar") {
+		t.Fatalf("Bad")
+	}
+}
@@ -0,0 +1,139 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/vault"
+)
+
+// testClusterAddr returns a free local address for a cluster listener
+func testClusterAddr(t *testing.T) *net.TCPAddr {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr)
+}
+
+func TestHTTP_Forwarding(t *testing.T) {
+	ln1, addr1 := TestListener(t)
+	defer ln1.Close()
+	ln2, addr2 := TestListener(t)
+	defer ln2.Close()
+
+	clusterAddr1 := testClusterAddr(t)
+	clusterAddr2 := testClusterAddr(t)
+
+	// Create an HA Vault
+	inmha := physical.NewInmemHA(logger)
+	core1, err := vault.NewCore(&vault.CoreConfig{
+		Physical:      inmha,
+		HAPhysical:    inmha,
+		AdvertiseAddr: addr1,
+		ClusterAddr:   "https://" + clusterAddr1.String(),
+		DisableMlock:  true,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	TestServerWithListener(t, ln1, addr1, core1)
+	core1.SetClusterListenerAddrs([]*net.TCPAddr{clusterAddr1})
+
+	key, root := vault.TestCoreInit(t, core1)
+	if _, err := core1.Unseal(vault.TestKeyCopy(key)); err != nil {
+		t.Fatalf("unseal err: %s", err)
+	}
+
+	// Give the first core a chance to grab the lock
+	time.Sleep(time.Second)
+
+	// Create a second HA Vault
+	core2, err := vault.NewCore(&vault.CoreConfig{
+		Physical:      inmha,
+		HAPhysical:    inmha,
+		AdvertiseAddr: addr2,
+		ClusterAddr:   "https://" + clusterAddr2.String(),
+		DisableMlock:  true,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	TestServerWithListener(t, ln2, addr2, core2)
+	core2.SetClusterListenerAddrs([]*net.TCPAddr{clusterAddr2})
+	if _, err := core2.Unseal(vault.TestKeyCopy(key)); err != nil {
+		t.Fatalf("unseal err: %s", err)
+	}
+
+	if standby, err := core2.Standby(); err != nil || !standby {
+		t.Fatalf("expected core2 to be a standby: %v, %v", standby, err)
+	}
+
+	// Redirects are not followed, so a successful response proves that
+	// the standby forwarded the request
+	client := cleanhttp.DefaultClient()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	doReq := func(method, addr string, body interface{}) *http.Response {
+		buf := new(bytes.Buffer)
+		if body != nil {
+			if err := json.NewEncoder(buf).Encode(body); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		}
+		req, err := http.NewRequest(method, addr, buf)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		req.Header.Set(AuthHeaderName, root)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return resp
+	}
+
+	// WRITE to STANDBY
+	resp := doReq("PUT", addr2+"/v1/secret/foo", map[string]interface{}{
+		"data": "bar",
+	})
+	testResponseStatus(t, resp, 204)
+
+	// READ from STANDBY
+	resp = doReq("GET", addr2+"/v1/secret/foo", nil)
+	var actual map[string]interface{}
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	data, ok := actual["data"].(map[string]interface{})
+	if !ok || data["data"] != "bar" {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// Asking for no forwarding results in the redirect
+	req, err := http.NewRequest("GET", addr2+"/v1/secret/foo", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	req.Header.Set(AuthHeaderName, root)
+	req.Header.Set(vault.NoRequestForwardingHeaderName, "true")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	testResponseStatus(t, resp, 307)
+
+	// A client that does not hold the cluster key is rejected by the
+	// cluster listener
+	badClient := cleanhttp.DefaultClient()
+	if _, err := badClient.Post("https://"+clusterAddr1.String()+"/cluster/local/forwarded-request", "application/json", nil); err == nil {
+		t.Fatalf("expected TLS error connecting without the cluster key")
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/logical"
@@ -16,6 +19,14 @@ import (
 // AuthHeaderName is the name of the header containing the token.
 const AuthHeaderName = "X-Vault-Token"
 
+// WrapTTLHeaderName is the name of the header containing a requested
+// response-wrapping TTL.
+const WrapTTLHeaderName = "X-Vault-Wrap-TTL"
+
+// NamespaceHeaderName is the name of the header containing the namespace
+// the request is made within.
+const NamespaceHeaderName = "X-Vault-Namespace"
+
 // Handler returns an http.Handler for the API. This can be used on
 // its own to mount the Vault API within another web server.
 func Handler(core *vault.Core) http.Handler {
@@ -24,20 +35,25 @@ func Handler(core *vault.Core) http.Handler {
 	mux.Handle("/v1/sys/init", handleSysInit(core))
 	mux.Handle("/v1/sys/seal-status", handleSysSealStatus(core))
 	mux.Handle("/v1/sys/seal", handleSysSeal(core))
-	mux.Handle("/v1/sys/step-down", handleSysStepDown(core))
+	mux.Handle("/v1/sys/step-down", handleRequestForwarding(core, handleSysStepDown(core)))
 	mux.Handle("/v1/sys/unseal", handleSysUnseal(core))
-	mux.Handle("/v1/sys/renew/", handleLogical(core, false, nil))
+	mux.Handle("/v1/sys/renew/", handleRequestForwarding(core, handleLogical(core, false, nil)))
+	mux.Handle("/v1/sys/leases/", handleRequestForwarding(core, handleLogical(core, false, nil)))
 	mux.Handle("/v1/sys/leader", handleSysLeader(core))
 	mux.Handle("/v1/sys/health", handleSysHealth(core))
-	mux.Handle("/v1/sys/generate-root/attempt", handleSysGenerateRootAttempt(core))
-	mux.Handle("/v1/sys/generate-root/update", handleSysGenerateRootUpdate(core))
-	mux.Handle("/v1/sys/rekey/init", handleSysRekeyInit(core, false))
-	mux.Handle("/v1/sys/rekey/update", handleSysRekeyUpdate(core, false))
-	mux.Handle("/v1/sys/rekey-recovery-key/init", handleSysRekeyInit(core, true))
-	mux.Handle("/v1/sys/rekey-recovery-key/update", handleSysRekeyUpdate(core, true))
-	mux.Handle("/v1/sys/capabilities-self", handleLogical(core, true, sysCapabilitiesSelfCallback))
-	mux.Handle("/v1/sys/", handleLogical(core, true, nil))
-	mux.Handle("/v1/", handleLogical(core, false, nil))
+	mux.Handle("/v1/sys/generate-root/attempt", handleRequestForwarding(core, handleSysGenerateRootAttempt(core)))
+	mux.Handle("/v1/sys/generate-root/update", handleRequestForwarding(core, handleSysGenerateRootUpdate(core)))
+	mux.Handle("/v1/sys/rekey/init", handleRequestForwarding(core, handleSysRekeyInit(core, false)))
+	mux.Handle("/v1/sys/rekey/update", handleRequestForwarding(core, handleSysRekeyUpdate(core, false)))
+	mux.Handle("/v1/sys/rekey-recovery-key/init", handleRequestForwarding(core, handleSysRekeyInit(core, true)))
+	mux.Handle("/v1/sys/rekey-recovery-key/update", handleRequestForwarding(core, handleSysRekeyUpdate(core, true)))
+	mux.Handle("/v1/sys/capabilities-self", handleRequestForwarding(core, handleLogical(core, true, sysCapabilitiesSelfCallback)))
+	mux.Handle("/v1/sys/storage/snapshot", handleRequestForwarding(core, handleSysStorageSnapshot(core)))
+	mux.Handle("/v1/sys/control-group/authorize", handleRequestForwarding(core, handleLogical(core, true, sysCapabilitiesSelfCallback)))
+	mux.Handle("/v1/sys/wrapping/unwrap", handleRequestForwarding(core, handleLogical(core, false, sysWrappingUnwrapCallback)))
+	mux.Handle("/v1/sys/wrapping/", handleRequestForwarding(core, handleLogical(core, false, nil)))
+	mux.Handle("/v1/sys/", handleRequestForwarding(core, handleLogical(core, true, nil)))
+	mux.Handle("/v1/", handleRequestForwarding(core, handleLogical(core, false, nil)))
 
 	// Wrap the handler in another handler to trigger all help paths.
 	handler := handleHelpHandler(mux, core)
@@ -48,7 +64,8 @@ func Handler(core *vault.Core) http.Handler {
 // ClientToken is required in the handler of sys/capabilities-self endpoint in
 // system backend. But the ClientToken gets obfuscated before the request gets
 // forwarded to any logical backend. So, setting the ClientToken in the data
-// field for this request.
+// field for this request. The same goes for sys/control-group/authorize,
+// which is approved by the client token.
 func sysCapabilitiesSelfCallback(req *logical.Request) error {
 	if req == nil || req.Data == nil {
 		return fmt.Errorf("invalid request")
@@ -57,6 +74,61 @@ func sysCapabilitiesSelfCallback(req *logical.Request) error {
 	return nil
 }
 
+// handleRequestForwarding forwards the request to the active node when this
+// node is a standby. If forwarding is not possible, the given handler serves
+// the request, which results in a redirect to the active node.
+func handleRequestForwarding(core *vault.Core, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(vault.NoRequestForwardingHeaderName) != "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		// Note: in an HA setup, this call will also ensure that the
+		// connection to the active node is set up, as that happens once
+		// the advertised cluster values are read
+		isLeader, leaderAddr, err := core.Leader()
+		if err != nil || isLeader || leaderAddr == "" {
+			// Errors such as being sealed or not running in HA mode are
+			// reported by the handler itself
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		statusCode, header, body, err := core.ForwardRequest(r)
+		if err != nil {
+			// Fall back to redirecting the client
+			respondStandby(core, w, r.URL)
+			return
+		}
+
+		for k, v := range header {
+			for _, j := range v {
+				w.Header().Add(k, j)
+			}
+		}
+		w.WriteHeader(statusCode)
+		w.Write(body)
+	})
+}
+
+// sys/wrapping/unwrap accepts the wrapping token either in the request body or
+// as the client token. As with sys/capabilities-self, the ClientToken gets
+// obfuscated before reaching the system backend, so if no token was given in
+// the body the ClientToken is set in the data field.
+func sysWrappingUnwrapCallback(req *logical.Request) error {
+	if req == nil {
+		return fmt.Errorf("invalid request")
+	}
+	if req.Data == nil {
+		req.Data = make(map[string]interface{})
+	}
+	if token, ok := req.Data["token"]; !ok || token == "" {
+		req.Data["token"] = req.ClientToken
+	}
+	return nil
+}
+
 // stripPrefix is a helper to strip a prefix from the path. It will
 // return false from the second return value if it the prefix doesn't exist.
 func stripPrefix(prefix, path string) (string, bool) {
@@ -93,6 +165,10 @@ func request(core *vault.Core, w http.ResponseWriter, rawReq *http.Request, r *l
 		return resp, false
 	}
 	if err != nil {
+		// Tell clients exceeding a rate limit quota when to come back
+		if rlErr, ok := err.(*vault.RateLimitError); ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rlErr.RetryAfter.Seconds()))))
+		}
 		respondErrorStatus(w, err)
 		return resp, false
 	}
@@ -153,6 +229,43 @@ func requestAuth(r *http.Request, req *logical.Request) *logical.Request {
 	return req
 }
 
+// requestNamespace places the request within the namespace given in the
+// header, if any, by prefixing its path with the namespace path.
+func requestNamespace(r *http.Request, req *logical.Request) *logical.Request {
+	v := strings.Trim(r.Header.Get(NamespaceHeaderName), "/")
+	if v != "" {
+		req.Path = v + "/" + req.Path
+	}
+
+	return req
+}
+
+// requestWrapTTL adds the response-wrapping TTL to the logical.Request
+// if it was specified. The value can be either a number of seconds or a
+// duration string such as "5m".
+func requestWrapTTL(r *http.Request, req *logical.Request) (*logical.Request, error) {
+	v := r.Header.Get(WrapTTLHeaderName)
+	if v == "" {
+		return req, nil
+	}
+
+	var wrapTTL time.Duration
+	if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+		wrapTTL = time.Duration(seconds) * time.Second
+	} else {
+		wrapTTL, err = time.ParseDuration(v)
+		if err != nil {
+			return req, fmt.Errorf("invalid wrap TTL %q: %s", v, err)
+		}
+	}
+	if wrapTTL < 0 {
+		return req, fmt.Errorf("wrap TTL must not be negative")
+	}
+
+	req.WrapTTL = wrapTTL
+	return req, nil
+}
+
 // Determines the type of the error being returned and sets the HTTP
 // status code appropriately
 func respondErrorStatus(w http.ResponseWriter, err error) {
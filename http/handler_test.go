@@ -56,6 +56,14 @@ func TestSysMounts_headerAuth(t *testing.T) {
 				"max_lease_ttl":     float64(0),
 			},
 		},
+		"identity/": map[string]interface{}{
+			"description": "identity store",
+			"type":        "identity",
+			"config": map[string]interface{}{
+				"default_lease_ttl": float64(0),
+				"max_lease_ttl":     float64(0),
+			},
+		},
 	}
 	testResponseStatus(t, resp, 200)
 	testResponseBody(t, resp, &actual)
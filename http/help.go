@@ -27,11 +27,11 @@ func handleHelp(core *vault.Core, w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	resp, err := core.HandleRequest(requestAuth(req, &logical.Request{
+	resp, err := core.HandleRequest(requestNamespace(req, requestAuth(req, &logical.Request{
 		Operation:  logical.HelpOperation,
 		Path:       path,
 		Connection: getConnection(req),
-	}))
+	})))
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err)
 		return
@@ -68,12 +68,31 @@ func handleLogical(core *vault.Core, dataOnly bool, prepareRequestCallback Prepa
 			}
 		}
 
-		req := requestAuth(r, &logical.Request{
+		// List requests take their parameters, such as those for paging,
+		// from the query string
+		if op == logical.ListOperation {
+			for key, values := range r.URL.Query() {
+				if key == "list" || len(values) == 0 {
+					continue
+				}
+				if data == nil {
+					data = make(map[string]interface{})
+				}
+				data[key] = values[0]
+			}
+		}
+
+		req := requestNamespace(r, requestAuth(r, &logical.Request{
 			Operation:  op,
 			Path:       path,
 			Data:       data,
 			Connection: getConnection(r),
-		})
+		}))
+		req, err := requestWrapTTL(r, req)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err)
+			return
+		}
 
 		// Certain endpoints may require changes to the request object.
 		// They will have a callback registered to do the needful.
@@ -112,7 +131,9 @@ func respondLogical(w http.ResponseWriter, r *http.Request, path string, dataOnl
 			return
 		}
 
-		if dataOnly {
+		// Wrapped responses, such as those of requests awaiting the
+		// approval of a control group, only carry the wrapping information
+		if dataOnly && resp.WrapInfo == nil {
 			respondOk(w, resp.Data)
 			return
 		}
@@ -123,29 +144,7 @@ func respondLogical(w http.ResponseWriter, r *http.Request, path string, dataOnl
 			return
 		}
 
-		logicalResp := &LogicalResponse{
-			Data:     resp.Data,
-			Warnings: resp.Warnings(),
-		}
-		if resp.Secret != nil {
-			logicalResp.LeaseID = resp.Secret.LeaseID
-			logicalResp.Renewable = resp.Secret.Renewable
-			logicalResp.LeaseDuration = int(resp.Secret.TTL.Seconds())
-		}
-
-		// If we have authentication information, then
-		// set up the result structure.
-		if resp.Auth != nil {
-			logicalResp.Auth = &Auth{
-				ClientToken:   resp.Auth.ClientToken,
-				Accessor:      resp.Auth.Accessor,
-				Policies:      resp.Auth.Policies,
-				Metadata:      resp.Auth.Metadata,
-				LeaseDuration: int(resp.Auth.TTL.Seconds()),
-				Renewable:     resp.Auth.Renewable,
-			}
-		}
-
+		logicalResp := logical.SanitizeResponse(resp)
 		httpResp = logicalResp
 	}
 
@@ -221,21 +220,3 @@ func getConnection(r *http.Request) (connection *logical.Connection) {
 	}
 	return
 }
-
-type LogicalResponse struct {
-	LeaseID       string                 `json:"lease_id"`
-	Renewable     bool                   `json:"renewable"`
-	LeaseDuration int                    `json:"lease_duration"`
-	Data          map[string]interface{} `json:"data"`
-	Warnings      []string               `json:"warnings"`
-	Auth          *Auth                  `json:"auth"`
-}
-
-type Auth struct {
-	ClientToken   string            `json:"client_token"`
-	Accessor      string            `json:"accessor"`
-	Policies      []string          `json:"policies"`
-	Metadata      map[string]string `json:"metadata"`
-	LeaseDuration int               `json:"lease_duration"`
-	Renewable     bool              `json:"renewable"`
-}
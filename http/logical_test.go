@@ -40,8 +40,9 @@ func TestLogical(t *testing.T) {
 		"data": map[string]interface{}{
 			"data": "bar",
 		},
-		"auth":     nil,
-		"warnings": nilWarnings,
+		"auth":      nil,
+		"wrap_info": nil,
+		"warnings":  nilWarnings,
 	}
 	testResponseStatus(t, resp, 200)
 	testResponseBody(t, resp, &actual)
@@ -138,9 +139,11 @@ func TestLogical_StandbyRedirect(t *testing.T) {
 			"ttl":          float64(0),
 			"creation_ttl": float64(0),
 			"role":         "",
+			"entity_id":    "",
 		},
-		"warnings": nilWarnings,
-		"auth":     nil,
+		"wrap_info": nil,
+		"warnings":  nilWarnings,
+		"auth":      nil,
 	}
 
 	testResponseStatus(t, resp, 200)
@@ -182,8 +185,10 @@ func TestLogical_CreateToken(t *testing.T) {
 			"metadata":       nil,
 			"lease_duration": float64(0),
 			"renewable":      true,
+			"entity_id":      "",
 		},
-		"warnings": nilWarnings,
+		"wrap_info": nil,
+		"warnings":  nilWarnings,
 	}
 	testResponseStatus(t, resp, 200)
 	testResponseBody(t, resp, &actual)
@@ -221,3 +226,26 @@ func TestLogical_RawHTTP(t *testing.T) {
 		t.Fatalf("Bad: %s", body.Bytes())
 	}
 }
+
+func TestLogical_ListQuery(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+	TestServerAuth(t, addr, token)
+
+	for i := 0; i < 2; i++ {
+		resp := testHttpPut(t, token, addr+"/v1/auth/token/create", nil)
+		testResponseStatus(t, resp, 200)
+	}
+
+	// Query parameters other than list are passed on to the backend
+	resp := testHttpGet(t, token, addr+"/v1/auth/token/accessors?list=true&limit=2")
+	testResponseStatus(t, resp, 200)
+
+	var actual map[string]interface{}
+	testResponseBody(t, resp, &actual)
+	keys := actual["data"].(map[string]interface{})["keys"].([]interface{})
+	if len(keys) != 2 {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
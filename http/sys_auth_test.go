@@ -24,6 +24,7 @@ func TestSysAuth(t *testing.T) {
 	}
 	testResponseStatus(t, resp, 200)
 	testResponseBody(t, resp, &actual)
+	testAuthAccessors(t, expected, actual)
 	if !reflect.DeepEqual(actual, expected) {
 		t.Fatalf("bad: %#v", actual)
 	}
@@ -56,6 +57,7 @@ func TestSysEnableAuth(t *testing.T) {
 	}
 	testResponseStatus(t, resp, 200)
 	testResponseBody(t, resp, &actual)
+	testAuthAccessors(t, expected, actual)
 	if !reflect.DeepEqual(actual, expected) {
 		t.Fatalf("bad: %#v", actual)
 	}
@@ -87,7 +89,22 @@ func TestSysDisableAuth(t *testing.T) {
 	}
 	testResponseStatus(t, resp, 200)
 	testResponseBody(t, resp, &actual)
+	testAuthAccessors(t, expected, actual)
 	if !reflect.DeepEqual(actual, expected) {
 		t.Fatalf("bad: %#v", actual)
 	}
 }
+
+// testAuthAccessors checks that every credential backend of the response
+// has an accessor, and copies it to the expected response
+func testAuthAccessors(t *testing.T, expected, actual map[string]interface{}) {
+	for path, info := range actual {
+		accessor, _ := info.(map[string]interface{})["accessor"].(string)
+		if accessor == "" {
+			t.Fatalf("missing accessor for %s: %#v", path, actual)
+		}
+		if exp, ok := expected[path].(map[string]interface{}); ok {
+			exp["accessor"] = accessor
+		}
+	}
+}
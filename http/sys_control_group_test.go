@@ -0,0 +1,85 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/vault"
+)
+
+func TestSysControlGroup(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+	TestServerAuth(t, addr, token)
+
+	resp := testHttpPut(t, token, addr+"/v1/sys/policy/requester", map[string]interface{}{
+		"rules": `path "sys/mounts" {
+	capabilities = ["read"]
+	control_group {
+		approver_policies = ["approver"]
+	}
+}`,
+	})
+	testResponseStatus(t, resp, 204)
+	resp = testHttpPut(t, token, addr+"/v1/sys/policy/approver", map[string]interface{}{
+		"rules": `path "sys/control-group/authorize" { capabilities = ["update"] }`,
+	})
+	testResponseStatus(t, resp, 204)
+
+	// Requesters and approvers need an entity, issued here through a role
+	resp = testHttpPut(t, token, addr+"/v1/auth/token/roles/people", map[string]interface{}{
+		"allowed_policies":       "requester,approver",
+		"allowed_entity_aliases": "*",
+	})
+	testResponseStatus(t, resp, 204)
+
+	tokens := make(map[string]string)
+	for _, policy := range []string{"requester", "approver"} {
+		resp = testHttpPut(t, token, addr+"/v1/auth/token/create/people", map[string]interface{}{
+			"policies":     []string{policy},
+			"entity_alias": policy,
+		})
+		var actual map[string]interface{}
+		testResponseStatus(t, resp, 200)
+		testResponseBody(t, resp, &actual)
+		tokens[policy] = actual["auth"].(map[string]interface{})["client_token"].(string)
+	}
+
+	// The request is answered with the wrapping information only
+	resp = testHttpGet(t, tokens["requester"], addr+"/v1/sys/mounts")
+	var actual map[string]interface{}
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	wrapInfo, ok := actual["wrap_info"].(map[string]interface{})
+	if !ok || actual["data"] != nil {
+		t.Fatalf("bad: %#v", actual)
+	}
+	wrapToken, accessor := wrapInfo["token"].(string), wrapInfo["accessor"].(string)
+	if wrapToken == "" || accessor == "" {
+		t.Fatalf("bad: %#v", wrapInfo)
+	}
+
+	// Unwrapping before the approval does not use up the wrapping token
+	resp = testHttpPut(t, wrapToken, addr+"/v1/sys/wrapping/unwrap", nil)
+	testResponseStatus(t, resp, 400)
+
+	// The approver is the client token
+	resp = testHttpPut(t, tokens["approver"], addr+"/v1/sys/control-group/authorize", map[string]interface{}{
+		"accessor": accessor,
+	})
+	actual = nil
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	if actual["approved"] != true {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	resp = testHttpPut(t, wrapToken, addr+"/v1/sys/wrapping/unwrap", nil)
+	actual = nil
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	data, ok := actual["data"].(map[string]interface{})
+	if !ok || data["secret/"] == nil {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
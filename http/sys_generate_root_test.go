@@ -303,6 +303,8 @@ func TestSysGenerateRoot_Update_OTP(t *testing.T) {
 		"ttl":          float64(0),
 		"path":         "auth/token/root",
 		"role":         "",
+		"entity_id":    "",
+		"type":         "service",
 	}
 
 	resp = testHttpGet(t, newRootToken, addr+"/v1/auth/token/lookup-self")
@@ -385,6 +387,8 @@ func TestSysGenerateRoot_Update_PGP(t *testing.T) {
 		"ttl":          float64(0),
 		"path":         "auth/token/root",
 		"role":         "",
+		"entity_id":    "",
+		"type":         "service",
 	}
 
 	resp = testHttpGet(t, newRootToken, addr+"/v1/auth/token/lookup-self")
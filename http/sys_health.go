@@ -67,6 +67,7 @@ func handleSysHealthGet(core *vault.Core, w http.ResponseWriter, r *http.Request
 	// Check system status
 	sealed, _ := core.Sealed()
 	standby, _ := core.Standby()
+	restoring, restored := core.LeaseRestoreStatus()
 	init, err := core.Initialized()
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err)
@@ -90,6 +91,9 @@ func handleSysHealthGet(core *vault.Core, w http.ResponseWriter, r *http.Request
 		Sealed:        sealed,
 		Standby:       standby,
 		ServerTimeUTC: time.Now().UTC().Unix(),
+
+		LeaseRestoreInProgress: restoring,
+		LeasesRestored:         restored,
 	}
 
 	// Generate the response
@@ -104,4 +108,7 @@ type HealthResponse struct {
 	Sealed        bool  `json:"sealed"`
 	Standby       bool  `json:"standby"`
 	ServerTimeUTC int64 `json:"server_time_utc"`
+
+	LeaseRestoreInProgress bool `json:"lease_restore_in_progress"`
+	LeasesRestored         int  `json:"leases_restored"`
 }
@@ -21,9 +21,11 @@ func TestSysHealth_get(t *testing.T) {
 
 	var actual map[string]interface{}
 	expected := map[string]interface{}{
-		"initialized": true,
-		"sealed":      false,
-		"standby":     false,
+		"initialized":               true,
+		"sealed":                    false,
+		"standby":                   false,
+		"lease_restore_in_progress": false,
+		"leases_restored":           float64(0),
 	}
 	testResponseStatus(t, resp, 200)
 	testResponseBody(t, resp, &actual)
@@ -41,9 +43,11 @@ func TestSysHealth_get(t *testing.T) {
 
 	actual = map[string]interface{}{}
 	expected = map[string]interface{}{
-		"initialized": true,
-		"sealed":      true,
-		"standby":     false,
+		"initialized":               true,
+		"sealed":                    true,
+		"standby":                   false,
+		"lease_restore_in_progress": false,
+		"leases_restored":           float64(0),
 	}
 	testResponseStatus(t, resp, 500)
 	testResponseBody(t, resp, &actual)
@@ -69,9 +73,11 @@ func TestSysHealth_customcodes(t *testing.T) {
 
 	var actual map[string]interface{}
 	expected := map[string]interface{}{
-		"initialized": true,
-		"sealed":      false,
-		"standby":     false,
+		"initialized":               true,
+		"sealed":                    false,
+		"standby":                   false,
+		"lease_restore_in_progress": false,
+		"leases_restored":           float64(0),
 	}
 	testResponseStatus(t, resp, 202)
 	testResponseBody(t, resp, &actual)
@@ -94,9 +100,11 @@ func TestSysHealth_customcodes(t *testing.T) {
 
 	actual = map[string]interface{}{}
 	expected = map[string]interface{}{
-		"initialized": true,
-		"sealed":      true,
-		"standby":     false,
+		"initialized":               true,
+		"sealed":                    true,
+		"standby":                   false,
+		"lease_restore_in_progress": false,
+		"leases_restored":           float64(0),
 	}
 	testResponseStatus(t, resp, 503)
 	testResponseBody(t, resp, &actual)
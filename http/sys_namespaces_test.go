@@ -0,0 +1,72 @@
+package http
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/vault"
+)
+
+func testHttpNamespace(t *testing.T, method, token, namespace, addr string) *http.Response {
+	req, err := http.NewRequest(method, addr, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	req.Header.Set(AuthHeaderName, token)
+	req.Header.Set(NamespaceHeaderName, namespace)
+
+	resp, err := cleanhttp.DefaultClient().Do(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return resp
+}
+
+func TestSysNamespaces(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+	TestServerAuth(t, addr, token)
+
+	resp := testHttpPut(t, token, addr+"/v1/sys/namespaces/team", nil)
+	testResponseStatus(t, resp, 200)
+
+	resp = testHttpPut(t, token, addr+"/v1/team/sys/policy/foo", map[string]interface{}{
+		"rules": ``,
+	})
+	testResponseStatus(t, resp, 204)
+
+	// The header places the request within the namespace
+	resp = testHttpNamespace(t, "GET", token, "team", addr+"/v1/sys/policy")
+
+	var actual map[string]interface{}
+	expected := map[string]interface{}{
+		"policies": []interface{}{"default", "foo", "root"},
+		"keys":     []interface{}{"default", "foo", "root"},
+	}
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: got\n%#v\nexpected\n%#v\n", actual, expected)
+	}
+
+	resp = testHttpNamespace(t, "LIST", token, "/", addr+"/v1/sys/namespaces")
+
+	actual = map[string]interface{}{}
+	expected = map[string]interface{}{
+		"keys": []interface{}{"team/"},
+	}
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: got\n%#v\nexpected\n%#v\n", actual, expected)
+	}
+
+	resp = testHttpDelete(t, token, addr+"/v1/sys/namespaces/team")
+	testResponseStatus(t, resp, 204)
+
+	resp = testHttpNamespace(t, "GET", token, "team/", addr+"/v1/sys/policy")
+	testResponseStatus(t, resp, 404)
+}
@@ -0,0 +1,114 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/vault/logical/plugin"
+	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/vault"
+)
+
+// TestSysPlugins_Serve is not a real test: it is the plugin run by
+// TestSysPlugins, which registers the test binary in the catalog.
+func TestSysPlugins_Serve(t *testing.T) {
+	if os.Getenv(plugin.MetadataModeEnv) == "" && os.Getenv(plugin.UnwrapTokenEnv) == "" {
+		return
+	}
+	if err := plugin.Serve(vault.PassthroughBackendFactory); err != nil {
+		fmt.Fprintf(os.Stderr, "serve failed: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func TestSysPlugins(t *testing.T) {
+	ln, addr := TestListener(t)
+	defer ln.Close()
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	core, err := vault.NewCore(&vault.CoreConfig{
+		Physical:        physical.NewInmem(logger),
+		DisableMlock:    true,
+		Logger:          logger,
+		AdvertiseAddr:   addr,
+		PluginDirectory: filepath.Dir(os.Args[0]),
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	key, token := vault.TestCoreInit(t, core)
+	if _, err := core.Unseal(vault.TestKeyCopy(key)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	TestServerWithListener(t, ln, addr, core)
+	TestServerAuth(t, addr, token)
+
+	buf, err := ioutil.ReadFile(os.Args[0])
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sum := sha256.Sum256(buf)
+	command := filepath.Base(os.Args[0]) + " -test.run=^TestSysPlugins_Serve$"
+
+	resp := testHttpPut(t, token, addr+"/v1/sys/plugins/catalog/passthrough", map[string]interface{}{
+		"command": command,
+		"sha_256": hex.EncodeToString(sum[:]),
+	})
+	testResponseStatus(t, resp, 204)
+
+	resp = testHttpGet(t, token, addr+"/v1/sys/plugins/catalog/passthrough")
+	var actual map[string]interface{}
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	if actual["command"] != command {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	resp = testHttpPost(t, token, addr+"/v1/sys/mounts/external", map[string]interface{}{
+		"type":        "plugin",
+		"plugin_name": "passthrough",
+	})
+	testResponseStatus(t, resp, 204)
+
+	resp = testHttpGet(t, token, addr+"/v1/sys/mounts")
+	actual = nil
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	mount, ok := actual["external/"].(map[string]interface{})
+	if !ok || mount["type"] != "plugin" || mount["plugin_name"] != "passthrough" {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// Requests are served by the plugin, which stores through Vault
+	resp = testHttpPut(t, token, addr+"/v1/external/foo", map[string]interface{}{
+		"data": "bar",
+	})
+	testResponseStatus(t, resp, 204)
+	testSysPluginsRead(t, token, addr)
+
+	// The plugin is run again once Vault is unsealed
+	if err := core.Seal(token); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := core.Unseal(vault.TestKeyCopy(key)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testSysPluginsRead(t, token, addr)
+}
+
+func testSysPluginsRead(t *testing.T, token, addr string) {
+	resp := testHttpGet(t, token, addr+"/v1/external/foo")
+	var actual map[string]interface{}
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	if actual["data"].(map[string]interface{})["data"] != "bar" {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/vault"
+)
+
+func TestSysRateLimitQuota(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+	TestServerAuth(t, addr, token)
+
+	resp := testHttpPut(t, token, addr+"/v1/sys/quotas/rate-limit/global", map[string]interface{}{
+		"rate":     1,
+		"interval": 60,
+	})
+	testResponseStatus(t, resp, 204)
+
+	resp = testHttpGet(t, token, addr+"/v1/sys/mounts")
+	testResponseStatus(t, resp, 200)
+
+	resp = testHttpGet(t, token, addr+"/v1/sys/mounts")
+	testResponseStatus(t, resp, 429)
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "60" && retryAfter != "59" {
+		t.Fatalf("bad: %q", retryAfter)
+	}
+
+	// The quotas themselves can still be managed
+	resp = testHttpDelete(t, token, addr+"/v1/sys/quotas/rate-limit/global")
+	testResponseStatus(t, resp, 204)
+	resp = testHttpGet(t, token, addr+"/v1/sys/mounts")
+	testResponseStatus(t, resp, 200)
+}
@@ -98,12 +98,17 @@ func handleSysRekeyInitPut(core *vault.Core, recovery bool, w http.ResponseWrite
 		return
 	}
 
-	// Right now we don't support this, but the rest of the code is ready for
-	// when we do, hence the check below for this to be false if
-	// StoredShares is greater than zero
-	if core.SealAccess().StoredKeysSupported() {
-		respondError(w, http.StatusBadRequest, fmt.Errorf("rekeying of barrier not supported when stored key support is available"))
-		return
+	// Seals storing keys keep the single barrier key share, as for
+	// initialization
+	if !recovery && core.SealAccess().StoredKeysSupported() {
+		if req.SecretShares != 1 || req.SecretThreshold != 1 || req.StoredShares != 1 {
+			respondError(w, http.StatusBadRequest, fmt.Errorf("secret shares, secret threshold and stored shares must be 1 when the seal stores keys"))
+			return
+		}
+		if len(req.PGPKeys) > 0 {
+			respondError(w, http.StatusBadRequest, fmt.Errorf("PGP keys not supported when storing shares"))
+			return
+		}
 	}
 
 	// Initialize the rekey
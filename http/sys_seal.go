@@ -102,7 +102,11 @@ func handleSysUnseal(core *vault.Core) http.Handler {
 			}
 
 			// Attempt the unseal
-			if _, err := core.Unseal(key); err != nil {
+			unseal := core.Unseal
+			if req.Migrate {
+				unseal = core.UnsealMigrate
+			}
+			if _, err := unseal(key); err != nil {
 				// Ignore ErrInvalidKey because its a user error that we
 				// mask away. We just show them the seal status.
 				if !errwrap.ContainsType(err, new(vault.ErrInvalidKey)) {
@@ -147,21 +151,24 @@ func handleSysSealStatusRaw(core *vault.Core, w http.ResponseWriter, r *http.Req
 	}
 
 	respondOk(w, &SealStatusResponse{
-		Sealed:   sealed,
-		T:        sealConfig.SecretThreshold,
-		N:        sealConfig.SecretShares,
-		Progress: core.SecretProgress(),
+		Sealed:    sealed,
+		T:         sealConfig.SecretThreshold,
+		N:         sealConfig.SecretShares,
+		Progress:  core.SecretProgress(),
+		Migration: core.SealMigrationPending(),
 	})
 }
 
 type SealStatusResponse struct {
-	Sealed   bool `json:"sealed"`
-	T        int  `json:"t"`
-	N        int  `json:"n"`
-	Progress int  `json:"progress"`
+	Sealed    bool `json:"sealed"`
+	T         int  `json:"t"`
+	N         int  `json:"n"`
+	Progress  int  `json:"progress"`
+	Migration bool `json:"migration,omitempty"`
 }
 
 type UnsealRequest struct {
-	Key   string
-	Reset bool
+	Key     string
+	Reset   bool
+	Migrate bool
 }
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/vault"
+)
+
+func handleSysStorageSnapshot(core *vault.Core) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handleSysStorageSnapshotSave(core, w, r)
+		case "PUT", "POST":
+			handleSysStorageSnapshotRestore(core, w, r)
+		default:
+			respondError(w, http.StatusMethodNotAllowed, nil)
+		}
+	})
+}
+
+func handleSysStorageSnapshotSave(core *vault.Core, w http.ResponseWriter, r *http.Request) {
+	req := requestAuth(r, &logical.Request{
+		Connection: getConnection(r),
+	})
+
+	snap, err := core.SnapshotSave(req)
+	if err != nil {
+		respondSnapshotError(w, err)
+		return
+	}
+	defer snap.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, snap)
+}
+
+func handleSysStorageSnapshotRestore(core *vault.Core, w http.ResponseWriter, r *http.Request) {
+	req := requestAuth(r, &logical.Request{
+		Connection: getConnection(r),
+	})
+
+	force := false
+	if forceRaw := r.URL.Query().Get("force"); forceRaw != "" {
+		var err error
+		force, err = strconv.ParseBool(forceRaw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if err := core.SnapshotRestore(req, r.Body, force); err != nil {
+		respondSnapshotError(w, err)
+		return
+	}
+
+	respondOk(w, nil)
+}
+
+func respondSnapshotError(w http.ResponseWriter, err error) {
+	if err == logical.ErrPermissionDenied {
+		respondError(w, http.StatusForbidden, err)
+		return
+	}
+	// Tell clients exceeding a rate limit quota when to come back
+	if rlErr, ok := err.(*vault.RateLimitError); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rlErr.RetryAfter.Seconds()))))
+	}
+	respondErrorStatus(w, err)
+}
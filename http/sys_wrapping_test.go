@@ -0,0 +1,86 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/vault"
+)
+
+func TestSysWrapping(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+	TestServerAuth(t, addr, token)
+
+	resp := testHttpPut(t, token, addr+"/v1/secret/foo", map[string]interface{}{
+		"data": "bar",
+	})
+	testResponseStatus(t, resp, 204)
+
+	// Read the secret, requesting a wrapped response
+	req, err := http.NewRequest("GET", addr+"/v1/secret/foo", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	req.Header.Set(AuthHeaderName, token)
+	req.Header.Set(WrapTTLHeaderName, "5m")
+	resp, err = cleanhttp.DefaultClient().Do(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var actual map[string]interface{}
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	if actual["data"] != nil {
+		t.Fatalf("wrapped response leaked data: %#v", actual)
+	}
+	wrapInfo, ok := actual["wrap_info"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("bad: %#v", actual)
+	}
+	if wrapInfo["ttl"] != float64(300) {
+		t.Fatalf("bad: %#v", wrapInfo)
+	}
+	wrapToken, ok := wrapInfo["token"].(string)
+	if !ok || wrapToken == "" {
+		t.Fatalf("bad: %#v", wrapInfo)
+	}
+
+	// Unwrap using the wrapping token as the client token
+	resp = testHttpPut(t, wrapToken, addr+"/v1/sys/wrapping/unwrap", nil)
+	actual = nil
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	data, ok := actual["data"].(map[string]interface{})
+	if !ok || data["data"] != "bar" {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// The token is single-use
+	resp = testHttpPut(t, token, addr+"/v1/sys/wrapping/unwrap", map[string]interface{}{
+		"token": wrapToken,
+	})
+	testResponseStatus(t, resp, 400)
+}
+
+func TestSysWrapping_badTTL(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+	TestServerAuth(t, addr, token)
+
+	req, err := http.NewRequest("GET", addr+"/v1/auth/token/lookup-self", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	req.Header.Set(AuthHeaderName, token)
+	req.Header.Set(WrapTTLHeaderName, "-5")
+	resp, err := cleanhttp.DefaultClient().Do(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	testResponseStatus(t, resp, 400)
+}
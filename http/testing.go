@@ -28,9 +28,13 @@ func TestListener(t *testing.T) (net.Listener, string) {
 func TestServerWithListener(t *testing.T, ln net.Listener, addr string, core *vault.Core) {
 	// Create a muxer to handle our requests so that we can authenticate
 	// for tests.
+	handler := Handler(core)
 	mux := http.NewServeMux()
 	mux.Handle("/_test/auth", http.HandlerFunc(testHandleAuth))
-	mux.Handle("/", Handler(core))
+	mux.Handle("/", handler)
+
+	// Serve requests forwarded by standbys with the same handler
+	core.SetClusterHandler(handler)
 
 	server := &http.Server{
 		Addr:    ln.Addr().String(),
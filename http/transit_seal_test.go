@@ -0,0 +1,310 @@
+package http
+
+import (
+	"log"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/vault/builtin/logical/transit"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/vault"
+)
+
+// testTransitSeal starts a Vault providing the transit key "unseal" and
+// returns a transit seal using it
+func testTransitSeal(t *testing.T) (*vault.TransitSeal, net.Listener) {
+	if err := vault.AddTestLogicalBackend("transit", transit.Factory); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	transitCore, _, transitToken := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, transitCore)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/mounts/transit")
+	req.ClientToken = transitToken
+	req.Data["type"] = "transit"
+	if _, err := transitCore.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req = logical.TestRequest(t, logical.UpdateOperation, "transit/keys/unseal")
+	req.ClientToken = transitToken
+	if _, err := transitCore.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	seal, err := vault.NewTransitSeal(map[string]string{
+		"address":  addr,
+		"token":    transitToken,
+		"key_name": "unseal",
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return seal, ln
+}
+
+func TestTransitSeal(t *testing.T) {
+	seal, ln := testTransitSeal(t)
+	defer ln.Close()
+	core := vault.TestCoreWithSeal(t, seal)
+
+	result, err := core.Initialize(&vault.SealConfig{
+		SecretShares:    1,
+		SecretThreshold: 1,
+		StoredShares:    1,
+	}, &vault.SealConfig{
+		SecretShares:    3,
+		SecretThreshold: 2,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(result.SecretShares) != 0 || len(result.RecoveryShares) != 3 {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	testTransitSealUnseal := func() {
+		if err := core.UnsealWithStoredKeys(); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if sealed, _ := core.Sealed(); sealed {
+			t.Fatalf("should be unsealed")
+		}
+	}
+	testTransitSealUnseal()
+
+	// Sealing and unsealing again only requires the transit Vault
+	if err := core.Seal(result.RootToken); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testTransitSealUnseal()
+
+	// The recovery key authorizes a rekey of the barrier
+	if err := core.RekeyInit(&vault.SealConfig{
+		SecretShares:    1,
+		SecretThreshold: 1,
+		StoredShares:    1,
+	}, false); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	required, err := core.RekeyThreshold(false)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if required != 2 {
+		t.Fatalf("bad: %d", required)
+	}
+	rkconf, err := core.RekeyConfig(false)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var rekeyResult *vault.RekeyResult
+	for _, key := range result.RecoveryShares[:2] {
+		rekeyResult, err = core.RekeyUpdate(key, rkconf.Nonce, false)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if rekeyResult == nil || len(rekeyResult.SecretShares) != 0 {
+		t.Fatalf("bad: %#v", rekeyResult)
+	}
+
+	// The new master key was stored through the seal
+	if err := core.Seal(result.RootToken); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testTransitSealUnseal()
+}
+
+func TestTransitSeal_migrate(t *testing.T) {
+	seal, ln := testTransitSeal(t)
+	defer ln.Close()
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	inm := physical.NewInmem(logger)
+	newCore := func(seal, oldSeal vault.Seal) *vault.Core {
+		core, err := vault.NewCore(&vault.CoreConfig{
+			Physical:     inm,
+			Seal:         seal,
+			OldSeal:      oldSeal,
+			DisableMlock: true,
+			Logger:       logger,
+		})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return core
+	}
+
+	// Start out with the default seal
+	core := newCore(nil, nil)
+	result, err := core.Initialize(&vault.SealConfig{
+		SecretShares:    3,
+		SecretThreshold: 2,
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Migrate to the transit seal with the unseal keys
+	core = newCore(seal, &vault.DefaultSeal{})
+	if !core.SealMigrationPending() {
+		t.Fatalf("should be pending migration")
+	}
+	if _, err := core.Unseal(result.SecretShares[0]); err == nil {
+		t.Fatalf("should require migration")
+	}
+	for i, key := range result.SecretShares[:2] {
+		unsealed, err := core.UnsealMigrate(key)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if unsealed != (i == 1) {
+			t.Fatalf("bad: %d %v", i, unsealed)
+		}
+	}
+	if core.SealMigrationPending() {
+		t.Fatalf("should not be pending migration")
+	}
+
+	// The transit seal unseals on its own, and the unseal keys are now the
+	// recovery keys
+	core = newCore(seal, &vault.DefaultSeal{})
+	if sealed, _ := core.Sealed(); sealed {
+		t.Fatalf("should be unsealed")
+	}
+	conf, err := core.SealAccess().BarrierConfig()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if conf.Type != "transit" || conf.StoredShares != 1 {
+		t.Fatalf("bad: %#v", conf)
+	}
+	conf, err = core.SealAccess().RecoveryConfig()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if conf.SecretShares != 3 || conf.SecretThreshold != 2 {
+		t.Fatalf("bad: %#v", conf)
+	}
+	if err := core.Seal(result.RootToken); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Migrate back to the default seal with the recovery keys
+	core = newCore(nil, seal)
+	if !core.SealMigrationPending() {
+		t.Fatalf("should be pending migration")
+	}
+	for i, key := range result.SecretShares[1:] {
+		unsealed, err := core.UnsealMigrate(key)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if unsealed != (i == 1) {
+			t.Fatalf("bad: %d %v", i, unsealed)
+		}
+	}
+
+	// The recovery keys are the unseal keys again
+	core = newCore(nil, seal)
+	if core.SealMigrationPending() {
+		t.Fatalf("should not be pending migration")
+	}
+	for i, key := range result.SecretShares[:2] {
+		unsealed, err := core.Unseal(key)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if unsealed != (i == 1) {
+			t.Fatalf("bad: %d %v", i, unsealed)
+		}
+	}
+}
+
+func TestTransitSeal_migrateInterrupted(t *testing.T) {
+	seal, ln := testTransitSeal(t)
+	defer ln.Close()
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	inm := physical.NewInmem(logger)
+	newCore := func(seal, oldSeal vault.Seal) *vault.Core {
+		core, err := vault.NewCore(&vault.CoreConfig{
+			Physical:     inm,
+			Seal:         seal,
+			OldSeal:      oldSeal,
+			DisableMlock: true,
+			Logger:       logger,
+		})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return core
+	}
+
+	core := newCore(nil, nil)
+	result, err := core.Initialize(&vault.SealConfig{
+		SecretShares:    3,
+		SecretThreshold: 2,
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	shamirConfig, err := inm.Get("core/seal-config")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	core = newCore(seal, &vault.DefaultSeal{})
+	for _, key := range result.SecretShares[:2] {
+		if _, err := core.UnsealMigrate(key); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if err := core.Seal(result.RootToken); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Interrupt the migration before the seal configuration is written, once
+	// the barrier has been rekeyed
+	if err := inm.Put(shamirConfig); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The barrier was rekeyed, so the shares no longer unseal without the
+	// transit seal
+	core = newCore(nil, nil)
+	var unsealErr error
+	for _, key := range result.SecretShares[:2] {
+		if _, unsealErr = core.Unseal(key); unsealErr != nil {
+			break
+		}
+	}
+	if unsealErr == nil {
+		t.Fatalf("should not unseal")
+	}
+
+	// Running the migration again completes it
+	core = newCore(seal, &vault.DefaultSeal{})
+	if !core.SealMigrationPending() {
+		t.Fatalf("should be pending migration")
+	}
+	for i, key := range result.SecretShares[1:] {
+		unsealed, err := core.UnsealMigrate(key)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if unsealed != (i == 1) {
+			t.Fatalf("bad: %d %v", i, unsealed)
+		}
+	}
+	if err := core.Seal(result.RootToken); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	core = newCore(seal, &vault.DefaultSeal{})
+	if sealed, _ := core.Sealed(); sealed {
+		t.Fatalf("should be unsealed")
+	}
+}
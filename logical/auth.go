@@ -34,12 +34,32 @@ type Auth struct {
 	// returned. Setting this manually will have no effect.
 	ClientToken string
 
+	// Alias identifies the authenticated user to the identity store, which
+	// resolves it to an entity. If unset, the token has no entity.
+	Alias *Alias
+
+	// GroupAliases identify the groups of the credential backend the user
+	// is a member of. They make the user a member of the external groups
+	// of the identity store with these aliases.
+	GroupAliases []*Alias
+
+	// EntityID is the entity the alias was resolved to. This will be filled
+	// in by Vault core when an alias is returned. Setting this manually
+	// will have no effect.
+	EntityID string
+
 	// Accessor is the identifier for the ClientToken. This can be used
 	// to perform management functionalities (especially revocation) when
 	// ClientToken in the audit logs are obfuscated. Accessor can be used
 	// to revoke a ClientToken and to lookup the capabilities of the ClientToken,
 	// both without actually knowing the ClientToken.
 	Accessor string
+
+	// TokenType is the type of the token to generate, either "service" or
+	// "batch". Batch tokens are never written to storage, and cannot be
+	// renewed, revoked on their own or create child tokens. If unset, the
+	// token type configured for the mount is used.
+	TokenType string
 }
 
 func (a *Auth) GoString() string {
@@ -0,0 +1,20 @@
+package logical
+
+import "fmt"
+
+// Alias identifies a user or a group to the credential backend that
+// authenticated them. Together with the accessor of the mount of the
+// backend, it ties a login to an entity or a group of the identity store.
+type Alias struct {
+	// Name is the unique identifier of the user or group within the
+	// credential backend, such as the username.
+	Name string
+
+	// Metadata is stored with the alias, to help operators tell aliases
+	// apart. It has no effect on authorization.
+	Metadata map[string]string
+}
+
+func (a *Alias) GoString() string {
+	return fmt.Sprintf("*%#v", *a)
+}
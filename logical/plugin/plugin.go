@@ -0,0 +1,399 @@
+// Package plugin runs logical backends as external processes, so they can
+// be built and shipped apart from Vault.
+//
+// Vault starts the plugin binary and hands it, through a response-wrapping
+// token, the TLS material of a mutually authenticated channel. The plugin
+// presents the token back to Vault over its stdout and reads the unwrapped
+// material from its stdin, then listens on the loopback interface and
+// serves its backend over JSON-RPC. Calls into the storage and system view
+// of the mount flow back to Vault over a second connection.
+//
+// The token is not unwrapped through the Vault API: plugins are started by
+// the first request to their mount, and an API request made while it is
+// being served could wait on a seal or step-down that waits on it in turn.
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// UnwrapTokenEnv holds the response-wrapping token the plugin unwraps
+	// its TLS material with
+	UnwrapTokenEnv = "VAULT_PLUGIN_UNWRAP_TOKEN"
+
+	// MetadataModeEnv is set when the plugin is only run to report its
+	// special paths, as when its mount is loaded while unsealing. No TLS
+	// material is handed out then.
+	MetadataModeEnv = "VAULT_PLUGIN_METADATA_MODE"
+
+	// handshakePrefix starts the line the plugin writes to its stdout to
+	// report back to Vault
+	handshakePrefix = "VAULT_PLUGIN|1|"
+
+	// serverName is the name in the certificate of the plugin
+	serverName = "vault-plugin"
+
+	// startTimeout bounds how long Vault waits on a plugin to report back
+	startTimeout = 30 * time.Second
+
+	// unwrapTTL is the TTL of the token carrying the TLS material
+	unwrapTTL = time.Minute
+)
+
+// The first byte Vault writes on a connection tells the plugin what the
+// connection is for
+const (
+	connBackend  byte = 'b'
+	connCallback byte = 'c'
+)
+
+// handshake is reported by the plugin on its stdout. A handshake holding
+// an unwrap token asks Vault to unwrap it, and is answered on the stdin of
+// the plugin with an unwrapReply.
+type handshake struct {
+	Addr        string         `json:"addr,omitempty"`
+	Paths       *logical.Paths `json:"paths,omitempty"`
+	UnwrapToken string         `json:"unwrap_token,omitempty"`
+}
+
+// unwrapReply answers the unwrap request of a plugin
+type unwrapReply struct {
+	Data  map[string]interface{} `json:"data,omitempty"`
+	Error string                 `json:"error,omitempty"`
+}
+
+// WrapFunc stores the given data behind a response-wrapping token with the
+// given TTL and returns the token
+type WrapFunc func(data map[string]interface{}, ttl time.Duration) (string, error)
+
+// UnwrapFunc returns the data behind the given response-wrapping token,
+// using the token up. It is called while the request starting the plugin
+// is being served.
+type UnwrapFunc func(token string) (map[string]interface{}, error)
+
+// Runner describes how Vault runs a plugin
+type Runner struct {
+	// Name is the name of the plugin, used in logs
+	Name string
+
+	// Command is the absolute path of the plugin binary, whose SHA-256
+	// sum must match Sha256. The binary is hashed and then run by its path,
+	// so the plugin directory must only be writable by trusted users: a
+	// binary replaced in between would be run unchecked.
+	Command string
+	Args    []string
+	Sha256  []byte
+
+	// Wrap creates the response-wrapping token handed to the plugin, and
+	// Unwrap unwraps it when the plugin presents it
+	Wrap   WrapFunc
+	Unwrap UnwrapFunc
+}
+
+// NewBackend returns the backend served by the plugin. The plugin is first
+// run in metadata mode for its special paths; the process serving requests
+// is only started when the backend is first used, and is restarted if it
+// goes away.
+func NewBackend(runner *Runner, conf *logical.BackendConfig) (logical.Backend, error) {
+	logger := conf.Logger
+	if logger == nil {
+		logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+
+	hs, cmd, err := runner.start(logger, []string{MetadataModeEnv + "=true"})
+	if err != nil {
+		return nil, err
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	paths := hs.Paths
+	if paths == nil {
+		paths = new(logical.Paths)
+	}
+	return &backend{
+		runner: runner,
+		conf:   conf,
+		logger: logger,
+		paths:  paths,
+	}, nil
+}
+
+// start verifies the plugin binary, runs it with the given environment, and
+// waits for it to report back
+func (r *Runner) start(logger *log.Logger, env []string) (*handshake, *exec.Cmd, error) {
+	buf, err := ioutil.ReadFile(r.Command)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read plugin %s: %v", r.Name, err)
+	}
+	sum := sha256.Sum256(buf)
+	if !bytes.Equal(sum[:], r.Sha256) {
+		return nil, nil, fmt.Errorf("SHA-256 mismatch for plugin %s", r.Name)
+	}
+
+	cmd := exec.Command(r.Command, r.Args...)
+	cmd.Env = env
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("failed to run plugin %s: %v", r.Name, err)
+	}
+
+	name := filepath.Base(r.Command)
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			logger.Printf("[INFO] plugin: %s: %s", name, scanner.Text())
+		}
+	}()
+
+	hsCh := make(chan *handshake, 1)
+	errCh := make(chan error, 1)
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, handshakePrefix) {
+				continue
+			}
+			hs := new(handshake)
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, handshakePrefix)), hs); err != nil {
+				errCh <- fmt.Errorf("invalid handshake from plugin %s: %v", r.Name, err)
+				return
+			}
+			if hs.UnwrapToken != "" {
+				if err := r.answerUnwrap(stdin, hs.UnwrapToken); err != nil {
+					errCh <- fmt.Errorf("failed to answer plugin %s: %v", r.Name, err)
+					return
+				}
+				continue
+			}
+			hsCh <- hs
+			io.Copy(ioutil.Discard, stdout)
+			return
+		}
+		errCh <- fmt.Errorf("plugin %s exited without a handshake", r.Name)
+	}()
+
+	select {
+	case hs := <-hsCh:
+		return hs, cmd, nil
+	case err = <-errCh:
+	case <-time.After(startTimeout):
+		err = fmt.Errorf("timed out waiting on plugin %s", r.Name)
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	// Wait for the handshake to be given up on, so that no unwrap is
+	// answered once the request starting the plugin is over
+	<-doneCh
+	return nil, nil, err
+}
+
+// answerUnwrap unwraps the token presented by the plugin and writes the
+// reply to its stdin. Tokens which cannot be unwrapped are answered with an
+// error.
+func (r *Runner) answerUnwrap(stdin io.Writer, token string) error {
+	reply := new(unwrapReply)
+	if r.Unwrap == nil {
+		reply.Error = "unwrapping is unavailable"
+	} else if data, err := r.Unwrap(token); err != nil {
+		reply.Error = err.Error()
+	} else {
+		reply.Data = data
+	}
+	return json.NewEncoder(stdin).Encode(reply)
+}
+
+// backend is the backend of a plugin, as seen by Vault
+type backend struct {
+	runner *Runner
+	conf   *logical.BackendConfig
+	logger *log.Logger
+	paths  *logical.Paths
+
+	l    sync.Mutex
+	proc *process
+}
+
+// process is a running plugin serving its backend
+type process struct {
+	cmd       *exec.Cmd
+	client    *rpc.Client
+	callbacks net.Conn
+}
+
+func (b *backend) HandleRequest(req *logical.Request) (*logical.Response, error) {
+	proc, err := b.process()
+	if err != nil {
+		return nil, err
+	}
+	var reply HandleRequestReply
+	if err := proc.client.Call("Plugin.HandleRequest", encodeRequest(req), &reply); err != nil {
+		b.lost(proc, err)
+		return nil, fmt.Errorf("plugin %s: %v", b.runner.Name, err)
+	}
+	return decodeResponse(&reply), reply.Error.err()
+}
+
+func (b *backend) HandleExistenceCheck(req *logical.Request) (bool, bool, error) {
+	proc, err := b.process()
+	if err != nil {
+		return false, false, err
+	}
+	var reply HandleExistenceCheckReply
+	if err := proc.client.Call("Plugin.HandleExistenceCheck", encodeRequest(req), &reply); err != nil {
+		b.lost(proc, err)
+		return false, false, fmt.Errorf("plugin %s: %v", b.runner.Name, err)
+	}
+	return reply.CheckFound, reply.Exists, reply.Error.err()
+}
+
+func (b *backend) SpecialPaths() *logical.Paths {
+	return b.paths
+}
+
+func (b *backend) System() logical.SystemView {
+	return b.conf.System
+}
+
+func (b *backend) Cleanup() {
+	b.l.Lock()
+	defer b.l.Unlock()
+	if b.proc == nil {
+		return
+	}
+	b.proc.client.Call("Plugin.Cleanup", struct{}{}, &struct{}{})
+	b.proc.stop()
+	b.proc = nil
+}
+
+// process returns the running plugin, starting it if needed
+func (b *backend) process() (*process, error) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	if b.proc != nil {
+		return b.proc, nil
+	}
+
+	proc, err := b.launch()
+	if err != nil {
+		return nil, err
+	}
+	b.proc = proc
+	return proc, nil
+}
+
+// lost drops the running plugin if the call failed because the connection
+// went away, so the next call starts it again
+func (b *backend) lost(proc *process, err error) {
+	if _, ok := err.(rpc.ServerError); ok {
+		return
+	}
+	b.l.Lock()
+	defer b.l.Unlock()
+	if b.proc == proc {
+		b.logger.Printf("[ERR] plugin: lost connection to plugin %s: %v", b.runner.Name, err)
+		proc.stop()
+		b.proc = nil
+	}
+}
+
+// launch starts the plugin with its TLS material, and connects to it
+func (b *backend) launch() (*process, error) {
+	tlsConfig, bundle, err := generateTLS()
+	if err != nil {
+		return nil, err
+	}
+	token, err := b.runner.Wrap(bundle.data(), unwrapTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap TLS material of plugin %s: %v", b.runner.Name, err)
+	}
+
+	hs, cmd, err := b.runner.start(b.logger, []string{
+		UnwrapTokenEnv + "=" + token,
+	})
+	if err != nil {
+		return nil, err
+	}
+	proc := &process{cmd: cmd}
+
+	callbacks, err := dial(hs.Addr, tlsConfig, connCallback)
+	if err != nil {
+		proc.stop()
+		return nil, fmt.Errorf("failed to connect to plugin %s: %v", b.runner.Name, err)
+	}
+	proc.callbacks = callbacks
+	server := rpc.NewServer()
+	server.RegisterName("Storage", &storageServer{storage: b.conf.StorageView})
+	server.RegisterName("System", &systemServer{system: b.conf.System})
+	go server.ServeCodec(jsonrpc.NewServerCodec(callbacks))
+
+	conn, err := dial(hs.Addr, tlsConfig, connBackend)
+	if err != nil {
+		proc.stop()
+		return nil, fmt.Errorf("failed to connect to plugin %s: %v", b.runner.Name, err)
+	}
+	proc.client = jsonrpc.NewClient(conn)
+
+	b.logger.Printf("[INFO] plugin: started plugin %s", b.runner.Name)
+	return proc, nil
+}
+
+// dial connects to the plugin and tells it what the connection is for
+func dial(addr string, tlsConfig *tls.Config, kind byte) (net.Conn, error) {
+	conn, err := tls.Dial("tcp", addr, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write([]byte{kind}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// stop closes the connections to the plugin and kills it
+func (p *process) stop() {
+	if p.client != nil {
+		p.client.Close()
+	}
+	if p.callbacks != nil {
+		p.callbacks.Close()
+	}
+	p.cmd.Process.Kill()
+	p.cmd.Wait()
+}
@@ -0,0 +1,240 @@
+package plugin
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// TestPlugin_Serve is not a real test: it is the plugin run by the other
+// tests, which re-execute the test binary.
+func TestPlugin_Serve(t *testing.T) {
+	if os.Getenv(MetadataModeEnv) == "" && os.Getenv(UnwrapTokenEnv) == "" {
+		return
+	}
+	if err := Serve(testFactory); err != nil {
+		fmt.Fprintf(os.Stderr, "serve failed: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func testFactory(conf *logical.BackendConfig) (logical.Backend, error) {
+	b := &framework.Backend{
+		PathsSpecial: &logical.Paths{
+			Root: []string{"root/*"},
+		},
+		Paths: []*framework.Path{
+			&framework.Path{
+				Pattern: "kv/(?P<key>.+)",
+				Fields: map[string]*framework.FieldSchema{
+					"key":   &framework.FieldSchema{Type: framework.TypeString},
+					"value": &framework.FieldSchema{Type: framework.TypeString},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+						entry, err := req.Storage.Get(data.Get("key").(string))
+						if err != nil {
+							return nil, err
+						}
+						if entry == nil {
+							return nil, nil
+						}
+						resp := &logical.Response{
+							Data: map[string]interface{}{
+								"value": string(entry.Value),
+							},
+						}
+						resp.AddWarning("read from plugin")
+						return resp, nil
+					},
+					logical.UpdateOperation: func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+						return nil, req.Storage.Put(&logical.StorageEntry{
+							Key:   data.Get("key").(string),
+							Value: []byte(data.Get("value").(string)),
+						})
+					},
+					logical.DeleteOperation: func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+						return nil, logical.ErrPermissionDenied
+					},
+				},
+			},
+		},
+	}
+	return b.Setup(conf)
+}
+
+// testRunner returns a runner of the test binary as a plugin, whose TLS
+// material is wrapped in memory
+func testRunner(t *testing.T) *Runner {
+	buf, err := ioutil.ReadFile(os.Args[0])
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sum := sha256.Sum256(buf)
+
+	var l sync.Mutex
+	wrapped := make(map[string]map[string]interface{})
+	return &Runner{
+		Name:    "test",
+		Command: os.Args[0],
+		Args:    []string{"-test.run=TestPlugin_Serve"},
+		Sha256:  sum[:],
+		Wrap: func(data map[string]interface{}, ttl time.Duration) (string, error) {
+			l.Lock()
+			defer l.Unlock()
+			token := fmt.Sprintf("token-%d", len(wrapped))
+			wrapped[token] = data
+			return token, nil
+		},
+		Unwrap: func(token string) (map[string]interface{}, error) {
+			l.Lock()
+			defer l.Unlock()
+			data, ok := wrapped[token]
+			if !ok {
+				return nil, fmt.Errorf("invalid token")
+			}
+			delete(wrapped, token)
+			return data, nil
+		},
+	}
+}
+
+func TestPlugin_Backend(t *testing.T) {
+	runner := testRunner(t)
+
+	storage := new(logical.InmemStorage)
+	b, err := NewBackend(runner, &logical.BackendConfig{
+		StorageView: storage,
+		System:      logical.TestSystemView(),
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer b.Cleanup()
+
+	paths := b.SpecialPaths()
+	if len(paths.Root) != 1 || paths.Root[0] != "root/*" {
+		t.Fatalf("bad: %#v", paths)
+	}
+
+	// Writes go through to the storage of the mount
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "kv/foo",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"value": "bar",
+		},
+	}
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	entry, err := storage.Get("foo")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if entry == nil || string(entry.Value) != "bar" {
+		t.Fatalf("bad: %#v", entry)
+	}
+
+	req = &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "kv/foo",
+		Storage:   storage,
+	}
+	resp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Data["value"] != "bar" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if warnings := resp.Warnings(); len(warnings) != 1 || warnings[0] != "read from plugin" {
+		t.Fatalf("bad: %#v", warnings)
+	}
+
+	// Errors of the logical package are passed through as-is
+	req = &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "kv/foo",
+		Storage:   storage,
+	}
+	if _, err := b.HandleRequest(req); err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v", err)
+	}
+	req = &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "nope",
+		Storage:   storage,
+	}
+	if _, err := b.HandleRequest(req); err != logical.ErrUnsupportedPath {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The plugin is started again if it goes away
+	b.(*backend).l.Lock()
+	b.(*backend).proc.cmd.Process.Kill()
+	b.(*backend).l.Unlock()
+	req = &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "kv/foo",
+		Storage:   storage,
+	}
+	if _, err := b.HandleRequest(req); err == nil {
+		t.Fatalf("expected error")
+	}
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Data["value"] != "bar" {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
+func TestPlugin_Backend_Sha256(t *testing.T) {
+	runner := testRunner(t)
+
+	runner.Sha256 = make([]byte, sha256.Size)
+	_, err := NewBackend(runner, &logical.BackendConfig{
+		StorageView: new(logical.InmemStorage),
+		System:      logical.TestSystemView(),
+	})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestPlugin_Backend_unwrapFailure(t *testing.T) {
+	runner := testRunner(t)
+	runner.Unwrap = func(token string) (map[string]interface{}, error) {
+		return nil, fmt.Errorf("token already used")
+	}
+
+	b, err := NewBackend(runner, &logical.BackendConfig{
+		StorageView: new(logical.InmemStorage),
+		System:      logical.TestSystemView(),
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer b.Cleanup()
+
+	// The plugin gives up without its TLS material
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "kv/foo",
+		Storage:   new(logical.InmemStorage),
+	}
+	if _, err := b.HandleRequest(req); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,323 @@
+package plugin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/rpc"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// The plugin serves its backend over one connection, and calls back into
+// Vault for storage and system information over another. Both carry
+// JSON-RPC.
+
+// HandleRequestArgs is a request handed to the backend of a plugin. The
+// TLS connection state of the client cannot be encoded, so only its peer
+// certificates are carried.
+type HandleRequestArgs struct {
+	Request          *logical.Request
+	PeerCertificates [][]byte
+}
+
+// HandleRequestReply is the response of the backend of a plugin to a
+// request. The warnings of the response are not exported, so they are
+// carried apart.
+type HandleRequestReply struct {
+	Response *logical.Response
+	Warnings []string
+	Error    *RPCError
+}
+
+// HandleExistenceCheckReply is the result of an existence check by the
+// backend of a plugin
+type HandleExistenceCheckReply struct {
+	CheckFound bool
+	Exists     bool
+	Error      *RPCError
+}
+
+// StorageGetReply is the entry read from storage, nil if there is none
+type StorageGetReply struct {
+	Entry *logical.StorageEntry
+}
+
+// SudoPrivilegeArgs are the arguments of a sudo privilege check
+type SudoPrivilegeArgs struct {
+	Path  string
+	Token string
+}
+
+// RPCError is an error returned by a backend across the connection. The
+// errors defined by the logical package are matched back by message so
+// Vault can still tell them apart, and coded errors keep their code.
+type RPCError struct {
+	Message string
+	Code    int
+}
+
+// knownErrors are the errors which are returned as-is once they cross
+// the connection
+var knownErrors = []error{
+	logical.ErrUnsupportedOperation,
+	logical.ErrUnsupportedPath,
+	logical.ErrInvalidRequest,
+	logical.ErrPermissionDenied,
+}
+
+func newRPCError(err error) *RPCError {
+	if err == nil {
+		return nil
+	}
+	e := &RPCError{Message: err.Error()}
+	if coded, ok := err.(logical.HTTPCodedError); ok {
+		e.Code = coded.Code()
+	}
+	return e
+}
+
+func (e *RPCError) err() error {
+	if e == nil {
+		return nil
+	}
+	for _, known := range knownErrors {
+		if e.Message == known.Error() {
+			return known
+		}
+	}
+	if e.Code != 0 {
+		return logical.CodedError(e.Code, e.Message)
+	}
+	return errors.New(e.Message)
+}
+
+// encodeRequest strips the request of what cannot cross the connection:
+// the storage, which the plugin reaches through its own client, and the
+// TLS connection state.
+func encodeRequest(req *logical.Request) *HandleRequestArgs {
+	r := *req
+	r.Storage = nil
+	args := &HandleRequestArgs{Request: &r}
+	if req.Connection != nil {
+		conn := *req.Connection
+		if conn.ConnState != nil {
+			for _, cert := range conn.ConnState.PeerCertificates {
+				args.PeerCertificates = append(args.PeerCertificates, cert.Raw)
+			}
+		}
+		conn.ConnState = nil
+		r.Connection = &conn
+	}
+	return args
+}
+
+// decodeRequest rebuilds the request handed to the backend of a plugin
+func decodeRequest(args *HandleRequestArgs, storage logical.Storage) (*logical.Request, error) {
+	req := args.Request
+	if req == nil {
+		req = new(logical.Request)
+	}
+	req.Storage = storage
+	if len(args.PeerCertificates) > 0 {
+		state := new(tls.ConnectionState)
+		for _, raw := range args.PeerCertificates {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return nil, err
+			}
+			state.PeerCertificates = append(state.PeerCertificates, cert)
+		}
+		if req.Connection == nil {
+			req.Connection = new(logical.Connection)
+		}
+		req.Connection.ConnState = state
+	}
+	return req, nil
+}
+
+// decodeResponse rebuilds the response of the backend of a plugin
+func decodeResponse(reply *HandleRequestReply) *logical.Response {
+	resp := reply.Response
+	if resp == nil {
+		return nil
+	}
+	for _, warning := range reply.Warnings {
+		resp.AddWarning(warning)
+	}
+
+	// Raw HTTP responses lose their types in JSON
+	if resp.Data != nil {
+		if body, ok := resp.Data[logical.HTTPRawBody].(string); ok {
+			if raw, err := base64.StdEncoding.DecodeString(body); err == nil {
+				resp.Data[logical.HTTPRawBody] = raw
+			}
+		}
+		if code, ok := resp.Data[logical.HTTPStatusCode].(float64); ok {
+			resp.Data[logical.HTTPStatusCode] = int(code)
+		}
+	}
+	return resp
+}
+
+// backendServer serves the backend of a plugin to Vault
+type backendServer struct {
+	backend logical.Backend
+	storage logical.Storage
+}
+
+func (s *backendServer) HandleRequest(args *HandleRequestArgs, reply *HandleRequestReply) error {
+	req, err := decodeRequest(args, s.storage)
+	if err != nil {
+		reply.Error = newRPCError(err)
+		return nil
+	}
+	resp, err := s.backend.HandleRequest(req)
+	reply.Response = resp
+	if resp != nil {
+		reply.Warnings = resp.Warnings()
+	}
+	reply.Error = newRPCError(err)
+	return nil
+}
+
+func (s *backendServer) HandleExistenceCheck(args *HandleRequestArgs, reply *HandleExistenceCheckReply) error {
+	req, err := decodeRequest(args, s.storage)
+	if err != nil {
+		reply.Error = newRPCError(err)
+		return nil
+	}
+	checkFound, exists, err := s.backend.HandleExistenceCheck(req)
+	reply.CheckFound = checkFound
+	reply.Exists = exists
+	reply.Error = newRPCError(err)
+	return nil
+}
+
+func (s *backendServer) SpecialPaths(_ struct{}, reply *logical.Paths) error {
+	if paths := s.backend.SpecialPaths(); paths != nil {
+		*reply = *paths
+	}
+	return nil
+}
+
+func (s *backendServer) Cleanup(_ struct{}, _ *struct{}) error {
+	s.backend.Cleanup()
+	return nil
+}
+
+// storageServer serves the storage of the mount to the plugin
+type storageServer struct {
+	storage logical.Storage
+}
+
+func (s *storageServer) List(prefix string, reply *[]string) error {
+	keys, err := s.storage.List(prefix)
+	*reply = keys
+	return err
+}
+
+func (s *storageServer) Get(key string, reply *StorageGetReply) error {
+	entry, err := s.storage.Get(key)
+	reply.Entry = entry
+	return err
+}
+
+func (s *storageServer) Put(entry *logical.StorageEntry, _ *struct{}) error {
+	return s.storage.Put(entry)
+}
+
+func (s *storageServer) Delete(key string, _ *struct{}) error {
+	return s.storage.Delete(key)
+}
+
+// storageClient is the storage of the mount, as seen by the plugin
+type storageClient struct {
+	client *rpc.Client
+}
+
+func (s *storageClient) List(prefix string) ([]string, error) {
+	var keys []string
+	err := s.client.Call("Storage.List", prefix, &keys)
+	return keys, err
+}
+
+func (s *storageClient) Get(key string) (*logical.StorageEntry, error) {
+	var reply StorageGetReply
+	err := s.client.Call("Storage.Get", key, &reply)
+	return reply.Entry, err
+}
+
+func (s *storageClient) Put(entry *logical.StorageEntry) error {
+	return s.client.Call("Storage.Put", entry, &struct{}{})
+}
+
+func (s *storageClient) Delete(key string) error {
+	return s.client.Call("Storage.Delete", key, &struct{}{})
+}
+
+// systemServer serves the system view of the mount to the plugin
+type systemServer struct {
+	system logical.SystemView
+}
+
+func (s *systemServer) DefaultLeaseTTL(_ struct{}, reply *time.Duration) error {
+	*reply = s.system.DefaultLeaseTTL()
+	return nil
+}
+
+func (s *systemServer) MaxLeaseTTL(_ struct{}, reply *time.Duration) error {
+	*reply = s.system.MaxLeaseTTL()
+	return nil
+}
+
+func (s *systemServer) SudoPrivilege(args *SudoPrivilegeArgs, reply *bool) error {
+	*reply = s.system.SudoPrivilege(args.Path, args.Token)
+	return nil
+}
+
+func (s *systemServer) Tainted(_ struct{}, reply *bool) error {
+	*reply = s.system.Tainted()
+	return nil
+}
+
+// systemClient is the system view of the mount, as seen by the plugin.
+// The view cannot return errors, so failed calls are logged and return
+// the zero value, which never grants sudo privileges.
+type systemClient struct {
+	client *rpc.Client
+	logger *log.Logger
+}
+
+func (s *systemClient) DefaultLeaseTTL() time.Duration {
+	var ttl time.Duration
+	s.call("System.DefaultLeaseTTL", struct{}{}, &ttl)
+	return ttl
+}
+
+func (s *systemClient) MaxLeaseTTL() time.Duration {
+	var ttl time.Duration
+	s.call("System.MaxLeaseTTL", struct{}{}, &ttl)
+	return ttl
+}
+
+func (s *systemClient) SudoPrivilege(path string, token string) bool {
+	var sudo bool
+	s.call("System.SudoPrivilege", &SudoPrivilegeArgs{Path: path, Token: token}, &sudo)
+	return sudo
+}
+
+func (s *systemClient) Tainted() bool {
+	var tainted bool
+	s.call("System.Tainted", struct{}{}, &tainted)
+	return tainted
+}
+
+func (s *systemClient) call(method string, args interface{}, reply interface{}) {
+	if err := s.client.Call(method, args, reply); err != nil {
+		s.logger.Printf("[ERR] plugin: %s failed: %v", method, err)
+	}
+}
@@ -0,0 +1,163 @@
+package plugin
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// errMetadataMode is returned by the storage of a plugin run in metadata
+// mode
+var errMetadataMode = errors.New("storage is unavailable in metadata mode")
+
+// Serve serves the backend built by the factory to Vault. It is meant to
+// be called from the main function of a plugin binary run by Vault, and
+// returns once Vault closes the connection.
+func Serve(factory logical.Factory) error {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	if os.Getenv(MetadataModeEnv) == "true" {
+		return serveMetadata(factory, logger)
+	}
+
+	bundle, err := unwrapTLS(os.Stdout, os.Stdin, os.Getenv(UnwrapTokenEnv))
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := bundle.serverConfig()
+	if err != nil {
+		return err
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	if err := writeHandshake(os.Stdout, &handshake{Addr: ln.Addr().String()}); err != nil {
+		return err
+	}
+
+	// Wait on Vault to connect, dropping anyone without its certificate
+	var backendConn, callbackConn net.Conn
+	for backendConn == nil || callbackConn == nil {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		kind := make([]byte, 1)
+		if _, err := io.ReadFull(conn, kind); err != nil {
+			logger.Printf("[WARN] plugin: dropping connection from %s: %v", conn.RemoteAddr(), err)
+			conn.Close()
+			continue
+		}
+		switch {
+		case kind[0] == connBackend && backendConn == nil:
+			backendConn = conn
+		case kind[0] == connCallback && callbackConn == nil:
+			callbackConn = conn
+		default:
+			conn.Close()
+		}
+	}
+	ln.Close()
+
+	return serveConns(factory, backendConn, callbackConn, logger)
+}
+
+// serveConns serves the backend over the given connection, with the
+// storage and system view reached over the callback connection
+func serveConns(factory logical.Factory, backendConn, callbackConn io.ReadWriteCloser, logger *log.Logger) error {
+	callbacks := jsonrpc.NewClient(callbackConn)
+	defer callbacks.Close()
+
+	storage := &storageClient{client: callbacks}
+	b, err := factory(&logical.BackendConfig{
+		StorageView: storage,
+		Logger:      logger,
+		System:      &systemClient{client: callbacks, logger: logger},
+	})
+	if err != nil {
+		backendConn.Close()
+		return err
+	}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("Plugin", &backendServer{backend: b, storage: storage}); err != nil {
+		backendConn.Close()
+		return err
+	}
+	server.ServeCodec(jsonrpc.NewServerCodec(backendConn))
+	return nil
+}
+
+// serveMetadata reports the special paths of the backend
+func serveMetadata(factory logical.Factory, logger *log.Logger) error {
+	b, err := factory(&logical.BackendConfig{
+		StorageView: metadataStorage{},
+		Logger:      logger,
+		System:      logical.StaticSystemView{},
+	})
+	if err != nil {
+		return err
+	}
+	defer b.Cleanup()
+	return writeHandshake(os.Stdout, &handshake{Paths: b.SpecialPaths()})
+}
+
+func writeHandshake(w io.Writer, hs *handshake) error {
+	buf, err := json.Marshal(hs)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", handshakePrefix, buf)
+	return err
+}
+
+// unwrapTLS presents the response-wrapping token to Vault and reads back
+// the TLS material of the plugin
+func unwrapTLS(w io.Writer, r io.Reader, token string) (*tlsBundle, error) {
+	if token == "" {
+		return nil, fmt.Errorf("plugin must be run by Vault")
+	}
+	if err := writeHandshake(w, &handshake{UnwrapToken: token}); err != nil {
+		return nil, err
+	}
+
+	line, err := bufio.NewReader(r).ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TLS material: %v", err)
+	}
+	var reply struct {
+		Data  *tlsBundle `json:"data"`
+		Error string     `json:"error"`
+	}
+	if err := json.Unmarshal(line, &reply); err != nil {
+		return nil, fmt.Errorf("failed to decode TLS material: %v", err)
+	}
+	if reply.Error != "" {
+		return nil, fmt.Errorf("failed to unwrap TLS material: %s", reply.Error)
+	}
+	if reply.Data == nil {
+		return nil, fmt.Errorf("no TLS material in wrapped response")
+	}
+	return reply.Data, nil
+}
+
+// metadataStorage is the storage of a plugin run in metadata mode
+type metadataStorage struct{}
+
+func (metadataStorage) List(string) ([]string, error)             { return nil, errMetadataMode }
+func (metadataStorage) Get(string) (*logical.StorageEntry, error) { return nil, errMetadataMode }
+func (metadataStorage) Put(*logical.StorageEntry) error           { return errMetadataMode }
+func (metadataStorage) Delete(string) error                       { return errMetadataMode }
@@ -0,0 +1,133 @@
+package plugin
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	mathrand "math/rand"
+	"time"
+)
+
+// tlsBundle is the TLS material handed to a plugin through a
+// response-wrapping token: the certificate the plugin serves with, and the
+// certificate Vault presents as the client, which the plugin pins.
+type tlsBundle struct {
+	Certificate       string `json:"certificate"`
+	PrivateKey        string `json:"private_key"`
+	ClientCertificate string `json:"client_certificate"`
+}
+
+// data returns the bundle as the data of a response, to be wrapped
+func (b *tlsBundle) data() map[string]interface{} {
+	return map[string]interface{}{
+		"certificate":        b.Certificate,
+		"private_key":        b.PrivateKey,
+		"client_certificate": b.ClientCertificate,
+	}
+}
+
+// serverConfig returns the TLS configuration of the plugin, which only
+// accepts connections from the holder of the client certificate
+func (b *tlsBundle) serverConfig() (*tls.Config, error) {
+	cert, err := tls.X509KeyPair([]byte(b.Certificate), []byte(b.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plugin certificate: %v", err)
+	}
+
+	block, _ := pem.Decode([]byte(b.ClientCertificate))
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode client certificate")
+	}
+	clientCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse client certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(clientCert)
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// generateTLS creates a certificate for the plugin and one for Vault as its
+// client. It returns the TLS configuration Vault dials the plugin with,
+// which only trusts the certificate of the plugin, and the bundle to hand
+// to the plugin.
+func generateTLS() (*tls.Config, *tlsBundle, error) {
+	serverCert, serverKey, err := generateCert(serverName, x509.ExtKeyUsageServerAuth)
+	if err != nil {
+		return nil, nil, err
+	}
+	clientCert, clientKey, err := generateCert("vault", x509.ExtKeyUsageClientAuth)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parsed, err := x509.ParseCertificate(serverCert)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse plugin certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+
+	serverKeyBytes, err := x509.MarshalECPrivateKey(serverKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode plugin key: %v", err)
+	}
+	bundle := &tlsBundle{
+		Certificate:       string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: serverCert})),
+		PrivateKey:        string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: serverKeyBytes})),
+		ClientCertificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientCert})),
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{
+			tls.Certificate{
+				Certificate: [][]byte{clientCert},
+				PrivateKey:  clientKey,
+			},
+		},
+		RootCAs:    pool,
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+	return config, bundle, nil
+}
+
+// generateCert creates a self-signed certificate for the given name
+func generateCert(name string, usage x509.ExtKeyUsage) ([]byte, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName: name,
+		},
+		DNSNames:              []string{name},
+		ExtKeyUsage:           []x509.ExtKeyUsage{usage},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageKeyAgreement | x509.KeyUsageCertSign,
+		SerialNumber:          big.NewInt(mathrand.Int63()),
+		NotBefore:             time.Now().Add(-30 * time.Second),
+		NotAfter:              time.Now().Add(262980 * time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate certificate: %v", err)
+	}
+	return certBytes, key, nil
+}
@@ -3,6 +3,7 @@ package logical
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 // Request is a struct that stores the parameters and context
@@ -52,6 +53,11 @@ type Request struct {
 	// paths relative to itself. The `Path` is effectively the client
 	// request path with the MountPoint trimmed off.
 	MountPoint string
+
+	// WrapTTL, if non-zero, requests that the response be wrapped in the
+	// cubbyhole of a single-use token with the given TTL rather than being
+	// returned directly to the client.
+	WrapTTL time.Duration
 }
 
 // Get returns a data field and guards for nil Data
@@ -3,6 +3,7 @@ package logical
 import (
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/mitchellh/copystructure"
 )
@@ -26,6 +27,26 @@ const (
 	HTTPStatusCode = "http_status_code"
 )
 
+// WrapInfo contains information about a response that has been wrapped
+// in the cubbyhole of a single-use token.
+type WrapInfo struct {
+	// Token is the single-use token that can be used to unwrap the response
+	Token string
+
+	// TTL is the TTL of the wrapping token
+	TTL time.Duration
+
+	// CreationTime is the time the wrapping token was created
+	CreationTime time.Time
+
+	// Accessor is the accessor of the wrapping token
+	Accessor string
+
+	// WrappedAccessor is the accessor of the wrapped token, if the wrapped
+	// response contained authentication information
+	WrappedAccessor string
+}
+
 // Response is a struct that stores the response of a request.
 // It is used to abstract the details of the higher level request protocol.
 type Response struct {
@@ -48,6 +69,11 @@ type Response struct {
 	// for any logical backend and ignored.
 	Redirect string
 
+	// WrapInfo, if not nil, means that the original response has been
+	// stored in the cubbyhole of a single-use token and only the
+	// information about that token is returned to the client.
+	WrapInfo *WrapInfo
+
 	// Warnings allow operations or backends to return warnings in response
 	// to user actions without failing the action outright.
 	// Making it private helps ensure that it is easy for various parts of
@@ -87,6 +113,11 @@ func init() {
 			ret.Data = retData.(map[string]interface{})
 		}
 
+		if input.WrapInfo != nil {
+			retWrapInfo := *input.WrapInfo
+			ret.WrapInfo = &retWrapInfo
+		}
+
 		if input.Warnings() != nil {
 			for _, warning := range input.Warnings() {
 				ret.AddWarning(warning)
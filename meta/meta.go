@@ -47,6 +47,7 @@ type Meta struct {
 	flagClientCert string
 	flagClientKey  string
 	flagInsecure   bool
+	flagWrapTTL    string
 
 	// Queried if no token can be found
 	TokenHelper TokenHelperFunc
@@ -137,6 +138,17 @@ func (m *Meta) Client() (*api.Client, error) {
 		client.SetToken(token)
 	}
 
+	// If a response-wrapping TTL was requested, apply it to every request
+	wrapTTL := os.Getenv("VAULT_WRAP_TTL")
+	if m.flagWrapTTL != "" {
+		wrapTTL = m.flagWrapTTL
+	}
+	if wrapTTL != "" {
+		client.SetWrappingLookupFunc(func(operation, path string) string {
+			return wrapTTL
+		})
+	}
+
 	return client, nil
 }
 
@@ -157,6 +169,7 @@ func (m *Meta) FlagSet(n string, fs FlagSetFlags) *flag.FlagSet {
 		f.StringVar(&m.flagClientKey, "client-key", "", "")
 		f.BoolVar(&m.flagInsecure, "insecure", false, "")
 		f.BoolVar(&m.flagInsecure, "tls-skip-verify", false, "")
+		f.StringVar(&m.flagWrapTTL, "wrap-ttl", "", "")
 	}
 
 	// Create an io.Writer that writes to our Ui properly for errors.
@@ -271,6 +284,15 @@ func GeneralOptionsUsage() string {
   -tls-skip-verify        Do not verify TLS certificate. This is highly
                           not recommended. Verification will also be skipped
                           if VAULT_SKIP_VERIFY is set.
+
+  -wrap-ttl=""            Indicates that the response should be wrapped in a
+                          cubbyhole token with the requested TTL. The response
+                          can be fetched by calling the "sys/wrapping/unwrap"
+                          endpoint, passing in the wrapping token's ID. This
+                          is a numeric string with an optional suffix
+                          "s", "m", or "h"; if no suffix is specified it will
+                          be parsed as seconds. May also be specified via
+                          VAULT_WRAP_TTL.
 `
 	return general
 }
@@ -65,6 +65,22 @@ type ServiceDiscovery interface {
 	RunServiceDiscovery(shutdownCh ShutdownChannel, advertiseAddr string) error
 }
 
+// PeerManager is an optional interface that an HABackend can implement
+// if it manages the membership of its own cluster, as the raft backend does.
+type PeerManager interface {
+	// Peers returns the addresses of the members of the cluster
+	Peers() ([]string, error)
+
+	// Leader returns the address of the current cluster leader, if known
+	Leader() string
+
+	// AddPeer adds the node at the given address to the cluster
+	AddPeer(addr string) error
+
+	// RemovePeer removes the node at the given address from the cluster
+	RemovePeer(addr string) error
+}
+
 type Lock interface {
 	// Lock is used to acquire the given lock
 	// The stopCh is optional and if closed should interrupt the lock
@@ -113,6 +129,7 @@ var builtinBackends = map[string]Factory{
 	"etcd":       newEtcdBackend,
 	"mysql":      newMySQLBackend,
 	"postgresql": newPostgreSQLBackend,
+	"raft":       newRaftBackend,
 }
 
 // PermitPool is a wrapper around a semaphore library to keep things
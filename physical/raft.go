@@ -0,0 +1,1023 @@
+package physical
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/boltdb/bolt"
+	"github.com/hashicorp/raft"
+)
+
+const (
+	// defaultRaftAddress is the address the raft transport binds to if none
+	// is given in the configuration
+	defaultRaftAddress = "127.0.0.1:8202"
+
+	// raftApplyTimeout bounds how long a write waits to be committed
+	raftApplyTimeout = 10 * time.Second
+
+	// raftLockHoldoff is how long a leader which released the lock while
+	// other nodes are in the cluster waits before acquiring it again
+	raftLockHoldoff = 30 * time.Second
+
+	// raftTransportTimeout is the I/O deadline for raft RPCs
+	raftTransportTimeout = 10 * time.Second
+
+	// raftTransportMaxPool is the number of connections pooled per peer
+	raftTransportMaxPool = 3
+
+	// raftSnapshotsRetained is the number of FSM snapshots kept on disk
+	raftSnapshotsRetained = 2
+
+	// defaultDeadServerThreshold is how long a peer may be unreachable
+	// before the dead server cleanup removes it from the cluster
+	defaultDeadServerThreshold = 24 * time.Hour
+
+	// minDeadServerThreshold is the lowest accepted dead server threshold
+	minDeadServerThreshold = time.Minute
+
+	// defaultMinQuorum is the number of peers below which the dead server
+	// cleanup never removes a peer
+	defaultMinQuorum = 3
+
+	// autopilotInterval is how often the leader checks the health of peers
+	autopilotInterval = 10 * time.Second
+
+	// peerProbeTimeout bounds the health check connection to a peer
+	peerProbeTimeout = 2 * time.Second
+)
+
+var (
+	// Bucket names used in the bolt database
+	raftLogsBucket = []byte("logs")
+	raftConfBucket = []byte("conf")
+	raftDataBucket = []byte("data")
+
+	// ErrRaftNotLeader is returned when a write is attempted on a node that
+	// is not the raft leader. Only the active Vault node should write.
+	ErrRaftNotLeader = errors.New("raft: node is not the leader")
+
+	// errRaftKeyNotFound mirrors the error expected by raft from the
+	// StableStore when a key is missing
+	errRaftKeyNotFound = errors.New("not found")
+)
+
+// raftOp is a single operation replicated through the raft log
+type raftOp struct {
+	Op    string `json:"op"`
+	Key   string `json:"key"`
+	Value []byte `json:"value,omitempty"`
+}
+
+const (
+	raftOpPut    = "put"
+	raftOpDelete = "delete"
+)
+
+// RaftBackend is a physical backend that replicates data between Vault
+// nodes using the raft consensus protocol. Data is stored locally in a bolt
+// database, so no external storage system is required. It implements
+// HABackend: the raft leader is the only node able to hold the lock.
+type RaftBackend struct {
+	path    string
+	logger  *log.Logger
+	db      *bolt.DB
+	raft    *raft.Raft
+	trans   *raft.NetworkTransport
+	stream  *raftStreamLayer
+	peers   raft.PeerStore
+	localID string
+
+	leaderLock     sync.RWMutex
+	isLeader       bool
+	leaderChangeCh chan struct{}
+
+	lockHoldoff      time.Duration
+	lockHoldoffUntil time.Time
+	lockHoldoffLock  sync.Mutex
+
+	cleanupDeadServers  bool
+	deadServerThreshold time.Duration
+	minQuorum           int
+	peerLastContact     map[string]time.Time
+	peerLastContactLock sync.Mutex
+
+	shutdownCh   chan struct{}
+	shutdownLock sync.Mutex
+	shutdown     bool
+}
+
+// newRaftBackend constructs a raft backend using the given configuration
+func newRaftBackend(conf map[string]string, logger *log.Logger) (Backend, error) {
+	path, ok := conf["path"]
+	if !ok {
+		return nil, fmt.Errorf("'path' must be set")
+	}
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create raft directory: %v", err)
+	}
+
+	addr, ok := conf["address"]
+	if !ok {
+		addr = defaultRaftAddress
+	}
+
+	var advertise net.Addr
+	if advertiseAddr, ok := conf["advertise_address"]; ok {
+		tcpAddr, err := net.ResolveTCPAddr("tcp", advertiseAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse advertise_address: %v", err)
+		}
+		advertise = tcpAddr
+	}
+
+	bootstrap := false
+	if bootstrapRaw, ok := conf["bootstrap"]; ok {
+		var err error
+		bootstrap, err = strconv.ParseBool(bootstrapRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing bootstrap parameter: %v", err)
+		}
+	}
+
+	cleanupDeadServers := false
+	if cleanupRaw, ok := conf["autopilot_cleanup_dead_servers"]; ok {
+		var err error
+		cleanupDeadServers, err = strconv.ParseBool(cleanupRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing autopilot_cleanup_dead_servers parameter: %v", err)
+		}
+	}
+
+	deadServerThreshold := defaultDeadServerThreshold
+	if thresholdRaw, ok := conf["autopilot_dead_server_threshold"]; ok {
+		var err error
+		deadServerThreshold, err = time.ParseDuration(thresholdRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing autopilot_dead_server_threshold parameter: %v", err)
+		}
+		if deadServerThreshold < minDeadServerThreshold {
+			return nil, fmt.Errorf("autopilot_dead_server_threshold must be at least %s", minDeadServerThreshold)
+		}
+	}
+
+	minQuorum := defaultMinQuorum
+	if minQuorumRaw, ok := conf["autopilot_min_quorum"]; ok {
+		var err error
+		minQuorum, err = strconv.Atoi(minQuorumRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing autopilot_min_quorum parameter: %v", err)
+		}
+		if minQuorum < 1 {
+			return nil, fmt.Errorf("autopilot_min_quorum must be at least 1")
+		}
+	}
+
+	// Peers authenticate each other with certificates signed by the CA
+	tlsConfig, err := raftTLSConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open the local database holding the raft log, the raft metadata and
+	// the replicated data itself
+	db, err := bolt.Open(filepath.Join(path, "raft.db"), 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open raft database: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{raftLogsBucket, raftConfBucket, raftDataBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize raft database: %v", err)
+	}
+
+	snaps, err := raft.NewFileSnapshotStoreWithLogger(path, raftSnapshotsRetained, logger)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create raft snapshot store: %v", err)
+	}
+
+	stream, err := newRaftStreamLayer(addr, advertise, tlsConfig)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create raft transport: %v", err)
+	}
+	trans := raft.NewNetworkTransportWithLogger(stream, raftTransportMaxPool, raftTransportTimeout, logger)
+
+	peers := raft.NewJSONPeers(path, trans)
+
+	b := &RaftBackend{
+		path:                path,
+		logger:              logger,
+		db:                  db,
+		trans:               trans,
+		stream:              stream,
+		peers:               peers,
+		localID:             trans.LocalAddr(),
+		leaderChangeCh:      make(chan struct{}),
+		lockHoldoff:         raftLockHoldoff,
+		cleanupDeadServers:  cleanupDeadServers,
+		deadServerThreshold: deadServerThreshold,
+		minQuorum:           minQuorum,
+		peerLastContact:     make(map[string]time.Time),
+		shutdownCh:          make(chan struct{}),
+	}
+
+	notifyCh := make(chan bool, 1)
+	raftConf := raft.DefaultConfig()
+	raftConf.Logger = logger
+	raftConf.NotifyCh = notifyCh
+	raftConf.ShutdownOnRemove = false
+
+	// A node may only elect itself if it is bootstrapping a new cluster
+	// and has no other peers configured
+	existing, err := peers.Peers()
+	if err != nil {
+		trans.Close()
+		db.Close()
+		return nil, fmt.Errorf("failed to read raft peers: %v", err)
+	}
+	if bootstrap && len(existing) <= 1 {
+		raftConf.EnableSingleNode = true
+	}
+
+	b.raft, err = raft.NewRaft(raftConf, &raftFSM{b: b}, &raftLogStore{db: db}, &raftLogStore{db: db}, snaps, peers, trans)
+	if err != nil {
+		trans.Close()
+		db.Close()
+		return nil, fmt.Errorf("failed to start raft: %v", err)
+	}
+
+	go b.monitorLeadership(notifyCh)
+	go b.runAutopilot()
+
+	return b, nil
+}
+
+// raftTLSConfig loads the certificate of the node and the CA the
+// certificates of its peers must be signed by. Every raft connection is
+// mutually authenticated, as anyone able to talk raft to a node can
+// overwrite its data.
+func raftTLSConfig(conf map[string]string) (*tls.Config, error) {
+	certFile, keyFile, caFile := conf["tls_cert_file"], conf["tls_key_file"], conf["tls_ca_file"]
+	if certFile == "" || keyFile == "" || caFile == "" {
+		return nil, fmt.Errorf("'tls_cert_file', 'tls_key_file' and 'tls_ca_file' must be set")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load raft certificate: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("failed to parse CA certificate")
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// raftStreamLayer is the raft stream layer over mutual TLS. Connections
+// from peers without a certificate signed by the CA fail their handshake
+// before any raft RPC is read.
+type raftStreamLayer struct {
+	net.Listener
+	advertise net.Addr
+	tlsConfig *tls.Config
+}
+
+// newRaftStreamLayer listens on the given address for raft connections
+func newRaftStreamLayer(addr string, advertise net.Addr, tlsConfig *tls.Config) (*raftStreamLayer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &raftStreamLayer{
+		Listener:  tls.NewListener(ln, tlsConfig),
+		advertise: advertise,
+		tlsConfig: tlsConfig,
+	}
+
+	// Peers must be able to dial back the address of the node
+	tcpAddr, ok := stream.Addr().(*net.TCPAddr)
+	if !ok || tcpAddr.IP.IsUnspecified() {
+		ln.Close()
+		return nil, fmt.Errorf("raft address %s is not advertisable; set advertise_address", stream.Addr())
+	}
+	return stream, nil
+}
+
+// Dial connects to a peer, which must present a certificate signed by the
+// CA and valid for its address
+func (s *raftStreamLayer) Dial(address string, timeout time.Duration) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := s.tlsConfig.Clone()
+	tlsConfig.ServerName = host
+	return tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", address, tlsConfig)
+}
+
+// Addr returns the address peers reach the node at
+func (s *raftStreamLayer) Addr() net.Addr {
+	if s.advertise != nil {
+		return s.advertise
+	}
+	return s.Listener.Addr()
+}
+
+// Shutdown stops raft and closes the local database
+func (b *RaftBackend) Shutdown() error {
+	b.shutdownLock.Lock()
+	defer b.shutdownLock.Unlock()
+	if b.shutdown {
+		return nil
+	}
+	b.shutdown = true
+	close(b.shutdownCh)
+
+	if err := b.raft.Shutdown().Error(); err != nil {
+		return err
+	}
+	b.trans.Close()
+	return b.db.Close()
+}
+
+// monitorLeadership tracks raft leadership changes and wakes up anything
+// waiting on them
+func (b *RaftBackend) monitorLeadership(notifyCh <-chan bool) {
+	for {
+		select {
+		case isLeader := <-notifyCh:
+			b.leaderLock.Lock()
+			b.isLeader = isLeader
+			close(b.leaderChangeCh)
+			b.leaderChangeCh = make(chan struct{})
+			b.leaderLock.Unlock()
+		case <-b.shutdownCh:
+			return
+		}
+	}
+}
+
+// leadershipState returns whether this node is the raft leader along with
+// a channel that is closed on the next leadership change
+func (b *RaftBackend) leadershipState() (bool, <-chan struct{}) {
+	b.leaderLock.RLock()
+	defer b.leaderLock.RUnlock()
+	return b.isLeader, b.leaderChangeCh
+}
+
+// apply replicates the operation through the raft log and waits for it to
+// be applied to the local FSM
+func (b *RaftBackend) apply(op *raftOp) error {
+	if b.raft.State() != raft.Leader {
+		return ErrRaftNotLeader
+	}
+
+	buf, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+
+	future := b.raft.Apply(buf, raftApplyTimeout)
+	if err := future.Error(); err != nil {
+		if err == raft.ErrNotLeader {
+			return ErrRaftNotLeader
+		}
+		return err
+	}
+	if resp := future.Response(); resp != nil {
+		if err, ok := resp.(error); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// Put is used to insert or update an entry
+func (b *RaftBackend) Put(entry *Entry) error {
+	defer metrics.MeasureSince([]string{"raft", "put"}, time.Now())
+
+	return b.apply(&raftOp{
+		Op:    raftOpPut,
+		Key:   entry.Key,
+		Value: entry.Value,
+	})
+}
+
+// Get is used to fetch an entry
+func (b *RaftBackend) Get(key string) (*Entry, error) {
+	defer metrics.MeasureSince([]string{"raft", "get"}, time.Now())
+
+	var entry *Entry
+	err := b.db.View(func(tx *bolt.Tx) error {
+		val := tx.Bucket(raftDataBucket).Get([]byte(key))
+		if val == nil {
+			return nil
+		}
+		entry = &Entry{
+			Key:   key,
+			Value: append([]byte(nil), val...),
+		}
+		return nil
+	})
+	return entry, err
+}
+
+// Delete is used to permanently delete an entry
+func (b *RaftBackend) Delete(key string) error {
+	defer metrics.MeasureSince([]string{"raft", "delete"}, time.Now())
+
+	return b.apply(&raftOp{
+		Op:  raftOpDelete,
+		Key: key,
+	})
+}
+
+// List is used to list all the keys under a given
+// prefix, up to the next prefix.
+func (b *RaftBackend) List(prefix string) ([]string, error) {
+	defer metrics.MeasureSince([]string{"raft", "list"}, time.Now())
+
+	var out []string
+	seen := make(map[string]struct{})
+	err := b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(raftDataBucket).Cursor()
+		prefixBytes := []byte(prefix)
+		for k, _ := c.Seek(prefixBytes); k != nil && bytes.HasPrefix(k, prefixBytes); k, _ = c.Next() {
+			key := strings.TrimPrefix(string(k), prefix)
+			if i := strings.Index(key, "/"); i != -1 {
+				// Add truncated 'folder' paths
+				key = key[:i+1]
+			}
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			out = append(out, key)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// LockWith is used for mutual exclusion based on the given key.
+func (b *RaftBackend) LockWith(key, value string) (Lock, error) {
+	return &RaftLock{
+		b:     b,
+		key:   key,
+		value: value,
+	}, nil
+}
+
+// Peers returns the addresses of the raft peers
+func (b *RaftBackend) Peers() ([]string, error) {
+	peers, err := b.peers.Peers()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(peers)
+	return peers, nil
+}
+
+// Leader returns the address of the current raft leader, if known
+func (b *RaftBackend) Leader() string {
+	return b.raft.Leader()
+}
+
+// AddPeer adds the raft node at the given address to the cluster. This
+// must be run on the leader.
+func (b *RaftBackend) AddPeer(addr string) error {
+	if _, err := net.ResolveTCPAddr("tcp", addr); err != nil {
+		return fmt.Errorf("invalid peer address %q: %v", addr, err)
+	}
+	if err := b.raft.AddPeer(addr).Error(); err != nil {
+		if err == raft.ErrNotLeader {
+			return ErrRaftNotLeader
+		}
+		return err
+	}
+	return nil
+}
+
+// RemovePeer removes the raft node at the given address from the cluster.
+// This must be run on the leader.
+func (b *RaftBackend) RemovePeer(addr string) error {
+	if err := b.raft.RemovePeer(addr).Error(); err != nil {
+		if err == raft.ErrNotLeader {
+			return ErrRaftNotLeader
+		}
+		return err
+	}
+
+	b.peerLastContactLock.Lock()
+	delete(b.peerLastContact, addr)
+	b.peerLastContactLock.Unlock()
+	return nil
+}
+
+// runAutopilot periodically checks the health of the peers while this node
+// is the leader and removes the ones that have been unreachable for too
+// long, if enabled
+func (b *RaftBackend) runAutopilot() {
+	if !b.cleanupDeadServers {
+		return
+	}
+
+	ticker := time.NewTicker(autopilotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if b.raft.State() != raft.Leader {
+				continue
+			}
+			b.probePeers()
+			if err := b.pruneDeadServers(); err != nil {
+				b.logger.Printf("[ERR] physical/raft: failed to remove dead servers: %v", err)
+			}
+		case <-b.shutdownCh:
+			return
+		}
+	}
+}
+
+// probePeers records the time of the last successful contact with each peer
+func (b *RaftBackend) probePeers() {
+	peers, err := b.peers.Peers()
+	if err != nil {
+		b.logger.Printf("[ERR] physical/raft: failed to read peers: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, peer := range peers {
+		if peer == b.localID {
+			continue
+		}
+
+		// Dialing completes the TLS handshake, so only a peer presenting
+		// a certificate signed by the CA counts as healthy
+		conn, err := b.stream.Dial(peer, peerProbeTimeout)
+		b.peerLastContactLock.Lock()
+		if err == nil {
+			conn.Close()
+			b.peerLastContact[peer] = now
+		} else if _, ok := b.peerLastContact[peer]; !ok {
+			// Give peers we have never seen a full threshold to show up
+			b.peerLastContact[peer] = now
+		}
+		b.peerLastContactLock.Unlock()
+	}
+}
+
+// pruneDeadServers removes peers that have not been reachable within the
+// dead server threshold, as long as doing so keeps a healthy majority and
+// does not shrink the cluster below the minimum quorum
+func (b *RaftBackend) pruneDeadServers() error {
+	peers, err := b.peers.Peers()
+	if err != nil {
+		return err
+	}
+
+	b.peerLastContactLock.Lock()
+	var dead []string
+	for _, peer := range peers {
+		if peer == b.localID {
+			continue
+		}
+		last, ok := b.peerLastContact[peer]
+		if ok && time.Since(last) > b.deadServerThreshold {
+			dead = append(dead, peer)
+		}
+	}
+	b.peerLastContactLock.Unlock()
+
+	if len(dead) == 0 {
+		return nil
+	}
+
+	// Only remove dead servers if the healthy ones still form a majority
+	// of the current configuration
+	if len(dead) >= (len(peers)+1)/2 {
+		b.logger.Printf("[WARN] physical/raft: not removing %d dead servers, it would leave the cluster without a healthy majority", len(dead))
+		return nil
+	}
+
+	sort.Strings(dead)
+	remaining := len(peers)
+	for _, peer := range dead {
+		if remaining-1 < b.minQuorum {
+			b.logger.Printf("[WARN] physical/raft: not removing dead server %s, it would leave fewer than %d servers", peer, b.minQuorum)
+			return nil
+		}
+		b.logger.Printf("[INFO] physical/raft: removing dead server %s", peer)
+		if err := b.RemovePeer(peer); err != nil {
+			return err
+		}
+		remaining--
+	}
+	return nil
+}
+
+// RaftLock is a Lock implementation for the raft backend. The lock can only
+// be held by the raft leader and is lost as soon as leadership is lost.
+type RaftLock struct {
+	b     *RaftBackend
+	key   string
+	value string
+
+	held      bool
+	leaderCh  chan struct{}
+	stopCh    chan struct{}
+	closeOnce *sync.Once
+	l         sync.Mutex
+}
+
+// Lock blocks until this node is the raft leader, then records the lock
+// value so that other nodes can discover the holder
+func (l *RaftLock) Lock(stopCh <-chan struct{}) (<-chan struct{}, error) {
+	l.l.Lock()
+	defer l.l.Unlock()
+	if l.held {
+		return nil, fmt.Errorf("lock already held")
+	}
+
+	// Wait until we are the leader, and no longer held off after releasing
+	// the lock
+	for {
+		isLeader, changeCh := l.b.leadershipState()
+		var holdoffCh <-chan time.Time
+		if isLeader {
+			wait := l.b.lockHoldoffRemaining()
+			if wait <= 0 {
+				break
+			}
+			holdoffCh = time.After(wait)
+		}
+		select {
+		case <-changeCh:
+		case <-holdoffCh:
+		case <-stopCh:
+			return nil, nil
+		case <-l.b.shutdownCh:
+			return nil, fmt.Errorf("raft backend is shut down")
+		}
+	}
+
+	// Make sure all previous entries are applied before the caller starts
+	// reading
+	if err := l.b.raft.Barrier(raftApplyTimeout).Error(); err != nil {
+		return nil, err
+	}
+
+	err := l.b.apply(&raftOp{
+		Op:    raftOpPut,
+		Key:   l.key,
+		Value: []byte(l.value),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	l.held = true
+	l.leaderCh = make(chan struct{})
+	l.stopCh = make(chan struct{})
+	l.closeOnce = new(sync.Once)
+	go l.monitorLeadership(l.leaderCh, l.stopCh, l.closeOnce)
+	return l.leaderCh, nil
+}
+
+// monitorLeadership closes the leader channel once raft leadership is lost
+func (l *RaftLock) monitorLeadership(leaderCh, stopCh chan struct{}, closeOnce *sync.Once) {
+	for {
+		isLeader, changeCh := l.b.leadershipState()
+		if !isLeader {
+			closeOnce.Do(func() { close(leaderCh) })
+			return
+		}
+		select {
+		case <-changeCh:
+		case <-stopCh:
+			return
+		case <-l.b.shutdownCh:
+			closeOnce.Do(func() { close(leaderCh) })
+			return
+		}
+	}
+}
+
+// Unlock is used to release the lock
+func (l *RaftLock) Unlock() error {
+	l.l.Lock()
+	defer l.l.Unlock()
+	if !l.held {
+		return nil
+	}
+
+	close(l.stopCh)
+	l.closeOnce.Do(func() { close(l.leaderCh) })
+	l.held = false
+
+	// The vendored raft library cannot transfer leadership, so a leader
+	// stepping down would take the lock again right away. Hold it off for a
+	// while, giving leadership a chance to move to another node.
+	if peers, err := l.b.peers.Peers(); err == nil && len(peers) > 1 {
+		l.b.holdoffLock()
+	}
+
+	// Clear the value if we are still the leader, otherwise the new leader
+	// will overwrite it
+	err := l.b.apply(&raftOp{
+		Op:  raftOpDelete,
+		Key: l.key,
+	})
+	if err == ErrRaftNotLeader {
+		return nil
+	}
+	return err
+}
+
+// holdoffLock keeps this node from acquiring the lock for the hold-off
+// period
+func (b *RaftBackend) holdoffLock() {
+	b.lockHoldoffLock.Lock()
+	defer b.lockHoldoffLock.Unlock()
+	b.lockHoldoffUntil = time.Now().Add(b.lockHoldoff)
+}
+
+// lockHoldoffRemaining returns how long this node is still kept from
+// acquiring the lock
+func (b *RaftBackend) lockHoldoffRemaining() time.Duration {
+	b.lockHoldoffLock.Lock()
+	defer b.lockHoldoffLock.Unlock()
+	return b.lockHoldoffUntil.Sub(time.Now())
+}
+
+// Value returns the value of the lock and if it is held, as seen by the
+// local copy of the data
+func (l *RaftLock) Value() (bool, string, error) {
+	entry, err := l.b.Get(l.key)
+	if err != nil {
+		return false, "", err
+	}
+	if entry == nil {
+		return false, "", nil
+	}
+	return true, string(entry.Value), nil
+}
+
+// raftFSM applies replicated operations to the local bolt database
+type raftFSM struct {
+	b *RaftBackend
+}
+
+// Apply applies a committed log entry to the data bucket
+func (f *raftFSM) Apply(l *raft.Log) interface{} {
+	var op raftOp
+	if err := json.Unmarshal(l.Data, &op); err != nil {
+		f.b.logger.Printf("[ERR] physical/raft: failed to decode log entry %d: %v", l.Index, err)
+		return err
+	}
+
+	err := f.b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(raftDataBucket)
+		switch op.Op {
+		case raftOpPut:
+			return bucket.Put([]byte(op.Key), op.Value)
+		case raftOpDelete:
+			return bucket.Delete([]byte(op.Key))
+		default:
+			return fmt.Errorf("unknown operation %q", op.Op)
+		}
+	})
+	if err != nil {
+		f.b.logger.Printf("[ERR] physical/raft: failed to apply log entry %d: %v", l.Index, err)
+		return err
+	}
+	return nil
+}
+
+// Snapshot returns a point-in-time view of the data bucket
+func (f *raftFSM) Snapshot() (raft.FSMSnapshot, error) {
+	tx, err := f.b.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	return &raftFSMSnapshot{tx: tx}, nil
+}
+
+// Restore replaces the data bucket with the contents of the snapshot
+func (f *raftFSM) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+
+	dec := json.NewDecoder(rc)
+	return f.b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(raftDataBucket); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		bucket, err := tx.CreateBucket(raftDataBucket)
+		if err != nil {
+			return err
+		}
+
+		for {
+			var entry Entry
+			if err := dec.Decode(&entry); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(entry.Key), entry.Value); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// raftFSMSnapshot streams the data bucket as a sequence of JSON entries
+type raftFSMSnapshot struct {
+	tx *bolt.Tx
+}
+
+// Persist writes the snapshot to the sink
+func (s *raftFSMSnapshot) Persist(sink raft.SnapshotSink) error {
+	enc := json.NewEncoder(sink)
+	err := s.tx.Bucket(raftDataBucket).ForEach(func(k, v []byte) error {
+		return enc.Encode(&Entry{
+			Key:   string(k),
+			Value: v,
+		})
+	})
+	if err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+// Release ends the read transaction backing the snapshot
+func (s *raftFSMSnapshot) Release() {
+	s.tx.Rollback()
+}
+
+// raftLogStore implements the raft LogStore and StableStore on top of the
+// backend's bolt database
+type raftLogStore struct {
+	db *bolt.DB
+}
+
+func uint64ToBytes(u uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, u)
+	return buf
+}
+
+func bytesToUint64(b []byte) uint64 {
+	return binary.BigEndian.Uint64(b)
+}
+
+// FirstIndex returns the first index written, or 0 for no entries
+func (s *raftLogStore) FirstIndex() (uint64, error) {
+	var idx uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if k, _ := tx.Bucket(raftLogsBucket).Cursor().First(); k != nil {
+			idx = bytesToUint64(k)
+		}
+		return nil
+	})
+	return idx, err
+}
+
+// LastIndex returns the last index written, or 0 for no entries
+func (s *raftLogStore) LastIndex() (uint64, error) {
+	var idx uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if k, _ := tx.Bucket(raftLogsBucket).Cursor().Last(); k != nil {
+			idx = bytesToUint64(k)
+		}
+		return nil
+	})
+	return idx, err
+}
+
+// GetLog gets a log entry at a given index
+func (s *raftLogStore) GetLog(index uint64, log *raft.Log) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		val := tx.Bucket(raftLogsBucket).Get(uint64ToBytes(index))
+		if val == nil {
+			return raft.ErrLogNotFound
+		}
+		return json.Unmarshal(val, log)
+	})
+}
+
+// StoreLog stores a log entry
+func (s *raftLogStore) StoreLog(log *raft.Log) error {
+	return s.StoreLogs([]*raft.Log{log})
+}
+
+// StoreLogs stores multiple log entries
+func (s *raftLogStore) StoreLogs(logs []*raft.Log) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(raftLogsBucket)
+		for _, log := range logs {
+			val, err := json.Marshal(log)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(uint64ToBytes(log.Index), val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteRange deletes a range of log entries, inclusive
+func (s *raftLogStore) DeleteRange(min, max uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(raftLogsBucket).Cursor()
+		for k, _ := c.Seek(uint64ToBytes(min)); k != nil; k, _ = c.Next() {
+			if bytesToUint64(k) > max {
+				break
+			}
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Set stores a raft metadata value
+func (s *raftLogStore) Set(key []byte, val []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(raftConfBucket).Put(key, val)
+	})
+}
+
+// Get returns a raft metadata value
+func (s *raftLogStore) Get(key []byte) ([]byte, error) {
+	var val []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(raftConfBucket).Get(key)
+		if v == nil {
+			return errRaftKeyNotFound
+		}
+		val = append([]byte(nil), v...)
+		return nil
+	})
+	return val, err
+}
+
+// SetUint64 stores a raft metadata integer
+func (s *raftLogStore) SetUint64(key []byte, val uint64) error {
+	return s.Set(key, uint64ToBytes(val))
+}
+
+// GetUint64 returns a raft metadata integer
+func (s *raftLogStore) GetUint64(key []byte) (uint64, error) {
+	val, err := s.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	return bytesToUint64(val), nil
+}
@@ -0,0 +1,316 @@
+package physical
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	physicaltest "github.com/hashicorp/vault/physical/testing"
+)
+
+// testRaftTLS returns the TLS configuration shared by the nodes of a test
+// cluster
+func testRaftTLS(t *testing.T) (map[string]string, func()) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return physicaltest.RaftTLSConfig(t, dir), func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func testRaftBackend(t *testing.T, tlsConf map[string]string, bootstrap bool) (*RaftBackend, func()) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	conf := map[string]string{
+		"path":    dir,
+		"address": "127.0.0.1:0",
+	}
+	for k, v := range tlsConf {
+		conf[k] = v
+	}
+	if bootstrap {
+		conf["bootstrap"] = "true"
+	}
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	b, err := NewBackend("raft", logger, conf)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("err: %s", err)
+	}
+
+	rb := b.(*RaftBackend)
+	return rb, func() {
+		rb.Shutdown()
+		os.RemoveAll(dir)
+	}
+}
+
+func testRaftWaitLeader(t *testing.T, b *RaftBackend) {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if isLeader, _ := b.leadershipState(); isLeader {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("node %s did not become the leader", b.localID)
+}
+
+func testRaftWaitKey(t *testing.T, b *RaftBackend, key string, exists bool) {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		entry, err := b.Get(key)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if (entry != nil) == exists {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("key %q on %s: expected exists=%v", key, b.localID, exists)
+}
+
+func TestRaftBackend(t *testing.T) {
+	tlsConf, cleanupTLS := testRaftTLS(t)
+	defer cleanupTLS()
+	b, cleanup := testRaftBackend(t, tlsConf, true)
+	defer cleanup()
+	testRaftWaitLeader(t, b)
+
+	testBackend(t, b)
+	testBackend_ListPrefix(t, b)
+}
+
+func TestRaftBackend_HA(t *testing.T) {
+	tlsConf, cleanupTLS := testRaftTLS(t)
+	defer cleanupTLS()
+	b1, cleanup1 := testRaftBackend(t, tlsConf, true)
+	defer cleanup1()
+	b2, cleanup2 := testRaftBackend(t, tlsConf, false)
+	defer cleanup2()
+	testRaftWaitLeader(t, b1)
+
+	if err := b1.AddPeer(b2.localID); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	peers, err := b1.Peers()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(peers) != 2 {
+		t.Fatalf("bad: %v", peers)
+	}
+
+	// Writes are replicated to the follower
+	if err := b1.Put(&Entry{Key: "foo", Value: []byte("bar")}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testRaftWaitKey(t, b2, "foo", true)
+
+	// The follower rejects writes
+	if err := b2.Put(&Entry{Key: "foo", Value: []byte("baz")}); err != ErrRaftNotLeader {
+		t.Fatalf("expected not leader error, got: %v", err)
+	}
+
+	// The leader acquires the lock
+	lock, err := b1.LockWith("core/lock", "bar")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	leaderCh, err := lock.Lock(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if leaderCh == nil {
+		t.Fatalf("failed to get leader ch")
+	}
+
+	// The follower sees the holder
+	testRaftWaitKey(t, b2, "core/lock", true)
+	lock2, err := b2.LockWith("core/lock", "baz")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	held, val, err := lock2.Value()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !held || val != "bar" {
+		t.Fatalf("bad: %v %v", held, val)
+	}
+
+	// The follower cannot acquire the lock
+	stopCh := make(chan struct{})
+	time.AfterFunc(50*time.Millisecond, func() {
+		close(stopCh)
+	})
+	leaderCh2, err := lock2.Lock(stopCh)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if leaderCh2 != nil {
+		t.Fatalf("should not get leader ch")
+	}
+
+	// Releasing the lock clears the value
+	b1.lockHoldoff = 500 * time.Millisecond
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	select {
+	case <-leaderCh:
+	default:
+		t.Fatalf("leader ch should be closed")
+	}
+	testRaftWaitKey(t, b2, "core/lock", false)
+
+	// The leader is held off from acquiring the lock again for a while
+	stopCh = make(chan struct{})
+	time.AfterFunc(50*time.Millisecond, func() {
+		close(stopCh)
+	})
+	leaderCh, err = lock.Lock(stopCh)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if leaderCh != nil {
+		t.Fatalf("should not get leader ch during the hold-off")
+	}
+	leaderCh, err = lock.Lock(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if leaderCh == nil {
+		t.Fatalf("failed to get leader ch")
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Removing the follower shrinks the cluster
+	if err := b1.RemovePeer(b2.localID); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	peers, err = b1.Peers()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(peers) != 1 || peers[0] != b1.localID {
+		t.Fatalf("bad: %v", peers)
+	}
+}
+
+func TestRaftBackend_PruneDeadServers(t *testing.T) {
+	tlsConf, cleanupTLS := testRaftTLS(t)
+	defer cleanupTLS()
+	b1, cleanup1 := testRaftBackend(t, tlsConf, true)
+	defer cleanup1()
+	b2, cleanup2 := testRaftBackend(t, tlsConf, false)
+	defer cleanup2()
+	b3, cleanup3 := testRaftBackend(t, tlsConf, false)
+	defer cleanup3()
+	testRaftWaitLeader(t, b1)
+
+	for _, b := range []*RaftBackend{b2, b3} {
+		if err := b1.AddPeer(b.localID); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	b1.deadServerThreshold = time.Minute
+	b1.minQuorum = 3
+
+	// Take the third node down and pretend it has been gone for a while
+	b3.Shutdown()
+	b1.probePeers()
+	b1.peerLastContactLock.Lock()
+	b1.peerLastContact[b3.localID] = time.Now().Add(-2 * time.Minute)
+	b1.peerLastContactLock.Unlock()
+
+	// The minimum quorum prevents the removal
+	if err := b1.pruneDeadServers(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	peers, err := b1.Peers()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(peers) != 3 {
+		t.Fatalf("bad: %v", peers)
+	}
+
+	b1.minQuorum = 1
+	if err := b1.pruneDeadServers(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	peers, err = b1.Peers()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(peers) != 2 {
+		t.Fatalf("bad: %v", peers)
+	}
+	for _, peer := range peers {
+		if peer == b3.localID {
+			t.Fatalf("dead server not removed: %v", peers)
+		}
+	}
+}
+
+func TestRaftBackend_TLS(t *testing.T) {
+	tlsConf, cleanupTLS := testRaftTLS(t)
+	defer cleanupTLS()
+	b, cleanup := testRaftBackend(t, tlsConf, true)
+	defer cleanup()
+	testRaftWaitLeader(t, b)
+
+	// Peers without a certificate signed by the CA cannot talk raft, be it
+	// in plain TCP or with a certificate of another CA
+	plain, err := raft.NewTCPTransport("127.0.0.1:0", nil, 1, time.Second, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer plain.Close()
+
+	otherConf, cleanupOther := testRaftTLS(t)
+	defer cleanupOther()
+	otherTLS, err := raftTLSConfig(otherConf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	stream, err := newRaftStreamLayer("127.0.0.1:0", nil, otherTLS)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	other := raft.NewNetworkTransport(stream, 1, time.Second, ioutil.Discard)
+	defer other.Close()
+
+	for _, trans := range []*raft.NetworkTransport{plain, other} {
+		req := &raft.AppendEntriesRequest{
+			Term:   1000,
+			Leader: trans.EncodePeer(trans.LocalAddr()),
+		}
+		var resp raft.AppendEntriesResponse
+		if err := trans.AppendEntries(b.localID, req, &resp); err == nil {
+			t.Fatalf("should fail: %#v", resp)
+		}
+	}
+	if isLeader, _ := b.leadershipState(); !isLeader {
+		t.Fatalf("should still be the leader")
+	}
+
+	// The configuration requires the TLS files
+	if _, err := NewBackend("raft", b.logger, map[string]string{"path": b.path}); err == nil {
+		t.Fatalf("should fail")
+	}
+}
@@ -1,7 +1,12 @@
 package vault
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/armon/go-radix"
+	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 )
 
@@ -16,6 +21,161 @@ type ACL struct {
 
 	// root is enabled if the "root" named policy is present.
 	root bool
+
+	// namespacePath is the path of the namespace the policies belong to.
+	// Nothing outside of it is permitted, even with the root policy.
+	namespacePath string
+}
+
+// aclPermissions are the permissions the policies of an ACL grant on a path
+type aclPermissions struct {
+	CapabilitiesBitmap uint32
+	AllowedParameters  map[string][]string
+	DeniedParameters   map[string][]string
+	RequiredParameters []string
+	ControlGroup       *ControlGroup
+
+	// Conditional holds the grants of policies on the path which only
+	// apply to requests meeting their conditions
+	Conditional []*PathCapabilities
+}
+
+// merge adds the permissions of another policy on the same path
+func (p *aclPermissions) merge(pc *PathCapabilities) {
+	p.CapabilitiesBitmap |= pc.CapabilitiesBitmap
+	p.AllowedParameters = mergeParameters(p.AllowedParameters, pc.AllowedParameters)
+	p.DeniedParameters = mergeParameters(p.DeniedParameters, pc.DeniedParameters)
+	for _, name := range pc.RequiredParameters {
+		if !strutil.StrListContains(p.RequiredParameters, name) {
+			p.RequiredParameters = append(p.RequiredParameters, name)
+		}
+	}
+	p.ControlGroup = mergeControlGroups(p.ControlGroup, pc.ControlGroup)
+}
+
+// effective returns the permissions granted to a request from the given
+// connection made at the given time, with the conditional grants whose
+// conditions are met merged in
+func (p *aclPermissions) effective(conn *logical.Connection, now time.Time) *aclPermissions {
+	if len(p.Conditional) == 0 {
+		return p
+	}
+
+	result := &aclPermissions{
+		CapabilitiesBitmap: p.CapabilitiesBitmap,
+		AllowedParameters:  p.AllowedParameters,
+		DeniedParameters:   p.DeniedParameters,
+		RequiredParameters: append([]string{}, p.RequiredParameters...),
+		ControlGroup:       p.ControlGroup,
+	}
+	for _, pc := range p.Conditional {
+		if !pc.Conditions.met(conn, now) {
+			continue
+		}
+		switch {
+		case result.CapabilitiesBitmap&DenyCapabilityInt > 0:
+			return result
+		case pc.CapabilitiesBitmap&DenyCapabilityInt > 0:
+			return &aclPermissions{CapabilitiesBitmap: DenyCapabilityInt}
+		default:
+			result.merge(pc)
+		}
+	}
+	return result
+}
+
+// mergeParameters combines the value globs of parameter constraints. A
+// parameter constrained to any value by either stays so.
+func mergeParameters(existing, other map[string][]string) map[string][]string {
+	if len(other) == 0 {
+		return existing
+	}
+	result := make(map[string][]string, len(existing)+len(other))
+	for name, globs := range existing {
+		result[name] = globs
+	}
+	for name, globs := range other {
+		current, ok := result[name]
+		switch {
+		case !ok:
+			result[name] = globs
+		case len(current) == 0 || len(globs) == 0:
+			result[name] = []string{}
+		default:
+			result[name] = append(append([]string{}, current...), globs...)
+		}
+	}
+	return result
+}
+
+// allowParameters checks the data of a request against the parameter
+// constraints
+func (p *aclPermissions) allowParameters(data map[string]interface{}) bool {
+	for _, name := range p.RequiredParameters {
+		if _, ok := data[name]; !ok {
+			return false
+		}
+	}
+
+	for name, raw := range data {
+		values := parameterValues(raw)
+
+		// Any value matching a denied glob is refused
+		for _, key := range []string{name, "*"} {
+			globs, ok := p.DeniedParameters[key]
+			if !ok {
+				continue
+			}
+			if len(globs) == 0 {
+				return false
+			}
+			for _, value := range values {
+				if strutil.GlobListMatch(globs, value) {
+					return false
+				}
+			}
+		}
+
+		// Every value has to match an allowed glob
+		if len(p.AllowedParameters) == 0 {
+			continue
+		}
+		globs, ok := p.AllowedParameters[name]
+		if !ok {
+			globs, ok = p.AllowedParameters["*"]
+		}
+		if !ok {
+			return false
+		}
+		if len(globs) == 0 {
+			continue
+		}
+		for _, value := range values {
+			if !strutil.GlobListMatch(globs, value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// parameterValues returns the values a request parameter holds. Lists,
+// whether sent as such or comma-delimited, hold each of their elements.
+func parameterValues(raw interface{}) []string {
+	switch v := raw.(type) {
+	case string:
+		return strings.Split(v, ",")
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, parameterValues(item)...)
+		}
+		return values
+	default:
+		return []string{fmt.Sprintf("%v", v)}
+	}
 }
 
 // New is used to construct a policy based ACL from a set of policies.
@@ -47,24 +207,32 @@ func NewACL(policies []*Policy) (*ACL, error) {
 			// Check for an existing policy
 			raw, ok := tree.Get(pc.Prefix)
 			if !ok {
-				tree.Insert(pc.Prefix, pc.CapabilitiesBitmap)
-				continue
+				raw = &aclPermissions{}
+				tree.Insert(pc.Prefix, raw)
 			}
-			existing := raw.(uint32)
+			existing := raw.(*aclPermissions)
 
 			switch {
-			case existing&DenyCapabilityInt > 0:
+			case pc.Conditions != nil:
+				// Conditional grants are only merged in when authorizing a
+				// request which meets their conditions
+				existing.Conditional = append(existing.Conditional, pc)
+
+			case existing.CapabilitiesBitmap&DenyCapabilityInt > 0:
 				// If we are explicitly denied in the existing capability set,
 				// don't save anything else
 
 			case pc.CapabilitiesBitmap&DenyCapabilityInt > 0:
 				// If this new policy explicitly denies, only save the deny value
-				tree.Insert(pc.Prefix, DenyCapabilityInt)
+				*existing = aclPermissions{
+					CapabilitiesBitmap: DenyCapabilityInt,
+					Conditional:        existing.Conditional,
+				}
 
 			default:
-				// Insert the capabilities in this new policy into the existing
+				// Merge the permissions of this new policy into the existing
 				// value
-				tree.Insert(pc.Prefix, existing|pc.CapabilitiesBitmap)
+				existing.merge(pc)
 			}
 		}
 	}
@@ -72,28 +240,23 @@ func NewACL(policies []*Policy) (*ACL, error) {
 }
 
 func (a *ACL) Capabilities(path string) (pathCapabilities []string) {
+	if !strings.HasPrefix(path, a.namespacePath) {
+		return []string{DenyCapability}
+	}
+
 	// Fast-path root
 	if a.root {
 		return []string{RootCapability}
 	}
 
-	// Find an exact matching rule, look for glob if no match
-	var capabilities uint32
-	raw, ok := a.exactRules.Get(path)
-	if ok {
-		capabilities = raw.(uint32)
-		goto CHECK
-	}
-
-	// Find a glob rule, default deny if no match
-	_, raw, ok = a.globRules.LongestPrefix(path)
-	if !ok {
+	// Find an exact matching rule, look for glob if no match. Grants
+	// conditioned on the network of the request are not reported.
+	perms := a.permissions(path)
+	if perms == nil {
 		return []string{DenyCapability}
-	} else {
-		capabilities = raw.(uint32)
 	}
+	capabilities := perms.effective(nil, time.Now()).CapabilitiesBitmap
 
-CHECK:
 	if capabilities&SudoCapabilityInt > 0 {
 		pathCapabilities = append(pathCapabilities, SudoCapability)
 	}
@@ -121,10 +284,27 @@ CHECK:
 	return
 }
 
-// AllowOperation is used to check if the given operation is permitted. The
-// first bool indicates if an op is allowed, the second whether sudo priviliges
-// exist for that op and path.
-func (a *ACL) AllowOperation(op logical.Operation, path string) (allowed bool, sudo bool) {
+// permissions returns the permissions on the path, from an exact matching
+// rule or else from the longest glob rule, or nil if no rule matches
+func (a *ACL) permissions(path string) *aclPermissions {
+	if raw, ok := a.exactRules.Get(path); ok {
+		return raw.(*aclPermissions)
+	}
+	if _, raw, ok := a.globRules.LongestPrefix(path); ok {
+		return raw.(*aclPermissions)
+	}
+	return nil
+}
+
+// AllowOperation is used to check if the operation of the request is
+// permitted on its path, with its data. The first bool indicates if an op
+// is allowed, the second whether sudo priviliges exist for that op and path.
+func (a *ACL) AllowOperation(req *logical.Request) (allowed bool, sudo bool) {
+	op, path := req.Operation, req.Path
+	if !strings.HasPrefix(path, a.namespacePath) {
+		return false, false
+	}
+
 	// Fast-path root
 	if a.root {
 		return true, true
@@ -136,22 +316,13 @@ func (a *ACL) AllowOperation(op logical.Operation, path string) (allowed bool, s
 	}
 
 	// Find an exact matching rule, look for glob if no match
-	var capabilities uint32
-	raw, ok := a.exactRules.Get(path)
-	if ok {
-		capabilities = raw.(uint32)
-		goto CHECK
-	}
-
-	// Find a glob rule, default deny if no match
-	_, raw, ok = a.globRules.LongestPrefix(path)
-	if !ok {
+	perms := a.permissions(path)
+	if perms == nil {
 		return false, false
-	} else {
-		capabilities = raw.(uint32)
 	}
+	perms = perms.effective(req.Connection, time.Now())
+	capabilities := perms.CapabilitiesBitmap
 
-CHECK:
 	// Check if the minimum permissions are met
 	// If "deny" has been explicitly set, only deny will be in the map, so we
 	// only need to check for the existence of other values
@@ -175,5 +346,27 @@ CHECK:
 	default:
 		return false, false
 	}
+
+	// The data of requests writing to the path has to meet the parameter
+	// constraints
+	switch op {
+	case logical.CreateOperation, logical.UpdateOperation:
+		if allowed && !perms.allowParameters(req.Data) {
+			allowed = false
+		}
+	}
 	return
 }
+
+// ControlGroup returns the control group the request has to be approved by
+// before it is executed, or nil if it needs no approval
+func (a *ACL) ControlGroup(req *logical.Request) *ControlGroup {
+	if a.root || req.Operation == logical.HelpOperation {
+		return nil
+	}
+	perms := a.permissions(req.Path)
+	if perms == nil {
+		return nil
+	}
+	return perms.effective(req.Connection, time.Now()).ControlGroup
+}
@@ -59,7 +59,7 @@ func TestACL_Root(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	}
 
-	allowed, rootPrivs := acl.AllowOperation(logical.UpdateOperation, "sys/mount/foo")
+	allowed, rootPrivs := acl.AllowOperation(&logical.Request{Operation: logical.UpdateOperation, Path: "sys/mount/foo"})
 	if !rootPrivs {
 		t.Fatalf("expected root")
 	}
@@ -81,7 +81,7 @@ func TestACL_Single(t *testing.T) {
 
 	// Type of operation is not important here as we only care about checking
 	// sudo/root
-	_, rootPrivs := acl.AllowOperation(logical.ReadOperation, "sys/mount/foo")
+	_, rootPrivs := acl.AllowOperation(&logical.Request{Operation: logical.ReadOperation, Path: "sys/mount/foo"})
 	if rootPrivs {
 		t.Fatalf("unexpected root")
 	}
@@ -117,7 +117,7 @@ func TestACL_Single(t *testing.T) {
 	}
 
 	for _, tc := range tcases {
-		allowed, rootPrivs := acl.AllowOperation(tc.op, tc.path)
+		allowed, rootPrivs := acl.AllowOperation(&logical.Request{Operation: tc.op, Path: tc.path})
 		if allowed != tc.allowed {
 			t.Fatalf("bad: case %#v: %v, %v", tc, allowed, rootPrivs)
 		}
@@ -148,7 +148,7 @@ func TestACL_Layered(t *testing.T) {
 func testLayeredACL(t *testing.T, acl *ACL) {
 	// Type of operation is not important here as we only care about checking
 	// sudo/root
-	_, rootPrivs := acl.AllowOperation(logical.ReadOperation, "sys/mount/foo")
+	_, rootPrivs := acl.AllowOperation(&logical.Request{Operation: logical.ReadOperation, Path: "sys/mount/foo"})
 	if rootPrivs {
 		t.Fatalf("unexpected root")
 	}
@@ -189,7 +189,7 @@ func testLayeredACL(t *testing.T, acl *ACL) {
 	}
 
 	for _, tc := range tcases {
-		allowed, rootPrivs := acl.AllowOperation(tc.op, tc.path)
+		allowed, rootPrivs := acl.AllowOperation(&logical.Request{Operation: tc.op, Path: tc.path})
 		if allowed != tc.allowed {
 			t.Fatalf("bad: case %#v: %v, %v", tc, allowed, rootPrivs)
 		}
@@ -199,6 +199,110 @@ func testLayeredACL(t *testing.T, acl *ACL) {
 	}
 }
 
+func TestACL_Parameters(t *testing.T) {
+	policy1, err := Parse(aclParametersPolicy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	policy2, err := Parse(aclParametersPolicy2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	acl, err := NewACL([]*Policy{policy1, policy2})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	type tcase struct {
+		op      logical.Operation
+		path    string
+		data    map[string]interface{}
+		allowed bool
+	}
+	tcases := []tcase{
+		{logical.UpdateOperation, "pki/issue/web", map[string]interface{}{"common_name": "web.internal"}, true},
+		{logical.UpdateOperation, "pki/issue/web", map[string]interface{}{"common_name": "web.internal", "ttl": "1h"}, true},
+		{logical.UpdateOperation, "pki/issue/web", map[string]interface{}{"common_name": "web.example.com"}, false},
+		{logical.UpdateOperation, "pki/issue/web", map[string]interface{}{"common_name": "a.internal,b.example.com"}, false},
+		{logical.UpdateOperation, "pki/issue/web", map[string]interface{}{"common_name": "web.internal", "format": "der"}, false},
+		{logical.UpdateOperation, "pki/issue/web", map[string]interface{}{"ttl": "1h"}, false},
+		{logical.UpdateOperation, "pki/issue/web", nil, false},
+
+		{logical.UpdateOperation, "auth/token/create", map[string]interface{}{"policies": []interface{}{"dev"}}, true},
+		{logical.UpdateOperation, "auth/token/create", map[string]interface{}{"policies": []interface{}{"dev", "root"}}, false},
+		{logical.UpdateOperation, "auth/token/create", map[string]interface{}{"policies": "dev,root"}, false},
+		{logical.UpdateOperation, "auth/token/create", map[string]interface{}{"id": "foo"}, false},
+		{logical.UpdateOperation, "auth/token/create", nil, true},
+
+		// Layered policies combine the globs of the values
+		{logical.UpdateOperation, "secret/foo", map[string]interface{}{"zip": "zap", "env": "dev"}, true},
+		{logical.UpdateOperation, "secret/foo", map[string]interface{}{"zip": "zap", "env": "prod"}, true},
+		{logical.UpdateOperation, "secret/foo", map[string]interface{}{"zip": "zap", "env": "stage"}, false},
+		{logical.UpdateOperation, "secret/foo", map[string]interface{}{"zip": "zap", "env": "dev", "debug": true}, false},
+
+		// Only data written is constrained
+		{logical.ReadOperation, "secret/foo", nil, true},
+	}
+
+	for _, tc := range tcases {
+		req := &logical.Request{
+			Operation: tc.op,
+			Path:      tc.path,
+			Data:      tc.data,
+		}
+		if allowed, _ := acl.AllowOperation(req); allowed != tc.allowed {
+			t.Fatalf("bad: case %#v: %v", tc, allowed)
+		}
+	}
+}
+
+func TestACL_Conditions(t *testing.T) {
+	policy, err := Parse(aclConditionsPolicy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	acl, err := NewACL([]*Policy{policy})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	type tcase struct {
+		op      logical.Operation
+		path    string
+		addr    string
+		allowed bool
+	}
+	tcases := []tcase{
+		{logical.ReadOperation, "sys/raw/foo", "10.0.5.12", true},
+		{logical.UpdateOperation, "sys/raw/foo", "10.0.5.12", true},
+		{logical.ReadOperation, "sys/raw/foo", "10.0.6.12", false},
+		{logical.ReadOperation, "sys/raw/foo", "", false},
+		{logical.ReadOperation, "sys/mounts", "10.0.6.12", true},
+		{logical.ReadOperation, "secret/foo", "10.0.6.12", true},
+		{logical.UpdateOperation, "secret/foo", "10.0.6.12", false},
+		{logical.UpdateOperation, "secret/foo", "10.0.5.12", true},
+		{logical.ReadOperation, "secret/private", "10.0.5.12", false},
+	}
+
+	for _, tc := range tcases {
+		req := &logical.Request{
+			Operation: tc.op,
+			Path:      tc.path,
+		}
+		if tc.addr != "" {
+			req.Connection = &logical.Connection{RemoteAddr: tc.addr}
+		}
+		if allowed, _ := acl.AllowOperation(req); allowed != tc.allowed {
+			t.Fatalf("bad: case %#v: %v", tc, allowed)
+		}
+	}
+
+	// Conditional grants are not reported as capabilities
+	if caps := acl.Capabilities("secret/foo"); !reflect.DeepEqual(caps, []string{"read", "list"}) {
+		t.Fatalf("bad: %v", caps)
+	}
+}
+
 var aclPolicy = `
 name = "dev"
 path "dev/*" {
@@ -248,3 +352,66 @@ path "foo/bar" {
 	capabilities = ["deny"]
 }
 `
+
+var aclParametersPolicy = `
+name = "params"
+path "pki/issue/web" {
+	capabilities = ["update"]
+	allowed_parameters = {
+		"common_name" = ["*.internal"]
+		"ttl" = []
+	}
+	required_parameters = ["common_name"]
+}
+path "auth/token/create" {
+	capabilities = ["update"]
+	denied_parameters = {
+		"policies" = ["root"]
+		"id" = []
+	}
+}
+path "secret/*" {
+	capabilities = ["read", "update"]
+	allowed_parameters = {
+		"*" = []
+		"env" = ["dev"]
+	}
+	denied_parameters = {
+		"debug" = []
+	}
+}
+`
+
+var aclParametersPolicy2 = `
+name = "params2"
+path "secret/*" {
+	capabilities = ["update"]
+	allowed_parameters = {
+		"env" = ["prod"]
+	}
+}
+`
+
+var aclConditionsPolicy = `
+path "sys/*" {
+	policy = "read"
+}
+path "sys/raw/*" {
+	capabilities = ["read", "update"]
+	allowed_cidrs = ["10.0.5.0/24"]
+}
+path "secret/*" {
+	policy = "read"
+}
+path "secret/*" {
+	policy = "write"
+	allowed_cidrs = ["10.0.5.0/24"]
+}
+path "secret/private" {
+	policy = "deny"
+}
+path "secret/private" {
+	policy = "read"
+	allowed_cidrs = ["10.0.5.0/24"]
+}
+`
@@ -219,6 +219,18 @@ func (c *Core) teardownAudits() error {
 	return nil
 }
 
+// logAuditEvent records an event that happened outside of the handling of
+// a request, such as the outcome of work done in the background, in the
+// audit log. Nothing is logged once the vault has been sealed.
+func (c *Core) logAuditEvent(req *logical.Request, resp *logical.Response, err error) error {
+	c.auditLock.RLock()
+	defer c.auditLock.RUnlock()
+	if c.auditBroker == nil {
+		return nil
+	}
+	return c.auditBroker.LogResponse(nil, req, resp, err)
+}
+
 // newAuditBackend is used to create and configure a new audit backend by name
 func (c *Core) newAuditBackend(t string, view logical.Storage, conf map[string]string) (audit.Backend, error) {
 	f, ok := c.auditBackends[t]
@@ -37,7 +37,7 @@ func (c *Core) enableCredential(entry *MountEntry) error {
 	}
 
 	// Ensure there is a name
-	if entry.Path == "/" {
+	if entry.Path == "/" || entry.Path == c.namespaceByPath(entry.Path).Path {
 		return fmt.Errorf("backend path must be specified")
 	}
 
@@ -67,10 +67,15 @@ func (c *Core) enableCredential(entry *MountEntry) error {
 		return err
 	}
 	entry.UUID = entryUUID
+	accessor, err := c.generateMountAccessor(entry.Type)
+	if err != nil {
+		return err
+	}
+	entry.Accessor = accessor
 	view := NewBarrierView(c.barrier, credentialBarrierPrefix+entry.UUID+"/")
 
 	// Create the new backend
-	backend, err := c.newCredentialBackend(entry.Type, c.mountEntrySysView(entry), view, nil)
+	backend, err := c.newCredentialBackend(entry.Type, c.mountEntrySysView(entry), view, entry.backendConfig())
 	if err != nil {
 		return err
 	}
@@ -85,7 +90,7 @@ func (c *Core) enableCredential(entry *MountEntry) error {
 	c.auth = newTable
 
 	// Mount the backend
-	path := credentialRoutePrefix + entry.Path
+	path := c.credentialRoutePath(entry.Path)
 	if err := c.router.Mount(backend, path, entry, view); err != nil {
 		return err
 	}
@@ -102,12 +107,12 @@ func (c *Core) disableCredential(path string) error {
 	}
 
 	// Ensure the token backend is not affected
-	if path == "token/" {
+	if strings.TrimPrefix(path, c.namespaceByPath(path).Path) == "token/" {
 		return fmt.Errorf("token credential backend cannot be disabled")
 	}
 
 	// Store the view for this backend
-	fullPath := credentialRoutePrefix + path
+	fullPath := c.credentialRoutePath(path)
 	view := c.router.MatchingStorageView(fullPath)
 	if view == nil {
 		return fmt.Errorf("no matching backend")
@@ -208,8 +213,29 @@ func (c *Core) loadCredentials() error {
 		c.auth = authTable
 	}
 
-	// Done if we have restored the auth table
+	// Done if we have restored the auth table, unless entries enabled before
+	// accessors existed need one
 	if c.auth != nil {
+		needPersist := false
+		for _, entry := range c.auth.Entries {
+			if entry.Accessor != "" {
+				continue
+			}
+			accessor, err := c.generateMountAccessor(entry.Type)
+			if err != nil {
+				c.logger.Printf("[ERR] core: failed to generate accessor for %s: %v", entry.Path, err)
+				return errLoadAuthFailed
+			}
+			entry.Accessor = accessor
+			needPersist = true
+		}
+		if !needPersist {
+			return nil
+		}
+		if err := c.persistAuth(c.auth); err != nil {
+			c.logger.Printf("[ERR] core: failed to persist auth table: %v", err)
+			return errLoadAuthFailed
+		}
 		return nil
 	}
 
@@ -222,6 +248,31 @@ func (c *Core) loadCredentials() error {
 	return nil
 }
 
+// generateMountAccessor generates an accessor for a credential backend of
+// the given type, unique within the auth table. The auth lock must be held.
+func (c *Core) generateMountAccessor(entryType string) (string, error) {
+	for {
+		id, err := uuid.GenerateUUID()
+		if err != nil {
+			return "", err
+		}
+		accessor := fmt.Sprintf("auth_%s_%s", entryType, id[:8])
+
+		unique := true
+		if c.auth != nil {
+			for _, entry := range c.auth.Entries {
+				if entry.Accessor == accessor {
+					unique = false
+					break
+				}
+			}
+		}
+		if unique {
+			return accessor, nil
+		}
+	}
+}
+
 // persistAuth is used to persist the auth table after modification
 func (c *Core) persistAuth(table *MountTable) error {
 	// Marshal the table
@@ -260,7 +311,7 @@ func (c *Core) setupCredentials() error {
 		view = NewBarrierView(c.barrier, credentialBarrierPrefix+entry.UUID+"/")
 
 		// Initialize the backend
-		backend, err = c.newCredentialBackend(entry.Type, c.mountEntrySysView(entry), view, nil)
+		backend, err = c.newCredentialBackend(entry.Type, c.mountEntrySysView(entry), view, entry.backendConfig())
 		if err != nil {
 			c.logger.Printf(
 				"[ERR] core: failed to create credential entry %s: %v",
@@ -269,7 +320,7 @@ func (c *Core) setupCredentials() error {
 		}
 
 		// Mount the backend
-		path := credentialRoutePrefix + entry.Path
+		path := c.credentialRoutePath(entry.Path)
 		err = c.router.Mount(backend, path, entry, view)
 		if err != nil {
 			c.logger.Printf("[ERR] core: failed to mount auth entry %s: %v", entry.Path, err)
@@ -293,12 +344,30 @@ func (c *Core) setupCredentials() error {
 	return nil
 }
 
+// credentialRoutePath returns the router path of the credential backend at
+// the given path of the auth table. Within a namespace, the credential
+// backends are routed under the path of the namespace.
+func (c *Core) credentialRoutePath(path string) string {
+	ns := c.namespaceByPath(path)
+	return ns.Path + credentialRoutePrefix + strings.TrimPrefix(path, ns.Path)
+}
+
 // teardownCredentials is used before we seal the vault to reset the credential
 // backends to their unloaded state. This is reversed by loadCredentials.
 func (c *Core) teardownCredentials() error {
 	c.authLock.Lock()
 	defer c.authLock.Unlock()
 
+	// Clean up the backends, so those served by plugins stop their process
+	if c.auth != nil {
+		for _, e := range c.auth.Entries {
+			b, ok := c.router.root.Get(c.credentialRoutePath(e.Path))
+			if ok {
+				b.(*routeEntry).backend.Cleanup()
+			}
+		}
+	}
+
 	c.auth = nil
 	c.tokenStore = nil
 	return nil
@@ -339,6 +408,7 @@ func defaultAuthTable() *MountTable {
 		Type:        "token",
 		Description: "token based credentials",
 		UUID:        tokenUUID,
+		Accessor:    "auth_token_" + tokenUUID[:8],
 	}
 	table.Entries = append(table.Entries, tokenAuth)
 	return table
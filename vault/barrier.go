@@ -86,6 +86,10 @@ type SecurityBarrier interface {
 	// VerifyMaster is used to check if the given key matches the master key
 	VerifyMaster(key []byte) error
 
+	// VerifyKeyring is used to check if the given encrypted keyring can
+	// be decrypted with the current master key
+	VerifyKeyring(value []byte) error
+
 	// ReloadKeyring is used to re-read the underlying keyring.
 	// This is used for HA deployments to ensure the latest keyring
 	// is present in the leader.
@@ -225,6 +225,34 @@ func (b *AESGCMBarrier) VerifyMaster(key []byte) error {
 	return nil
 }
 
+// VerifyKeyring is used to check if the given encrypted keyring can be
+// decrypted with the current master key
+func (b *AESGCMBarrier) VerifyKeyring(value []byte) error {
+	b.l.RLock()
+	defer b.l.RUnlock()
+	if b.sealed {
+		return ErrBarrierSealed
+	}
+
+	gcm, err := b.aeadFromKey(b.keyring.MasterKey())
+	if err != nil {
+		return err
+	}
+	if len(value) < 5+gcm.NonceSize()+gcm.Overhead() {
+		return fmt.Errorf("keyring is malformed")
+	}
+
+	plain, err := b.decrypt(keyringPath, gcm, value)
+	if err != nil {
+		if strings.Contains(err.Error(), "message authentication failed") {
+			return ErrBarrierInvalidKey
+		}
+		return err
+	}
+	memzero(plain)
+	return nil
+}
+
 // ReloadKeyring is used to re-read the underlying keyring.
 // This is used for HA deployments to ensure the latest keyring
 // is present in the leader.
@@ -1,6 +1,10 @@
 package vault
 
-import "sort"
+import (
+	"sort"
+
+	"github.com/hashicorp/vault/logical"
+)
 
 // Struct to identify user input errors.
 // This is helpful in responding the appropriate status codes to clients
@@ -36,20 +40,10 @@ func (c *Core) Capabilities(token, path string) ([]string, error) {
 		return []string{DenyCapability}, nil
 	}
 
-	var policies []*Policy
-	for _, tePolicy := range te.Policies {
-		policy, err := c.policyStore.GetPolicy(tePolicy)
-		if err != nil {
-			return nil, err
-		}
-		policies = append(policies, policy)
-	}
-
-	if len(policies) == 0 {
+	acl, err := c.tokenACL(te)
+	if err == logical.ErrPermissionDenied {
 		return []string{DenyCapability}, nil
 	}
-
-	acl, err := NewACL(policies)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,366 @@
+package vault
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/forwarding"
+)
+
+const (
+	// NoRequestForwardingHeaderName is the name of the header that, if set,
+	// instructs a standby to serve the request itself instead of forwarding
+	// it to the active node
+	NoRequestForwardingHeaderName = "X-Vault-No-Request-Forwarding"
+
+	// clusterForwardingPath is the path on the cluster listener that serves
+	// requests forwarded from standby nodes
+	clusterForwardingPath = "/cluster/local/forwarded-request"
+
+	// clusterForwardingTimeout bounds how long a standby waits on the
+	// active node for a forwarded request
+	clusterForwardingTimeout = 60 * time.Second
+)
+
+var (
+	// ErrCannotForward is returned when a standby is unable to forward a
+	// request to the active node, for instance because the active node
+	// has not advertised a cluster address
+	ErrCannotForward = errors.New("cannot forward request; no connection or address not known")
+)
+
+// activeAdvertisement is the value stored under the leader prefix by the
+// active node. As it is stored in the barrier, only nodes that can unseal
+// the barrier can read the cluster key within.
+type activeAdvertisement struct {
+	AdvertiseAddr    string            `json:"advertise_addr"`
+	ClusterAddr      string            `json:"cluster_addr,omitempty"`
+	ClusterCert      []byte            `json:"cluster_cert,omitempty"`
+	ClusterKeyParams *clusterKeyParams `json:"cluster_key_params,omitempty"`
+}
+
+// clusterKeyParams holds the parameters of the ECDSA key used for the
+// cluster TLS identity
+type clusterKeyParams struct {
+	Type string   `json:"type"`
+	X    *big.Int `json:"x"`
+	Y    *big.Int `json:"y"`
+	D    *big.Int `json:"d"`
+}
+
+// activeConnection is the client a standby uses to forward requests to the
+// active node, along with the identity it was built for
+type activeConnection struct {
+	*http.Client
+	clusterAddr string
+	clusterCert []byte
+}
+
+// SetClusterListenerAddrs sets the addresses the cluster listeners bind to
+// while this node is active
+func (c *Core) SetClusterListenerAddrs(addrs []*net.TCPAddr) {
+	c.clusterParamsLock.Lock()
+	defer c.clusterParamsLock.Unlock()
+	c.clusterListenerAddrs = addrs
+}
+
+// SetClusterHandler sets the handler used to serve requests forwarded by
+// standby nodes
+func (c *Core) SetClusterHandler(handler http.Handler) {
+	c.clusterParamsLock.Lock()
+	defer c.clusterParamsLock.Unlock()
+	c.clusterHandler = handler
+}
+
+// setupCluster generates the TLS identity used on the cluster channel for
+// this leadership term
+func (c *Core) setupCluster() error {
+	c.clusterParamsLock.Lock()
+	defer c.clusterParamsLock.Unlock()
+
+	key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate cluster key: %v", err)
+	}
+
+	host, err := uuid.GenerateUUID()
+	if err != nil {
+		return err
+	}
+	host = "fw-" + host
+
+	template := &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName: host,
+		},
+		DNSNames: []string{host},
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth,
+			x509.ExtKeyUsageClientAuth,
+		},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageKeyAgreement | x509.KeyUsageCertSign,
+		SerialNumber:          big.NewInt(mathrand.Int63()),
+		NotBefore:             time.Now().Add(-30 * time.Second),
+		NotAfter:              time.Now().Add(262980 * time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return fmt.Errorf("failed to generate cluster certificate: %v", err)
+	}
+
+	c.localClusterPrivateKey = key
+	c.localClusterCert = certBytes
+	return nil
+}
+
+// clusterTLSConfig returns a TLS configuration that both presents and
+// requires the given cluster certificate, so that only nodes holding the
+// key can talk to each other
+func clusterTLSConfig(certBytes []byte, key *ecdsa.PrivateKey) (*tls.Config, error) {
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cluster certificate: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{
+			tls.Certificate{
+				Certificate: [][]byte{certBytes},
+				PrivateKey:  key,
+			},
+		},
+		RootCAs:    pool,
+		ClientCAs:  pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ServerName: cert.Subject.CommonName,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// startClusterListener starts listening for forwarded requests on the
+// cluster addresses. It is a no-op if no addresses or handler are set.
+func (c *Core) startClusterListener() error {
+	c.clusterParamsLock.Lock()
+	defer c.clusterParamsLock.Unlock()
+
+	if len(c.clusterListenerAddrs) == 0 || c.clusterHandler == nil {
+		return nil
+	}
+	if c.clusterListeners != nil {
+		return fmt.Errorf("cluster listeners already running")
+	}
+
+	tlsConfig, err := clusterTLSConfig(c.localClusterCert, c.localClusterPrivateKey)
+	if err != nil {
+		return err
+	}
+
+	handler := c.clusterForwardingHandler(c.clusterHandler)
+	listeners := make([]net.Listener, 0, len(c.clusterListenerAddrs))
+	for _, addr := range c.clusterListenerAddrs {
+		ln, err := net.ListenTCP("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("failed to start cluster listener on %s: %v", addr, err)
+		}
+
+		tlsLn := tls.NewListener(ln, tlsConfig)
+		server := &http.Server{
+			Handler: handler,
+		}
+		go server.Serve(tlsLn)
+
+		c.logger.Printf("[INFO] core: serving cluster requests on %s", ln.Addr())
+		listeners = append(listeners, tlsLn)
+	}
+
+	c.clusterListeners = listeners
+	return nil
+}
+
+// stopClusterListener stops the cluster listeners, if running
+func (c *Core) stopClusterListener() {
+	c.clusterParamsLock.Lock()
+	defer c.clusterParamsLock.Unlock()
+
+	for _, ln := range c.clusterListeners {
+		ln.Close()
+	}
+	c.clusterListeners = nil
+}
+
+// clusterForwardingHandler unpacks requests forwarded by standby nodes and
+// serves them with the given handler
+func (c *Core) clusterForwardingHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != clusterForwardingPath || r.Method != "POST" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		req, err := forwarding.ParseForwardedRequest(r)
+		if err != nil {
+			c.logger.Printf("[ERR] core: error parsing forwarded request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// A forwarded request must never be forwarded again
+		req.Header.Set(NoRequestForwardingHeaderName, "true")
+
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// advertisement builds the value the active node stores under the leader
+// prefix
+func (c *Core) advertisement() *activeAdvertisement {
+	c.clusterParamsLock.RLock()
+	defer c.clusterParamsLock.RUnlock()
+
+	adv := &activeAdvertisement{
+		AdvertiseAddr: c.advertiseAddr,
+	}
+	if c.clusterAddr != "" && c.localClusterPrivateKey != nil {
+		adv.ClusterAddr = c.clusterAddr
+		adv.ClusterCert = c.localClusterCert
+		adv.ClusterKeyParams = &clusterKeyParams{
+			Type: "p521",
+			X:    c.localClusterPrivateKey.X,
+			Y:    c.localClusterPrivateKey.Y,
+			D:    c.localClusterPrivateKey.D,
+		}
+	}
+	return adv
+}
+
+// parseAdvertisement decodes a leader advertisement, handling entries
+// written by versions that only stored the advertise address
+func parseAdvertisement(value []byte) *activeAdvertisement {
+	var adv activeAdvertisement
+	if err := json.Unmarshal(value, &adv); err != nil || adv.AdvertiseAddr == "" {
+		return &activeAdvertisement{
+			AdvertiseAddr: string(value),
+		}
+	}
+	return &adv
+}
+
+// refreshRequestForwardingConnection ensures that a standby has a client
+// set up for the cluster identity advertised by the active node
+func (c *Core) refreshRequestForwardingConnection(adv *activeAdvertisement) error {
+	c.requestForwardingConnectionLock.Lock()
+	defer c.requestForwardingConnectionLock.Unlock()
+
+	if adv.ClusterAddr == "" || adv.ClusterCert == nil || adv.ClusterKeyParams == nil {
+		c.requestForwardingConnection = nil
+		return nil
+	}
+
+	// Nothing to do if the leader has not changed
+	if conn := c.requestForwardingConnection; conn != nil &&
+		conn.clusterAddr == adv.ClusterAddr &&
+		string(conn.clusterCert) == string(adv.ClusterCert) {
+		return nil
+	}
+
+	if adv.ClusterKeyParams.Type != "p521" {
+		return fmt.Errorf("unsupported cluster key type %q", adv.ClusterKeyParams.Type)
+	}
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P521(),
+			X:     adv.ClusterKeyParams.X,
+			Y:     adv.ClusterKeyParams.Y,
+		},
+		D: adv.ClusterKeyParams.D,
+	}
+
+	tlsConfig, err := clusterTLSConfig(adv.ClusterCert, key)
+	if err != nil {
+		c.requestForwardingConnection = nil
+		return err
+	}
+
+	transport := cleanhttp.DefaultPooledTransport()
+	transport.TLSClientConfig = tlsConfig
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   clusterForwardingTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			// Redirects are passed back to the original client
+			return http.ErrUseLastResponse
+		},
+	}
+
+	c.requestForwardingConnection = &activeConnection{
+		Client:      client,
+		clusterAddr: adv.ClusterAddr,
+		clusterCert: adv.ClusterCert,
+	}
+	return nil
+}
+
+// clearForwardingClients removes any forwarding client, used when this node
+// becomes active
+func (c *Core) clearForwardingClients() {
+	c.requestForwardingConnectionLock.Lock()
+	defer c.requestForwardingConnectionLock.Unlock()
+	c.requestForwardingConnection = nil
+}
+
+// ForwardRequest forwards the given HTTP request to the active node over the
+// cluster channel and returns the active node's response
+func (c *Core) ForwardRequest(req *http.Request) (int, http.Header, []byte, error) {
+	c.requestForwardingConnectionLock.RLock()
+	conn := c.requestForwardingConnection
+	c.requestForwardingConnectionLock.RUnlock()
+	if conn == nil {
+		return 0, nil, nil, ErrCannotForward
+	}
+
+	addr := strings.TrimSuffix(conn.clusterAddr, "/") + clusterForwardingPath
+	freq, err := forwarding.GenerateForwardedRequest(req, addr)
+	if err != nil {
+		c.logger.Printf("[ERR] core: error creating forwarded request: %v", err)
+		return 0, nil, nil, fmt.Errorf("error creating forwarding request")
+	}
+
+	resp, err := conn.Do(freq)
+	if err != nil {
+		c.logger.Printf("[ERR] core: error forwarding request to %s: %v", conn.clusterAddr, err)
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	return resp.StatusCode, resp.Header, body, nil
+}
@@ -0,0 +1,376 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// controlGroupSubPath is the sub-path used for the requests awaiting
+	// approval within the system view
+	controlGroupSubPath = "control-group/"
+
+	// defaultControlGroupTTL is how long a request awaits approval if its
+	// control group sets no TTL
+	defaultControlGroupTTL = 24 * time.Hour
+)
+
+// ControlGroup requires a request to be approved by a number of others
+// holding one of the approver policies, or belonging to one of the
+// approver groups, before it is executed
+type ControlGroup struct {
+	Approvals        int           `hcl:"approvals"`
+	ApproverPolicies []string      `hcl:"approver_policies"`
+	ApproverGroups   []string      `hcl:"approver_groups"`
+	TTL              time.Duration `hcl:"-"`
+	TTLRaw           string        `hcl:"ttl"`
+}
+
+// validate checks the control group of a policy path and sets its
+// defaults
+func (cg *ControlGroup) validate() error {
+	if cg.Approvals == 0 {
+		cg.Approvals = 1
+	}
+	if cg.Approvals < 0 {
+		return fmt.Errorf("control group approvals must be positive")
+	}
+	if len(cg.ApproverPolicies) == 0 && len(cg.ApproverGroups) == 0 {
+		return fmt.Errorf("control group requires approver_policies or approver_groups")
+	}
+
+	cg.TTL = defaultControlGroupTTL
+	if cg.TTLRaw != "" {
+		ttl, err := time.ParseDuration(cg.TTLRaw)
+		if err != nil {
+			return fmt.Errorf("invalid control group ttl: %v", err)
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("control group ttl must be positive")
+		}
+		cg.TTL = ttl
+	}
+	return nil
+}
+
+// mergeControlGroups combines the control groups of several policies on
+// the same path into the strictest: the most approvals from any of the
+// approvers, within the shortest TTL
+func mergeControlGroups(existing, other *ControlGroup) *ControlGroup {
+	switch {
+	case other == nil:
+		return existing
+	case existing == nil:
+		return other
+	}
+
+	result := &ControlGroup{
+		Approvals:        existing.Approvals,
+		ApproverPolicies: append([]string{}, existing.ApproverPolicies...),
+		ApproverGroups:   append([]string{}, existing.ApproverGroups...),
+		TTL:              existing.TTL,
+	}
+	if other.Approvals > result.Approvals {
+		result.Approvals = other.Approvals
+	}
+	for _, name := range other.ApproverPolicies {
+		if !strutil.StrListContains(result.ApproverPolicies, name) {
+			result.ApproverPolicies = append(result.ApproverPolicies, name)
+		}
+	}
+	for _, name := range other.ApproverGroups {
+		if !strutil.StrListContains(result.ApproverGroups, name) {
+			result.ApproverGroups = append(result.ApproverGroups, name)
+		}
+	}
+	if other.TTL < result.TTL {
+		result.TTL = other.TTL
+	}
+	return result
+}
+
+// controlGroupRequest is a request parked until its control group has
+// approved it. It is keyed by the accessor of the response-wrapping token
+// the requester receives, whose cubbyhole holds the response once the
+// request has been executed.
+type controlGroupRequest struct {
+	Accessor     string    `json:"accessor"`
+	CreationTime time.Time `json:"creation_time"`
+
+	// The request itself. The data is cleared once the request has been
+	// executed.
+	Operation  logical.Operation      `json:"operation"`
+	Path       string                 `json:"path"`
+	Data       map[string]interface{} `json:"data"`
+	RemoteAddr string                 `json:"remote_addr"`
+
+	// The requester, identified by the entity of its token. The request is
+	// executed with the token found by its accessor, so that the token
+	// itself is not stored.
+	RequesterEntityID string `json:"requester_entity_id"`
+	RequesterAccessor string `json:"requester_accessor"`
+
+	Approvals        int      `json:"approvals"`
+	ApproverPolicies []string `json:"approver_policies"`
+	ApproverGroups   []string `json:"approver_groups"`
+
+	// Authorizations holds the entities of the approvers so far
+	Authorizations []string `json:"authorizations"`
+	Approved       bool     `json:"approved"`
+}
+
+// controlGroupView returns the view the requests awaiting approval are
+// stored in
+func (c *Core) controlGroupView() *BarrierView {
+	return c.systemBarrierView.SubView(controlGroupSubPath)
+}
+
+// parkControlGroupRequest stores a request requiring the approval of the
+// control group and returns a response-wrapping token in its place. Tokens
+// without an entity cannot make such requests: child tokens would let a
+// single client pass for several. Nor can tokens without an accessor, such
+// as batch tokens, as the request is executed with the token found by it.
+func (c *Core) parkControlGroupRequest(req *logical.Request, te *TokenEntry, cg *ControlGroup) (*logical.Response, error) {
+	if te.EntityID == "" {
+		return logical.ErrorResponse("control group requests require a token with an entity"),
+			logical.ErrPermissionDenied
+	}
+	if te.Accessor == "" {
+		return logical.ErrorResponse("control group requests require a token with an accessor"),
+			logical.ErrPermissionDenied
+	}
+
+	wrapInfo, err := c.createWrappingToken(cg.TTL, "")
+	if err != nil {
+		return nil, err
+	}
+
+	cgReq := &controlGroupRequest{
+		Accessor:          wrapInfo.Accessor,
+		CreationTime:      wrapInfo.CreationTime,
+		Operation:         req.Operation,
+		Path:              req.Path,
+		Data:              req.Data,
+		RequesterEntityID: te.EntityID,
+		RequesterAccessor: te.Accessor,
+		Approvals:         cg.Approvals,
+		ApproverPolicies:  cg.ApproverPolicies,
+		ApproverGroups:    cg.ApproverGroups,
+	}
+	if req.Connection != nil {
+		cgReq.RemoteAddr = req.Connection.RemoteAddr
+	}
+	if err := c.persistControlGroupRequest(cgReq); err != nil {
+		c.tokenStore.Revoke(wrapInfo.Token)
+		return nil, err
+	}
+
+	resp := &logical.Response{
+		WrapInfo: wrapInfo,
+	}
+	resp.AddWarning(fmt.Sprintf(
+		"Request requires %d control group approval(s); the response can be unwrapped once approved",
+		cg.Approvals))
+	return resp, nil
+}
+
+func (c *Core) persistControlGroupRequest(cgReq *controlGroupRequest) error {
+	entry, err := logical.StorageEntryJSON(cgReq.Accessor, cgReq)
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to encode control group request: %v", err)
+		return ErrInternalError
+	}
+	if err := c.controlGroupView().Put(entry); err != nil {
+		c.logger.Printf("[ERR] core: failed to persist control group request: %v", err)
+		return ErrInternalError
+	}
+	return nil
+}
+
+// controlGroupRequest returns the request parked under the accessor of the
+// given response-wrapping token, or nil if there is none. Requests whose
+// wrapping token has expired are removed.
+func (c *Core) controlGroupRequest(accessor string) (*controlGroupRequest, error) {
+	if accessor == "" {
+		return nil, nil
+	}
+
+	view := c.controlGroupView()
+	entry, err := view.Get(accessor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read control group request: %v", err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	if token, err := c.tokenStore.lookupByAccessor(accessor); err != nil || token == "" {
+		if err := view.Delete(accessor); err != nil {
+			c.logger.Printf("[ERR] core: failed to remove expired control group request: %v", err)
+		}
+		return nil, nil
+	}
+
+	var cgReq controlGroupRequest
+	if err := json.Unmarshal(entry.Value, &cgReq); err != nil {
+		return nil, fmt.Errorf("failed to decode control group request: %v", err)
+	}
+	return &cgReq, nil
+}
+
+// awaitingControlGroup checks if the token is the response-wrapping token
+// of a request still awaiting the approval of its control group. Such a
+// token is not used up by attempts to unwrap the response before it is in.
+func (c *Core) awaitingControlGroup(te *TokenEntry) bool {
+	if te.Path != wrappingTokenPath {
+		return false
+	}
+	cgReq, err := c.controlGroupRequest(te.Accessor)
+	return err == nil && cgReq != nil && !cgReq.Approved
+}
+
+// authorizeControlGroupRequest adds the approval of the given token to the
+// request parked under the accessor. Once enough approvals have been given
+// the request is executed and its response stored for the requester.
+func (c *Core) authorizeControlGroupRequest(accessor, token string) (*controlGroupRequest, error) {
+	c.controlGroupLock.Lock()
+	defer c.controlGroupLock.Unlock()
+
+	cgReq, err := c.controlGroupRequest(accessor)
+	if err != nil {
+		return nil, err
+	}
+	if cgReq == nil {
+		return nil, fmt.Errorf("no control group request found for accessor")
+	}
+	if cgReq.Approved {
+		return nil, fmt.Errorf("request has already been approved")
+	}
+
+	te, err := c.tokenStore.Lookup(token)
+	if err != nil {
+		return nil, err
+	}
+	if te == nil {
+		return nil, logical.ErrPermissionDenied
+	}
+
+	// Approvals are counted per entity, which the child tokens of an
+	// approver share
+	if te.EntityID == "" {
+		return nil, fmt.Errorf("control group approvals require a token with an entity")
+	}
+	if te.EntityID == cgReq.RequesterEntityID {
+		return nil, fmt.Errorf("requests cannot be approved by their requester")
+	}
+	if strutil.StrListContains(cgReq.Authorizations, te.EntityID) {
+		return nil, fmt.Errorf("request has already been approved by this entity")
+	}
+	if !c.controlGroupApprover(cgReq, te) {
+		return nil, logical.ErrPermissionDenied
+	}
+
+	cgReq.Authorizations = append(cgReq.Authorizations, te.EntityID)
+	if len(cgReq.Authorizations) >= cgReq.Approvals {
+		if err := c.executeControlGroupRequest(cgReq); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.persistControlGroupRequest(cgReq); err != nil {
+		return nil, err
+	}
+	return cgReq, nil
+}
+
+// controlGroupApprover checks if the token holds one of the approver
+// policies of the request, itself or through its entity, or if its entity
+// is a member of one of the approver groups
+func (c *Core) controlGroupApprover(cgReq *controlGroupRequest, te *TokenEntry) bool {
+	policies := te.Policies
+	var groups []string
+	if te.EntityID != "" && c.identityStore != nil {
+		policies = append(append([]string{}, policies...), c.identityStore.EntityPolicies(te.EntityID)...)
+		groups = c.identityStore.EntityGroupNames(te.EntityID)
+	}
+
+	for _, name := range cgReq.ApproverPolicies {
+		if strutil.StrListContains(policies, name) {
+			return true
+		}
+	}
+	for _, name := range cgReq.ApproverGroups {
+		if strutil.StrListContains(groups, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// executeControlGroupRequest executes the approved request with the token
+// of its requester, as long as it is still valid, and stores the response in the cubbyhole of the
+// response-wrapping token the requester holds
+func (c *Core) executeControlGroupRequest(cgReq *controlGroupRequest) error {
+	wrappingToken, err := c.tokenStore.lookupByAccessor(cgReq.Accessor)
+	if err != nil || wrappingToken == "" {
+		return fmt.Errorf("response-wrapping token of the request has expired")
+	}
+	clientToken, err := c.tokenStore.lookupByAccessor(cgReq.RequesterAccessor)
+	if err != nil {
+		return err
+	}
+	if clientToken == "" {
+		return fmt.Errorf("token of the requester is no longer valid")
+	}
+
+	req := &logical.Request{
+		Operation:   cgReq.Operation,
+		Path:        cgReq.Path,
+		Data:        cgReq.Data,
+		ClientToken: clientToken,
+		Connection: &logical.Connection{
+			RemoteAddr: cgReq.RemoteAddr,
+		},
+	}
+	resp, auth, err := c.handleRequest(req, true)
+
+	// Ensure we don't leak internal data
+	if resp != nil {
+		if resp.Secret != nil {
+			resp.Secret.InternalData = nil
+		}
+		if resp.Auth != nil {
+			resp.Auth.InternalData = nil
+		}
+	}
+
+	if err := c.auditBroker.LogResponse(auth, req, resp, err); err != nil {
+		c.logger.Printf("[ERR] core: failed to audit response (request path: %s): %v",
+			req.Path, err)
+		return ErrInternalError
+	}
+
+	// Errors are returned to the requester as the response
+	if err != nil && (resp == nil || !resp.IsError()) {
+		resp = logical.ErrorResponse(err.Error())
+	}
+	if resp == nil {
+		resp = &logical.Response{}
+	}
+	marshaled, err := json.Marshal(logical.SanitizeResponse(resp))
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to marshal control group response: %v", err)
+		return ErrInternalError
+	}
+	if err := c.storeWrappedResponse(wrappingToken, string(marshaled)); err != nil {
+		return err
+	}
+
+	cgReq.Approved = true
+	cgReq.Data = nil
+	return nil
+}
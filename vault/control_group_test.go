@@ -0,0 +1,268 @@
+package vault
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPolicy_ParseControlGroup(t *testing.T) {
+	p, err := Parse(strings.TrimSpace(`
+path "sys/raw/*" {
+	capabilities = ["read"]
+	control_group {
+		approvals = 2
+		approver_policies = ["security"]
+		approver_groups = ["admins"]
+		ttl = "4h"
+	}
+}
+path "pki/root/generate/*" {
+	capabilities = ["update"]
+	control_group {
+		approver_groups = ["admins"]
+	}
+}
+`))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cg := p.Paths[0].ControlGroup
+	if cg.Approvals != 2 || cg.TTL.String() != "4h0m0s" ||
+		!reflect.DeepEqual(cg.ApproverPolicies, []string{"security"}) ||
+		!reflect.DeepEqual(cg.ApproverGroups, []string{"admins"}) {
+		t.Fatalf("bad: %#v", cg)
+	}
+	cg = p.Paths[1].ControlGroup
+	if cg.Approvals != 1 || cg.TTL != defaultControlGroupTTL {
+		t.Fatalf("bad: %#v", cg)
+	}
+
+	for _, rules := range []string{
+		`approvals = 2`,
+		`approvals = -1
+		approver_policies = ["security"]`,
+		`approver_policies = ["security"]
+		ttl = "soon"`,
+	} {
+		_, err := Parse(`path "sys/raw/*" {
+	capabilities = ["read"]
+	control_group {
+		` + rules + `
+	}
+}`)
+		if err == nil {
+			t.Fatalf("should fail: %s", rules)
+		}
+	}
+}
+
+func TestACL_ControlGroup(t *testing.T) {
+	policy1, err := Parse(`
+path "sys/raw/*" {
+	capabilities = ["read"]
+	control_group {
+		approver_policies = ["security"]
+		ttl = "4h"
+	}
+}
+path "secret/*" {
+	capabilities = ["read"]
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	policy2, err := Parse(`
+path "sys/raw/*" {
+	capabilities = ["read"]
+	control_group {
+		approvals = 2
+		approver_groups = ["admins"]
+	}
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	acl, err := NewACL([]*Policy{policy1, policy2})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The control groups of several policies combine into the strictest
+	cg := acl.ControlGroup(&logical.Request{Operation: logical.ReadOperation, Path: "sys/raw/foo"})
+	if cg == nil || cg.Approvals != 2 || cg.TTL.String() != "4h0m0s" ||
+		!reflect.DeepEqual(cg.ApproverPolicies, []string{"security"}) ||
+		!reflect.DeepEqual(cg.ApproverGroups, []string{"admins"}) {
+		t.Fatalf("bad: %#v", cg)
+	}
+	if cg := acl.ControlGroup(&logical.Request{Operation: logical.ReadOperation, Path: "secret/foo"}); cg != nil {
+		t.Fatalf("bad: %#v", cg)
+	}
+}
+
+func TestCore_ControlGroup(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	testIdentityRequest(t, c, logical.UpdateOperation, "sys/policy/requester", root, map[string]interface{}{
+		"rules": `
+path "secret/foo" {
+	capabilities = ["read"]
+	control_group {
+		approvals = 2
+		approver_policies = ["approver"]
+	}
+}
+path "sys/control-group/*" {
+	capabilities = ["update"]
+}
+path "auth/token/create" {
+	capabilities = ["update"]
+}`,
+	})
+	testIdentityRequest(t, c, logical.UpdateOperation, "sys/policy/approver", root, map[string]interface{}{
+		"rules": `
+path "sys/control-group/*" { capabilities = ["update"] }
+path "auth/token/create" { capabilities = ["update"] }`,
+	})
+	testIdentityRequest(t, c, logical.UpdateOperation, "secret/foo", root, map[string]interface{}{
+		"zip": "zap",
+	})
+
+	// Every client logs in as an entity of its own
+	noop := &NoopBackend{Login: []string{"login"}}
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return noop, nil
+	}
+	testIdentityRequest(t, c, logical.UpdateOperation, "sys/auth/foo", root, map[string]interface{}{
+		"type": "noop",
+	})
+	tokens := make(map[string]string)
+	for _, name := range []string{"requester", "approver1", "approver2"} {
+		noop.Response = &logical.Response{
+			Auth: &logical.Auth{
+				Policies: []string{strings.TrimRight(name, "12")},
+				Alias:    &logical.Alias{Name: name},
+			},
+		}
+		resp, err := c.HandleRequest(&logical.Request{Path: "auth/foo/login"})
+		if err != nil {
+			t.Fatalf("err: %v %v", err, resp)
+		}
+		tokens[name] = resp.Auth.ClientToken
+	}
+	childToken := func(token, policy string) string {
+		resp := testIdentityRequest(t, c, logical.UpdateOperation, "auth/token/create", token, map[string]interface{}{
+			"policies": []string{policy},
+		})
+		return resp.Auth.ClientToken
+	}
+
+	// Tokens without an entity cannot make requests
+	req := logical.TestRequest(t, logical.ReadOperation, "secret/foo")
+	req.ClientToken = childToken(root, "requester")
+	if _, err := c.HandleRequest(req); err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The request is parked rather than executed
+	resp := testIdentityRequest(t, c, logical.ReadOperation, "secret/foo", tokens["requester"], nil)
+	if resp == nil || resp.WrapInfo == nil || resp.WrapInfo.Accessor == "" || resp.Data != nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+	wrapToken, accessor := resp.WrapInfo.Token, resp.WrapInfo.Accessor
+
+	// It cannot be unwrapped before it has been approved
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/wrapping/unwrap")
+	req.ClientToken = root
+	req.Data["token"] = wrapToken
+	resp, err := c.HandleRequest(req)
+	if err == nil || !strings.Contains(resp.Data["error"].(string), "awaiting control group approval") {
+		t.Fatalf("bad: %#v %v", resp, err)
+	}
+
+	// Nor does trying with the wrapping token itself use it up
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/wrapping/unwrap")
+	req.ClientToken = wrapToken
+	if _, err := c.HandleRequest(req); err == nil {
+		t.Fatalf("should fail")
+	}
+	if te, err := c.tokenStore.Lookup(wrapToken); err != nil || te == nil || te.NumUses != 1 {
+		t.Fatalf("bad: %v %#v", err, te)
+	}
+
+	// The token of the requester is not stored
+	entry, err := c.controlGroupView().Get(accessor)
+	if err != nil || entry == nil {
+		t.Fatalf("bad: %v %#v", err, entry)
+	}
+	if strings.Contains(string(entry.Value), tokens["requester"]) {
+		t.Fatalf("token stored: %s", entry.Value)
+	}
+
+	// Requesters cannot approve their own requests, nor can others
+	// approve twice, even through child tokens
+	authorize := func(token string) (*logical.Response, error) {
+		req := logical.TestRequest(t, logical.UpdateOperation, "sys/control-group/authorize")
+		req.ClientToken = token
+		req.Data["accessor"] = accessor
+		req.Data["token"] = token
+		return c.HandleRequest(req)
+	}
+	if _, err := authorize(tokens["requester"]); err == nil {
+		t.Fatalf("should fail")
+	}
+	if _, err := authorize(childToken(tokens["requester"], "requester")); err == nil {
+		t.Fatalf("should fail")
+	}
+	resp, err = authorize(tokens["approver1"])
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if resp.Data["approved"] != false || resp.Data["approvals"] != 1 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if _, err := authorize(tokens["approver1"]); err == nil {
+		t.Fatalf("should fail")
+	}
+	if _, err := authorize(childToken(tokens["approver1"], "approver")); err == nil {
+		t.Fatalf("should fail")
+	}
+
+	// Tokens without an entity cannot approve
+	if _, err := authorize(childToken(root, "approver")); err == nil {
+		t.Fatalf("should fail")
+	}
+
+	// The final approval executes the request
+	resp, err = authorize(tokens["approver2"])
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if resp.Data["approved"] != true {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	resp = testIdentityRequest(t, c, logical.UpdateOperation, "sys/control-group/request", root, map[string]interface{}{
+		"accessor": accessor,
+	})
+	if resp.Data["approved"] != true || resp.Data["request_path"] != "secret/foo" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// The requester unwraps the response
+	resp = testIdentityRequest(t, c, logical.UpdateOperation, "sys/wrapping/unwrap", wrapToken, map[string]interface{}{
+		"token": wrapToken,
+	})
+	var unwrapped logical.HTTPResponse
+	if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &unwrapped); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unwrapped.Data["zip"] != "zap" {
+		t.Fatalf("bad: %#v", unwrapped)
+	}
+}
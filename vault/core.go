@@ -2,21 +2,28 @@ package vault
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/armon/go-metrics"
+	"github.com/armon/go-radix"
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/cidrutil"
 	"github.com/hashicorp/vault/helper/mlock"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
@@ -120,6 +127,11 @@ type Core struct {
 	// Our Seal, for seal configuration information
 	seal Seal
 
+	// newSeal is the seal being migrated to while a seal migration is
+	// pending. The master key is still protected by seal until Vault is
+	// unsealed with migration.
+	newSeal Seal
+
 	// barrier is the security barrier wrapping the physical backend
 	barrier SecurityBarrier
 
@@ -179,6 +191,23 @@ type Core struct {
 	// change underneath a calling function
 	authLock sync.RWMutex
 
+	// namespaces is loaded after unseal since it is a protected
+	// configuration. namespacePaths indexes it by path, and
+	// namespacePolicyStores holds the policy store of each namespace
+	// other than the root by namespace ID.
+	namespaces            *NamespaceTable
+	namespacePaths        *radix.Tree
+	namespacePolicyStores map[string]*PolicyStore
+
+	// namespacesLock is used to ensure that the namespace table does not
+	// change underneath a calling function. It is never held while
+	// acquiring the mounts or auth locks.
+	namespacesLock sync.RWMutex
+
+	// namespaceSystemBackend is the system backend served within
+	// namespaces other than the root
+	namespaceSystemBackend logical.Backend
+
 	// audit is loaded after unseal since it is a protected
 	// configuration
 	audit *MountTable
@@ -207,6 +236,39 @@ type Core struct {
 	// token store is used to manage authentication tokens
 	tokenStore *TokenStore
 
+	// identityStore is used to manage the entities and groups clients
+	// are resolved to on login
+	identityStore *IdentityStore
+
+	// pluginDirectory is the directory holding the plugin binaries which
+	// can be registered in the plugin catalog
+	pluginDirectory string
+
+	// pluginCatalog holds the plugins the mounts of type plugin are
+	// served by
+	pluginCatalog *PluginCatalog
+
+	// rateLimitQuotas are the rate limit quotas by name. They are loaded
+	// after unseal.
+	rateLimitQuotas map[string]*RateLimitQuota
+
+	// leaseCountQuotas are the lease count quotas by name. They are
+	// loaded after unseal, along with the counts of leases they hold.
+	leaseCountQuotas map[string]*LeaseCountQuota
+
+	// quotasLock is used to ensure that the quotas do not change
+	// underneath a calling function
+	quotasLock sync.RWMutex
+
+	// quotasStopCh and quotasDoneCh are used to stop persisting the
+	// buckets of the clients of the rate limit quotas
+	quotasStopCh chan struct{}
+	quotasDoneCh chan struct{}
+
+	// controlGroupLock serializes the approvals of requests awaiting
+	// the approval of a control group
+	controlGroupLock sync.Mutex
+
 	// metricsCh is used to stop the metrics streaming
 	metricsCh chan struct{}
 
@@ -218,6 +280,33 @@ type Core struct {
 	maxLeaseTTL     time.Duration
 
 	logger *log.Logger
+
+	// clusterAddr is the address we advertise to standbys for request
+	// forwarding if we hold the lock
+	clusterAddr string
+
+	// clusterListenerAddrs is the set of addresses the cluster listeners
+	// bind to while this node is active
+	clusterListenerAddrs []*net.TCPAddr
+
+	// clusterHandler is the handler used to serve forwarded requests
+	clusterHandler http.Handler
+
+	// clusterListeners are the running cluster listeners, if active
+	clusterListeners []net.Listener
+
+	// localClusterPrivateKey and localClusterCert are the TLS identity of
+	// the cluster channel generated by the active node
+	localClusterPrivateKey *ecdsa.PrivateKey
+	localClusterCert       []byte
+
+	// clusterParamsLock protects the cluster values above
+	clusterParamsLock sync.RWMutex
+
+	// requestForwardingConnection is the client a standby uses to forward
+	// requests to the active node
+	requestForwardingConnection     *activeConnection
+	requestForwardingConnectionLock sync.RWMutex
 }
 
 // CoreConfig is used to parameterize a core
@@ -228,11 +317,14 @@ type CoreConfig struct {
 	Physical           physical.Backend
 	HAPhysical         physical.HABackend // May be nil, which disables HA operations
 	Seal               Seal
+	OldSeal            Seal // The seal previously in use, to migrate from
 	Logger             *log.Logger
 	DisableCache       bool   // Disables the LRU cache on the physical backend
 	DisableMlock       bool   // Disables mlock syscall
 	CacheSize          int    // Custom cache size of zero for default
 	AdvertiseAddr      string // Set as the leader address for HA
+	ClusterAddr        string // Set as the cluster address for request forwarding
+	PluginDirectory    string // Directory holding the plugin binaries
 	DefaultLeaseTTL    time.Duration
 	MaxLeaseTTL        time.Duration
 }
@@ -265,6 +357,31 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		}
 	}
 
+	// Validate the cluster addr if its given to us
+	if conf.ClusterAddr != "" {
+		u, err := url.Parse(conf.ClusterAddr)
+		if err != nil {
+			return nil, fmt.Errorf("cluster address is not valid url: %s", err)
+		}
+
+		if u.Scheme != "https" {
+			return nil, fmt.Errorf("cluster address must use the 'https' scheme")
+		}
+	}
+
+	// Resolve the plugin directory, so plugin commands can be checked to
+	// stay within it
+	var pluginDirectory string
+	if conf.PluginDirectory != "" {
+		dir, err := filepath.Abs(conf.PluginDirectory)
+		if err != nil {
+			return nil, fmt.Errorf("plugin directory is not valid: %s", err)
+		}
+		if pluginDirectory, err = filepath.EvalSymlinks(dir); err != nil {
+			return nil, fmt.Errorf("plugin directory is not valid: %s", err)
+		}
+	}
+
 	// Wrap the backend in a cache unless disabled
 	if !conf.DisableCache {
 		_, isCache := conf.Physical.(*physical.Cache)
@@ -306,6 +423,8 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 	c := &Core{
 		ha:              conf.HAPhysical,
 		advertiseAddr:   conf.AdvertiseAddr,
+		clusterAddr:     conf.ClusterAddr,
+		pluginDirectory: pluginDirectory,
 		physical:        conf.Physical,
 		seal:            conf.Seal,
 		barrier:         barrier,
@@ -327,6 +446,10 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		logicalBackends["generic"] = PassthroughBackendFactory
 	}
 	logicalBackends["cubbyhole"] = CubbyholeBackendFactory
+	logicalBackends[pluginBackendType] = c.newPluginBackend
+	logicalBackends["identity"] = func(config *logical.BackendConfig) (logical.Backend, error) {
+		return NewIdentityStore(c, config)
+	}
 	logicalBackends["system"] = func(config *logical.BackendConfig) (logical.Backend, error) {
 		return NewSystemBackend(c, config), nil
 	}
@@ -336,6 +459,7 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 	for k, f := range conf.CredentialBackends {
 		credentialBackends[k] = f
 	}
+	credentialBackends[pluginBackendType] = c.newPluginBackend
 	credentialBackends["token"] = func(config *logical.BackendConfig) (logical.Backend, error) {
 		return NewTokenStore(c, config)
 	}
@@ -352,6 +476,12 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 	}
 	c.seal.SetCore(c)
 
+	if conf.OldSeal != nil {
+		if err := c.setupSealMigration(conf.OldSeal); err != nil {
+			return nil, err
+		}
+	}
+
 	// Attempt unsealing with stored keys; if there are no stored keys this
 	// returns nil, otherwise returns nil or an error
 	storedKeyErr := c.UnsealWithStoredKeys()
@@ -385,6 +515,11 @@ func (c *Core) HandleRequest(req *logical.Request) (resp *logical.Response, err
 		return nil, ErrStandby
 	}
 
+	// Refuse requests of clients exceeding their rate limit quota
+	if err := c.checkRateLimitQuotas(req); err != nil {
+		return nil, err
+	}
+
 	// Allowing writing to a path ending in / makes it extremely difficult to
 	// understand user intent for the filesystem-like backends (generic,
 	// cubbyhole) -- did they want a key named foo/ or did they want to write
@@ -401,7 +536,7 @@ func (c *Core) HandleRequest(req *logical.Request) (resp *logical.Response, err
 	if c.router.LoginPath(req.Path) {
 		resp, auth, err = c.handleLoginRequest(req)
 	} else {
-		resp, auth, err = c.handleRequest(req)
+		resp, auth, err = c.handleRequest(req, false)
 	}
 
 	// Ensure we don't leak internal data
@@ -414,6 +549,15 @@ func (c *Core) HandleRequest(req *logical.Request) (resp *logical.Response, err
 		}
 	}
 
+	// If response wrapping was requested, store the response in the
+	// cubbyhole of a single-use token and only return the token info
+	if err == nil && req.WrapTTL != 0 && resp != nil && !resp.IsError() &&
+		resp.Redirect == "" && resp.WrapInfo == nil {
+		if _, ok := resp.Data[logical.HTTPContentType]; !ok {
+			resp, err = c.wrapInCubbyhole(req, resp)
+		}
+	}
+
 	// Create an audit trail of the response
 	if err := c.auditBroker.LogResponse(auth, req, resp, err); err != nil {
 		c.logger.Printf("[ERR] core: failed to audit response (request path: %s): %v",
@@ -424,13 +568,22 @@ func (c *Core) HandleRequest(req *logical.Request) (resp *logical.Response, err
 	return
 }
 
-func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, retAuth *logical.Auth, retErr error) {
+// handleRequest handles a request that is not a login. Requests to paths
+// behind a control group are parked until approved, unless approved is set
+// for executing a request that has been.
+func (c *Core) handleRequest(req *logical.Request, approved bool) (retResp *logical.Response, retAuth *logical.Auth, retErr error) {
 	defer metrics.MeasureSince([]string{"core", "handle_request"}, time.Now())
 
 	// Validate the token
-	auth, te, err := c.checkToken(req)
+	auth, te, controlGroup, err := c.checkToken(req)
 	if te != nil {
 		defer func() {
+			// The response-wrapping token of a request awaiting approval
+			// is only used up once the response can be unwrapped
+			if c.awaitingControlGroup(te) {
+				return
+			}
+
 			// Attempt to use the token (decrement num_uses)
 			// If a secret was generated and num_uses is currently 1, it will be
 			// immediately revoked; in that case, don't return the leased
@@ -479,12 +632,22 @@ func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, r
 		return nil, auth, ErrInternalError
 	}
 
+	// Park the request until the control group has approved it
+	if controlGroup != nil && !approved {
+		resp, err := c.parkControlGroupRequest(req, te, controlGroup)
+		return resp, auth, err
+	}
+
 	// Route the request
 	resp, err := c.router.Route(req)
 
+	// The builtin backends are served at the same paths within every
+	// namespace
+	nsPath := strings.TrimPrefix(req.Path, c.namespaceByPath(req.Path).Path)
+
 	// If there is a secret, we must register it with the expiration manager.
 	// We exclude renewal of a lease, since it does not need to be re-registered
-	if resp != nil && resp.Secret != nil && !strings.HasPrefix(req.Path, "sys/renew/") {
+	if resp != nil && resp.Secret != nil && !strings.HasPrefix(nsPath, "sys/renew/") {
 		// Get the SystemView for the mount
 		sysView := c.router.MatchingSystemView(req.Path)
 		if sysView == nil {
@@ -519,8 +682,21 @@ func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, r
 		}
 
 		if registerLease {
+			// Secrets exceeding a lease count quota are revoked right
+			// away rather than handed out
+			if err := c.reserveLease(req.Path); err != nil {
+				revokeReq := logical.RevokeRequest(req.Path, resp.Secret, resp.Data)
+				if _, revokeErr := c.router.Route(revokeReq); revokeErr != nil {
+					c.logger.Printf(
+						"[ERR] core: failed to revoke secret exceeding lease count quota "+
+							"(request path: %s): %v", req.Path, revokeErr)
+				}
+				return nil, auth, err
+			}
+
 			leaseID, err := c.expiration.Register(req, resp)
 			if err != nil {
+				c.releaseLease(req.Path)
 				c.logger.Printf(
 					"[ERR] core: failed to register lease "+
 						"(request path: %s): %v", req.Path, err)
@@ -533,8 +709,8 @@ func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, r
 	// Only the token store is allowed to return an auth block, for any
 	// other request this is an internal error. We exclude renewal of a token,
 	// since it does not need to be re-registered
-	if resp != nil && resp.Auth != nil && !strings.HasPrefix(req.Path, "auth/token/renew") {
-		if !strings.HasPrefix(req.Path, "auth/token/") {
+	if resp != nil && resp.Auth != nil && !strings.HasPrefix(nsPath, "auth/token/renew") {
+		if !strings.HasPrefix(nsPath, "auth/token/") {
 			c.logger.Printf(
 				"[ERR] core: unexpected Auth response for non-token backend "+
 					"(request path: %s)", req.Path)
@@ -587,9 +763,12 @@ func (c *Core) handleLoginRequest(req *logical.Request) (*logical.Response, *log
 	if resp != nil && resp.Auth != nil {
 		auth = resp.Auth
 
+		// Tokens are issued in the namespace of the credential backend
+		ns := c.namespaceByPath(req.Path)
+
 		// Determine the source of the login
 		source := c.router.MatchingMount(req.Path)
-		source = strings.TrimPrefix(source, credentialRoutePrefix)
+		source = strings.TrimPrefix(source, ns.Path+credentialRoutePrefix)
 		source = strings.Replace(source, "/", "-", -1)
 
 		// Prepend the source to the display name
@@ -614,12 +793,35 @@ func (c *Core) handleLoginRequest(req *logical.Request) (*logical.Response, *log
 
 		// Generate a token
 		te := TokenEntry{
-			Path:         req.Path,
-			Policies:     auth.Policies,
-			Meta:         auth.Metadata,
-			DisplayName:  auth.DisplayName,
-			CreationTime: time.Now().Unix(),
-			TTL:          auth.TTL,
+			Path:          req.Path,
+			NamespaceID:   ns.ID,
+			Policies:      auth.Policies,
+			Meta:          auth.Metadata,
+			MetaFromLogin: true,
+			DisplayName:   auth.DisplayName,
+			CreationTime:  time.Now().Unix(),
+			TTL:           auth.TTL,
+			Type:          auth.TokenType,
+		}
+
+		// The credential backend may pick the type of the token, otherwise
+		// the mount does
+		if te.Type == "" {
+			if mount := c.router.MatchingMountEntry(req.Path); mount != nil {
+				te.Type = mount.Config.TokenType
+			}
+		}
+		if !validTokenType(te.Type) {
+			c.logger.Printf("[ERR] core: invalid token type %q "+
+				"(request path: %s)", te.Type, req.Path)
+			return nil, auth, ErrInternalError
+		}
+
+		// Batch tokens cannot be revoked, so root ones are not exempt
+		// from the default lease either
+		if te.Type == tokenTypeBatch && te.TTL == 0 {
+			te.TTL = sysView.DefaultLeaseTTL()
+			auth.TTL = te.TTL
 		}
 
 		if strutil.StrListSubset(te.Policies, []string{"root"}) {
@@ -647,15 +849,33 @@ func (c *Core) handleLoginRequest(req *logical.Request) (*logical.Response, *log
 			sort.Strings(te.Policies)
 		}
 
+		// Resolve the client to its entity, when the credential backend
+		// tells who the client is within it
+		if auth.Alias != nil && c.identityStore != nil {
+			mount := c.router.MatchingMountEntry(req.Path)
+			entity, err := c.identityStore.EntityByAlias(mount, auth.Alias, auth.GroupAliases)
+			if err != nil {
+				c.logger.Printf("[ERR] core: failed to resolve entity "+
+					"(request path: %s): %v", req.Path, err)
+				return nil, auth, ErrInternalError
+			}
+			te.EntityID = entity.ID
+		}
+
 		if err := c.tokenStore.create(&te); err != nil {
 			c.logger.Printf("[ERR] core: failed to create token: %v", err)
 			return nil, auth, ErrInternalError
 		}
 
-		// Populate the client token and accessor
+		// Populate the client token, accessor and entity
 		auth.ClientToken = te.ID
 		auth.Accessor = te.Accessor
+		auth.EntityID = te.EntityID
 		auth.Policies = te.Policies
+		auth.TokenType = te.Type
+		if te.Type == tokenTypeBatch {
+			auth.Renewable = false
+		}
 
 		// Register with the expiration manager
 		if err := c.expiration.RegisterAuth(te.Path, auth); err != nil {
@@ -697,7 +917,10 @@ func (c *Core) fetchACLandTokenEntry(req *logical.Request) (*ACL, *TokenEntry, e
 	}
 
 	// Construct the corresponding ACL object
-	acl, err := c.policyStore.ACL(te.Policies...)
+	acl, err := c.tokenACL(te)
+	if err == logical.ErrPermissionDenied {
+		return nil, nil, err
+	}
 	if err != nil {
 		c.logger.Printf("[ERR] core: failed to construct ACL: %v", err)
 		return nil, nil, ErrInternalError
@@ -706,12 +929,43 @@ func (c *Core) fetchACLandTokenEntry(req *logical.Request) (*ACL, *TokenEntry, e
 	return acl, te, nil
 }
 
-func (c *Core) checkToken(req *logical.Request) (*logical.Auth, *TokenEntry, error) {
+// tokenACL returns the ACL of the token, built from the policies of the
+// namespace it was issued in. Tokens of a deleted namespace are denied.
+func (c *Core) tokenACL(te *TokenEntry) (*ACL, error) {
+	ns := c.namespaceByID(te.NamespaceID)
+	if ns == nil {
+		return nil, logical.ErrPermissionDenied
+	}
+	ps := c.namespacePolicyStore(ns)
+	if ps == nil {
+		return nil, logical.ErrPermissionDenied
+	}
+
+	// The entity of the token and its groups grant policies as well
+	policies := te.Policies
+	data := &templateData{Token: te}
+	if te.EntityID != "" && c.identityStore != nil {
+		policies = append(append([]string{}, policies...), c.identityStore.EntityPolicies(te.EntityID)...)
+		data.Entity = c.identityStore.Entity(te.EntityID)
+	}
+
+	// Templated paths of the policies are rendered for the token
+	return ps.templatedACL(data, policies...)
+}
+
+// checkToken validates the token of the request against the ACL of its
+// path. The control group the request needs the approval of, if any, is
+// returned as well.
+func (c *Core) checkToken(req *logical.Request) (*logical.Auth, *TokenEntry, *ControlGroup, error) {
 	defer metrics.MeasureSince([]string{"core", "check_token"}, time.Now())
 
 	acl, te, err := c.fetchACLandTokenEntry(req)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
+	}
+
+	if err := c.checkBoundCIDRs(req, te); err != nil {
+		return nil, nil, nil, err
 	}
 
 	// Check if this is a root protected path
@@ -732,7 +986,7 @@ func (c *Core) checkToken(req *logical.Request) (*logical.Auth, *TokenEntry, err
 			// Continue on
 		default:
 			c.logger.Printf("[ERR] core: failed to run existence check: %v", err)
-			return nil, nil, ErrInternalError
+			return nil, nil, nil, ErrInternalError
 		}
 
 		switch {
@@ -751,12 +1005,12 @@ func (c *Core) checkToken(req *logical.Request) (*logical.Auth, *TokenEntry, err
 	}
 
 	// Check the standard non-root ACLs
-	allowed, rootPrivs := acl.AllowOperation(req.Operation, req.Path)
+	allowed, rootPrivs := acl.AllowOperation(req)
 	if !allowed {
-		return nil, nil, logical.ErrPermissionDenied
+		return nil, nil, nil, logical.ErrPermissionDenied
 	}
 	if rootPath && !rootPrivs {
-		return nil, nil, logical.ErrPermissionDenied
+		return nil, nil, nil, logical.ErrPermissionDenied
 	}
 
 	// Create the auth response
@@ -766,7 +1020,24 @@ func (c *Core) checkToken(req *logical.Request) (*logical.Auth, *TokenEntry, err
 		Metadata:    te.Meta,
 		DisplayName: te.DisplayName,
 	}
-	return auth, te, nil
+	return auth, te, acl.ControlGroup(req), nil
+}
+
+// checkBoundCIDRs verifies that a token bound to networks is presented from
+// one of them
+func (c *Core) checkBoundCIDRs(req *logical.Request, te *TokenEntry) error {
+	if len(te.BoundCIDRs) == 0 {
+		return nil
+	}
+	boundCIDRs, err := cidrutil.ParseCIDRs(te.BoundCIDRs)
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to parse bound CIDRs of token: %v", err)
+		return ErrInternalError
+	}
+	if req.Connection == nil || !cidrutil.IPInCIDRs(req.Connection.RemoteAddr, boundCIDRs) {
+		return logical.ErrPermissionDenied
+	}
+	return nil
 }
 
 // Sealed checks if the Vault is current sealed
@@ -783,6 +1054,17 @@ func (c *Core) Standby() (bool, error) {
 	return c.standby, nil
 }
 
+// LeaseRestoreStatus returns whether the leases are still being restored
+// in the background, and the number restored so far
+func (c *Core) LeaseRestoreStatus() (bool, int) {
+	c.metricsMutex.Lock()
+	defer c.metricsMutex.Unlock()
+	if c.expiration == nil {
+		return false, 0
+	}
+	return c.expiration.restore.progress()
+}
+
 // Leader is used to get the current active leader
 func (c *Core) Leader() (bool, string, error) {
 	c.stateLock.RLock()
@@ -827,8 +1109,16 @@ func (c *Core) Leader() (bool, string, error) {
 		return false, "", nil
 	}
 
+	// The advertisement also carries the cluster information used to
+	// forward requests to the leader, so make sure our connection to it
+	// is current
+	adv := parseAdvertisement(entry.Value)
+	if err := c.refreshRequestForwardingConnection(adv); err != nil {
+		c.logger.Printf("[WARN] core: error setting up request forwarding connection: %v", err)
+	}
+
 	// Leader address is in the entry
-	return false, string(entry.Value), nil
+	return false, adv.AdvertiseAddr, nil
 }
 
 // SecretProgress returns the number of keys provided so far
@@ -856,7 +1146,20 @@ func (c *Core) ResetUnsealProcess() {
 // should be made.
 func (c *Core) Unseal(key []byte) (bool, error) {
 	defer metrics.MeasureSince([]string{"core", "unseal"}, time.Now())
+	return c.unseal(key, false)
+}
 
+// UnsealMigrate is used to provide one of the key parts to unseal the Vault
+// while a seal migration is pending. Once enough parts are provided, the
+// master key is moved to the new seal before Vault is unsealed. The parts
+// are the unseal keys when migrating from the default seal and the recovery
+// keys otherwise.
+func (c *Core) UnsealMigrate(key []byte) (bool, error) {
+	defer metrics.MeasureSince([]string{"core", "unseal_migrate"}, time.Now())
+	return c.unseal(key, true)
+}
+
+func (c *Core) unseal(key []byte, migrate bool) (bool, error) {
 	// Verify the key length
 	min, max := c.barrier.KeyLength()
 	max += shamir.ShareOverhead
@@ -867,8 +1170,29 @@ func (c *Core) Unseal(key []byte) (bool, error) {
 		return false, &ErrInvalidKey{fmt.Sprintf("key is longer than maximum %d bytes", max)}
 	}
 
-	// Get the seal configuration
-	config, err := c.seal.BarrierConfig()
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+
+	// Check if already unsealed
+	if !c.sealed {
+		return true, nil
+	}
+
+	switch {
+	case migrate && c.newSeal == nil:
+		return false, fmt.Errorf("no seal migration is pending")
+	case !migrate && c.newSeal != nil:
+		return false, fmt.Errorf("a seal migration is pending; unseal with migration enabled")
+	}
+
+	// Get the configuration of the key parts
+	var config *SealConfig
+	var err error
+	if migrate {
+		config, err = c.migrationUnlockConfig()
+	} else {
+		config, err = c.seal.BarrierConfig()
+	}
 	if err != nil {
 		return false, err
 	}
@@ -878,14 +1202,6 @@ func (c *Core) Unseal(key []byte) (bool, error) {
 		return false, ErrNotInit
 	}
 
-	c.stateLock.Lock()
-	defer c.stateLock.Unlock()
-
-	// Check if already unsealed
-	if !c.sealed {
-		return true, nil
-	}
-
 	// Check if we already have this piece
 	for _, existing := range c.unlockParts {
 		if bytes.Equal(existing, key) {
@@ -918,8 +1234,14 @@ func (c *Core) Unseal(key []byte) (bool, error) {
 	defer memzero(masterKey)
 
 	// Attempt to unlock
-	if err := c.barrier.Unseal(masterKey); err != nil {
-		return false, err
+	if migrate {
+		if err := c.migrateSeal(masterKey); err != nil {
+			return false, err
+		}
+	} else {
+		if err := c.barrier.Unseal(masterKey); err != nil {
+			return false, err
+		}
 	}
 	c.logger.Printf("[INFO] core: vault is unsealed")
 
@@ -997,7 +1319,7 @@ func (c *Core) Seal(token string) (retErr error) {
 	}
 
 	// Verify that this operation is allowed
-	allowed, rootPrivs := acl.AllowOperation(req.Operation, req.Path)
+	allowed, rootPrivs := acl.AllowOperation(req)
 	if !allowed {
 		return logical.ErrPermissionDenied
 	}
@@ -1051,7 +1373,7 @@ func (c *Core) StepDown(token string) error {
 	}
 
 	// Verify that this operation is allowed
-	allowed, rootPrivs := acl.AllowOperation(req.Operation, req.Path)
+	allowed, rootPrivs := acl.AllowOperation(req)
 	if !allowed {
 		return logical.ErrPermissionDenied
 	}
@@ -1141,6 +1463,12 @@ func (c *Core) postUnseal() (retErr error) {
 			return err
 		}
 	}
+	if err := c.loadNamespaces(); err != nil {
+		return err
+	}
+	if err := c.setupPluginCatalog(); err != nil {
+		return err
+	}
 	if err := c.loadMounts(); err != nil {
 		return err
 	}
@@ -1159,6 +1487,9 @@ func (c *Core) postUnseal() (retErr error) {
 	if err := c.setupCredentials(); err != nil {
 		return err
 	}
+	if err := c.setupNamespaces(); err != nil {
+		return err
+	}
 	if err := c.setupExpiration(); err != nil {
 		return err
 	}
@@ -1168,6 +1499,9 @@ func (c *Core) postUnseal() (retErr error) {
 	if err := c.setupAudits(); err != nil {
 		return err
 	}
+	if err := c.setupQuotas(); err != nil {
+		return err
+	}
 	c.metricsCh = make(chan struct{})
 	go c.emitMetrics(c.metricsCh)
 	c.logger.Printf("[INFO] core: post-unseal setup complete")
@@ -1191,6 +1525,9 @@ func (c *Core) preSeal() error {
 		c.metricsCh = nil
 	}
 	var result error
+	if err := c.teardownQuotas(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("[ERR] error tearing down quotas: {{err}}", err))
+	}
 	if err := c.teardownAudits(); err != nil {
 		result = multierror.Append(result, errwrap.Wrapf("[ERR] error tearing down audits: {{err}}", err))
 	}
@@ -1209,6 +1546,9 @@ func (c *Core) preSeal() error {
 	if err := c.unloadMounts(); err != nil {
 		result = multierror.Append(result, errwrap.Wrapf("[ERR] error unloading mounts: {{err}}", err))
 	}
+	if err := c.teardownNamespaces(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("[ERR] error tearing down namespaces: {{err}}", err))
+	}
 	if cache, ok := c.physical.(*physical.Cache); ok {
 		cache.Purge()
 	}
@@ -1262,9 +1602,26 @@ func (c *Core) runStandby(doneCh, stopCh, manualStepDownCh chan struct{}) {
 		}
 		c.logger.Printf("[INFO] core: acquired lock, enabling active operation")
 
+		// We no longer need to forward anything to another node
+		c.clearForwardingClients()
+
+		// Generate the cluster identity for this term and start serving
+		// requests forwarded by standbys
+		if err := c.setupCluster(); err != nil {
+			c.logger.Printf("[ERR] core: cluster setup failed: %v", err)
+			lock.Unlock()
+			continue
+		}
+		if err := c.startClusterListener(); err != nil {
+			c.logger.Printf("[ERR] core: cluster listener setup failed: %v", err)
+			lock.Unlock()
+			continue
+		}
+
 		// Advertise ourself as leader
 		if err := c.advertiseLeader(uuid, leaderLostCh); err != nil {
 			c.logger.Printf("[ERR] core: leader advertisement setup failed: %v", err)
+			c.stopClusterListener()
 			lock.Unlock()
 			continue
 		}
@@ -1280,6 +1637,7 @@ func (c *Core) runStandby(doneCh, stopCh, manualStepDownCh chan struct{}) {
 		// Handle a failure to unseal
 		if err != nil {
 			c.logger.Printf("[ERR] core: post-unseal setup failed: %v", err)
+			c.stopClusterListener()
 			lock.Unlock()
 			continue
 		}
@@ -1301,6 +1659,9 @@ func (c *Core) runStandby(doneCh, stopCh, manualStepDownCh chan struct{}) {
 			c.logger.Printf("[ERR] core: clearing leader advertisement failed: %v", err)
 		}
 
+		// Stop serving forwarded requests
+		c.stopClusterListener()
+
 		// Attempt the pre-seal process
 		c.stateLock.Lock()
 		c.standby = true
@@ -1413,12 +1774,15 @@ func (c *Core) acquireLock(lock physical.Lock, stopCh <-chan struct{}) <-chan st
 // advertiseLeader is used to advertise the current node as leader
 func (c *Core) advertiseLeader(uuid string, leaderLostCh <-chan struct{}) error {
 	go c.cleanLeaderPrefix(uuid, leaderLostCh)
+	val, err := json.Marshal(c.advertisement())
+	if err != nil {
+		return err
+	}
 	ent := &Entry{
 		Key:   coreLeaderPrefix + uuid,
-		Value: []byte(c.advertiseAddr),
+		Value: val,
 	}
-	err := c.barrier.Put(ent)
-	if err != nil {
+	if err := c.barrier.Put(ent); err != nil {
 		return err
 	}
 
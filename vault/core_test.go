@@ -668,9 +668,10 @@ func TestCore_HandleLogin_Token(t *testing.T) {
 		Meta: map[string]string{
 			"user": "armon",
 		},
-		DisplayName:  "foo-armon",
-		TTL:          time.Hour * 24,
-		CreationTime: te.CreationTime,
+		MetaFromLogin: true,
+		DisplayName:   "foo-armon",
+		TTL:           time.Hour * 24,
+		CreationTime:  te.CreationTime,
 	}
 
 	if !reflect.DeepEqual(te, expect) {
@@ -683,6 +684,151 @@ func TestCore_HandleLogin_Token(t *testing.T) {
 	}
 }
 
+func TestCore_HandleLogin_BatchToken(t *testing.T) {
+	noop := &NoopBackend{
+		Login: []string{"login"},
+		Response: &logical.Response{
+			Auth: &logical.Auth{
+				Policies:    []string{"foo"},
+				DisplayName: "armon",
+			},
+		},
+	}
+	c, _, root := TestCoreUnsealed(t)
+	c.credentialBackends["noop"] = func(conf *logical.BackendConfig) (logical.Backend, error) {
+		return noop, nil
+	}
+
+	// Enable the credential backend issuing batch tokens
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/auth/foo")
+	req.Data["type"] = "noop"
+	req.Data["token_type"] = "batch"
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	stored, err := c.tokenStore.view.List(lookupPrefix)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	lresp, err := c.HandleRequest(&logical.Request{Path: "auth/foo/login"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	clientToken := lresp.Auth.ClientToken
+	if !isBatchToken(clientToken) || lresp.Auth.Accessor != "" || lresp.Auth.Renewable {
+		t.Fatalf("bad: %#v", lresp.Auth)
+	}
+
+	te, err := c.tokenStore.Lookup(clientToken)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if te == nil || te.Type != tokenTypeBatch || te.Path != "auth/foo/login" || te.TTL != noop.System().DefaultLeaseTTL() {
+		t.Fatalf("bad: %#v", te)
+	}
+
+	// Neither the token nor its lease were stored
+	after, err := c.tokenStore.view.List(lookupPrefix)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(after) != len(stored) {
+		t.Fatalf("bad: %v %v", stored, after)
+	}
+	le, err := c.expiration.FetchLeaseTimesByToken(te.Path, clientToken)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if le != nil {
+		t.Fatalf("bad: %#v", le)
+	}
+
+	// Batch tokens have no cubbyhole
+	req = logical.TestRequest(t, logical.ReadOperation, "cubbyhole/foo")
+	req.ClientToken = clientToken
+	if _, err := c.HandleRequest(req); err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The leases created by the orphan batch token end with it
+	req = logical.TestRequest(t, logical.UpdateOperation, "secret/foo")
+	req.Data["foo"] = "bar"
+	req.Data["lease"] = "100h"
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	policy, _ := Parse(`name = "foo"
+path "secret/*" { policy = "read" }`)
+	if err := c.policyStore.SetPolicy(policy); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	noop.Response.Auth.TTL = time.Hour
+	lresp, err = c.HandleRequest(&logical.Request{Path: "auth/foo/login"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	te, err = c.tokenStore.Lookup(lresp.Auth.ClientToken)
+	if err != nil || te == nil || te.TTL != time.Hour {
+		t.Fatalf("bad: %v %#v", err, te)
+	}
+	req = logical.TestRequest(t, logical.ReadOperation, "secret/foo")
+	req.ClientToken = te.ID
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expire := time.Unix(te.CreationTime, 0).Add(te.TTL).UTC()
+	if resp.Secret == nil || resp.Secret.TTL > time.Hour {
+		t.Fatalf("bad: %#v %v", resp.Secret, expire)
+	}
+	lease, err := c.expiration.loadEntry(resp.Secret.LeaseID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if lease.ClientToken != "" || !lease.MaxExpireTime.Equal(expire) {
+		t.Fatalf("bad: %#v", lease)
+	}
+}
+
+func TestCore_HandleRequest_BoundCIDRs(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	te := &TokenEntry{
+		Policies:   []string{"root"},
+		BoundCIDRs: []string{"10.0.0.0/8"},
+	}
+	if err := c.tokenStore.create(te); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for addr, expected := range map[string]error{
+		"10.1.2.3":    nil,
+		"192.168.1.1": logical.ErrPermissionDenied,
+		"":            logical.ErrPermissionDenied,
+	} {
+		req := logical.TestRequest(t, logical.ReadOperation, "sys/mounts")
+		req.ClientToken = te.ID
+		if addr != "" {
+			req.Connection = &logical.Connection{RemoteAddr: addr}
+		}
+		if _, err := c.HandleRequest(req); err != expected {
+			t.Fatalf("%s: expected %v, got %v", addr, expected, err)
+		}
+	}
+
+	// Unbound tokens are valid from anywhere
+	req := logical.TestRequest(t, logical.ReadOperation, "sys/mounts")
+	req.ClientToken = root
+	req.Connection = &logical.Connection{RemoteAddr: "192.168.1.1"}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func TestCore_HandleRequest_AuditTrail(t *testing.T) {
 	// Create a noop audit backend
 	noop := &NoopAudit{}
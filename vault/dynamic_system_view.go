@@ -36,7 +36,7 @@ func (d dynamicSystemView) SudoPrivilege(path string, token string) bool {
 	}
 
 	// Construct the corresponding ACL object
-	acl, err := d.core.policyStore.ACL(te.Policies...)
+	acl, err := d.core.tokenACL(te)
 	if err != nil {
 		d.core.logger.Printf("[ERR] failed to retrieve ACL for policies [%#v]: %s", te.Policies, err)
 		return false
@@ -45,7 +45,10 @@ func (d dynamicSystemView) SudoPrivilege(path string, token string) bool {
 	// The operation type isn't important here as this is run from a path the
 	// user has already been given access to; we only care about whether they
 	// have sudo
-	_, rootPrivs := acl.AllowOperation(logical.ReadOperation, path)
+	_, rootPrivs := acl.AllowOperation(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      path,
+	})
 	return rootPrivs
 }
 
@@ -29,12 +29,22 @@ const (
 	// maxRevokeAttempts limits how many revoke attempts are made
 	maxRevokeAttempts = 6
 
-	// revokeRetryBase is a baseline retry time
+	// revokeRetryBase is a baseline retry time, doubled on each failed
+	// revoke attempt
 	revokeRetryBase = 10 * time.Second
 
+	// maxRevokeRetryDelay caps the time between revoke attempts
+	maxRevokeRetryDelay = time.Hour
+
 	// minRevokeDelay is used to prevent an instant revoke on restore
 	minRevokeDelay = 5 * time.Second
 
+	// restoreWorkers is the number of leases loaded in parallel on restore
+	restoreWorkers = 64
+
+	// expireWorkers is the number of expired leases revoked in parallel
+	expireWorkers = 64
+
 	// maxLeaseDuration is the default maximum lease duration
 	maxLeaseTTL = 30 * 24 * time.Hour
 
@@ -53,8 +63,31 @@ type ExpirationManager struct {
 	tokenStore *TokenStore
 	logger     *log.Logger
 
-	pending     map[string]*time.Timer
-	pendingLock sync.Mutex
+	// queue holds the leases pending expiration. It is served by the
+	// expiration loop, which runs between start and Stop.
+	queue *expirationQueue
+
+	// loopLock guards stopCh, which is closed to stop the expiration loop
+	// and the restore. wg waits for them and the revocations they started.
+	loopLock sync.Mutex
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+
+	// restore tracks the progress of restoring the leases
+	restore *restoreStatus
+
+	// tidyStatus tracks the progress of the last tidy operation
+	tidyStatus *tidyStatus
+
+	// revokeHook, if set, is called with the ID of each lease of a
+	// secret once it has been revoked
+	revokeHook func(leaseID string)
+
+	// revokeEventHook, if set, is called when an expired lease fails to
+	// be revoked, becomes irrevocable, or is revoked after failing to be
+	// before. The error is that of the failed revoke attempt, and is nil
+	// once the lease has been revoked.
+	revokeEventHook func(le *leaseEntry, err error)
 }
 
 // NewExpirationManager creates a new ExpirationManager that is backed
@@ -69,8 +102,11 @@ func NewExpirationManager(router *Router, view *BarrierView, ts *TokenStore, log
 		tokenView:  view.SubView(tokenViewPrefix),
 		tokenStore: ts,
 		logger:     logger,
-		pending:    make(map[string]*time.Timer),
+		queue:      newExpirationQueue(),
+		restore:    &restoreStatus{},
+		tidyStatus: &tidyStatus{},
 	}
+	exp.start()
 	return exp
 }
 
@@ -84,6 +120,8 @@ func (c *Core) setupExpiration() error {
 
 	// Create the manager
 	mgr := NewExpirationManager(c.router, view, c.tokenStore, c.logger)
+	mgr.revokeHook = c.releaseLease
+	mgr.revokeEventHook = c.auditRevokeEvent
 	c.expiration = mgr
 
 	// Link the token store to this
@@ -93,6 +131,11 @@ func (c *Core) setupExpiration() error {
 	if err := c.expiration.Restore(); err != nil {
 		return fmt.Errorf("expiration state restore failed: %v", err)
 	}
+
+	// Resume the token revocations interrupted by a seal or failover
+	if err := c.tokenStore.resumeRevocations(); err != nil {
+		return fmt.Errorf("token revocation resume failed: %v", err)
+	}
 	return nil
 }
 
@@ -100,6 +143,7 @@ func (c *Core) setupExpiration() error {
 // sealing the Vault.
 func (c *Core) stopExpiration() error {
 	if c.expiration != nil {
+		c.tokenStore.stopRevocations()
 		if err := c.expiration.Stop(); err != nil {
 			return err
 		}
@@ -110,63 +154,214 @@ func (c *Core) stopExpiration() error {
 	return nil
 }
 
+// auditRevokeEvent records a change in the revocation state of an expired
+// lease in the audit log, as the response to a revoke request for it
+func (c *Core) auditRevokeEvent(le *leaseEntry, revokeErr error) {
+	req := &logical.Request{
+		Operation: logical.RevokeOperation,
+		Path:      le.LeaseID,
+	}
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"revoked":         revokeErr == nil,
+			"revoke_attempts": le.RevokeAttempts,
+			"irrevocable":     le.Irrevocable,
+		},
+	}
+	if err := c.logAuditEvent(req, resp, revokeErr); err != nil {
+		c.logger.Printf("[ERR] core: failed to audit revocation of '%s': %v", le.LeaseID, err)
+	}
+}
+
 // Restore is used to recover the lease states when starting.
-// This is used after starting the vault.
+// This is used after starting the vault. The leases are loaded in the
+// background while requests are served; a lease touched before it is
+// loaded is scheduled by the request that touched it instead.
 func (m *ExpirationManager) Restore() error {
-	m.pendingLock.Lock()
-	defer m.pendingLock.Unlock()
-
-	// Accumulate existing leases
-	existing, err := CollectKeys(m.idView)
+	// Listing the top level up front fails the unseal if the leases
+	// cannot be read at all
+	keys, err := m.idView.List("")
 	if err != nil {
 		return fmt.Errorf("failed to scan for leases: %v", err)
 	}
 
-	// Restore each key
-	for _, leaseID := range existing {
-		// Load the entry
-		le, err := m.loadEntry(leaseID)
-		if err != nil {
-			return err
-		}
+	m.loopLock.Lock()
+	defer m.loopLock.Unlock()
+	m.startLocked()
 
-		// If there is no entry, nothing to restore
-		if le == nil {
-			continue
-		}
+	if !m.restore.begin(len(keys) > 0) {
+		return nil
+	}
+	m.wg.Add(1)
+	go m.restoreLeases(keys, m.stopCh)
+	return nil
+}
+
+// restoreLeases walks the leases and schedules their expiration, loading
+// them with bounded parallelism
+func (m *ExpirationManager) restoreLeases(keys []string, stopCh chan struct{}) {
+	defer m.wg.Done()
+	defer metrics.MeasureSince([]string{"expire", "restore"}, time.Now())
+
+	leaseIDs := make(chan string, restoreWorkers)
+	var workers sync.WaitGroup
+	for i := 0; i < restoreWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for leaseID := range leaseIDs {
+				if err := m.restoreLease(leaseID); err != nil {
+					m.logger.Printf("[ERR] expire: failed to restore lease '%s': %v", leaseID, err)
+				}
+			}
+		}()
+	}
+
+	err := m.walkLeases("", keys, leaseIDs, stopCh)
+	close(leaseIDs)
+	workers.Wait()
 
-		// If there is no expiry time, don't do anything
-		if le.ExpireTime.IsZero() {
+	restored := m.restore.finish()
+	switch {
+	case err != nil:
+		m.logger.Printf("[ERR] expire: lease restore aborted after %d leases: %v", restored, err)
+	case restored > 0:
+		m.logger.Printf("[INFO] expire: restored %d leases", restored)
+	}
+}
+
+// walkLeases passes the ID of every lease under the given keys to out,
+// until the walk is done or stopCh is closed
+func (m *ExpirationManager) walkLeases(prefix string, keys []string, out chan<- string, stopCh chan struct{}) error {
+	for _, key := range keys {
+		if strings.HasSuffix(key, "/") {
+			subKeys, err := m.idView.List(prefix + key)
+			if err != nil {
+				return fmt.Errorf("failed to scan for leases: %v", err)
+			}
+			if err := m.walkLeases(prefix+key, subKeys, out, stopCh); err != nil {
+				return err
+			}
 			continue
 		}
 
-		// Determine the remaining time to expiration
-		expires := le.ExpireTime.Sub(time.Now().UTC())
-		if expires <= 0 {
-			expires = minRevokeDelay
+		select {
+		case out <- prefix + key:
+		case <-stopCh:
+			return nil
 		}
+	}
+	return nil
+}
+
+// restoreLease loads a lease and schedules its expiration
+func (m *ExpirationManager) restoreLease(leaseID string) error {
+	le, err := m.loadEntry(leaseID)
+	if err != nil {
+		return err
+	}
+
+	// If there is no entry or no expiry time, nothing to restore.
+	// Irrevocable leases are left for an operator to deal with.
+	if le == nil || le.ExpireTime.IsZero() || le.Irrevocable {
+		return nil
+	}
 
-		// Setup revocation timer
-		m.pending[le.LeaseID] = time.AfterFunc(expires, func() {
-			m.expireID(le.LeaseID)
-		})
+	// Determine the remaining time to expiration, or to the next
+	// revoke attempt if revoking has failed before
+	expires := le.ExpireTime.Sub(time.Now().UTC())
+	if le.RevokeAttempts > 0 {
+		expires = revokeRetryDelay(le.RevokeAttempts)
+	}
+	if expires <= 0 {
+		expires = minRevokeDelay
 	}
-	if len(m.pending) > 0 {
-		m.logger.Printf("[INFO] expire: restored %d leases", len(m.pending))
+
+	// The entry loaded may be stale if the lease has been touched since,
+	// in which case it has already been scheduled or revoked
+	m.restore.lock.Lock()
+	defer m.restore.lock.Unlock()
+	if _, ok := m.restore.touched[leaseID]; ok {
+		return nil
 	}
+	m.restore.restored++
+	m.queue.schedule(leaseID, time.Now().Add(expires))
 	return nil
 }
 
+// start runs the expiration loop if it is not running
+func (m *ExpirationManager) start() {
+	m.loopLock.Lock()
+	defer m.loopLock.Unlock()
+	m.startLocked()
+}
+
+func (m *ExpirationManager) startLocked() {
+	if m.stopCh != nil {
+		return
+	}
+	m.stopCh = make(chan struct{})
+	m.wg.Add(1)
+	go m.expireLoop(m.stopCh)
+}
+
+// expireLoop waits for the leases in the queue to expire and revokes them,
+// until stopCh is closed
+func (m *ExpirationManager) expireLoop(stopCh chan struct{}) {
+	defer m.wg.Done()
+	sem := make(chan struct{}, expireWorkers)
+	for {
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if next, ok := m.queue.next(); ok {
+			timer = time.NewTimer(next.Sub(time.Now()))
+			expired = timer.C
+		}
+
+		stopped := false
+		select {
+		case <-stopCh:
+			stopped = true
+		case <-m.queue.wakeCh:
+		case <-expired:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if stopped {
+			return
+		}
+
+		for _, leaseID := range m.queue.popDue(time.Now()) {
+			select {
+			case sem <- struct{}{}:
+			case <-stopCh:
+				return
+			}
+			m.wg.Add(1)
+			go func(leaseID string) {
+				defer m.wg.Done()
+				defer func() { <-sem }()
+				m.expireID(leaseID)
+			}(leaseID)
+		}
+	}
+}
+
 // Stop is used to prevent further automatic revocations.
 // This must be called before sealing the view.
 func (m *ExpirationManager) Stop() error {
-	// Stop all the pending expiration timers
-	m.pendingLock.Lock()
-	for _, timer := range m.pending {
-		timer.Stop()
+	m.loopLock.Lock()
+	if m.stopCh != nil {
+		close(m.stopCh)
+		m.stopCh = nil
 	}
-	m.pending = make(map[string]*time.Timer)
-	m.pendingLock.Unlock()
+	m.loopLock.Unlock()
+
+	// Wait for the restore and the revocations in flight, then drop the
+	// pending expirations
+	m.wg.Wait()
+	m.queue.clear()
 	return nil
 }
 
@@ -207,19 +402,18 @@ func (m *ExpirationManager) revokeCommon(leaseID string, force, skipToken bool)
 	if err := m.deleteEntry(leaseID); err != nil {
 		return err
 	}
+	if le.Auth == nil && m.revokeHook != nil {
+		m.revokeHook(leaseID)
+	}
 
 	// Delete the secondary index
 	if err := m.removeIndexByToken(le.ClientToken, le.LeaseID); err != nil {
 		return err
 	}
 
-	// Clear the expiration handler
-	m.pendingLock.Lock()
-	if timer, ok := m.pending[leaseID]; ok {
-		timer.Stop()
-		delete(m.pending, leaseID)
-	}
-	m.pendingLock.Unlock()
+	// Clear the pending expiration
+	m.restore.touch(leaseID)
+	m.queue.remove(leaseID)
 	return nil
 }
 
@@ -330,6 +524,11 @@ func (m *ExpirationManager) Renew(leaseID string, increment time.Duration) (*log
 	// Attach the LeaseID
 	resp.Secret.LeaseID = leaseID
 
+	// Leases of batch tokens cannot be renewed past the token
+	if !le.MaxExpireTime.IsZero() {
+		capLeaseTTL(resp.Secret, le.MaxExpireTime)
+	}
+
 	// Update the lease entry
 	le.Data = resp.Data
 	le.Secret = resp.Secret
@@ -427,14 +626,34 @@ func (m *ExpirationManager) Register(req *logical.Request, resp *logical.Respons
 	if err != nil {
 		return "", err
 	}
+	// Batch tokens are never stored, so the leases they create are revoked
+	// along with their parent instead. As orphan batch tokens have no
+	// parent, the leases of any batch token end when the token expires.
+	clientToken := req.ClientToken
+	var maxExpireTime time.Time
+	if isBatchToken(clientToken) {
+		te, err := m.tokenStore.Lookup(clientToken)
+		if err != nil {
+			return "", err
+		}
+		if te == nil {
+			return "", fmt.Errorf("batch token is no longer valid")
+		}
+		clientToken = te.Parent
+		maxExpireTime = time.Unix(te.CreationTime, 0).Add(te.TTL).UTC()
+		capLeaseTTL(resp.Secret, maxExpireTime)
+	}
+
 	le := leaseEntry{
 		LeaseID:     path.Join(req.Path, leaseUUID),
-		ClientToken: req.ClientToken,
+		ClientToken: clientToken,
 		Path:        req.Path,
 		Data:        resp.Data,
 		Secret:      resp.Secret,
 		IssueTime:   time.Now().UTC(),
 		ExpireTime:  resp.Secret.ExpirationTime(),
+
+		MaxExpireTime: maxExpireTime,
 	}
 
 	// Encode the entry
@@ -443,8 +662,10 @@ func (m *ExpirationManager) Register(req *logical.Request, resp *logical.Respons
 	}
 
 	// Maintain secondary index by token
-	if err := m.createIndexByToken(le.ClientToken, le.LeaseID); err != nil {
-		return "", err
+	if le.ClientToken != "" {
+		if err := m.createIndexByToken(le.ClientToken, le.LeaseID); err != nil {
+			return "", err
+		}
 	}
 
 	// Setup revocation timer if there is a lease
@@ -460,6 +681,11 @@ func (m *ExpirationManager) Register(req *logical.Request, resp *logical.Respons
 func (m *ExpirationManager) RegisterAuth(source string, auth *logical.Auth) error {
 	defer metrics.MeasureSince([]string{"expire", "register-auth"}, time.Now())
 
+	// Batch tokens expire on their own
+	if isBatchToken(auth.ClientToken) {
+		return nil
+	}
+
 	// Create a lease entry
 	le := leaseEntry{
 		LeaseID:     path.Join(source, m.tokenStore.SaltID(auth.ClientToken)),
@@ -514,53 +740,250 @@ func (m *ExpirationManager) FetchLeaseTimes(leaseID string) (*leaseEntry, error)
 	return ret, nil
 }
 
+// ListLeases returns the leases directly under the given prefix, along
+// with the prefixes holding further leases, which end in a slash
+func (m *ExpirationManager) ListLeases(prefix string) ([]string, error) {
+	defer metrics.MeasureSince([]string{"expire", "list-leases"}, time.Now())
+	return m.idView.List(prefix)
+}
+
+// CountLeases counts the leases under the given prefix by the mount
+// that issued them
+func (m *ExpirationManager) CountLeases(prefix string) (map[string]int, error) {
+	defer metrics.MeasureSince([]string{"expire", "count-leases"}, time.Now())
+
+	keys, err := CollectKeys(m.idView.SubView(prefix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan for leases: %v", err)
+	}
+
+	counts := make(map[string]int)
+	for _, key := range keys {
+		counts[m.router.MatchingMount(prefix+key)]++
+	}
+	return counts, nil
+}
+
+// Tidy walks the leases and their index by token, revoking the leases of
+// tokens that no longer exist and the expired leases whose revocation was
+// given up on, restoring missing index entries and removing those of leases
+// that no longer exist
+func (m *ExpirationManager) Tidy(status *tidyStatus) error {
+	defer metrics.MeasureSince([]string{"expire", "tidy"}, time.Now())
+
+	// Leases not restored yet would be taken for lost
+	if m.restore.inProgress() {
+		return fmt.Errorf("lease restore in progress")
+	}
+
+	leaseIDs, err := CollectKeys(m.idView)
+	if err != nil {
+		return fmt.Errorf("failed to scan for leases: %v", err)
+	}
+	for _, leaseID := range leaseIDs {
+		status.scan()
+		le, err := m.loadEntry(leaseID)
+		if err != nil {
+			status.fail("failed to load lease '%s': %v", leaseID, err)
+			continue
+		}
+		if le == nil {
+			continue
+		}
+
+		// Leases outliving their token escaped its revocation
+		if le.ClientToken != "" {
+			te, err := m.tokenStore.Lookup(le.ClientToken)
+			if err != nil {
+				status.fail("failed to lookup token of lease '%s': %v", leaseID, err)
+				continue
+			}
+			if te == nil {
+				if err := m.Revoke(leaseID); err != nil {
+					status.fail("failed to revoke lease '%s' of missing token: %v", leaseID, err)
+				} else {
+					status.change("revoked_leases_of_missing_tokens")
+				}
+				continue
+			}
+		}
+
+		// Expired leases not pending expiration have been lost track of.
+		// The irrevocable ones are left for an operator to deal with.
+		if !m.queue.contains(leaseID) && !le.Irrevocable && !le.ExpireTime.IsZero() && le.ExpireTime.Before(time.Now().UTC()) {
+			if err := m.Revoke(leaseID); err != nil {
+				status.fail("failed to revoke expired lease '%s': %v", leaseID, err)
+			} else {
+				status.change("revoked_expired_leases")
+			}
+			continue
+		}
+
+		// Leases of secrets are indexed by the token that created them
+		if le.Auth == nil && le.ClientToken != "" {
+			index, err := m.indexByToken(le.ClientToken, leaseID)
+			if err != nil {
+				status.fail("failed to read token index of lease '%s': %v", leaseID, err)
+				continue
+			}
+			if index == nil {
+				if err := m.createIndexByToken(le.ClientToken, leaseID); err != nil {
+					status.fail("failed to restore token index of lease '%s': %v", leaseID, err)
+				} else {
+					status.change("restored_token_indexes")
+				}
+			}
+		}
+	}
+
+	// Remove the index entries of leases that no longer exist
+	tokens, err := m.tokenView.List("")
+	if err != nil {
+		return fmt.Errorf("failed to scan for lease indexes: %v", err)
+	}
+	for _, token := range tokens {
+		subKeys, err := m.tokenView.List(token)
+		if err != nil {
+			return fmt.Errorf("failed to scan for lease indexes: %v", err)
+		}
+		for _, sub := range subKeys {
+			status.scan()
+			out, err := m.tokenView.Get(token + sub)
+			if err != nil {
+				status.fail("failed to read lease index: %v", err)
+				continue
+			}
+			if out == nil {
+				continue
+			}
+			le, err := m.loadEntry(string(out.Value))
+			if err != nil {
+				status.fail("failed to load lease '%s': %v", out.Value, err)
+				continue
+			}
+			if le != nil {
+				continue
+			}
+			if err := m.tokenView.Delete(token + sub); err != nil {
+				status.fail("failed to remove lease index: %v", err)
+			} else {
+				status.change("removed_token_indexes")
+			}
+		}
+	}
+	return nil
+}
+
 // updatePending is used to update a pending invocation for a lease
 func (m *ExpirationManager) updatePending(le *leaseEntry, leaseTotal time.Duration) {
-	m.pendingLock.Lock()
-	defer m.pendingLock.Unlock()
-
-	// Check for an existing timer
-	timer, ok := m.pending[le.LeaseID]
-
-	// Create entry if it does not exist
-	if !ok && leaseTotal > 0 {
-		timer := time.AfterFunc(leaseTotal, func() {
-			m.expireID(le.LeaseID)
-		})
-		m.pending[le.LeaseID] = timer
+	m.restore.touch(le.LeaseID)
+
+	// Delete the pending expiration if the expiration time is zero
+	if leaseTotal == 0 {
+		m.queue.remove(le.LeaseID)
 		return
 	}
+	m.queue.schedule(le.LeaseID, time.Now().Add(leaseTotal))
+}
+
+// expireID is invoked when a given ID is expired
+func (m *ExpirationManager) expireID(leaseID string) {
+	// Clear from the pending expiration
+	m.queue.remove(leaseID)
 
-	// Delete the timer if the expiration time is zero
-	if ok && leaseTotal == 0 {
-		timer.Stop()
-		delete(m.pending, le.LeaseID)
+	// Keep the entry to tell if revoking has failed before
+	le, err := m.loadEntry(leaseID)
+	if err != nil {
+		m.logger.Printf("[ERR] expire: failed to load lease '%s': %v", leaseID, err)
+		return
+	}
+	if le == nil || le.Irrevocable {
 		return
 	}
 
-	// Extend the timer by the lease total
-	if ok && leaseTotal > 0 {
-		timer.Reset(leaseTotal)
+	err = m.Revoke(leaseID)
+	if err == nil {
+		m.logger.Printf("[INFO] expire: revoked '%s'", leaseID)
+		if le.RevokeAttempts > 0 {
+			metrics.IncrCounter([]string{"expire", "revoke", "recovered"}, 1)
+			m.revokeEvent(le, nil)
+		}
+		return
 	}
+	m.logger.Printf("[ERR] expire: failed to revoke '%s': %v", leaseID, err)
+	m.revokeFailed(le, err)
 }
 
-// expireID is invoked when a given ID is expired
-func (m *ExpirationManager) expireID(leaseID string) {
-	// Clear from the pending expiration
-	m.pendingLock.Lock()
-	delete(m.pending, leaseID)
-	m.pendingLock.Unlock()
-
-	for attempt := uint(0); attempt < maxRevokeAttempts; attempt++ {
-		err := m.Revoke(leaseID)
-		if err == nil {
-			m.logger.Printf("[INFO] expire: revoked '%s'", leaseID)
-			return
+// revokeFailed records a failed attempt to revoke the expired lease. The
+// attempt is retried with exponential backoff, until the maximum number of
+// attempts has been made and the lease is marked irrevocable.
+func (m *ExpirationManager) revokeFailed(le *leaseEntry, revokeErr error) {
+	le.RevokeAttempts++
+	le.RevokeErr = revokeErr.Error()
+	if le.RevokeAttempts >= maxRevokeAttempts {
+		le.Irrevocable = true
+	}
+
+	// The retry state is persisted so that it survives a restart
+	if err := m.persistEntry(le); err != nil {
+		m.logger.Printf("[ERR] expire: failed to persist revoke attempts of '%s': %v", le.LeaseID, err)
+	}
+
+	if le.Irrevocable {
+		metrics.IncrCounter([]string{"expire", "revoke", "irrevocable"}, 1)
+		m.logger.Printf("[ERR] expire: maximum revoke attempts for '%s' reached, marked irrevocable", le.LeaseID)
+		m.revokeEvent(le, revokeErr)
+		return
+	}
+
+	metrics.IncrCounter([]string{"expire", "revoke", "retry"}, 1)
+	m.revokeEvent(le, revokeErr)
+
+	m.queue.schedule(le.LeaseID, time.Now().Add(revokeRetryDelay(le.RevokeAttempts)))
+}
+
+// revokeEvent passes a change in the revocation state of a lease to the
+// revoke event hook
+func (m *ExpirationManager) revokeEvent(le *leaseEntry, err error) {
+	if m.revokeEventHook != nil {
+		m.revokeEventHook(le, err)
+	}
+}
+
+// revokeRetryDelay returns the time to wait before the next attempt to
+// revoke a lease, after the given number of failed attempts
+func revokeRetryDelay(attempts int) time.Duration {
+	delay := revokeRetryBase
+	for i := 1; i < attempts && delay < maxRevokeRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRevokeRetryDelay {
+		delay = maxRevokeRetryDelay
+	}
+	return delay
+}
+
+// IrrevocableLeases returns the leases under the given prefix which have
+// been marked irrevocable after running out of revoke attempts
+func (m *ExpirationManager) IrrevocableLeases(prefix string) ([]*leaseEntry, error) {
+	defer metrics.MeasureSince([]string{"expire", "irrevocable-leases"}, time.Now())
+
+	keys, err := CollectKeys(m.idView.SubView(prefix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan for leases: %v", err)
+	}
+
+	var leases []*leaseEntry
+	for _, key := range keys {
+		le, err := m.loadEntry(prefix + key)
+		if err != nil {
+			return nil, err
+		}
+		if le != nil && le.Irrevocable {
+			leases = append(leases, le)
 		}
-		m.logger.Printf("[ERR] expire: failed to revoke '%s': %v", leaseID, err)
-		time.Sleep((1 << attempt) * revokeRetryBase)
 	}
-	m.logger.Printf("[ERR] expire: maximum revoke attempts for '%s' reached", leaseID)
+	return leases, nil
 }
 
 // revokeEntry is used to attempt revocation of an internal entry
@@ -720,10 +1143,70 @@ func (m *ExpirationManager) lookupByToken(token string) ([]string, error) {
 
 // emitMetrics is invoked periodically to emit statistics
 func (m *ExpirationManager) emitMetrics() {
-	m.pendingLock.Lock()
-	num := len(m.pending)
-	m.pendingLock.Unlock()
-	metrics.SetGauge([]string{"expire", "num_leases"}, float32(num))
+	metrics.SetGauge([]string{"expire", "num_leases"}, float32(m.queue.len()))
+}
+
+// restoreStatus tracks the progress of restoring the leases in the
+// background after unsealing or gaining leadership
+type restoreStatus struct {
+	lock sync.Mutex
+
+	running bool
+
+	// restored is the number of leases scheduled for expiration so far
+	restored int
+
+	// touched holds the leases registered, renewed or revoked while
+	// restoring. These are scheduled by the request that touched them,
+	// and are skipped by the restore.
+	touched map[string]struct{}
+}
+
+// begin marks the beginning of a restore, returning false if there is
+// nothing to restore
+func (s *restoreStatus) begin(leases bool) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.restored = 0
+	s.running = leases
+	if !leases {
+		return false
+	}
+	s.touched = make(map[string]struct{})
+	return true
+}
+
+// finish marks the end of the restore, returning the number of leases
+// restored
+func (s *restoreStatus) finish() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.running = false
+	s.touched = nil
+	return s.restored
+}
+
+// touch records that a lease has been touched by a request
+func (s *restoreStatus) touch(leaseID string) {
+	s.lock.Lock()
+	if s.running {
+		s.touched[leaseID] = struct{}{}
+	}
+	s.lock.Unlock()
+}
+
+func (s *restoreStatus) inProgress() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.running
+}
+
+// progress returns whether the restore is running and the number of
+// leases restored so far
+func (s *restoreStatus) progress() (bool, int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.running, s.restored
 }
 
 // leaseEntry is used to structure the values the expiration
@@ -738,6 +1221,30 @@ type leaseEntry struct {
 	IssueTime       time.Time              `json:"issue_time"`
 	ExpireTime      time.Time              `json:"expire_time"`
 	LastRenewalTime time.Time              `json:"last_renewal_time"`
+
+	// RevokeAttempts counts the attempts made to revoke the expired lease
+	// and RevokeErr holds the error of the last failed one. Once the
+	// attempts run out the lease is marked irrevocable, and is only
+	// revoked on request.
+	RevokeAttempts int    `json:"revoke_attempts,omitempty"`
+	RevokeErr      string `json:"revoke_err,omitempty"`
+	Irrevocable    bool   `json:"irrevocable,omitempty"`
+
+	// MaxExpireTime is the time the lease cannot outlive, if any. It is
+	// set for the leases of batch tokens, which end with the token.
+	MaxExpireTime time.Time `json:"max_expire_time"`
+}
+
+// capLeaseTTL limits the TTL of the secret so that its lease ends by the
+// given time
+func capLeaseTTL(secret *logical.Secret, maxExpireTime time.Time) {
+	remaining := maxExpireTime.Sub(time.Now())
+	if remaining < time.Second {
+		remaining = time.Second
+	}
+	if secret.TTL <= 0 || secret.TTL > remaining {
+		secret.TTL = remaining
+	}
 }
 
 // encode is used to JSON encode the lease entry
@@ -0,0 +1,146 @@
+package vault
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// expirationQueue holds the leases pending expiration ordered by the time
+// at which they expire. A single goroutine waits on the head of the queue
+// instead of a timer being armed for every lease.
+type expirationQueue struct {
+	lock  sync.Mutex
+	heap  expirationHeap
+	items map[string]*expirationItem
+
+	// wakeCh is signalled when the queue changes, so that the goroutine
+	// waiting on it can pick up a new head
+	wakeCh chan struct{}
+}
+
+// expirationItem is a lease pending expiration
+type expirationItem struct {
+	leaseID string
+	expires time.Time
+	index   int
+}
+
+func newExpirationQueue() *expirationQueue {
+	return &expirationQueue{
+		items:  make(map[string]*expirationItem),
+		wakeCh: make(chan struct{}, 1),
+	}
+}
+
+// schedule sets the lease to expire at the given time, replacing any
+// previous expiration time
+func (q *expirationQueue) schedule(leaseID string, expires time.Time) {
+	q.lock.Lock()
+	if item, ok := q.items[leaseID]; ok {
+		item.expires = expires
+		heap.Fix(&q.heap, item.index)
+	} else {
+		item := &expirationItem{leaseID: leaseID, expires: expires}
+		heap.Push(&q.heap, item)
+		q.items[leaseID] = item
+	}
+	q.lock.Unlock()
+	q.wake()
+}
+
+// remove takes the lease out of the queue, returning whether it was pending
+func (q *expirationQueue) remove(leaseID string) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	item, ok := q.items[leaseID]
+	if !ok {
+		return false
+	}
+	heap.Remove(&q.heap, item.index)
+	delete(q.items, leaseID)
+	return true
+}
+
+// contains returns whether the lease is pending expiration
+func (q *expirationQueue) contains(leaseID string) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	_, ok := q.items[leaseID]
+	return ok
+}
+
+// len returns the number of leases pending expiration
+func (q *expirationQueue) len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.heap)
+}
+
+// next returns the time at which the earliest lease expires, and false if
+// the queue is empty
+func (q *expirationQueue) next() (time.Time, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.heap) == 0 {
+		return time.Time{}, false
+	}
+	return q.heap[0].expires, true
+}
+
+// popDue takes the leases which have expired by the given time out of the
+// queue, earliest first
+func (q *expirationQueue) popDue(now time.Time) []string {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	var due []string
+	for len(q.heap) > 0 && !q.heap[0].expires.After(now) {
+		item := heap.Pop(&q.heap).(*expirationItem)
+		delete(q.items, item.leaseID)
+		due = append(due, item.leaseID)
+	}
+	return due
+}
+
+// clear empties the queue
+func (q *expirationQueue) clear() {
+	q.lock.Lock()
+	q.heap = nil
+	q.items = make(map[string]*expirationItem)
+	q.lock.Unlock()
+	q.wake()
+}
+
+func (q *expirationQueue) wake() {
+	select {
+	case q.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// expirationHeap implements heap.Interface, ordered by expiration time
+type expirationHeap []*expirationItem
+
+func (h expirationHeap) Len() int           { return len(h) }
+func (h expirationHeap) Less(i, j int) bool { return h[i].expires.Before(h[j].expires) }
+
+func (h expirationHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expirationHeap) Push(x interface{}) {
+	item := x.(*expirationItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *expirationHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
@@ -0,0 +1,46 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpirationQueue(t *testing.T) {
+	q := newExpirationQueue()
+	now := time.Now()
+
+	q.schedule("c", now.Add(3*time.Second))
+	q.schedule("a", now.Add(time.Second))
+	q.schedule("b", now.Add(4*time.Second))
+	q.schedule("d", now.Add(time.Hour))
+
+	// Rescheduling moves the lease
+	q.schedule("b", now.Add(2*time.Second))
+	if next, ok := q.next(); !ok || !next.Equal(now.Add(time.Second)) {
+		t.Fatalf("bad: %v %v", next, ok)
+	}
+
+	if !q.remove("c") || q.remove("c") || q.contains("c") {
+		t.Fatalf("bad remove")
+	}
+	if q.len() != 3 {
+		t.Fatalf("bad: %d", q.len())
+	}
+
+	if due := q.popDue(now); len(due) != 0 {
+		t.Fatalf("bad: %v", due)
+	}
+	due := q.popDue(now.Add(5 * time.Second))
+	if !reflect.DeepEqual(due, []string{"a", "b"}) {
+		t.Fatalf("bad: %v", due)
+	}
+	if q.contains("a") || !q.contains("d") || q.len() != 1 {
+		t.Fatalf("bad: %v", q.items)
+	}
+
+	q.clear()
+	if _, ok := q.next(); ok || q.len() != 0 {
+		t.Fatalf("queue not cleared")
+	}
+}
@@ -71,6 +71,12 @@ func TestExpiration_Restore(t *testing.T) {
 
 	// Ensure all are reaped
 	start := time.Now()
+	for exp.restore.inProgress() && time.Now().Sub(start) < time.Second {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if restoring, restored := exp.restore.progress(); restoring || restored != 3 {
+		t.Fatalf("bad: %v %d", restoring, restored)
+	}
 	for time.Now().Sub(start) < time.Second {
 		noop.Lock()
 		less := len(noop.Requests) < 3
@@ -89,6 +95,58 @@ func TestExpiration_Restore(t *testing.T) {
 	}
 }
 
+func TestExpiration_RestoreTouched(t *testing.T) {
+	exp := mockExpiration(t)
+	noop := &NoopBackend{}
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+	exp.router.Mount(noop, "prod/aws/", &MountEntry{UUID: "foo"}, view)
+
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "prod/aws/foo",
+	}
+	resp := &logical.Response{
+		Secret: &logical.Secret{
+			LeaseOptions: logical.LeaseOptions{
+				TTL: time.Hour,
+			},
+		},
+	}
+	leaseID, err := exp.Register(req, resp)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := exp.Stop(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A lease touched while restoring is not restored from its entry
+	exp.restore.begin(true)
+	exp.restore.touch(leaseID)
+	if err := exp.restoreLease(leaseID); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if exp.queue.contains(leaseID) {
+		t.Fatalf("touched lease restored")
+	}
+	if restoring, restored := exp.restore.progress(); !restoring || restored != 0 {
+		t.Fatalf("bad: %v %d", restoring, restored)
+	}
+
+	exp.restore.finish()
+	exp.restore.begin(true)
+	if err := exp.restoreLease(leaseID); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !exp.queue.contains(leaseID) {
+		t.Fatalf("lease not restored")
+	}
+	if restored := exp.restore.finish(); restored != 1 {
+		t.Fatalf("bad: %d", restored)
+	}
+}
+
 func TestExpiration_Register(t *testing.T) {
 	exp := mockExpiration(t)
 	req := &logical.Request{
@@ -403,6 +461,191 @@ func TestExpiration_RevokeByToken(t *testing.T) {
 	}
 }
 
+func TestExpiration_Tidy(t *testing.T) {
+	exp := mockExpiration(t)
+	noop := &NoopBackend{}
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+	meUUID, err := uuid.GenerateUUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp.router.Mount(noop, "prod/aws/", &MountEntry{UUID: meUUID}, view)
+
+	te := &TokenEntry{}
+	if err := exp.tokenStore.create(te); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	register := func(path, token string) string {
+		req := &logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        path,
+			ClientToken: token,
+		}
+		resp := &logical.Response{
+			Secret: &logical.Secret{
+				LeaseOptions: logical.LeaseOptions{
+					TTL: time.Hour,
+				},
+			},
+			Data: map[string]interface{}{
+				"access_key": "xyz",
+				"secret_key": "abcd",
+			},
+		}
+		leaseID, err := exp.Register(req, resp)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return leaseID
+	}
+
+	// A lease of a token that no longer exists
+	register("prod/aws/foo", "missing")
+
+	// A lease whose token index has been lost
+	lost := register("prod/aws/bar", te.ID)
+	if err := exp.removeIndexByToken(te.ID, lost); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A token index of a lease that no longer exists
+	gone := register("prod/aws/baz", te.ID)
+	if err := exp.deleteEntry(gone); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	status := &tidyStatus{}
+	status.start()
+	if err := exp.Tidy(status); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	status.finish()
+
+	data := status.data()
+	expected := map[string]interface{}{
+		"revoked_leases_of_missing_tokens": 1,
+		"restored_token_indexes":           1,
+		"removed_token_indexes":            1,
+	}
+	if !reflect.DeepEqual(data["changes"], expected) || len(data["errors"].([]string)) != 0 {
+		t.Fatalf("bad: %#v", data)
+	}
+	if !reflect.DeepEqual(noop.Paths, []string{"foo"}) {
+		t.Fatalf("bad: %v", noop.Paths)
+	}
+
+	leases, err := exp.lookupByToken(te.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(leases, []string{lost}) {
+		t.Fatalf("bad: %v", leases)
+	}
+}
+
+func TestExpiration_RevokeRetry(t *testing.T) {
+	core, _, _, root := TestCoreWithTokenStore(t)
+	exp := core.expiration
+
+	core.logicalBackends["badrenew"] = badRenewFactory
+	me := &MountEntry{
+		Path: "badrenew/",
+		Type: "badrenew",
+	}
+	if err := core.mount(me); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []*leaseEntry
+	exp.revokeEventHook = func(le *leaseEntry, err error) {
+		events = append(events, le)
+		core.auditRevokeEvent(le, err)
+	}
+
+	req := &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "badrenew/creds",
+		ClientToken: root,
+	}
+	resp, err := core.HandleRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaseID := resp.Secret.LeaseID
+
+	for i := 1; i <= maxRevokeAttempts; i++ {
+		exp.expireID(leaseID)
+
+		le, err := exp.loadEntry(leaseID)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if le.RevokeAttempts != i || le.RevokeErr == "" || le.Irrevocable != (i == maxRevokeAttempts) {
+			t.Fatalf("bad: %#v", le)
+		}
+
+		// Failed attempts are retried until they run out
+		pending := exp.queue.contains(leaseID)
+		if pending == le.Irrevocable {
+			t.Fatalf("bad: %v %#v", pending, le)
+		}
+	}
+	if len(events) != maxRevokeAttempts || !events[len(events)-1].Irrevocable {
+		t.Fatalf("bad: %#v", events)
+	}
+
+	// Irrevocable leases are no longer attempted
+	exp.expireID(leaseID)
+	if len(events) != maxRevokeAttempts {
+		t.Fatalf("bad: %#v", events)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "sys/leases/irrevocable")
+	req.ClientToken = root
+	resp, err = core.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	leases := resp.Data["leases"].([]map[string]interface{})
+	if len(leases) != 1 || leases[0]["lease_id"] != leaseID ||
+		leases[0]["revoke_attempts"] != maxRevokeAttempts || leases[0]["error"] == "" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Forcing the revocation removes the lease
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/leases/revoke-force/badrenew")
+	req.ClientToken = root
+	if resp, err := core.HandleRequest(req); err != nil || resp != nil {
+		t.Fatalf("err: %v %#v", err, resp)
+	}
+	le, err := exp.loadEntry(leaseID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if le != nil {
+		t.Fatalf("bad: %#v", le)
+	}
+}
+
+func TestRevokeRetryDelay(t *testing.T) {
+	cases := []struct {
+		Attempts int
+		Delay    time.Duration
+	}{
+		{1, revokeRetryBase},
+		{2, 2 * revokeRetryBase},
+		{5, 16 * revokeRetryBase},
+		{100, maxRevokeRetryDelay},
+	}
+	for _, tc := range cases {
+		if delay := revokeRetryDelay(tc.Attempts); delay != tc.Delay {
+			t.Fatalf("bad: %d %v", tc.Attempts, delay)
+		}
+	}
+}
+
 func TestExpiration_RenewToken(t *testing.T) {
 	exp := mockExpiration(t)
 	root, err := exp.tokenStore.rootToken()
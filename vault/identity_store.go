@@ -0,0 +1,1497 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	// identityEntityPrefix is the storage prefix of the entities
+	identityEntityPrefix = "entity/"
+
+	// identityGroupPrefix is the storage prefix of the groups
+	identityGroupPrefix = "group/"
+
+	// groupTypeInternal groups have their members managed within Vault
+	groupTypeInternal = "internal"
+
+	// groupTypeExternal groups have their members managed by a credential
+	// backend, through the group alias of the group
+	groupTypeExternal = "external"
+)
+
+// Entity represents a client of Vault. It ties the identities of the client
+// within credential backends, its aliases, to a single identity carrying
+// policies of its own.
+type Entity struct {
+	ID             string            `json:"id"`
+	Name           string            `json:"name"`
+	Policies       []string          `json:"policies"`
+	Metadata       map[string]string `json:"metadata"`
+	Aliases        []*Alias          `json:"aliases"`
+	CreationTime   time.Time         `json:"creation_time"`
+	LastUpdateTime time.Time         `json:"last_update_time"`
+}
+
+// Group is a set of entities, and of other groups, granted the policies of
+// the group. The members of an external group are the entities which last
+// logged in with the group alias of the group.
+type Group struct {
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	Type            string            `json:"type"`
+	Policies        []string          `json:"policies"`
+	Metadata        map[string]string `json:"metadata"`
+	MemberEntityIDs []string          `json:"member_entity_ids"`
+	MemberGroupIDs  []string          `json:"member_group_ids"`
+	Alias           *Alias            `json:"alias,omitempty"`
+	CreationTime    time.Time         `json:"creation_time"`
+	LastUpdateTime  time.Time         `json:"last_update_time"`
+}
+
+// Alias is the identity of an entity or a group within the credential
+// backend mounted with the given accessor. CanonicalID is the ID of the
+// entity or group the alias belongs to.
+type Alias struct {
+	ID            string            `json:"id"`
+	CanonicalID   string            `json:"canonical_id"`
+	MountAccessor string            `json:"mount_accessor"`
+	MountType     string            `json:"mount_type"`
+	Name          string            `json:"name"`
+	Metadata      map[string]string `json:"metadata"`
+	CreationTime  time.Time         `json:"creation_time"`
+}
+
+// factors returns the key identifying the alias within the identity store
+func (a *Alias) factors() string {
+	return a.MountAccessor + "/" + a.Name
+}
+
+// IdentityStore keeps the entities and groups, along with indexes to look
+// them up by name and by alias. All of them are kept in memory, and written
+// through to storage on modification.
+type IdentityStore struct {
+	*framework.Backend
+
+	view logical.Storage
+	core *Core
+
+	lock         sync.RWMutex
+	entities     map[string]*Entity
+	entityNames  map[string]string
+	aliases      map[string]*Alias
+	groups       map[string]*Group
+	groupNames   map[string]string
+	groupAliases map[string]*Alias
+}
+
+// NewIdentityStore is used to construct the identity store backed by the
+// storage view of its mount
+func NewIdentityStore(c *Core, config *logical.BackendConfig) (*IdentityStore, error) {
+	if config == nil {
+		return nil, fmt.Errorf("configuration passed into backend is nil")
+	}
+
+	i := &IdentityStore{
+		view: config.StorageView,
+		core: c,
+	}
+
+	i.Backend = &framework.Backend{
+		Help: strings.TrimSpace(identityHelp),
+
+		Paths: []*framework.Path{
+			&framework.Path{
+				Pattern: "entity$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Name of the entity, generated if unset",
+					},
+					"policies": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: identityPoliciesHelp,
+					},
+					"metadata": &framework.FieldSchema{
+						Type:        framework.TypeMap,
+						Description: "Metadata to store with the entity",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: i.handleEntityCreate,
+				},
+
+				HelpSynopsis:    identityEntityHelp,
+				HelpDescription: identityEntityHelp,
+			},
+
+			&framework.Path{
+				Pattern: "entity/id/?$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: i.handleEntityList,
+				},
+
+				HelpSynopsis:    identityEntityListHelp,
+				HelpDescription: identityEntityListHelp,
+			},
+
+			&framework.Path{
+				Pattern: "entity/id/" + framework.GenericNameRegex("id"),
+
+				Fields: map[string]*framework.FieldSchema{
+					"id": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "ID of the entity",
+					},
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Name of the entity",
+					},
+					"policies": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: identityPoliciesHelp,
+					},
+					"metadata": &framework.FieldSchema{
+						Type:        framework.TypeMap,
+						Description: "Metadata to store with the entity",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   i.handleEntityRead,
+					logical.UpdateOperation: i.handleEntityUpdate,
+					logical.DeleteOperation: i.handleEntityDelete,
+				},
+
+				HelpSynopsis:    identityEntityIDHelp,
+				HelpDescription: identityEntityIDHelp,
+			},
+
+			&framework.Path{
+				Pattern: "entity/name/" + framework.GenericNameRegex("name"),
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Name of the entity",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: i.handleEntityReadByName,
+				},
+
+				HelpSynopsis:    identityEntityNameHelp,
+				HelpDescription: identityEntityNameHelp,
+			},
+
+			&framework.Path{
+				Pattern: "entity-alias$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Name of the user within the credential backend",
+					},
+					"mount_accessor": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Accessor of the mount of the credential backend",
+					},
+					"canonical_id": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "ID of the entity the alias belongs to",
+					},
+					"metadata": &framework.FieldSchema{
+						Type:        framework.TypeMap,
+						Description: "Metadata to store with the alias",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: i.handleEntityAliasCreate,
+				},
+
+				HelpSynopsis:    identityEntityAliasHelp,
+				HelpDescription: identityEntityAliasHelp,
+			},
+
+			&framework.Path{
+				Pattern: "entity-alias/id/?$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: i.handleEntityAliasList,
+				},
+
+				HelpSynopsis:    identityEntityAliasListHelp,
+				HelpDescription: identityEntityAliasListHelp,
+			},
+
+			&framework.Path{
+				Pattern: "entity-alias/id/" + framework.GenericNameRegex("id"),
+
+				Fields: map[string]*framework.FieldSchema{
+					"id": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "ID of the alias",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   i.handleEntityAliasRead,
+					logical.DeleteOperation: i.handleEntityAliasDelete,
+				},
+
+				HelpSynopsis:    identityEntityAliasIDHelp,
+				HelpDescription: identityEntityAliasIDHelp,
+			},
+
+			&framework.Path{
+				Pattern: "group$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Name of the group, generated if unset",
+					},
+					"type": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Default:     groupTypeInternal,
+						Description: identityGroupTypeHelp,
+					},
+					"policies": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: identityPoliciesHelp,
+					},
+					"member_entity_ids": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: identityMemberEntityIDsHelp,
+					},
+					"member_group_ids": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: identityMemberGroupIDsHelp,
+					},
+					"metadata": &framework.FieldSchema{
+						Type:        framework.TypeMap,
+						Description: "Metadata to store with the group",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: i.handleGroupCreate,
+				},
+
+				HelpSynopsis:    identityGroupHelp,
+				HelpDescription: identityGroupHelp,
+			},
+
+			&framework.Path{
+				Pattern: "group/id/?$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: i.handleGroupList,
+				},
+
+				HelpSynopsis:    identityGroupListHelp,
+				HelpDescription: identityGroupListHelp,
+			},
+
+			&framework.Path{
+				Pattern: "group/id/" + framework.GenericNameRegex("id"),
+
+				Fields: map[string]*framework.FieldSchema{
+					"id": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "ID of the group",
+					},
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Name of the group",
+					},
+					"type": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: identityGroupTypeHelp,
+					},
+					"policies": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: identityPoliciesHelp,
+					},
+					"member_entity_ids": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: identityMemberEntityIDsHelp,
+					},
+					"member_group_ids": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: identityMemberGroupIDsHelp,
+					},
+					"metadata": &framework.FieldSchema{
+						Type:        framework.TypeMap,
+						Description: "Metadata to store with the group",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   i.handleGroupRead,
+					logical.UpdateOperation: i.handleGroupUpdate,
+					logical.DeleteOperation: i.handleGroupDelete,
+				},
+
+				HelpSynopsis:    identityGroupIDHelp,
+				HelpDescription: identityGroupIDHelp,
+			},
+
+			&framework.Path{
+				Pattern: "group/name/" + framework.GenericNameRegex("name"),
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Name of the group",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: i.handleGroupReadByName,
+				},
+
+				HelpSynopsis:    identityGroupNameHelp,
+				HelpDescription: identityGroupNameHelp,
+			},
+
+			&framework.Path{
+				Pattern: "group-alias$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Name of the group within the credential backend",
+					},
+					"mount_accessor": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Accessor of the mount of the credential backend",
+					},
+					"canonical_id": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "ID of the external group the alias belongs to",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: i.handleGroupAliasCreate,
+				},
+
+				HelpSynopsis:    identityGroupAliasHelp,
+				HelpDescription: identityGroupAliasHelp,
+			},
+
+			&framework.Path{
+				Pattern: "group-alias/id/?$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: i.handleGroupAliasList,
+				},
+
+				HelpSynopsis:    identityGroupAliasListHelp,
+				HelpDescription: identityGroupAliasListHelp,
+			},
+
+			&framework.Path{
+				Pattern: "group-alias/id/" + framework.GenericNameRegex("id"),
+
+				Fields: map[string]*framework.FieldSchema{
+					"id": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "ID of the alias",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   i.handleGroupAliasRead,
+					logical.DeleteOperation: i.handleGroupAliasDelete,
+				},
+
+				HelpSynopsis:    identityGroupAliasIDHelp,
+				HelpDescription: identityGroupAliasIDHelp,
+			},
+		},
+	}
+
+	i.Backend.Setup(config)
+
+	return i, nil
+}
+
+// load reads the entities and groups from storage and builds the indexes
+func (i *IdentityStore) load() error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.entities = make(map[string]*Entity)
+	i.entityNames = make(map[string]string)
+	i.aliases = make(map[string]*Alias)
+	i.groups = make(map[string]*Group)
+	i.groupNames = make(map[string]string)
+	i.groupAliases = make(map[string]*Alias)
+
+	ids, err := i.view.List(identityEntityPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to list entities: %v", err)
+	}
+	for _, id := range ids {
+		var entity Entity
+		if err := i.read(identityEntityPrefix+id, &entity); err != nil {
+			return err
+		}
+		i.indexEntity(&entity)
+	}
+
+	ids, err = i.view.List(identityGroupPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to list groups: %v", err)
+	}
+	for _, id := range ids {
+		var group Group
+		if err := i.read(identityGroupPrefix+id, &group); err != nil {
+			return err
+		}
+		i.indexGroup(&group)
+	}
+	return nil
+}
+
+func (i *IdentityStore) read(key string, out interface{}) error {
+	entry, err := i.view.Get(key)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", key, err)
+	}
+	if entry == nil {
+		return fmt.Errorf("missing %s", key)
+	}
+	if err := json.Unmarshal(entry.Value, out); err != nil {
+		return fmt.Errorf("failed to decode %s: %v", key, err)
+	}
+	return nil
+}
+
+func (i *IdentityStore) indexEntity(entity *Entity) {
+	i.entities[entity.ID] = entity
+	i.entityNames[entity.Name] = entity.ID
+	for _, alias := range entity.Aliases {
+		i.aliases[alias.factors()] = alias
+	}
+}
+
+func (i *IdentityStore) unindexEntity(entity *Entity) {
+	delete(i.entities, entity.ID)
+	delete(i.entityNames, entity.Name)
+	for _, alias := range entity.Aliases {
+		delete(i.aliases, alias.factors())
+	}
+}
+
+func (i *IdentityStore) indexGroup(group *Group) {
+	i.groups[group.ID] = group
+	i.groupNames[group.Name] = group.ID
+	if group.Alias != nil {
+		i.groupAliases[group.Alias.factors()] = group.Alias
+	}
+}
+
+func (i *IdentityStore) unindexGroup(group *Group) {
+	delete(i.groups, group.ID)
+	delete(i.groupNames, group.Name)
+	if group.Alias != nil {
+		delete(i.groupAliases, group.Alias.factors())
+	}
+}
+
+// persistEntity writes the entity to storage and updates the indexes.
+// The lock must be held.
+func (i *IdentityStore) persistEntity(entity *Entity) error {
+	entry, err := logical.StorageEntryJSON(identityEntityPrefix+entity.ID, entity)
+	if err != nil {
+		return err
+	}
+	if err := i.view.Put(entry); err != nil {
+		return err
+	}
+
+	if old, ok := i.entities[entity.ID]; ok {
+		i.unindexEntity(old)
+	}
+	i.indexEntity(entity)
+	return nil
+}
+
+// persistGroup writes the group to storage and updates the indexes. The
+// lock must be held.
+func (i *IdentityStore) persistGroup(group *Group) error {
+	entry, err := logical.StorageEntryJSON(identityGroupPrefix+group.ID, group)
+	if err != nil {
+		return err
+	}
+	if err := i.view.Put(entry); err != nil {
+		return err
+	}
+
+	if old, ok := i.groups[group.ID]; ok {
+		i.unindexGroup(old)
+	}
+	i.indexGroup(group)
+	return nil
+}
+
+// newIdentityID generates the ID of a new entity, group or alias
+func newIdentityID() (string, error) {
+	return uuid.GenerateUUID()
+}
+
+// clone returns a copy of the entity that can be modified before being
+// persisted, without affecting readers of the current one
+func (e *Entity) clone() *Entity {
+	out := *e
+	out.Aliases = append([]*Alias{}, e.Aliases...)
+	return &out
+}
+
+// clone returns a copy of the group that can be modified before being
+// persisted, without affecting readers of the current one
+func (g *Group) clone() *Group {
+	out := *g
+	out.MemberEntityIDs = append([]string{}, g.MemberEntityIDs...)
+	out.MemberGroupIDs = append([]string{}, g.MemberGroupIDs...)
+	return &out
+}
+
+// EntityByAlias resolves the alias a credential backend authenticated a
+// client with to its entity. An entity is created for aliases seen for
+// the first time. The external groups of the mount are updated to match
+// the group aliases of the client.
+func (i *IdentityStore) EntityByAlias(mount *MountEntry, alias *logical.Alias, groupAliases []*logical.Alias) (*Entity, error) {
+	if alias == nil || alias.Name == "" {
+		return nil, fmt.Errorf("missing alias name")
+	}
+	if mount == nil || mount.Accessor == "" {
+		return nil, fmt.Errorf("missing mount accessor")
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	key := (&Alias{MountAccessor: mount.Accessor, Name: alias.Name}).factors()
+
+	var entity *Entity
+	if existing, ok := i.aliases[key]; ok {
+		entity = i.entities[existing.CanonicalID]
+	}
+	if entity == nil {
+		var err error
+		entity, err = i.createEntity(mount, alias)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := i.refreshExternalGroups(entity, mount.Accessor, groupAliases); err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// createEntity creates an entity with the given alias. The lock must be
+// held.
+func (i *IdentityStore) createEntity(mount *MountEntry, alias *logical.Alias) (*Entity, error) {
+	entityID, err := newIdentityID()
+	if err != nil {
+		return nil, err
+	}
+	aliasID, err := newIdentityID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	entity := &Entity{
+		ID:             entityID,
+		Name:           "entity_" + entityID[:8],
+		CreationTime:   now,
+		LastUpdateTime: now,
+		Aliases: []*Alias{
+			&Alias{
+				ID:            aliasID,
+				CanonicalID:   entityID,
+				MountAccessor: mount.Accessor,
+				MountType:     mount.Type,
+				Name:          alias.Name,
+				Metadata:      alias.Metadata,
+				CreationTime:  now,
+			},
+		},
+	}
+	if err := i.persistEntity(entity); err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// refreshExternalGroups makes the entity a member of exactly the external
+// groups of the mount with the given group aliases. The lock must be held.
+func (i *IdentityStore) refreshExternalGroups(entity *Entity, mountAccessor string, groupAliases []*logical.Alias) error {
+	member := make(map[string]bool)
+	for _, groupAlias := range groupAliases {
+		key := (&Alias{MountAccessor: mountAccessor, Name: groupAlias.Name}).factors()
+		if alias, ok := i.groupAliases[key]; ok {
+			member[alias.CanonicalID] = true
+		}
+	}
+
+	for _, group := range i.groups {
+		if group.Type != groupTypeExternal || group.Alias == nil || group.Alias.MountAccessor != mountAccessor {
+			continue
+		}
+
+		isMember := strutil.StrListContains(group.MemberEntityIDs, entity.ID)
+		if isMember == member[group.ID] {
+			continue
+		}
+
+		group = group.clone()
+		if isMember {
+			group.MemberEntityIDs = removeString(group.MemberEntityIDs, entity.ID)
+		} else {
+			group.MemberEntityIDs = append(group.MemberEntityIDs, entity.ID)
+		}
+		group.LastUpdateTime = time.Now().UTC()
+		if err := i.persistGroup(group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Entity returns the entity with the given ID, or nil if there is none.
+// The entity must not be modified.
+func (i *IdentityStore) Entity(entityID string) *Entity {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return i.entities[entityID]
+}
+
+// EntityPolicies returns the policies granted to the entity with the given
+// ID, by the entity itself and by the groups it is a member of, directly
+// or through other groups
+func (i *IdentityStore) EntityPolicies(entityID string) []string {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	entity, ok := i.entities[entityID]
+	if !ok {
+		return nil
+	}
+
+	policies := append([]string{}, entity.Policies...)
+	for _, group := range i.entityGroups(entityID) {
+		policies = append(policies, group.Policies...)
+	}
+	return policies
+}
+
+// EntityGroupNames returns the names of the groups the entity with the
+// given ID is a member of, directly or through other groups
+func (i *IdentityStore) EntityGroupNames(entityID string) []string {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	var names []string
+	for _, group := range i.entityGroups(entityID) {
+		names = append(names, group.Name)
+	}
+	return names
+}
+
+// entityGroups returns the groups the entity is a member of, directly or
+// through other groups. The lock must be held.
+func (i *IdentityStore) entityGroups(entityID string) []*Group {
+	seen := make(map[string]bool)
+	var result []*Group
+	for _, group := range i.groups {
+		for _, id := range group.MemberEntityIDs {
+			if id == entityID && !seen[group.ID] {
+				seen[group.ID] = true
+				result = append(result, group)
+			}
+		}
+	}
+
+	// Groups including a group of the entity include the entity as well
+	for n := 0; n < len(result); n++ {
+		for _, group := range i.groups {
+			if seen[group.ID] {
+				continue
+			}
+			for _, id := range group.MemberGroupIDs {
+				if id == result[n].ID {
+					seen[group.ID] = true
+					result = append(result, group)
+					break
+				}
+			}
+		}
+	}
+	return result
+}
+
+// removeString returns the list without the occurrences of the given string
+func removeString(list []string, s string) []string {
+	var result []string
+	for _, item := range list {
+		if item != s {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// sanitizeIdentityList splits a comma-delimited list, trimming whitespace
+// and dropping empty items
+func sanitizeIdentityList(raw string) []string {
+	var result []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// identityMetadata converts the metadata given to the backend to strings
+func identityMetadata(raw interface{}) map[string]string {
+	data, _ := raw.(map[string]interface{})
+	if len(data) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(data))
+	for k, v := range data {
+		result[k] = fmt.Sprintf("%v", v)
+	}
+	return result
+}
+
+// mountTypeByAccessor returns the type of the credential backend mounted
+// with the given accessor
+func (i *IdentityStore) mountTypeByAccessor(accessor string) (string, error) {
+	i.core.authLock.RLock()
+	defer i.core.authLock.RUnlock()
+
+	if i.core.auth != nil {
+		for _, entry := range i.core.auth.Entries {
+			if entry.Accessor == accessor {
+				return entry.Type, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no credential backend with mount accessor '%s'", accessor)
+}
+
+func aliasResponseData(alias *Alias) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             alias.ID,
+		"canonical_id":   alias.CanonicalID,
+		"mount_accessor": alias.MountAccessor,
+		"mount_type":     alias.MountType,
+		"name":           alias.Name,
+		"metadata":       alias.Metadata,
+		"creation_time":  alias.CreationTime,
+	}
+}
+
+// entityResponseData formats the entity for responses. The lock must be
+// held.
+func (i *IdentityStore) entityResponseData(entity *Entity) map[string]interface{} {
+	aliases := make([]interface{}, 0, len(entity.Aliases))
+	for _, alias := range entity.Aliases {
+		aliases = append(aliases, aliasResponseData(alias))
+	}
+
+	groupIDs := []string{}
+	for _, group := range i.entityGroups(entity.ID) {
+		groupIDs = append(groupIDs, group.ID)
+	}
+	sort.Strings(groupIDs)
+
+	return map[string]interface{}{
+		"id":               entity.ID,
+		"name":             entity.Name,
+		"policies":         entity.Policies,
+		"metadata":         entity.Metadata,
+		"aliases":          aliases,
+		"group_ids":        groupIDs,
+		"creation_time":    entity.CreationTime,
+		"last_update_time": entity.LastUpdateTime,
+	}
+}
+
+func groupResponseData(group *Group) map[string]interface{} {
+	data := map[string]interface{}{
+		"id":                group.ID,
+		"name":              group.Name,
+		"type":              group.Type,
+		"policies":          group.Policies,
+		"metadata":          group.Metadata,
+		"member_entity_ids": group.MemberEntityIDs,
+		"member_group_ids":  group.MemberGroupIDs,
+		"alias":             nil,
+		"creation_time":     group.CreationTime,
+		"last_update_time":  group.LastUpdateTime,
+	}
+	if group.Alias != nil {
+		data["alias"] = aliasResponseData(group.Alias)
+	}
+	return data
+}
+
+// handleEntityCreate is used to create an entity
+func (i *IdentityStore) handleEntityCreate(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	id, err := newIdentityID()
+	if err != nil {
+		return nil, err
+	}
+	name := d.Get("name").(string)
+	if name == "" {
+		name = "entity_" + id[:8]
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if _, ok := i.entityNames[name]; ok {
+		return logical.ErrorResponse(fmt.Sprintf("entity name '%s' is already in use", name)), logical.ErrInvalidRequest
+	}
+
+	now := time.Now().UTC()
+	entity := &Entity{
+		ID:             id,
+		Name:           name,
+		Policies:       sanitizeIdentityList(d.Get("policies").(string)),
+		Metadata:       identityMetadata(d.Get("metadata")),
+		CreationTime:   now,
+		LastUpdateTime: now,
+	}
+	if err := i.persistEntity(entity); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"id":   entity.ID,
+			"name": entity.Name,
+		},
+	}, nil
+}
+
+// handleEntityUpdate is used to update the name, policies or metadata of
+// an entity
+func (i *IdentityStore) handleEntityUpdate(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	entity, ok := i.entities[d.Get("id").(string)]
+	if !ok {
+		return logical.ErrorResponse("entity not found"), logical.ErrInvalidRequest
+	}
+	entity = entity.clone()
+
+	if raw, ok := d.GetOk("name"); ok {
+		name := raw.(string)
+		if id, ok := i.entityNames[name]; ok && id != entity.ID {
+			return logical.ErrorResponse(fmt.Sprintf("entity name '%s' is already in use", name)), logical.ErrInvalidRequest
+		}
+		if name != "" {
+			entity.Name = name
+		}
+	}
+	if raw, ok := d.GetOk("policies"); ok {
+		entity.Policies = sanitizeIdentityList(raw.(string))
+	}
+	if raw, ok := d.GetOk("metadata"); ok {
+		entity.Metadata = identityMetadata(raw)
+	}
+	entity.LastUpdateTime = time.Now().UTC()
+
+	if err := i.persistEntity(entity); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// handleEntityRead is used to read an entity by its ID
+func (i *IdentityStore) handleEntityRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	entity, ok := i.entities[d.Get("id").(string)]
+	if !ok {
+		return nil, nil
+	}
+	return &logical.Response{Data: i.entityResponseData(entity)}, nil
+}
+
+// handleEntityReadByName is used to read an entity by its name
+func (i *IdentityStore) handleEntityReadByName(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	entity, ok := i.entities[i.entityNames[d.Get("name").(string)]]
+	if !ok {
+		return nil, nil
+	}
+	return &logical.Response{Data: i.entityResponseData(entity)}, nil
+}
+
+// handleEntityDelete is used to delete an entity along with its aliases.
+// Tokens of the entity lose the policies it granted.
+func (i *IdentityStore) handleEntityDelete(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	entity, ok := i.entities[d.Get("id").(string)]
+	if !ok {
+		return nil, nil
+	}
+
+	for _, group := range i.groups {
+		if !strutil.StrListContains(group.MemberEntityIDs, entity.ID) {
+			continue
+		}
+		group = group.clone()
+		group.MemberEntityIDs = removeString(group.MemberEntityIDs, entity.ID)
+		group.LastUpdateTime = time.Now().UTC()
+		if err := i.persistGroup(group); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := i.view.Delete(identityEntityPrefix + entity.ID); err != nil {
+		return nil, err
+	}
+	i.unindexEntity(entity)
+	return nil, nil
+}
+
+// handleEntityList is used to list the IDs of the entities
+func (i *IdentityStore) handleEntityList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	keys := make([]string, 0, len(i.entities))
+	for id := range i.entities {
+		keys = append(keys, id)
+	}
+	sort.Strings(keys)
+	return logical.ListResponse(keys), nil
+}
+
+// aliasByID returns the alias with the given ID among the aliases. The
+// lock must be held.
+func aliasByID(aliases map[string]*Alias, id string) *Alias {
+	for _, alias := range aliases {
+		if alias.ID == id {
+			return alias
+		}
+	}
+	return nil
+}
+
+// aliasIDs returns the sorted IDs of the aliases
+func aliasIDs(aliases map[string]*Alias) []string {
+	keys := make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		keys = append(keys, alias.ID)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// handleEntityAliasCreate is used to tie the user of a credential backend
+// to an existing entity
+func (i *IdentityStore) handleEntityAliasCreate(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	accessor := d.Get("mount_accessor").(string)
+	canonicalID := d.Get("canonical_id").(string)
+	if name == "" || accessor == "" || canonicalID == "" {
+		return logical.ErrorResponse("name, mount_accessor and canonical_id are required"), logical.ErrInvalidRequest
+	}
+	mountType, err := i.mountTypeByAccessor(accessor)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	aliasID, err := newIdentityID()
+	if err != nil {
+		return nil, err
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	entity, ok := i.entities[canonicalID]
+	if !ok {
+		return logical.ErrorResponse("entity not found"), logical.ErrInvalidRequest
+	}
+
+	alias := &Alias{
+		ID:            aliasID,
+		CanonicalID:   entity.ID,
+		MountAccessor: accessor,
+		MountType:     mountType,
+		Name:          name,
+		Metadata:      identityMetadata(d.Get("metadata")),
+		CreationTime:  time.Now().UTC(),
+	}
+	if existing, ok := i.aliases[alias.factors()]; ok {
+		return logical.ErrorResponse(fmt.Sprintf("alias is already tied to entity '%s'", existing.CanonicalID)), logical.ErrInvalidRequest
+	}
+	for _, existing := range entity.Aliases {
+		if existing.MountAccessor == accessor {
+			return logical.ErrorResponse("entity already has an alias for the mount accessor"), logical.ErrInvalidRequest
+		}
+	}
+
+	entity = entity.clone()
+	entity.Aliases = append(entity.Aliases, alias)
+	entity.LastUpdateTime = alias.CreationTime
+	if err := i.persistEntity(entity); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"id":           alias.ID,
+			"canonical_id": alias.CanonicalID,
+		},
+	}, nil
+}
+
+// handleEntityAliasRead is used to read an entity alias by its ID
+func (i *IdentityStore) handleEntityAliasRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	alias := aliasByID(i.aliases, d.Get("id").(string))
+	if alias == nil {
+		return nil, nil
+	}
+	return &logical.Response{Data: aliasResponseData(alias)}, nil
+}
+
+// handleEntityAliasDelete is used to remove an alias from its entity. The
+// next login with the alias creates a new entity.
+func (i *IdentityStore) handleEntityAliasDelete(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	alias := aliasByID(i.aliases, d.Get("id").(string))
+	if alias == nil {
+		return nil, nil
+	}
+	entity, ok := i.entities[alias.CanonicalID]
+	if !ok {
+		return nil, fmt.Errorf("entity of alias %s not found", alias.ID)
+	}
+
+	entity = entity.clone()
+	entity.Aliases = nil
+	for _, existing := range i.entities[alias.CanonicalID].Aliases {
+		if existing.ID != alias.ID {
+			entity.Aliases = append(entity.Aliases, existing)
+		}
+	}
+	entity.LastUpdateTime = time.Now().UTC()
+	if err := i.persistEntity(entity); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// handleEntityAliasList is used to list the IDs of the entity aliases
+func (i *IdentityStore) handleEntityAliasList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	return logical.ListResponse(aliasIDs(i.aliases)), nil
+}
+
+// groupContains returns whether the inner group is a member of the outer
+// group, directly or through other groups. The lock must be held.
+func (i *IdentityStore) groupContains(outerID, innerID string) bool {
+	seen := map[string]bool{outerID: true}
+	pending := []string{outerID}
+	for len(pending) > 0 {
+		group, ok := i.groups[pending[0]]
+		pending = pending[1:]
+		if !ok {
+			continue
+		}
+		for _, id := range group.MemberGroupIDs {
+			if id == innerID {
+				return true
+			}
+			if !seen[id] {
+				seen[id] = true
+				pending = append(pending, id)
+			}
+		}
+	}
+	return false
+}
+
+// setGroupMembers validates and sets the members of the group given to
+// the backend. The lock must be held.
+func (i *IdentityStore) setGroupMembers(group *Group, d *framework.FieldData) error {
+	if raw, ok := d.GetOk("member_entity_ids"); ok {
+		ids := sanitizeIdentityList(raw.(string))
+		if len(ids) > 0 && group.Type == groupTypeExternal {
+			return fmt.Errorf("members of external groups are managed by their alias")
+		}
+		for _, id := range ids {
+			if _, ok := i.entities[id]; !ok {
+				return fmt.Errorf("entity '%s' not found", id)
+			}
+		}
+		group.MemberEntityIDs = ids
+	}
+
+	if raw, ok := d.GetOk("member_group_ids"); ok {
+		ids := sanitizeIdentityList(raw.(string))
+		for _, id := range ids {
+			if _, ok := i.groups[id]; !ok {
+				return fmt.Errorf("group '%s' not found", id)
+			}
+			if id == group.ID || i.groupContains(id, group.ID) {
+				return fmt.Errorf("group '%s' would be a member of itself", id)
+			}
+		}
+		group.MemberGroupIDs = ids
+	}
+	return nil
+}
+
+// handleGroupCreate is used to create a group
+func (i *IdentityStore) handleGroupCreate(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	id, err := newIdentityID()
+	if err != nil {
+		return nil, err
+	}
+	name := d.Get("name").(string)
+	if name == "" {
+		name = "group_" + id[:8]
+	}
+	groupType := d.Get("type").(string)
+	if groupType != groupTypeInternal && groupType != groupTypeExternal {
+		return logical.ErrorResponse(fmt.Sprintf("invalid group type '%s'", groupType)), logical.ErrInvalidRequest
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if _, ok := i.groupNames[name]; ok {
+		return logical.ErrorResponse(fmt.Sprintf("group name '%s' is already in use", name)), logical.ErrInvalidRequest
+	}
+
+	now := time.Now().UTC()
+	group := &Group{
+		ID:             id,
+		Name:           name,
+		Type:           groupType,
+		Policies:       sanitizeIdentityList(d.Get("policies").(string)),
+		Metadata:       identityMetadata(d.Get("metadata")),
+		CreationTime:   now,
+		LastUpdateTime: now,
+	}
+	if err := i.setGroupMembers(group, d); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	if err := i.persistGroup(group); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"id":   group.ID,
+			"name": group.Name,
+		},
+	}, nil
+}
+
+// handleGroupUpdate is used to update a group
+func (i *IdentityStore) handleGroupUpdate(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	group, ok := i.groups[d.Get("id").(string)]
+	if !ok {
+		return logical.ErrorResponse("group not found"), logical.ErrInvalidRequest
+	}
+	group = group.clone()
+
+	if raw, ok := d.GetOk("type"); ok && raw.(string) != group.Type {
+		return logical.ErrorResponse("the type of a group cannot be changed"), logical.ErrInvalidRequest
+	}
+	if raw, ok := d.GetOk("name"); ok {
+		name := raw.(string)
+		if id, ok := i.groupNames[name]; ok && id != group.ID {
+			return logical.ErrorResponse(fmt.Sprintf("group name '%s' is already in use", name)), logical.ErrInvalidRequest
+		}
+		if name != "" {
+			group.Name = name
+		}
+	}
+	if raw, ok := d.GetOk("policies"); ok {
+		group.Policies = sanitizeIdentityList(raw.(string))
+	}
+	if raw, ok := d.GetOk("metadata"); ok {
+		group.Metadata = identityMetadata(raw)
+	}
+	if err := i.setGroupMembers(group, d); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	group.LastUpdateTime = time.Now().UTC()
+
+	if err := i.persistGroup(group); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// handleGroupRead is used to read a group by its ID
+func (i *IdentityStore) handleGroupRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	group, ok := i.groups[d.Get("id").(string)]
+	if !ok {
+		return nil, nil
+	}
+	return &logical.Response{Data: groupResponseData(group)}, nil
+}
+
+// handleGroupReadByName is used to read a group by its name
+func (i *IdentityStore) handleGroupReadByName(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	group, ok := i.groups[i.groupNames[d.Get("name").(string)]]
+	if !ok {
+		return nil, nil
+	}
+	return &logical.Response{Data: groupResponseData(group)}, nil
+}
+
+// handleGroupDelete is used to delete a group along with its alias
+func (i *IdentityStore) handleGroupDelete(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	group, ok := i.groups[d.Get("id").(string)]
+	if !ok {
+		return nil, nil
+	}
+
+	for _, parent := range i.groups {
+		if !strutil.StrListContains(parent.MemberGroupIDs, group.ID) {
+			continue
+		}
+		parent = parent.clone()
+		parent.MemberGroupIDs = removeString(parent.MemberGroupIDs, group.ID)
+		parent.LastUpdateTime = time.Now().UTC()
+		if err := i.persistGroup(parent); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := i.view.Delete(identityGroupPrefix + group.ID); err != nil {
+		return nil, err
+	}
+	i.unindexGroup(group)
+	return nil, nil
+}
+
+// handleGroupList is used to list the IDs of the groups
+func (i *IdentityStore) handleGroupList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	keys := make([]string, 0, len(i.groups))
+	for id := range i.groups {
+		keys = append(keys, id)
+	}
+	sort.Strings(keys)
+	return logical.ListResponse(keys), nil
+}
+
+// handleGroupAliasCreate is used to tie a group of a credential backend
+// to an external group
+func (i *IdentityStore) handleGroupAliasCreate(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	accessor := d.Get("mount_accessor").(string)
+	canonicalID := d.Get("canonical_id").(string)
+	if name == "" || accessor == "" || canonicalID == "" {
+		return logical.ErrorResponse("name, mount_accessor and canonical_id are required"), logical.ErrInvalidRequest
+	}
+	mountType, err := i.mountTypeByAccessor(accessor)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	aliasID, err := newIdentityID()
+	if err != nil {
+		return nil, err
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	group, ok := i.groups[canonicalID]
+	if !ok {
+		return logical.ErrorResponse("group not found"), logical.ErrInvalidRequest
+	}
+	if group.Type != groupTypeExternal {
+		return logical.ErrorResponse("only external groups can have an alias"), logical.ErrInvalidRequest
+	}
+	if group.Alias != nil {
+		return logical.ErrorResponse("group already has an alias"), logical.ErrInvalidRequest
+	}
+
+	alias := &Alias{
+		ID:            aliasID,
+		CanonicalID:   group.ID,
+		MountAccessor: accessor,
+		MountType:     mountType,
+		Name:          name,
+		CreationTime:  time.Now().UTC(),
+	}
+	if existing, ok := i.groupAliases[alias.factors()]; ok {
+		return logical.ErrorResponse(fmt.Sprintf("alias is already tied to group '%s'", existing.CanonicalID)), logical.ErrInvalidRequest
+	}
+
+	group = group.clone()
+	group.Alias = alias
+	group.LastUpdateTime = alias.CreationTime
+	if err := i.persistGroup(group); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"id":           alias.ID,
+			"canonical_id": alias.CanonicalID,
+		},
+	}, nil
+}
+
+// handleGroupAliasRead is used to read a group alias by its ID
+func (i *IdentityStore) handleGroupAliasRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	alias := aliasByID(i.groupAliases, d.Get("id").(string))
+	if alias == nil {
+		return nil, nil
+	}
+	return &logical.Response{Data: aliasResponseData(alias)}, nil
+}
+
+// handleGroupAliasDelete is used to remove the alias of a group. The
+// members of the group are kept until their next login.
+func (i *IdentityStore) handleGroupAliasDelete(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	alias := aliasByID(i.groupAliases, d.Get("id").(string))
+	if alias == nil {
+		return nil, nil
+	}
+	group, ok := i.groups[alias.CanonicalID]
+	if !ok {
+		return nil, fmt.Errorf("group of alias %s not found", alias.ID)
+	}
+
+	group = group.clone()
+	group.Alias = nil
+	group.LastUpdateTime = time.Now().UTC()
+	if err := i.persistGroup(group); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// handleGroupAliasList is used to list the IDs of the group aliases
+func (i *IdentityStore) handleGroupAliasList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	return logical.ListResponse(aliasIDs(i.groupAliases)), nil
+}
+
+const (
+	identityHelp = `The identity backend is always enabled and builtin to Vault.
+It keeps the entities clients are resolved to when logging in, along with
+their aliases within credential backends, and the groups of entities.
+Tokens of an entity are granted the policies of the entity and of its groups.`
+	identityEntityHelp          = `This endpoint creates entities.`
+	identityEntityListHelp      = `This endpoint lists the IDs of the entities.`
+	identityEntityIDHelp        = `This endpoint allows reading, updating, and deleting entities by ID.`
+	identityEntityNameHelp      = `This endpoint reads entities by name.`
+	identityEntityAliasHelp     = `This endpoint ties the user of a credential backend to an entity.`
+	identityEntityAliasListHelp = `This endpoint lists the IDs of the entity aliases.`
+	identityEntityAliasIDHelp   = `This endpoint allows reading and deleting entity aliases by ID.`
+	identityGroupHelp           = `This endpoint creates groups.`
+	identityGroupListHelp       = `This endpoint lists the IDs of the groups.`
+	identityGroupIDHelp         = `This endpoint allows reading, updating, and deleting groups by ID.`
+	identityGroupNameHelp       = `This endpoint reads groups by name.`
+	identityGroupAliasHelp      = `This endpoint ties a group of a credential backend to an external group.`
+	identityGroupAliasListHelp  = `This endpoint lists the IDs of the group aliases.`
+	identityGroupAliasIDHelp    = `This endpoint allows reading and deleting group aliases by ID.`
+	identityPoliciesHelp        = `Policies granted to the tokens of the
+entities. This parameter should be sent as
+a comma-delimited string.`
+	identityGroupTypeHelp = `Type of the group, either "internal" or
+"external". The members of external groups
+are the entities which logged in with the
+alias of the group.`
+	identityMemberEntityIDsHelp = `IDs of the entities which are members of
+the group. This parameter should be sent as
+a comma-delimited string.`
+	identityMemberGroupIDsHelp = `IDs of the groups which are members of
+the group, with their members. This parameter
+should be sent as a comma-delimited string.`
+)
@@ -0,0 +1,240 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func testIdentityRequest(t *testing.T, c *Core, op logical.Operation, path, token string, data map[string]interface{}) *logical.Response {
+	req := logical.TestRequest(t, op, path)
+	req.ClientToken = token
+	if data != nil {
+		req.Data = data
+	}
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("%s %s: err: %v %v", op, path, err, resp)
+	}
+	return resp
+}
+
+// testIdentityLoginBackend enables a noop credential backend at auth/foo
+// returning the given auth, and returns its mount accessor
+func testIdentityLoginBackend(t *testing.T, c *Core, root string, auth *logical.Auth) string {
+	noop := &NoopBackend{
+		Login:    []string{"login"},
+		Response: &logical.Response{Auth: auth},
+	}
+	c.credentialBackends["noop"] = func(conf *logical.BackendConfig) (logical.Backend, error) {
+		return noop, nil
+	}
+	testIdentityRequest(t, c, logical.UpdateOperation, "sys/auth/foo", root, map[string]interface{}{
+		"type": "noop",
+	})
+	return c.router.MatchingMountEntry("auth/foo/login").Accessor
+}
+
+func testIdentityLogin(t *testing.T, c *Core) *logical.Auth {
+	resp, err := c.HandleRequest(&logical.Request{Path: "auth/foo/login"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Auth.EntityID == "" {
+		t.Fatalf("bad: %#v", resp.Auth)
+	}
+	return resp.Auth
+}
+
+func TestIdentityStore_Login(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+	accessor := testIdentityLoginBackend(t, c, root, &logical.Auth{
+		Policies: []string{"foo"},
+		Alias:    &logical.Alias{Name: "armon"},
+	})
+
+	// The same alias resolves to the same entity on every login
+	auth := testIdentityLogin(t, c)
+	if again := testIdentityLogin(t, c); again.EntityID != auth.EntityID {
+		t.Fatalf("bad: %s %s", again.EntityID, auth.EntityID)
+	}
+
+	resp := testIdentityRequest(t, c, logical.ReadOperation, "identity/entity/id/"+auth.EntityID, root, nil)
+	aliases := resp.Data["aliases"].([]interface{})
+	if len(aliases) != 1 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	alias := aliases[0].(map[string]interface{})
+	if alias["name"] != "armon" || alias["mount_accessor"] != accessor || alias["mount_type"] != "noop" {
+		t.Fatalf("bad: %#v", alias)
+	}
+
+	te, err := c.tokenStore.Lookup(auth.ClientToken)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if te.EntityID != auth.EntityID {
+		t.Fatalf("bad: %#v", te)
+	}
+
+	// The token is granted the policies of the entity and of its groups
+	testIdentityRequest(t, c, logical.UpdateOperation, "sys/policy/entity", root, map[string]interface{}{
+		"rules": `
+path "secret/entity" { policy = "read" }
+path "auth/token/create" { policy = "write" }`,
+	})
+	testIdentityRequest(t, c, logical.UpdateOperation, "sys/policy/group", root, map[string]interface{}{
+		"rules": `path "secret/group" { policy = "read" }`,
+	})
+	if caps, err := c.Capabilities(auth.ClientToken, "secret/entity"); err != nil || !reflect.DeepEqual(caps, []string{"deny"}) {
+		t.Fatalf("bad: %v %v", caps, err)
+	}
+	testIdentityRequest(t, c, logical.UpdateOperation, "identity/entity/id/"+auth.EntityID, root, map[string]interface{}{
+		"policies": "entity",
+	})
+	resp = testIdentityRequest(t, c, logical.UpdateOperation, "identity/group", root, map[string]interface{}{
+		"member_entity_ids": auth.EntityID,
+	})
+	inner := resp.Data["id"].(string)
+	testIdentityRequest(t, c, logical.UpdateOperation, "identity/group", root, map[string]interface{}{
+		"name":             "outer",
+		"policies":         "group",
+		"member_group_ids": inner,
+	})
+
+	for _, path := range []string{"secret/entity", "secret/group"} {
+		caps, err := c.Capabilities(auth.ClientToken, path)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !reflect.DeepEqual(caps, []string{"list", "read"}) {
+			t.Fatalf("%s: bad: %v", path, caps)
+		}
+	}
+
+	// Child tokens stand for the same entity
+	resp = testIdentityRequest(t, c, logical.UpdateOperation, "auth/token/create", auth.ClientToken, nil)
+	child, err := c.tokenStore.Lookup(resp.Auth.ClientToken)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if child.EntityID != auth.EntityID {
+		t.Fatalf("bad: %#v", child)
+	}
+
+	// The entities and groups survive sealing
+	conf := &CoreConfig{
+		Physical:           c.physical,
+		DisableMlock:       true,
+		CredentialBackends: map[string]logical.Factory{"noop": c.credentialBackends["noop"]},
+	}
+	c2, err := NewCore(conf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal, err := c2.Unseal(key); err != nil || !unseal {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(c.identityStore.EntityPolicies(auth.EntityID), c2.identityStore.EntityPolicies(auth.EntityID)) {
+		t.Fatalf("mismatch: %v %v", c.identityStore.EntityPolicies(auth.EntityID), c2.identityStore.EntityPolicies(auth.EntityID))
+	}
+	resp = testIdentityRequest(t, c2, logical.ReadOperation, "identity/group/name/outer", root, nil)
+	if !reflect.DeepEqual(resp.Data["member_group_ids"], []string{inner}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
+
+func TestIdentityStore_ExternalGroups(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	auth := &logical.Auth{
+		Policies:     []string{"foo"},
+		Alias:        &logical.Alias{Name: "armon"},
+		GroupAliases: []*logical.Alias{&logical.Alias{Name: "admins"}},
+	}
+	accessor := testIdentityLoginBackend(t, c, root, auth)
+
+	resp := testIdentityRequest(t, c, logical.UpdateOperation, "identity/group", root, map[string]interface{}{
+		"name":     "admins",
+		"type":     "external",
+		"policies": "admin",
+	})
+	group := resp.Data["id"].(string)
+	testIdentityRequest(t, c, logical.UpdateOperation, "identity/group-alias", root, map[string]interface{}{
+		"name":           "admins",
+		"mount_accessor": accessor,
+		"canonical_id":   group,
+	})
+
+	// Logging in with the group alias makes the entity a member
+	login := testIdentityLogin(t, c)
+	resp = testIdentityRequest(t, c, logical.ReadOperation, "identity/group/id/"+group, root, nil)
+	if !reflect.DeepEqual(resp.Data["member_entity_ids"], []string{login.EntityID}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if policies := c.identityStore.EntityPolicies(login.EntityID); !reflect.DeepEqual(policies, []string{"admin"}) {
+		t.Fatalf("bad: %v", policies)
+	}
+
+	// Members of external groups cannot be set directly
+	req := logical.TestRequest(t, logical.UpdateOperation, "identity/group/id/"+group)
+	req.ClientToken = root
+	req.Data["member_entity_ids"] = login.EntityID
+	if _, err := c.HandleRequest(req); err == nil {
+		t.Fatalf("should fail")
+	}
+
+	// Logging in without it removes the entity from the group
+	auth.GroupAliases = nil
+	testIdentityLogin(t, c)
+	resp = testIdentityRequest(t, c, logical.ReadOperation, "identity/group/id/"+group, root, nil)
+	if len(resp.Data["member_entity_ids"].([]string)) != 0 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if policies := c.identityStore.EntityPolicies(login.EntityID); len(policies) != 0 {
+		t.Fatalf("bad: %v", policies)
+	}
+}
+
+func TestIdentityStore_Groups(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	resp := testIdentityRequest(t, c, logical.UpdateOperation, "identity/group", root, map[string]interface{}{
+		"name": "a",
+	})
+	a := resp.Data["id"].(string)
+	resp = testIdentityRequest(t, c, logical.UpdateOperation, "identity/group", root, map[string]interface{}{
+		"name":             "b",
+		"member_group_ids": a,
+	})
+	b := resp.Data["id"].(string)
+
+	// Groups cannot be members of themselves
+	for _, member := range []string{a, b} {
+		req := logical.TestRequest(t, logical.UpdateOperation, "identity/group/id/"+a)
+		req.ClientToken = root
+		req.Data["member_group_ids"] = member
+		if _, err := c.HandleRequest(req); err == nil {
+			t.Fatalf("should fail: %s", member)
+		}
+	}
+
+	// Names are unique
+	req := logical.TestRequest(t, logical.UpdateOperation, "identity/group")
+	req.ClientToken = root
+	req.Data["name"] = "a"
+	if _, err := c.HandleRequest(req); err == nil {
+		t.Fatalf("should fail")
+	}
+
+	// Deleting a group removes it from the groups it is a member of
+	testIdentityRequest(t, c, logical.DeleteOperation, "identity/group/id/"+a, root, nil)
+	resp = testIdentityRequest(t, c, logical.ReadOperation, "identity/group/id/"+b, root, nil)
+	if len(resp.Data["member_group_ids"].([]string)) != 0 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	resp = testIdentityRequest(t, c, logical.ListOperation, "identity/group/id/", root, nil)
+	if !reflect.DeepEqual(resp.Data["keys"], []string{b}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
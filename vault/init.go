@@ -227,6 +227,12 @@ func (c *Core) UnsealWithStoredKeys() error {
 		return nil
 	}
 
+	// The stored keys must not be used before they are moved to the new seal
+	if c.SealMigrationPending() {
+		c.logger.Printf("[WARN] core: seal migration pending, not unsealing with stored keys")
+		return nil
+	}
+
 	sealed, err := c.Sealed()
 	if err != nil {
 		c.logger.Printf("[ERR] core: error checking sealed status in auto-unseal: %s", err)
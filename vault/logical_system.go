@@ -1,12 +1,15 @@
 package vault
 
 import (
+	"encoding/hex"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	"github.com/hashicorp/vault/physical"
 	"github.com/mitchellh/mapstructure"
 )
 
@@ -31,10 +34,17 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) logical.Backend
 				"auth/*",
 				"remount",
 				"revoke-prefix/*",
+				"leases/lookup/*",
+				"leases/tidy",
+				"leases/irrevocable",
+				"leases/revoke-force/*",
 				"audit",
 				"audit/*",
 				"raw/*",
 				"rotate",
+				"storage/raft/*",
+				"namespaces/*",
+				"plugins/catalog/*",
 			},
 		},
 
@@ -192,6 +202,10 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) logical.Backend
 						Type:        framework.TypeMap,
 						Description: strings.TrimSpace(sysHelp["mount_config"][0]),
 					},
+					"plugin_name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["mount_plugin_name"][0]),
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -312,6 +326,94 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) logical.Backend
 				HelpDescription: strings.TrimSpace(sysHelp["revoke-prefix"][1]),
 			},
 
+			&framework.Path{
+				Pattern: "leases/lookup$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"lease_id": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["lease_id"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleLeaseLookup,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["leases-lookup"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["leases-lookup"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "leases/lookup/(?P<prefix>.+?)?$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"prefix": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["leases-list-prefix"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.handleLeaseList,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["leases-list"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["leases-list"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "leases/tidy$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.handleLeaseTidyStatus,
+					logical.UpdateOperation: b.handleLeaseTidy,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["leases-tidy"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["leases-tidy"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "leases/irrevocable$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handleLeaseIrrevocable,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["leases-irrevocable"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["leases-irrevocable"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "leases/revoke-force/(?P<prefix>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"prefix": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["revoke-force-path"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleRevokeForce,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["revoke-force"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["revoke-force"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "leases/count$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handleLeaseCount,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["leases-count"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["leases-count"][1]),
+			},
+
 			&framework.Path{
 				Pattern: "auth$",
 
@@ -339,6 +441,14 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) logical.Backend
 						Type:        framework.TypeString,
 						Description: strings.TrimSpace(sysHelp["auth_desc"][0]),
 					},
+					"token_type": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["auth_token_type"][0]),
+					},
+					"plugin_name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["auth_plugin_name"][0]),
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -386,6 +496,167 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) logical.Backend
 				HelpDescription: strings.TrimSpace(sysHelp["policy"][1]),
 			},
 
+			&framework.Path{
+				Pattern: "quotas/rate-limit/?$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.handleRateLimitQuotaList,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["rate-limit-quota-list"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["rate-limit-quota-list"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "quotas/rate-limit/(?P<name>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Name of the quota.",
+					},
+					"path": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Mount, or path within a mount, the quota applies to. Applies to all of Vault if empty.",
+					},
+					"rate": &framework.FieldSchema{
+						Type:        framework.TypeInt,
+						Description: "Number of requests each client may make per interval.",
+					},
+					"interval": &framework.FieldSchema{
+						Type:        framework.TypeDurationSecond,
+						Description: "Interval the rate applies to. Defaults to one second.",
+					},
+					"burst": &framework.FieldSchema{
+						Type:        framework.TypeInt,
+						Description: "Number of requests each client may make at once. Defaults to the rate.",
+					},
+					"key_by": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Default:     "ip",
+						Description: "Whether clients are told apart by their 'ip' address or their 'token'.",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.handleRateLimitQuotaRead,
+					logical.UpdateOperation: b.handleRateLimitQuotaSet,
+					logical.DeleteOperation: b.handleRateLimitQuotaDelete,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["rate-limit-quota"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["rate-limit-quota"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "quotas/lease-count/?$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.handleLeaseCountQuotaList,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["lease-count-quota-list"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["lease-count-quota-list"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "quotas/lease-count/(?P<name>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Name of the quota.",
+					},
+					"path": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Mount, or path within a mount, the quota applies to. Applies to all of Vault if empty.",
+					},
+					"max_leases": &framework.FieldSchema{
+						Type:        framework.TypeInt,
+						Description: "Maximum number of leases held under the path.",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.handleLeaseCountQuotaRead,
+					logical.UpdateOperation: b.handleLeaseCountQuotaSet,
+					logical.DeleteOperation: b.handleLeaseCountQuotaDelete,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["lease-count-quota"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["lease-count-quota"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "namespaces/?$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.handleNamespaceList,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["namespace-list"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["namespace-list"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "namespaces/(?P<name>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["namespace-name"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.handleNamespaceRead,
+					logical.UpdateOperation: b.handleNamespaceCreate,
+					logical.DeleteOperation: b.handleNamespaceDelete,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["namespace"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["namespace"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "plugins/catalog/?$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.handlePluginCatalogList,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["plugin-catalog-list"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["plugin-catalog-list"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "plugins/catalog/(?P<name>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Name of the plugin.",
+					},
+					"command": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Command running the plugin, relative to the plugin directory, along with its arguments.",
+					},
+					"sha_256": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Hex-encoded SHA-256 sum of the plugin binary.",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.handlePluginCatalogRead,
+					logical.UpdateOperation: b.handlePluginCatalogSet,
+					logical.DeleteOperation: b.handlePluginCatalogDelete,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["plugin-catalog"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["plugin-catalog"][1]),
+			},
+
 			&framework.Path{
 				Pattern:         "seal-status$",
 				HelpSynopsis:    strings.TrimSpace(sysHelp["seal-status"][0]),
@@ -508,82 +779,264 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) logical.Backend
 				HelpSynopsis:    strings.TrimSpace(sysHelp["rotate"][0]),
 				HelpDescription: strings.TrimSpace(sysHelp["rotate"][1]),
 			},
-		},
-	}
-
-	b.Backend.Setup(config)
 
-	return b.Backend
-}
+			&framework.Path{
+				Pattern: "wrapping/lookup$",
 
-// SystemBackend implements logical.Backend and is used to interact with
-// the core of the system. This backend is hardcoded to exist at the "sys"
-// prefix. Conceptually it is similar to procfs on Linux.
-type SystemBackend struct {
-	Core    *Core
-	Backend *framework.Backend
-}
+				Fields: map[string]*framework.FieldSchema{
+					"token": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Response-wrapping token to look up.",
+					},
+				},
 
-// handleCapabilitiesreturns the ACL capabilities of the token for a given path
-func (b *SystemBackend) handleCapabilities(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	capabilities, err := b.Core.Capabilities(d.Get("token").(string), d.Get("path").(string))
-	if err != nil {
-		return nil, err
-	}
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleWrappingLookup,
+				},
 
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"capabilities": capabilities,
-		},
-	}, nil
-}
+				HelpSynopsis:    strings.TrimSpace(sysHelp["wrapping_lookup"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["wrapping_lookup"][1]),
+			},
 
-// handleCapabilitiesAccessor returns the ACL capabilities of the token associted
-// with the given accessor for a given path.
-func (b *SystemBackend) handleCapabilitiesAccessor(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	accessor := d.Get("accessor").(string)
-	if accessor == "" {
-		return logical.ErrorResponse("missing accessor"), nil
-	}
+			&framework.Path{
+				Pattern: "wrapping/unwrap$",
 
-	token, err := b.Core.tokenStore.lookupByAccessor(accessor)
-	if err != nil {
-		return nil, err
-	}
+				Fields: map[string]*framework.FieldSchema{
+					"token": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Response-wrapping token to unwrap.",
+					},
+				},
 
-	capabilities, err := b.Core.Capabilities(token, d.Get("path").(string))
-	if err != nil {
-		return nil, err
-	}
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleWrappingUnwrap,
+				},
 
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"capabilities": capabilities,
-		},
-	}, nil
-}
+				HelpSynopsis:    strings.TrimSpace(sysHelp["wrapping_unwrap"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["wrapping_unwrap"][1]),
+			},
 
-// handleRekeyRetrieve returns backed-up, PGP-encrypted unseal keys from a
-// rekey operation
-func (b *SystemBackend) handleRekeyRetrieve(
-	req *logical.Request,
-	data *framework.FieldData,
-	recovery bool) (*logical.Response, error) {
-	backup, err := b.Core.RekeyRetrieveBackup(recovery)
-	if err != nil {
-		return nil, fmt.Errorf("unable to look up backed-up keys: %v", err)
-	}
-	if backup == nil {
-		return logical.ErrorResponse("no backed-up keys found"), nil
-	}
+			&framework.Path{
+				Pattern: "wrapping/rewrap$",
 
-	// Format the status
-	resp := &logical.Response{
-		Data: map[string]interface{}{
-			"nonce": backup.Nonce,
-			"keys":  backup.Keys,
-		},
-	}
+				Fields: map[string]*framework.FieldSchema{
+					"token": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Response-wrapping token to rewrap.",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleWrappingRewrap,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["wrapping_rewrap"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["wrapping_rewrap"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "control-group/authorize$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"accessor": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Accessor of the response-wrapping token of the request to approve.",
+					},
+					"token": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Token of the approver. Set to the client token over HTTP.",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleControlGroupAuthorize,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["control_group_authorize"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["control_group_authorize"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "control-group/request$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"accessor": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Accessor of the response-wrapping token of the request to look up.",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleControlGroupRequest,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["control_group_request"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["control_group_request"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "storage/raft/configuration$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handleRaftConfiguration,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["raft_configuration"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["raft_configuration"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "storage/raft/join$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"address": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Raft address of the node to add to the cluster.",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleRaftJoin,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["raft_join"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["raft_join"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "storage/raft/remove-peer$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"address": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Raft address of the node to remove from the cluster.",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleRaftRemovePeer,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["raft_remove_peer"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["raft_remove_peer"][1]),
+			},
+		},
+	}
+
+	b.Backend.Setup(config)
+
+	return b.Backend
+}
+
+// SystemBackend implements logical.Backend and is used to interact with
+// the core of the system. This backend is hardcoded to exist at the "sys"
+// prefix. Conceptually it is similar to procfs on Linux.
+type SystemBackend struct {
+	Core    *Core
+	Backend *framework.Backend
+}
+
+// namespace returns the namespace the system backend was reached through
+// for the given request. Paths given to the backend are relative to it.
+func (b *SystemBackend) namespace(req *logical.Request) *Namespace {
+	return b.Core.namespaceByPath(req.MountPoint)
+}
+
+// namespaceEntryPath returns the path of the mount or auth table entry
+// relative to the given namespace, and whether the entry belongs to it. The
+// builtin backends are served within every namespace.
+func (b *SystemBackend) namespaceEntryPath(ns *Namespace, entry *MountEntry) (string, bool) {
+	switch entry.Type {
+	case "system", "cubbyhole", "token":
+		return entry.Path, true
+	}
+	if b.Core.namespaceByPath(entry.Path).ID != ns.ID {
+		return "", false
+	}
+	return strings.TrimPrefix(entry.Path, ns.Path), true
+}
+
+// namespacePolicyStore returns the policy store of the namespace of the
+// request
+func (b *SystemBackend) namespacePolicyStore(req *logical.Request) (*PolicyStore, error) {
+	ps := b.Core.namespacePolicyStore(b.namespace(req))
+	if ps == nil {
+		return nil, fmt.Errorf("no policy store for namespace")
+	}
+	return ps, nil
+}
+
+// handleCapabilitiesreturns the ACL capabilities of the token for a given path
+func (b *SystemBackend) handleCapabilities(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	path := b.namespace(req).Path + d.Get("path").(string)
+	capabilities, err := b.Core.Capabilities(d.Get("token").(string), path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"capabilities": capabilities,
+		},
+	}, nil
+}
+
+// handleCapabilitiesAccessor returns the ACL capabilities of the token associted
+// with the given accessor for a given path.
+func (b *SystemBackend) handleCapabilitiesAccessor(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	accessor := d.Get("accessor").(string)
+	if accessor == "" {
+		return logical.ErrorResponse("missing accessor"), nil
+	}
+
+	token, err := b.Core.tokenStore.lookupByAccessor(accessor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens issued outside of the namespace are not visible within it
+	ns := b.namespace(req)
+	te, err := b.Core.tokenStore.Lookup(token)
+	if err != nil {
+		return nil, err
+	}
+	if te == nil || !b.Core.tokenInNamespace(te, ns) {
+		return nil, &StatusBadRequest{Err: "invalid accessor"}
+	}
+
+	capabilities, err := b.Core.Capabilities(token, ns.Path+d.Get("path").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"capabilities": capabilities,
+		},
+	}, nil
+}
+
+// handleRekeyRetrieve returns backed-up, PGP-encrypted unseal keys from a
+// rekey operation
+func (b *SystemBackend) handleRekeyRetrieve(
+	req *logical.Request,
+	data *framework.FieldData,
+	recovery bool) (*logical.Response, error) {
+	backup, err := b.Core.RekeyRetrieveBackup(recovery)
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up backed-up keys: %v", err)
+	}
+	if backup == nil {
+		return logical.ErrorResponse("no backed-up keys found"), nil
+	}
+
+	// Format the status
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"nonce": backup.Nonce,
+			"keys":  backup.Keys,
+		},
+	}
 
 	return resp, nil
 }
@@ -624,6 +1077,8 @@ func (b *SystemBackend) handleRekeyDeleteRecovery(
 // handleMountTable handles the "mounts" endpoint to provide the mount table
 func (b *SystemBackend) handleMountTable(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ns := b.namespace(req)
+
 	b.Core.mountsLock.RLock()
 	defer b.Core.mountsLock.RUnlock()
 
@@ -632,6 +1087,10 @@ func (b *SystemBackend) handleMountTable(
 	}
 
 	for _, entry := range b.Core.mounts.Entries {
+		path, ok := b.namespaceEntryPath(ns, entry)
+		if !ok {
+			continue
+		}
 		info := map[string]interface{}{
 			"type":        entry.Type,
 			"description": entry.Description,
@@ -640,8 +1099,11 @@ func (b *SystemBackend) handleMountTable(
 				"max_lease_ttl":     int(entry.Config.MaxLeaseTTL.Seconds()),
 			},
 		}
+		if entry.Config.PluginName != "" {
+			info["plugin_name"] = entry.Config.PluginName
+		}
 
-		resp.Data[entry.Path] = info
+		resp.Data[path] = info
 	}
 
 	return resp, nil
@@ -654,8 +1116,9 @@ func (b *SystemBackend) handleMount(
 	path := data.Get("path").(string)
 	logicalType := data.Get("type").(string)
 	description := data.Get("description").(string)
+	pluginName := data.Get("plugin_name").(string)
 
-	path = sanitizeMountPath(path)
+	path = b.namespace(req).Path + sanitizeMountPath(path)
 
 	var config MountConfig
 
@@ -716,6 +1179,10 @@ func (b *SystemBackend) handleMount(
 				"backend type must be specified as a string"),
 			logical.ErrInvalidRequest
 	}
+	if err := validatePluginName(logicalType, pluginName); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	config.PluginName = pluginName
 
 	// Create the mount entry
 	me := &MountEntry{
@@ -734,6 +1201,355 @@ func (b *SystemBackend) handleMount(
 	return nil, nil
 }
 
+// handleWrappingLookup returns the creation information of a
+// response-wrapping token without consuming it
+func (b *SystemBackend) handleWrappingLookup(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	te, err := b.Core.lookupWrappingToken(data.Get("token").(string))
+	if err != nil {
+		return handleError(err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"creation_ttl":  int64(te.TTL.Seconds()),
+			"creation_time": time.Unix(te.CreationTime, 0).UTC().Format(time.RFC3339),
+		},
+	}, nil
+}
+
+// handleWrappingUnwrap returns the response wrapped by the given token and
+// revokes the token
+func (b *SystemBackend) handleWrappingUnwrap(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	te, err := b.Core.lookupWrappingToken(data.Get("token").(string))
+	if err != nil {
+		return handleError(err)
+	}
+
+	response, err := b.Core.readWrappedResponse(te)
+	if err != nil {
+		return handleError(err)
+	}
+
+	// The response may only be unwrapped once
+	if err := b.Core.tokenStore.Revoke(te.ID); err != nil {
+		return nil, err
+	}
+
+	// The wrapped response is already in its final HTTP form, so it is
+	// passed through as-is
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/json",
+			logical.HTTPRawBody:     []byte(response),
+			logical.HTTPStatusCode:  200,
+		},
+	}, nil
+}
+
+// handleWrappingRewrap moves the response wrapped by the given token into
+// a new response-wrapping token and revokes the old one
+func (b *SystemBackend) handleWrappingRewrap(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	te, err := b.Core.lookupWrappingToken(data.Get("token").(string))
+	if err != nil {
+		return handleError(err)
+	}
+
+	response, err := b.Core.readWrappedResponse(te)
+	if err != nil {
+		return handleError(err)
+	}
+
+	wrapInfo, err := b.Core.createWrappingToken(te.TTL, response)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.Core.tokenStore.Revoke(te.ID); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		WrapInfo: wrapInfo,
+	}, nil
+}
+
+// handleRateLimitQuotaList lists the rate limit quotas
+func (b *SystemBackend) handleRateLimitQuotaList(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.Core.quotasLock.RLock()
+	defer b.Core.quotasLock.RUnlock()
+
+	names := make([]string, 0, len(b.Core.rateLimitQuotas))
+	for name := range b.Core.rateLimitQuotas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return logical.ListResponse(names), nil
+}
+
+// handleRateLimitQuotaRead returns the settings of a rate limit quota
+func (b *SystemBackend) handleRateLimitQuotaRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	quota := b.Core.rateLimitQuota(data.Get("name").(string))
+	if quota == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"name":     quota.Name,
+			"path":     quota.Path,
+			"rate":     quota.Rate,
+			"interval": int64(quota.Interval.Seconds()),
+			"burst":    quota.Burst,
+			"key_by":   quota.KeyBy,
+		},
+	}, nil
+}
+
+// handleRateLimitQuotaSet creates or updates a rate limit quota. Settings
+// that are not given keep their current values.
+func (b *SystemBackend) handleRateLimitQuotaSet(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	quota := &RateLimitQuota{Name: name}
+	if existing := b.Core.rateLimitQuota(name); existing != nil {
+		quota.Path = existing.Path
+		quota.Rate = existing.Rate
+		quota.Interval = existing.Interval
+		quota.Burst = existing.Burst
+		quota.KeyBy = existing.KeyBy
+	}
+	if raw, ok := data.GetOk("path"); ok {
+		quota.Path = raw.(string)
+	}
+	if raw, ok := data.GetOk("rate"); ok {
+		quota.Rate = raw.(int)
+	}
+	if raw, ok := data.GetOk("interval"); ok {
+		quota.Interval = time.Duration(raw.(int)) * time.Second
+	}
+	if raw, ok := data.GetOk("burst"); ok {
+		quota.Burst = raw.(int)
+	}
+	if raw, ok := data.GetOk("key_by"); ok {
+		quota.KeyBy = raw.(string)
+	}
+
+	if err := b.Core.setRateLimitQuota(quota); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	return nil, nil
+}
+
+// handleRateLimitQuotaDelete removes a rate limit quota
+func (b *SystemBackend) handleRateLimitQuotaDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := b.Core.deleteRateLimitQuota(data.Get("name").(string)); err != nil {
+		return handleError(err)
+	}
+	return nil, nil
+}
+
+// handleLeaseCountQuotaList lists the lease count quotas
+func (b *SystemBackend) handleLeaseCountQuotaList(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.Core.quotasLock.RLock()
+	defer b.Core.quotasLock.RUnlock()
+
+	names := make([]string, 0, len(b.Core.leaseCountQuotas))
+	for name := range b.Core.leaseCountQuotas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return logical.ListResponse(names), nil
+}
+
+// handleLeaseCountQuotaRead returns the settings of a lease count quota
+// along with the number of leases it holds
+func (b *SystemBackend) handleLeaseCountQuotaRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	quota, count := b.Core.leaseCountQuota(data.Get("name").(string))
+	if quota == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"name":       quota.Name,
+			"path":       quota.Path,
+			"max_leases": quota.MaxLeases,
+			"count":      count,
+		},
+	}, nil
+}
+
+// handleLeaseCountQuotaSet creates or updates a lease count quota.
+// Settings that are not given keep their current values.
+func (b *SystemBackend) handleLeaseCountQuotaSet(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	quota := &LeaseCountQuota{Name: name}
+	if existing, _ := b.Core.leaseCountQuota(name); existing != nil {
+		quota.Path = existing.Path
+		quota.MaxLeases = existing.MaxLeases
+	}
+	if raw, ok := data.GetOk("path"); ok {
+		quota.Path = raw.(string)
+	}
+	if raw, ok := data.GetOk("max_leases"); ok {
+		quota.MaxLeases = raw.(int)
+	}
+
+	if err := b.Core.setLeaseCountQuota(quota); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	return nil, nil
+}
+
+// handleLeaseCountQuotaDelete removes a lease count quota
+func (b *SystemBackend) handleLeaseCountQuotaDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := b.Core.deleteLeaseCountQuota(data.Get("name").(string)); err != nil {
+		return handleError(err)
+	}
+	return nil, nil
+}
+
+// handlePluginCatalogList lists the plugins registered in the catalog
+func (b *SystemBackend) handlePluginCatalogList(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	names, err := b.Core.pluginCatalog.List()
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(names), nil
+}
+
+// handlePluginCatalogRead returns a plugin registered in the catalog
+func (b *SystemBackend) handlePluginCatalogRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	entry, err := b.Core.pluginCatalog.Get(data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	command := append([]string{entry.Command}, entry.Args...)
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"name":    entry.Name,
+			"command": strings.Join(command, " "),
+			"sha_256": hex.EncodeToString(entry.Sha256),
+		},
+	}, nil
+}
+
+// handlePluginCatalogSet registers a plugin in the catalog
+func (b *SystemBackend) handlePluginCatalogSet(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	command := strings.Fields(data.Get("command").(string))
+	if len(command) == 0 {
+		return logical.ErrorResponse("missing command"), logical.ErrInvalidRequest
+	}
+	sha256, err := hex.DecodeString(data.Get("sha_256").(string))
+	if err != nil || len(sha256) != 32 {
+		return logical.ErrorResponse("sha_256 must be a hex-encoded SHA-256 sum"), logical.ErrInvalidRequest
+	}
+
+	entry := &pluginCatalogEntry{
+		Name:    data.Get("name").(string),
+		Command: command[0],
+		Args:    command[1:],
+		Sha256:  sha256,
+	}
+	if err := b.Core.pluginCatalog.Set(entry); err != nil {
+		return handleError(err)
+	}
+	return nil, nil
+}
+
+// handlePluginCatalogDelete removes a plugin from the catalog
+func (b *SystemBackend) handlePluginCatalogDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := b.Core.pluginCatalog.Delete(data.Get("name").(string)); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// controlGroupResponseData returns the status of a request awaiting the
+// approval of a control group
+func controlGroupResponseData(cgReq *controlGroupRequest) map[string]interface{} {
+	return map[string]interface{}{
+		"request_path":       cgReq.Path,
+		"request_entity_id":  cgReq.RequesterEntityID,
+		"request_accessor":   cgReq.RequesterAccessor,
+		"creation_time":      cgReq.CreationTime.Format(time.RFC3339),
+		"approvals_required": cgReq.Approvals,
+		"approvals":          len(cgReq.Authorizations),
+		"authorizations":     cgReq.Authorizations,
+		"approver_policies":  cgReq.ApproverPolicies,
+		"approver_groups":    cgReq.ApproverGroups,
+		"approved":           cgReq.Approved,
+	}
+}
+
+// handleControlGroupAuthorize adds the approval of the client token to a
+// request awaiting the approval of a control group
+func (b *SystemBackend) handleControlGroupAuthorize(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	accessor := data.Get("accessor").(string)
+	if accessor == "" {
+		return logical.ErrorResponse("missing accessor"), logical.ErrInvalidRequest
+	}
+	token := data.Get("token").(string)
+	if token == "" {
+		return logical.ErrorResponse("missing token"), logical.ErrInvalidRequest
+	}
+
+	cgReq, err := b.Core.authorizeControlGroupRequest(accessor, token)
+	if err == logical.ErrPermissionDenied || err == ErrInternalError {
+		return nil, err
+	}
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	return &logical.Response{
+		Data: controlGroupResponseData(cgReq),
+	}, nil
+}
+
+// handleControlGroupRequest returns the status of a request awaiting the
+// approval of a control group
+func (b *SystemBackend) handleControlGroupRequest(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	accessor := data.Get("accessor").(string)
+	if accessor == "" {
+		return logical.ErrorResponse("missing accessor"), logical.ErrInvalidRequest
+	}
+
+	cgReq, err := b.Core.controlGroupRequest(accessor)
+	if err != nil {
+		return nil, err
+	}
+	if cgReq == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: controlGroupResponseData(cgReq),
+	}, nil
+}
+
 // used to intercept an HTTPCodedError so it goes back to callee
 func handleError(
 	err error) (*logical.Response, error) {
@@ -753,7 +1569,7 @@ func (b *SystemBackend) handleUnmount(
 		return logical.ErrorResponse("path cannot be blank"), logical.ErrInvalidRequest
 	}
 
-	suffix = sanitizeMountPath(suffix)
+	suffix = b.namespace(req).Path + sanitizeMountPath(suffix)
 
 	// Attempt unmount
 	if err := b.Core.unmount(suffix); err != nil {
@@ -776,8 +1592,9 @@ func (b *SystemBackend) handleRemount(
 			logical.ErrInvalidRequest
 	}
 
-	fromPath = sanitizeMountPath(fromPath)
-	toPath = sanitizeMountPath(toPath)
+	ns := b.namespace(req)
+	fromPath = ns.Path + sanitizeMountPath(fromPath)
+	toPath = ns.Path + sanitizeMountPath(toPath)
 
 	// Attempt remount
 	if err := b.Core.remount(fromPath, toPath); err != nil {
@@ -788,6 +1605,21 @@ func (b *SystemBackend) handleRemount(
 	return nil, nil
 }
 
+// checkNamespaceTune prevents the builtin backends from being tuned within
+// a namespace other than the root, as their mount entries are those of the
+// root namespace
+func (b *SystemBackend) checkNamespaceTune(req *logical.Request, path string) error {
+	if b.namespace(req).ID == rootNamespaceID {
+		return nil
+	}
+	for _, p := range namespaceBuiltinMounts {
+		if strings.HasPrefix(path, p) {
+			return fmt.Errorf("[ERR] sys: cannot tune '%s' within a namespace", path)
+		}
+	}
+	return nil
+}
+
 // handleMountTuneRead is used to get config settings on a backend
 func (b *SystemBackend) handleMountTuneRead(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
@@ -799,6 +1631,11 @@ func (b *SystemBackend) handleMountTuneRead(
 	}
 
 	path = sanitizeMountPath(path)
+	if err := b.checkNamespaceTune(req, path); err != nil {
+		b.Backend.Logger().Print(err)
+		return handleError(err)
+	}
+	path = b.namespace(req).Path + path
 
 	sysView := b.Core.router.MatchingSystemView(path)
 	if sysView == nil {
@@ -837,6 +1674,11 @@ func (b *SystemBackend) handleMountTuneWrite(
 			return handleError(err)
 		}
 	}
+	if err := b.checkNamespaceTune(req, path); err != nil {
+		b.Backend.Logger().Print(err)
+		return handleError(err)
+	}
+	path = b.namespace(req).Path + path
 
 	mountEntry := b.Core.router.MatchingMountEntry(path)
 	if mountEntry == nil {
@@ -899,6 +1741,11 @@ func (b *SystemBackend) handleRenew(
 	// Convert the increment
 	increment := time.Duration(incrementRaw) * time.Second
 
+	// Leases of other namespaces are out of reach
+	if !b.namespace(req).HasPath(leaseID) {
+		return logical.ErrorResponse("lease not found or lease is not renewable"), logical.ErrInvalidRequest
+	}
+
 	// Invoke the expiration manager directly
 	resp, err := b.Core.expiration.Renew(leaseID, increment)
 	if err != nil {
@@ -914,24 +1761,160 @@ func (b *SystemBackend) handleRevoke(
 	// Get all the options
 	leaseID := data.Get("lease_id").(string)
 
+	// Leases of other namespaces are out of reach
+	if !b.namespace(req).HasPath(leaseID) {
+		return nil, nil
+	}
+
 	// Invoke the expiration manager directly
 	if err := b.Core.expiration.Revoke(leaseID); err != nil {
 		b.Backend.Logger().Printf("[ERR] sys: revoke '%s' failed: %v", leaseID, err)
 		return handleError(err)
 	}
-	return nil, nil
+	return nil, nil
+}
+
+// handleRevokePrefix is used to revoke a prefix with many LeaseIDs
+func (b *SystemBackend) handleRevokePrefix(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.handleRevokePrefixCommon(req, data, false)
+}
+
+// handleRevokeForce is used to revoke a prefix with many LeaseIDs, ignoring errors
+func (b *SystemBackend) handleRevokeForce(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.handleRevokePrefixCommon(req, data, true)
+}
+
+// handleLeaseLookup returns the times and state of a given LeaseID
+func (b *SystemBackend) handleLeaseLookup(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	leaseID := data.Get("lease_id").(string)
+	if leaseID == "" {
+		return logical.ErrorResponse("missing lease_id"), logical.ErrInvalidRequest
+	}
+
+	// Leases of other namespaces are out of reach
+	if !b.namespace(req).HasPath(leaseID) {
+		return logical.ErrorResponse("invalid lease"), logical.ErrInvalidRequest
+	}
+
+	le, err := b.Core.expiration.loadEntry(leaseID)
+	if err != nil {
+		return handleError(err)
+	}
+	if le == nil {
+		return logical.ErrorResponse("invalid lease"), logical.ErrInvalidRequest
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"id":           le.LeaseID,
+			"issue_time":   le.IssueTime,
+			"expire_time":  nil,
+			"last_renewal": nil,
+			"renewable":    le.renewable() == nil,
+			"ttl":          int64(0),
+		},
+	}
+	if !le.ExpireTime.IsZero() {
+		resp.Data["expire_time"] = le.ExpireTime
+		if ttl := le.ExpireTime.Sub(time.Now()); ttl > 0 {
+			resp.Data["ttl"] = int64(ttl.Seconds())
+		}
+	}
+	if !le.LastRenewalTime.IsZero() {
+		resp.Data["last_renewal"] = le.LastRenewalTime
+	}
+	return resp, nil
+}
+
+// handleLeaseList lists the leases and prefixes directly under a prefix
+func (b *SystemBackend) handleLeaseList(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	prefix := data.Get("prefix").(string)
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	// Leases of other namespaces are out of reach
+	if !b.namespace(req).HasPath(prefix) {
+		return logical.ErrorResponse("prefix must be within the namespace"), logical.ErrInvalidRequest
+	}
+
+	keys, err := b.Core.expiration.ListLeases(prefix)
+	if err != nil {
+		b.Backend.Logger().Printf("[ERR] sys: listing leases under '%s' failed: %v", prefix, err)
+		return handleError(err)
+	}
+	return logical.ListResponse(keys), nil
+}
+
+// handleLeaseCount counts the leases of the namespace by mount
+func (b *SystemBackend) handleLeaseCount(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	counts, err := b.Core.expiration.CountLeases(b.namespace(req).Path)
+	if err != nil {
+		b.Backend.Logger().Printf("[ERR] sys: counting leases failed: %v", err)
+		return handleError(err)
+	}
+
+	total := 0
+	mounts := make(map[string]interface{}, len(counts))
+	for mount, count := range counts {
+		mounts[mount] = count
+		total += count
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"total":  total,
+			"mounts": mounts,
+		},
+	}, nil
+}
+
+// handleLeaseIrrevocable lists the leases of the namespace that have been
+// marked irrevocable, along with the error of their last revoke attempt
+func (b *SystemBackend) handleLeaseIrrevocable(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	leases, err := b.Core.expiration.IrrevocableLeases(b.namespace(req).Path)
+	if err != nil {
+		b.Backend.Logger().Printf("[ERR] sys: listing irrevocable leases failed: %v", err)
+		return handleError(err)
+	}
+
+	result := make([]map[string]interface{}, 0, len(leases))
+	for _, le := range leases {
+		result = append(result, map[string]interface{}{
+			"lease_id":        le.LeaseID,
+			"expire_time":     le.ExpireTime,
+			"revoke_attempts": le.RevokeAttempts,
+			"error":           le.RevokeErr,
+		})
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"leases": result,
+		},
+	}, nil
 }
 
-// handleRevokePrefix is used to revoke a prefix with many LeaseIDs
-func (b *SystemBackend) handleRevokePrefix(
+// handleLeaseTidy starts tidying the leases in the background
+func (b *SystemBackend) handleLeaseTidy(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	return b.handleRevokePrefixCommon(req, data, false)
+	if b.namespace(req).Path != "" {
+		return logical.ErrorResponse("tidy is only available in the root namespace"), logical.ErrInvalidRequest
+	}
+	return b.Core.runTidy(req, b.Core.expiration.tidyStatus, b.Core.expiration.Tidy)
 }
 
-// handleRevokeForce is used to revoke a prefix with many LeaseIDs, ignoring errors
-func (b *SystemBackend) handleRevokeForce(
+// handleLeaseTidyStatus returns the progress of the last lease tidy
+// operation
+func (b *SystemBackend) handleLeaseTidyStatus(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	return b.handleRevokePrefixCommon(req, data, true)
+	return &logical.Response{
+		Data: b.Core.expiration.tidyStatus.data(),
+	}, nil
 }
 
 // handleRevokePrefixCommon is used to revoke a prefix with many LeaseIDs
@@ -940,6 +1923,11 @@ func (b *SystemBackend) handleRevokePrefixCommon(
 	// Get all the options
 	prefix := data.Get("prefix").(string)
 
+	// Leases of other namespaces are out of reach
+	if !b.namespace(req).HasPath(prefix) {
+		return logical.ErrorResponse("prefix must be within the namespace"), logical.ErrInvalidRequest
+	}
+
 	// Invoke the expiration manager directly
 	var err error
 	if force {
@@ -957,6 +1945,8 @@ func (b *SystemBackend) handleRevokePrefixCommon(
 // handleAuthTable handles the "auth" endpoint to provide the auth table
 func (b *SystemBackend) handleAuthTable(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ns := b.namespace(req)
+
 	b.Core.authLock.RLock()
 	defer b.Core.authLock.RUnlock()
 
@@ -964,11 +1954,22 @@ func (b *SystemBackend) handleAuthTable(
 		Data: make(map[string]interface{}),
 	}
 	for _, entry := range b.Core.auth.Entries {
+		path, ok := b.namespaceEntryPath(ns, entry)
+		if !ok {
+			continue
+		}
 		info := map[string]string{
 			"type":        entry.Type,
 			"description": entry.Description,
+			"accessor":    entry.Accessor,
 		}
-		resp.Data[entry.Path] = info
+		if entry.Config.TokenType != "" {
+			info["token_type"] = entry.Config.TokenType
+		}
+		if entry.Config.PluginName != "" {
+			info["plugin_name"] = entry.Config.PluginName
+		}
+		resp.Data[path] = info
 	}
 	return resp, nil
 }
@@ -980,20 +1981,34 @@ func (b *SystemBackend) handleEnableAuth(
 	path := data.Get("path").(string)
 	logicalType := data.Get("type").(string)
 	description := data.Get("description").(string)
+	tokenType := data.Get("token_type").(string)
+	pluginName := data.Get("plugin_name").(string)
 
 	if logicalType == "" {
 		return logical.ErrorResponse(
 				"backend type must be specified as a string"),
 			logical.ErrInvalidRequest
 	}
+	if !validTokenType(tokenType) {
+		return logical.ErrorResponse(fmt.Sprintf(
+				"invalid token type %q", tokenType)),
+			logical.ErrInvalidRequest
+	}
+	if err := validatePluginName(logicalType, pluginName); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
 
-	path = sanitizeMountPath(path)
+	path = b.namespace(req).Path + sanitizeMountPath(path)
 
 	// Create the mount entry
 	me := &MountEntry{
 		Path:        path,
 		Type:        logicalType,
 		Description: description,
+		Config: MountConfig{
+			TokenType:  tokenType,
+			PluginName: pluginName,
+		},
 	}
 
 	// Attempt enabling
@@ -1012,7 +2027,7 @@ func (b *SystemBackend) handleDisableAuth(
 		return logical.ErrorResponse("path cannot be blank"), logical.ErrInvalidRequest
 	}
 
-	suffix = sanitizeMountPath(suffix)
+	suffix = b.namespace(req).Path + sanitizeMountPath(suffix)
 
 	// Attempt disable
 	if err := b.Core.disableCredential(suffix); err != nil {
@@ -1025,8 +2040,13 @@ func (b *SystemBackend) handleDisableAuth(
 // handlePolicyList handles the "policy" endpoint to provide the enabled policies
 func (b *SystemBackend) handlePolicyList(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ps, err := b.namespacePolicyStore(req)
+	if err != nil {
+		return handleError(err)
+	}
+
 	// Get all the configured policies
-	policies, err := b.Core.policyStore.ListPolicies()
+	policies, err := ps.ListPolicies()
 
 	// Add the special "root" policy
 	policies = append(policies, "root")
@@ -1043,7 +2063,12 @@ func (b *SystemBackend) handlePolicyRead(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	name := data.Get("name").(string)
 
-	policy, err := b.Core.policyStore.GetPolicy(name)
+	ps, err := b.namespacePolicyStore(req)
+	if err != nil {
+		return handleError(err)
+	}
+
+	policy, err := ps.GetPolicy(name)
 	if err != nil {
 		return handleError(err)
 	}
@@ -1075,8 +2100,13 @@ func (b *SystemBackend) handlePolicySet(
 	// Override the name
 	parse.Name = strings.ToLower(name)
 
+	ps, err := b.namespacePolicyStore(req)
+	if err != nil {
+		return handleError(err)
+	}
+
 	// Update the policy
-	if err := b.Core.policyStore.SetPolicy(parse); err != nil {
+	if err := ps.SetPolicy(parse); err != nil {
 		return handleError(err)
 	}
 	return nil, nil
@@ -1086,7 +2116,95 @@ func (b *SystemBackend) handlePolicySet(
 func (b *SystemBackend) handlePolicyDelete(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	name := data.Get("name").(string)
-	if err := b.Core.policyStore.DeletePolicy(name); err != nil {
+
+	ps, err := b.namespacePolicyStore(req)
+	if err != nil {
+		return handleError(err)
+	}
+
+	if err := ps.DeletePolicy(name); err != nil {
+		return handleError(err)
+	}
+	return nil, nil
+}
+
+// handleNamespaceList handles the "namespaces" endpoint to list the
+// namespaces within the namespace of the request
+func (b *SystemBackend) handleNamespaceList(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ns := b.namespace(req)
+
+	var keys []string
+	for _, child := range b.Core.childNamespaces(ns) {
+		keys = append(keys, strings.TrimPrefix(child.Path, ns.Path))
+	}
+	sort.Strings(keys)
+	return logical.ListResponse(keys), nil
+}
+
+// childNamespace returns the namespace with the given name directly within
+// the namespace of the request, or nil if there is none
+func (b *SystemBackend) childNamespace(req *logical.Request, name string) *Namespace {
+	ns := b.namespace(req)
+	path := ns.Path + strings.TrimSuffix(name, "/") + "/"
+	for _, child := range b.Core.childNamespaces(ns) {
+		if child.Path == path {
+			return child
+		}
+	}
+	return nil
+}
+
+// handleNamespaceRead handles the "namespaces/<name>" endpoint to read a
+// namespace
+func (b *SystemBackend) handleNamespaceRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	child := b.childNamespace(req, data.Get("name").(string))
+	if child == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"id":   child.ID,
+			"path": child.Path,
+		},
+	}, nil
+}
+
+// handleNamespaceCreate handles the "namespaces/<name>" endpoint to create
+// a namespace within the namespace of the request
+func (b *SystemBackend) handleNamespaceCreate(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	ns, err := b.Core.createNamespace(b.namespace(req), name)
+	if err != nil {
+		b.Backend.Logger().Printf("[ERR] sys: create namespace '%s' failed: %v", name, err)
+		return handleError(err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"id":   ns.ID,
+			"path": ns.Path,
+		},
+	}, nil
+}
+
+// handleNamespaceDelete handles the "namespaces/<name>" endpoint to delete
+// a namespace, along with everything within it
+func (b *SystemBackend) handleNamespaceDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	child := b.childNamespace(req, name)
+	if child == nil {
+		return nil, nil
+	}
+
+	if err := b.Core.deleteNamespace(child); err != nil {
+		b.Backend.Logger().Printf("[ERR] sys: delete namespace '%s' failed: %v", name, err)
 		return handleError(err)
 	}
 	return nil, nil
@@ -1302,6 +2420,79 @@ func (b *SystemBackend) handleRotate(
 	return nil, nil
 }
 
+// raftPeerManager returns the peer manager of the HA backend, which is only
+// available when using raft storage
+func (b *SystemBackend) raftPeerManager() (physical.PeerManager, error) {
+	pm, ok := b.Core.ha.(physical.PeerManager)
+	if !ok {
+		return nil, fmt.Errorf("raft storage is not in use")
+	}
+	return pm, nil
+}
+
+// handleRaftConfiguration returns the raft peers and the current leader
+func (b *SystemBackend) handleRaftConfiguration(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	pm, err := b.raftPeerManager()
+	if err != nil {
+		return handleError(err)
+	}
+
+	peers, err := pm.Peers()
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"peers":  peers,
+			"leader": pm.Leader(),
+		},
+	}, nil
+}
+
+// handleRaftJoin adds a node to the raft cluster
+func (b *SystemBackend) handleRaftJoin(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	addr := data.Get("address").(string)
+	if addr == "" {
+		return logical.ErrorResponse("missing address"), logical.ErrInvalidRequest
+	}
+
+	pm, err := b.raftPeerManager()
+	if err != nil {
+		return handleError(err)
+	}
+
+	if err := pm.AddPeer(addr); err != nil {
+		b.Backend.Logger().Printf("[ERR] sys: failed to add raft peer %s: %v", addr, err)
+		return handleError(err)
+	}
+	b.Backend.Logger().Printf("[INFO] sys: added raft peer %s", addr)
+	return nil, nil
+}
+
+// handleRaftRemovePeer removes a node from the raft cluster
+func (b *SystemBackend) handleRaftRemovePeer(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	addr := data.Get("address").(string)
+	if addr == "" {
+		return logical.ErrorResponse("missing address"), logical.ErrInvalidRequest
+	}
+
+	pm, err := b.raftPeerManager()
+	if err != nil {
+		return handleError(err)
+	}
+
+	if err := pm.RemovePeer(addr); err != nil {
+		b.Backend.Logger().Printf("[ERR] sys: failed to remove raft peer %s: %v", addr, err)
+		return handleError(err)
+	}
+	b.Backend.Logger().Printf("[INFO] sys: removed raft peer %s", addr)
+	return nil, nil
+}
+
 func sanitizeMountPath(path string) string {
 	if !strings.HasSuffix(path, "/") {
 		path += "/"
@@ -1435,6 +2626,11 @@ west coast.
 and max_lease_ttl.`,
 	},
 
+	"mount_plugin_name": {
+		`Name of the catalog plugin serving the backend, for backends of type "plugin".`,
+		"",
+	},
+
 	"tune_default_lease_ttl": {
 		`The default lease TTL for this mount.`,
 	},
@@ -1499,6 +2695,63 @@ all matching leases.
 		`,
 	},
 
+	"leases-lookup": {
+		"Look up the times and state of a lease",
+		`
+Returns the time a lease was issued, when it was last renewed and when it
+expires, along with the remaining TTL and whether the lease can be renewed.
+The lease itself is not changed.
+		`,
+	},
+
+	"leases-list": {
+		"List the leases under a prefix",
+		`
+Lists the lease IDs directly under the given prefix, such as
+"postgresql/creds/readonly/". Entries ending in a slash are prefixes holding
+further leases. Listing requires sudo capability.
+		`,
+	},
+
+	"leases-list-prefix": {
+		`The prefix to list leases under. Example: "postgresql/creds/readonly"`,
+		"",
+	},
+
+	"leases-tidy": {
+		"Repair or remove inconsistent lease entries",
+		`
+Starts a background walk of the leases and their index by token. Leases of
+tokens that no longer exist are revoked, as are expired leases that are no
+longer queued for revocation, other than irrevocable ones. Missing index
+entries are restored, and those of leases that no longer exist are removed.
+
+Writing to this path starts the walk; reading it returns the progress of the
+last walk and the changes it made. The outcome is also recorded in the audit
+log once the walk is done.
+		`,
+	},
+
+	"leases-irrevocable": {
+		"List the leases that could not be revoked",
+		`
+Revoking an expired lease is retried with exponential backoff when it fails.
+Once the attempts run out, the lease is marked irrevocable and is no longer
+retried. This endpoint lists those leases, along with the number of attempts
+made and the error of the last one. They can be revoked again through
+"sys/revoke", or removed without revoking the secret in the backend through
+"sys/leases/revoke-force".
+		`,
+	},
+
+	"leases-count": {
+		"Count the leases by mount",
+		`
+Returns the number of leases held, in total and by the mount that issued
+them. Leases of tokens are counted under the mount of the token store.
+		`,
+	},
+
 	"revoke-prefix-path": {
 		`The path to revoke keys under. Example: "prod/aws/ops"`,
 		"",
@@ -1563,6 +2816,16 @@ Example: you might have an OAuth backend for GitHub, and one for Google Apps.
 		"",
 	},
 
+	"auth_token_type": {
+		`The type of the tokens issued by this credential backend, "service" or "batch". Defaults to "service".`,
+		"",
+	},
+
+	"auth_plugin_name": {
+		`Name of the catalog plugin serving the credential backend, for backends of type "plugin".`,
+		"",
+	},
+
 	"policy-list": {
 		`List the configured access control policies.`,
 		`
@@ -1590,6 +2853,40 @@ or delete a policy.
 		`,
 	},
 
+	"namespace-list": {
+		`List the namespaces within the current namespace.`,
+		`
+This path responds to the following HTTP methods.
+
+    LIST /
+        List the names of the namespaces within the current namespace.
+
+    GET /<name>
+        Retrieve the ID and path of the named namespace.
+
+    PUT /<name>
+        Create a namespace.
+
+    DELETE /<name>
+        Delete a namespace.
+		`,
+	},
+
+	"namespace": {
+		`Read, Create, or Delete a namespace.`,
+		`
+A namespace isolates mounts, credential backends, policies and tokens under
+its path. Tokens issued within a namespace have no access outside of it,
+while the namespace is administered from the namespace it was created in.
+Deleting a namespace revokes its leases and removes everything within it.
+		`,
+	},
+
+	"namespace-name": {
+		`The name of the namespace. Example: "team-a"`,
+		"",
+	},
+
 	"policy-name": {
 		`The name of the policy. Example: "ops"`,
 		"",
@@ -1686,4 +2983,157 @@ Enable a new audit backend or disable an existing backend.
 		`When there is no access to the token, token accessor can be used to fetch the token's capabilities
 		on a given path.`,
 	},
+
+	"wrapping_lookup": {
+		"Looks up the properties of a response-wrapping token.",
+		`
+Returns the creation TTL and creation time of the given response-wrapping
+token. The token is not consumed by the lookup.
+		`,
+	},
+
+	"wrapping_unwrap": {
+		"Unwraps a response-wrapping token.",
+		`
+Returns the response that was wrapped in the given response-wrapping token,
+or in the client token if no token is given, and revokes the wrapping token.
+		`,
+	},
+
+	"wrapping_rewrap": {
+		"Rotates a response-wrapping token.",
+		`
+Moves the response wrapped in the given response-wrapping token into a new
+response-wrapping token with the same TTL and revokes the given token. This
+is useful for long-lived secrets whose wrapping token may have been seen.
+		`,
+	},
+
+	"raft_configuration": {
+		"Returns the members of the raft cluster.",
+		`
+Returns the raft addresses of the members of the cluster and the address of
+the current raft leader. Only available when using raft storage.
+		`,
+	},
+
+	"raft_join": {
+		"Adds a node to the raft cluster.",
+		`
+Adds the node listening on the given raft address to the cluster. The node
+must be running with raft storage and without the bootstrap option. Data is
+replicated to the new node once it has been added.
+		`,
+	},
+
+	"raft_remove_peer": {
+		"Removes a node from the raft cluster.",
+		`
+Removes the node with the given raft address from the cluster. This should be
+done before permanently decommissioning a node so that it is no longer
+counted towards the quorum.
+		`,
+	},
+
+	"control_group_authorize": {
+		"Approves a request awaiting the approval of a control group.",
+		`
+Adds the approval of the client token to the request whose response-wrapping
+token has the given accessor. The token must have an entity, and hold one of
+the approver policies of the control group or have its entity be a member of
+one of the approver groups. Requesters cannot approve their own requests, and
+each entity counts once, whatever the number of its tokens. Once enough approvals have been given the request is
+executed, and its response can be unwrapped by the requester.
+		`,
+	},
+
+	"control_group_request": {
+		"Looks up a request awaiting the approval of a control group.",
+		`
+Returns the path and requester of the request whose response-wrapping token
+has the given accessor, along with the approvals it has been given so far.
+		`,
+	},
+
+	"rate-limit-quota-list": {
+		`List the rate limit quotas.`,
+		`
+This path responds to the following HTTP methods.
+
+    LIST /
+        List the names of the rate limit quotas.
+
+    GET /<name>
+        Retrieve the settings of the named quota.
+
+    PUT /<name>
+        Create or update a quota.
+
+    DELETE /<name>
+        Delete a quota.
+		`,
+	},
+
+	"rate-limit-quota": {
+		`Read, Create, Update, or Delete a rate limit quota.`,
+		`
+Rate limit quotas limit the rate of the requests each client makes, to a
+mount or path within a mount, or to all of Vault if no path is given. Clients
+are told apart by their IP address, or by their token if key_by is set to
+'token'. Each client can make a burst of requests at once, which is refilled
+at the given rate per interval. Requests over the limit are refused with the
+429 status code and a Retry-After header.
+
+Only the most specific quota applies to a request. Changing a quota resets
+the state of its clients. Requests to sys/quotas are not limited.
+		`,
+	},
+
+	"lease-count-quota-list": {
+		`List the lease count quotas.`,
+		`
+This path responds to the following HTTP methods.
+
+    LIST /
+        List the names of the lease count quotas.
+
+    GET /<name>
+        Retrieve the settings of the named quota and its count of leases.
+
+    PUT /<name>
+        Create or update a quota.
+
+    DELETE /<name>
+        Delete a quota.
+		`,
+	},
+
+	"lease-count-quota": {
+		`Read, Create, Update, or Delete a lease count quota.`,
+		`
+Lease count quotas cap the number of leases of secrets held under a mount or
+path within a mount, or under all of Vault if no path is given. Leases of
+tokens are not counted. Once a quota is full, requests that would issue
+another secret under its path are refused with the 429 status code, and the
+secret the backend issued is revoked. Leases count against every quota whose
+path they fall under, until they are revoked or expire.
+		`,
+	},
+
+	"plugin-catalog-list": {
+		`Lists the plugins registered in the catalog.`,
+		"",
+	},
+
+	"plugin-catalog": {
+		`Read, Register, or Remove a plugin in the catalog.`,
+		`
+The plugin catalog holds the external binaries that may serve mounts and
+credential backends of type "plugin". The command of a plugin is relative to
+the plugin_directory set in the server configuration, which it cannot leave,
+and is only run if the SHA-256 sum of its binary matches the one registered.
+Mounts served by a plugin fail to load while the plugin is missing from the
+catalog.
+		`,
+	},
 }
@@ -2,6 +2,9 @@ package vault
 
 import (
 	"crypto/sha256"
+	"io/ioutil"
+	"log"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
@@ -11,6 +14,8 @@ import (
 	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/helper/salt"
 	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
+	physicaltest "github.com/hashicorp/vault/physical/testing"
 )
 
 func TestSystemBackend_RootPaths(t *testing.T) {
@@ -18,10 +23,17 @@ func TestSystemBackend_RootPaths(t *testing.T) {
 		"auth/*",
 		"remount",
 		"revoke-prefix/*",
+		"leases/lookup/*",
+		"leases/tidy",
+		"leases/irrevocable",
+		"leases/revoke-force/*",
 		"audit",
 		"audit/*",
 		"raw/*",
 		"rotate",
+		"storage/raft/*",
+		"namespaces/*",
+		"plugins/catalog/*",
 	}
 
 	b := testSystemBackend(t)
@@ -66,6 +78,14 @@ func TestSystemBackend_mounts(t *testing.T) {
 				"max_lease_ttl":     resp.Data["cubbyhole/"].(map[string]interface{})["config"].(map[string]interface{})["max_lease_ttl"].(int),
 			},
 		},
+		"identity/": map[string]interface{}{
+			"description": "identity store",
+			"type":        "identity",
+			"config": map[string]interface{}{
+				"default_lease_ttl": resp.Data["identity/"].(map[string]interface{})["config"].(map[string]interface{})["default_lease_ttl"].(int),
+				"max_lease_ttl":     resp.Data["identity/"].(map[string]interface{})["config"].(map[string]interface{})["max_lease_ttl"].(int),
+			},
+		},
 	}
 	if !reflect.DeepEqual(resp.Data, exp) {
 		t.Fatalf("Got:\n%#v\nExpected:\n%#v", resp.Data, exp)
@@ -409,6 +429,85 @@ func TestSystemBackend_revoke_invalidID(t *testing.T) {
 	}
 }
 
+func TestSystemBackend_leases(t *testing.T) {
+	core, b, root := testCoreSystemBackend(t)
+
+	// Create a key with a lease
+	req := logical.TestRequest(t, logical.UpdateOperation, "secret/foo")
+	req.Data["foo"] = "bar"
+	req.Data["lease"] = "1h"
+	req.ClientToken = root
+	if _, err := core.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Read a key with a LeaseID
+	req = logical.TestRequest(t, logical.ReadOperation, "secret/foo")
+	req.ClientToken = root
+	resp, err := core.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Secret == nil || resp.Secret.LeaseID == "" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	leaseID := resp.Secret.LeaseID
+
+	// Lookup the lease
+	req = logical.TestRequest(t, logical.UpdateOperation, "leases/lookup")
+	req.Data["lease_id"] = leaseID
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %#v", err, resp)
+	}
+	if resp.Data["id"] != leaseID || resp.Data["renewable"] != true || resp.Data["last_renewal"] != nil {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if ttl := resp.Data["ttl"].(int64); ttl <= 3500 || ttl > 3600 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// List the leases
+	req = logical.TestRequest(t, logical.ListOperation, "leases/lookup/secret/")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %#v", err, resp)
+	}
+	if !reflect.DeepEqual(resp.Data["keys"], []string{"foo/"}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	req = logical.TestRequest(t, logical.ListOperation, "leases/lookup/secret/foo")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %#v", err, resp)
+	}
+	if !reflect.DeepEqual(resp.Data["keys"], []string{strings.TrimPrefix(leaseID, "secret/foo/")}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Count the leases. The token store holds none for the root token.
+	req = logical.TestRequest(t, logical.ReadOperation, "leases/count")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %#v", err, resp)
+	}
+	expected := map[string]interface{}{
+		"total":  1,
+		"mounts": map[string]interface{}{"secret/": 1},
+	}
+	if !reflect.DeepEqual(resp.Data, expected) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Lookup an unknown lease
+	req = logical.TestRequest(t, logical.UpdateOperation, "leases/lookup")
+	req.Data["lease_id"] = "secret/foo/nope"
+	resp, err = b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v %#v", err, resp)
+	}
+}
+
 func TestSystemBackend_revokePrefix(t *testing.T) {
 	core, b, root := testCoreSystemBackend(t)
 
@@ -528,8 +627,12 @@ func TestSystemBackend_authTable(t *testing.T) {
 		"token/": map[string]string{
 			"type":        "token",
 			"description": "token based credentials",
+			"accessor":    resp.Data["token/"].(map[string]string)["accessor"],
 		},
 	}
+	if !strings.HasPrefix(exp["token/"].(map[string]string)["accessor"], "auth_token_") {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
 	if !reflect.DeepEqual(resp.Data, exp) {
 		t.Fatalf("got: %#v expect: %#v", resp.Data, exp)
 	}
@@ -566,6 +669,41 @@ func TestSystemBackend_enableAuth_invalid(t *testing.T) {
 	}
 }
 
+func TestSystemBackend_enableAuth_plugin(t *testing.T) {
+	b := testSystemBackend(t)
+
+	// The plugin name is required for plugin backends, and only for them
+	req := logical.TestRequest(t, logical.UpdateOperation, "auth/foo")
+	req.Data["type"] = "plugin"
+	resp, err := b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["error"] != "plugin_name must be set for plugin backends" {
+		t.Fatalf("bad: %v", resp)
+	}
+
+	req.Data["type"] = "noop"
+	req.Data["plugin_name"] = "foo"
+	resp, err = b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["error"] != "plugin_name can only be set for plugin backends" {
+		t.Fatalf("bad: %v", resp)
+	}
+
+	// The plugin must be in the catalog
+	req.Data["type"] = "plugin"
+	resp, err = b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["error"] != "unknown plugin: foo" {
+		t.Fatalf("bad: %v", resp)
+	}
+}
+
 func TestSystemBackend_disableAuth(t *testing.T) {
 	c, b, _ := testCoreSystemBackend(t)
 	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
@@ -1008,6 +1146,93 @@ func TestSystemBackend_rotate(t *testing.T) {
 	}
 }
 
+func TestSystemBackend_raft(t *testing.T) {
+	// Raft endpoints are unavailable without raft storage
+	b := testSystemBackend(t)
+	req := logical.TestRequest(t, logical.ReadOperation, "storage/raft/configuration")
+	resp, err := b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["error"] != "raft storage is not in use" {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	conf := physicaltest.RaftTLSConfig(t, dir)
+	conf["path"] = dir
+	conf["address"] = "127.0.0.1:0"
+	conf["bootstrap"] = "true"
+	backend, err := physical.NewBackend("raft", logger, conf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	raftBackend := backend.(*physical.RaftBackend)
+	defer raftBackend.Shutdown()
+
+	// Wait for the node to elect itself
+	start := time.Now()
+	for raftBackend.Leader() == "" {
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("raft leader not elected")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	c, err := NewCore(&CoreConfig{
+		Physical:      raftBackend,
+		HAPhysical:    raftBackend,
+		AdvertiseAddr: "http://127.0.0.1:8200",
+		DisableMlock:  true,
+		Logger:        logger,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	key, _ := TestCoreInit(t, c)
+	if _, err := c.Unseal(TestKeyCopy(key)); err != nil {
+		t.Fatalf("unseal err: %s", err)
+	}
+	testWaitActive(t, c)
+
+	b = NewSystemBackend(c, &logical.BackendConfig{
+		Logger: c.logger,
+		System: logical.StaticSystemView{
+			DefaultLeaseTTLVal: time.Hour * 24,
+			MaxLeaseTTLVal:     time.Hour * 24 * 30,
+		},
+	})
+
+	req = logical.TestRequest(t, logical.ReadOperation, "storage/raft/configuration")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	peers := resp.Data["peers"].([]string)
+	if len(peers) != 1 || peers[0] != resp.Data["leader"] {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	req = logical.TestRequest(t, logical.UpdateOperation, "storage/raft/join")
+	resp, err = b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+
+	req = logical.TestRequest(t, logical.UpdateOperation, "storage/raft/remove-peer")
+	req.Data["address"] = "127.0.0.1:1"
+	resp, err = b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func testSystemBackend(t *testing.T) logical.Backend {
 	c, _, _ := TestCoreUnsealed(t)
 	bc := &logical.BackendConfig{
@@ -10,6 +10,7 @@ import (
 
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
 )
 
 const (
@@ -37,12 +38,14 @@ var (
 		"auth/",
 		"sys/",
 		"cubbyhole/",
+		"identity/",
 	}
 
 	untunableMounts = []string{
 		"cubbyhole/",
 		"sys/",
 		"audit/",
+		"identity/",
 	}
 
 	// singletonMounts can only exist in one location and are
@@ -50,6 +53,7 @@ var (
 	singletonMounts = []string{
 		"cubbyhole",
 		"system",
+		"identity",
 	}
 )
 
@@ -124,6 +128,7 @@ type MountEntry struct {
 	Type        string            `json:"type"`              // Logical backend Type
 	Description string            `json:"description"`       // User-provided description
 	UUID        string            `json:"uuid"`              // Barrier view UUID
+	Accessor    string            `json:"accessor"`          // Unique but more human-friendly ID, for credential backends
 	Config      MountConfig       `json:"config"`            // Configuration related to this mount (but not backend-derived)
 	Options     map[string]string `json:"options"`           // Backend options
 	Tainted     bool              `json:"tainted,omitempty"` // Set as a Write-Ahead flag for unmount/remount
@@ -133,6 +138,8 @@ type MountEntry struct {
 type MountConfig struct {
 	DefaultLeaseTTL time.Duration `json:"default_lease_ttl" structs:"default_lease_ttl" mapstructure:"default_lease_ttl"` // Override for global default
 	MaxLeaseTTL     time.Duration `json:"max_lease_ttl" structs:"max_lease_ttl" mapstructure:"max_lease_ttl"`             // Override for global default
+	TokenType       string        `json:"token_type,omitempty" structs:"token_type" mapstructure:"token_type"`            // Type of the tokens issued by a credential backend
+	PluginName      string        `json:"plugin_name,omitempty" structs:"plugin_name" mapstructure:"plugin_name"`         // Catalog plugin serving a backend of type plugin
 }
 
 // Returns a deep copy of the mount entry
@@ -151,6 +158,17 @@ func (e *MountEntry) Clone() *MountEntry {
 	}
 }
 
+// backendConfig returns the configuration passed to the factory of the
+// backend of the entry
+func (e *MountEntry) backendConfig() map[string]string {
+	if e.Config.PluginName == "" {
+		return nil
+	}
+	return map[string]string{
+		"plugin_name": e.Config.PluginName,
+	}
+}
+
 // Mount is used to mount a new backend to the mount table.
 func (c *Core) mount(me *MountEntry) error {
 	// Ensure we end the path in a slash
@@ -158,9 +176,13 @@ func (c *Core) mount(me *MountEntry) error {
 		me.Path += "/"
 	}
 
-	// Prevent protected paths from being mounted
+	// Prevent protected paths from being mounted, within any namespace
+	nsPath := strings.TrimPrefix(me.Path, c.namespaceByPath(me.Path).Path)
+	if nsPath == "" {
+		return logical.CodedError(403, fmt.Sprintf("cannot mount at namespace '%s'", me.Path))
+	}
 	for _, p := range protectedMounts {
-		if strings.HasPrefix(me.Path, p) {
+		if strings.HasPrefix(nsPath, p) {
 			return logical.CodedError(403, fmt.Sprintf("cannot mount '%s'", me.Path))
 		}
 	}
@@ -188,7 +210,7 @@ func (c *Core) mount(me *MountEntry) error {
 	me.UUID = meUUID
 	view := NewBarrierView(c.barrier, backendBarrierPrefix+me.UUID+"/")
 
-	backend, err := c.newLogicalBackend(me.Type, c.mountEntrySysView(me), view, nil)
+	backend, err := c.newLogicalBackend(me.Type, c.mountEntrySysView(me), view, me.backendConfig())
 	if err != nil {
 		return err
 	}
@@ -217,8 +239,9 @@ func (c *Core) unmount(path string) error {
 	}
 
 	// Prevent protected paths from being unmounted
+	nsPath := strings.TrimPrefix(path, c.namespaceByPath(path).Path)
 	for _, p := range protectedMounts {
-		if strings.HasPrefix(path, p) {
+		if strings.HasPrefix(nsPath, p) {
 			return fmt.Errorf("cannot unmount '%s'", path)
 		}
 	}
@@ -312,9 +335,18 @@ func (c *Core) remount(src, dst string) error {
 		dst += "/"
 	}
 
+	// Mounts stay within their namespace
+	srcNS, dstNS := c.namespaceByPath(src), c.namespaceByPath(dst)
+	if srcNS.ID != dstNS.ID {
+		return fmt.Errorf("cannot remount '%s' to another namespace", src)
+	}
+	if dst == dstNS.Path {
+		return fmt.Errorf("cannot remount to namespace '%s'", dst)
+	}
+
 	// Prevent protected paths from being remounted
 	for _, p := range protectedMounts {
-		if strings.HasPrefix(src, p) {
+		if strings.HasPrefix(strings.TrimPrefix(src, srcNS.Path), p) {
 			return fmt.Errorf("cannot remount '%s'", src)
 		}
 	}
@@ -484,7 +516,7 @@ func (c *Core) setupMounts() error {
 
 		// Initialize the backend
 		// Create the new backend
-		backend, err = c.newLogicalBackend(entry.Type, c.mountEntrySysView(entry), view, nil)
+		backend, err = c.newLogicalBackend(entry.Type, c.mountEntrySysView(entry), view, entry.backendConfig())
 		if err != nil {
 			c.logger.Printf(
 				"[ERR] core: failed to create mount entry %s: %v",
@@ -495,10 +527,21 @@ func (c *Core) setupMounts() error {
 		switch entry.Type {
 		case "system":
 			c.systemBarrierView = view
+			c.namespaceSystemBackend = newNamespaceSystemBackend(backend.(*framework.Backend), &logical.BackendConfig{
+				StorageView: view,
+				Logger:      c.logger,
+				System:      c.mountEntrySysView(entry),
+			})
 		case "cubbyhole":
 			ch := backend.(*CubbyholeBackend)
 			ch.saltUUID = entry.UUID
 			ch.storageView = view
+		case "identity":
+			c.identityStore = backend.(*IdentityStore)
+			if err := c.identityStore.load(); err != nil {
+				c.logger.Printf("[ERR] core: failed to load identity store: %v", err)
+				return errLoadMountsFailed
+			}
 		}
 
 		// Mount the backend
@@ -538,6 +581,8 @@ func (c *Core) unloadMounts() error {
 	c.mounts = nil
 	c.router = NewRouter()
 	c.systemBarrierView = nil
+	c.namespaceSystemBackend = nil
+	c.identityStore = nil
 	return nil
 }
 
@@ -615,7 +660,18 @@ func requiredMountTable() *MountTable {
 		Description: "system endpoints used for control, policy and debugging",
 		UUID:        sysUUID,
 	}
+	identityUUID, err := uuid.GenerateUUID()
+	if err != nil {
+		panic(fmt.Sprintf("could not create identity UUID: %v", err))
+	}
+	identityMount := &MountEntry{
+		Path:        "identity/",
+		Type:        "identity",
+		Description: "identity store",
+		UUID:        identityUUID,
+	}
 	table.Entries = append(table.Entries, cubbyholeMount)
 	table.Entries = append(table.Entries, sysMount)
+	table.Entries = append(table.Entries, identityMount)
 	return table
 }
@@ -321,7 +321,7 @@ func TestDefaultMountTable(t *testing.T) {
 }
 
 func verifyDefaultTable(t *testing.T, table *MountTable) {
-	if len(table.Entries) != 3 {
+	if len(table.Entries) != 4 {
 		t.Fatalf("bad: %v", table.Entries)
 	}
 	for idx, entry := range table.Entries {
@@ -347,6 +347,13 @@ func verifyDefaultTable(t *testing.T, table *MountTable) {
 			if entry.Type != "system" {
 				t.Fatalf("bad: %v", entry)
 			}
+		case 3:
+			if entry.Path != "identity/" {
+				t.Fatalf("bad: %v", entry)
+			}
+			if entry.Type != "identity" {
+				t.Fatalf("bad: %v", entry)
+			}
 		}
 		if entry.Description == "" {
 			t.Fatalf("bad: %v", entry)
@@ -0,0 +1,427 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/armon/go-radix"
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	// coreNamespaceConfigPath is used to store the namespace table.
+	// Namespaces are protected within the Vault itself, which means they
+	// can only be viewed or modified after an unseal.
+	coreNamespaceConfigPath = "core/namespaces"
+
+	// namespaceBarrierPrefix is the prefix, nested under the system view,
+	// of the data kept by the system for each namespace other than the root
+	namespaceBarrierPrefix = "namespaces/"
+
+	// rootNamespaceID is the ID of the root namespace. It is empty, so that
+	// tokens created before namespaces existed belong to the root namespace.
+	rootNamespaceID = ""
+)
+
+var (
+	// errLoadNamespacesFailed if loadNamespaces encounters an error
+	errLoadNamespacesFailed = errors.New("failed to setup namespace table")
+
+	// namespaceNameRegex is the pattern a namespace name must match
+	namespaceNameRegex = regexp.MustCompile("^[a-zA-Z0-9_-]+$")
+
+	// namespaceBuiltinMounts are the route prefixes of the root namespace
+	// that are also served within every other namespace. The backends
+	// behind them are shared, and tell namespaces apart by mount point.
+	namespaceBuiltinMounts = []string{
+		"sys/",
+		"cubbyhole/",
+		credentialRoutePrefix + "token/",
+	}
+
+	// namespaceSystemPaths are the prefixes of the system backend paths
+	// that are available within namespaces other than the root. The
+	// remaining paths act on the whole of Vault.
+	namespaceSystemPaths = []string{
+		"capabilities",
+		"mounts",
+		"remount",
+		"renew/",
+		"revoke",
+		"auth",
+		"policy",
+		"namespaces",
+	}
+
+	// rootNamespace is the namespace every other namespace descends from
+	rootNamespace = &Namespace{
+		ID: rootNamespaceID,
+	}
+)
+
+// Namespace is an isolated tenant of Vault. A namespace has its own mounts,
+// credential backends, policies and tokens, all of which live under the
+// path of the namespace. Namespaces nest: a namespace can administer the
+// namespaces created within it, but not the other way around.
+type Namespace struct {
+	ID   string `json:"id"`   // Unique ID, used to tie data to the namespace
+	Path string `json:"path"` // Path of the namespace, such as "team/"
+}
+
+// HasPath returns whether the given path is within the namespace
+func (n *Namespace) HasPath(path string) bool {
+	return strings.HasPrefix(path, n.Path)
+}
+
+// NamespaceTable is used to represent the internal namespace table
+type NamespaceTable struct {
+	Entries []*Namespace `json:"entries"`
+}
+
+// loadNamespaces is invoked as part of postUnseal to load the namespace
+// table. It is loaded before the mounts, whose paths depend on it.
+func (c *Core) loadNamespaces() error {
+	table := &NamespaceTable{}
+	raw, err := c.barrier.Get(coreNamespaceConfigPath)
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to read namespace table: %v", err)
+		return errLoadNamespacesFailed
+	}
+	if raw != nil {
+		if err := json.Unmarshal(raw.Value, table); err != nil {
+			c.logger.Printf("[ERR] core: failed to decode namespace table: %v", err)
+			return errLoadNamespacesFailed
+		}
+	}
+
+	c.namespacesLock.Lock()
+	defer c.namespacesLock.Unlock()
+
+	c.namespaces = table
+	c.namespacePaths = radix.New()
+	for _, ns := range table.Entries {
+		c.namespacePaths.Insert(ns.Path, ns)
+	}
+	c.namespacePolicyStores = make(map[string]*PolicyStore)
+	return nil
+}
+
+// persistNamespaces is used to persist the namespace table after
+// modification
+func (c *Core) persistNamespaces(table *NamespaceTable) error {
+	raw, err := json.Marshal(table)
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to encode namespace table: %v", err)
+		return err
+	}
+
+	entry := &Entry{
+		Key:   coreNamespaceConfigPath,
+		Value: raw,
+	}
+	if err := c.barrier.Put(entry); err != nil {
+		c.logger.Printf("[ERR] core: failed to persist namespace table: %v", err)
+		return err
+	}
+	return nil
+}
+
+// setupNamespaces is invoked after the mounts and credential backends are
+// set up to serve the builtin backends within each namespace
+func (c *Core) setupNamespaces() error {
+	c.namespacesLock.RLock()
+	entries := c.namespaces.Entries
+	c.namespacesLock.RUnlock()
+
+	for _, ns := range entries {
+		if err := c.setupNamespace(ns); err != nil {
+			c.logger.Printf("[ERR] core: failed to set up namespace %s: %v", ns.Path, err)
+			return errLoadNamespacesFailed
+		}
+	}
+	return nil
+}
+
+// teardownNamespaces is used before we seal the vault to reset the
+// namespaces to their unloaded state. This is reversed by loadNamespaces.
+func (c *Core) teardownNamespaces() error {
+	c.namespacesLock.Lock()
+	defer c.namespacesLock.Unlock()
+
+	c.namespaces = nil
+	c.namespacePaths = nil
+	c.namespacePolicyStores = nil
+	return nil
+}
+
+// setupNamespace creates the policy store of the namespace and mounts the
+// builtin backends under its path
+func (c *Core) setupNamespace(ns *Namespace) error {
+	view := c.systemBarrierView.SubView(namespaceBarrierPrefix + ns.ID + "/" + policySubPath)
+	ps := NewPolicyStore(view)
+	ps.namespace = ns
+
+	c.namespacesLock.Lock()
+	c.namespacePolicyStores[ns.ID] = ps
+	c.namespacesLock.Unlock()
+
+	for _, prefix := range namespaceBuiltinMounts {
+		backend := c.router.MatchingBackend(prefix)
+		if backend == nil {
+			return fmt.Errorf("no builtin backend at %s", prefix)
+		}
+		if prefix == "sys/" {
+			backend = c.namespaceSystemBackend
+		}
+
+		entry := c.router.MatchingMountEntry(prefix)
+		view := c.router.MatchingStorageView(prefix)
+		if err := c.router.Mount(backend, ns.Path+prefix, entry, view); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// namespaceByPath returns the namespace the given path is in
+func (c *Core) namespaceByPath(path string) *Namespace {
+	c.namespacesLock.RLock()
+	defer c.namespacesLock.RUnlock()
+
+	if c.namespacePaths == nil {
+		return rootNamespace
+	}
+	_, raw, ok := c.namespacePaths.LongestPrefix(path)
+	if !ok {
+		return rootNamespace
+	}
+	return raw.(*Namespace)
+}
+
+// namespaceByID returns the namespace with the given ID, or nil if there
+// is none
+func (c *Core) namespaceByID(id string) *Namespace {
+	if id == rootNamespaceID {
+		return rootNamespace
+	}
+
+	c.namespacesLock.RLock()
+	defer c.namespacesLock.RUnlock()
+
+	if c.namespaces == nil {
+		return nil
+	}
+	for _, ns := range c.namespaces.Entries {
+		if ns.ID == id {
+			return ns
+		}
+	}
+	return nil
+}
+
+// namespacePolicyStore returns the policy store of the given namespace
+func (c *Core) namespacePolicyStore(ns *Namespace) *PolicyStore {
+	if ns.ID == rootNamespaceID {
+		return c.policyStore
+	}
+
+	c.namespacesLock.RLock()
+	defer c.namespacesLock.RUnlock()
+	return c.namespacePolicyStores[ns.ID]
+}
+
+// tokenInNamespace returns whether the token was issued in the given
+// namespace or in one of the namespaces within it
+func (c *Core) tokenInNamespace(te *TokenEntry, ns *Namespace) bool {
+	tokenNS := c.namespaceByID(te.NamespaceID)
+	return tokenNS != nil && ns.HasPath(tokenNS.Path)
+}
+
+// childNamespaces returns the namespaces directly within the given one
+func (c *Core) childNamespaces(parent *Namespace) []*Namespace {
+	c.namespacesLock.RLock()
+	defer c.namespacesLock.RUnlock()
+
+	var children []*Namespace
+	for _, ns := range c.namespaces.Entries {
+		if !parent.HasPath(ns.Path) {
+			continue
+		}
+		name := strings.TrimPrefix(ns.Path, parent.Path)
+		if name != "" && strings.Count(name, "/") == 1 {
+			children = append(children, ns)
+		}
+	}
+	return children
+}
+
+// createNamespace is used to create a namespace within the given one
+func (c *Core) createNamespace(parent *Namespace, name string) (*Namespace, error) {
+	name = strings.TrimSuffix(name, "/")
+	if !namespaceNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("namespace name must match %s", namespaceNameRegex.String())
+	}
+	path := parent.Path + name + "/"
+
+	// The name shares the path space of the parent with its mounts
+	for _, p := range protectedMounts {
+		if name+"/" == p {
+			return nil, logical.CodedError(403, fmt.Sprintf("cannot create namespace '%s'", name))
+		}
+	}
+	if match := c.router.MatchingMount(path); match != "" {
+		return nil, logical.CodedError(409, fmt.Sprintf("existing mount at %s", match))
+	}
+	c.mountsLock.RLock()
+	for _, entry := range c.mounts.Entries {
+		if strings.HasPrefix(entry.Path, path) {
+			c.mountsLock.RUnlock()
+			return nil, logical.CodedError(409, fmt.Sprintf("existing mount at %s", entry.Path))
+		}
+	}
+	c.mountsLock.RUnlock()
+	c.authLock.RLock()
+	for _, entry := range c.auth.Entries {
+		if strings.HasPrefix(entry.Path, path) {
+			c.authLock.RUnlock()
+			return nil, logical.CodedError(409, fmt.Sprintf("existing credential backend at %s", entry.Path))
+		}
+	}
+	c.authLock.RUnlock()
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	ns := &Namespace{
+		ID:   id,
+		Path: path,
+	}
+
+	c.namespacesLock.Lock()
+	if _, ok := c.namespacePaths.Get(path); ok {
+		c.namespacesLock.Unlock()
+		return nil, logical.CodedError(409, fmt.Sprintf("namespace %s already exists", path))
+	}
+	newTable := &NamespaceTable{
+		Entries: append(append([]*Namespace{}, c.namespaces.Entries...), ns),
+	}
+	if err := c.persistNamespaces(newTable); err != nil {
+		c.namespacesLock.Unlock()
+		return nil, errors.New("failed to update namespace table")
+	}
+	c.namespaces = newTable
+	c.namespacePaths.Insert(ns.Path, ns)
+	c.namespacesLock.Unlock()
+
+	if err := c.setupNamespace(ns); err != nil {
+		return nil, err
+	}
+	if err := c.namespacePolicyStore(ns).createDefaultPolicy(); err != nil {
+		return nil, err
+	}
+
+	c.logger.Printf("[INFO] core: created namespace '%s'", ns.Path)
+	return ns, nil
+}
+
+// deleteNamespace is used to delete a namespace, revoking its leases and
+// removing its mounts, credential backends, policies and token roles
+func (c *Core) deleteNamespace(ns *Namespace) error {
+	if ns.ID == rootNamespaceID {
+		return fmt.Errorf("cannot delete the root namespace")
+	}
+	if len(c.childNamespaces(ns)) != 0 {
+		return logical.CodedError(400, fmt.Sprintf("namespace %s contains namespaces", ns.Path))
+	}
+
+	if err := c.expiration.RevokePrefix(ns.Path); err != nil {
+		return err
+	}
+
+	var mounts, auths []string
+	c.mountsLock.RLock()
+	for _, entry := range c.mounts.Entries {
+		if ns.HasPath(entry.Path) {
+			mounts = append(mounts, entry.Path)
+		}
+	}
+	c.mountsLock.RUnlock()
+	c.authLock.RLock()
+	for _, entry := range c.auth.Entries {
+		if ns.HasPath(entry.Path) {
+			auths = append(auths, entry.Path)
+		}
+	}
+	c.authLock.RUnlock()
+
+	for _, path := range mounts {
+		if err := c.unmount(path); err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("failed to unmount %s: {{err}}", path), err)
+		}
+	}
+	for _, path := range auths {
+		if err := c.disableCredential(path); err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("failed to disable credential backend %s: {{err}}", path), err)
+		}
+	}
+
+	// The builtin backends are shared with the root namespace, which keeps
+	// serving them
+	for _, prefix := range namespaceBuiltinMounts {
+		c.router.Unalias(ns.Path + prefix)
+	}
+
+	// Tokens issued in the namespace are not valid anymore once it is gone,
+	// so only the data kept for it remains to be removed
+	if err := ClearView(c.systemBarrierView.SubView(namespaceBarrierPrefix + ns.ID + "/")); err != nil {
+		return err
+	}
+	if err := ClearView(c.tokenStore.view.SubView(c.tokenStore.namespaceRolesPrefix(ns))); err != nil {
+		return err
+	}
+
+	c.namespacesLock.Lock()
+	defer c.namespacesLock.Unlock()
+
+	newTable := &NamespaceTable{}
+	for _, entry := range c.namespaces.Entries {
+		if entry.ID != ns.ID {
+			newTable.Entries = append(newTable.Entries, entry)
+		}
+	}
+	if err := c.persistNamespaces(newTable); err != nil {
+		return errors.New("failed to update namespace table")
+	}
+	c.namespaces = newTable
+	c.namespacePaths.Delete(ns.Path)
+	delete(c.namespacePolicyStores, ns.ID)
+
+	c.logger.Printf("[INFO] core: deleted namespace '%s'", ns.Path)
+	return nil
+}
+
+// newNamespaceSystemBackend creates the system backend served within
+// namespaces other than the root, limited to the namespaceSystemPaths
+func newNamespaceSystemBackend(sys *framework.Backend, config *logical.BackendConfig) logical.Backend {
+	b := &framework.Backend{
+		Help:         sys.Help,
+		PathsSpecial: sys.PathsSpecial,
+	}
+	for _, p := range sys.Paths {
+		for _, prefix := range namespaceSystemPaths {
+			if strings.HasPrefix(p.Pattern, prefix) {
+				b.Paths = append(b.Paths, p)
+				break
+			}
+		}
+	}
+	b.Setup(config)
+	return b
+}
@@ -0,0 +1,232 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// testNamespaceRequest makes a request against the core, failing the test
+// if the outcome does not match whether it should be permitted
+func testNamespaceRequest(t *testing.T, c *Core, op logical.Operation, path, token string, data map[string]interface{}, allowed bool) *logical.Response {
+	req := logical.TestRequest(t, op, path)
+	req.ClientToken = token
+	if data != nil {
+		req.Data = data
+	}
+	resp, err := c.HandleRequest(req)
+	if allowed && err != nil {
+		t.Fatalf("%s %s: err: %v %v", op, path, err, resp)
+	}
+	if !allowed && err != logical.ErrPermissionDenied {
+		t.Fatalf("%s %s: expected permission denied, got: %v %v", op, path, err, resp)
+	}
+	return resp
+}
+
+func TestCore_Namespaces(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+
+	resp := testNamespaceRequest(t, c, logical.UpdateOperation, "sys/namespaces/team", root, nil, true)
+	if resp.Data["path"] != "team/" {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// The root token administers the namespace
+	testNamespaceRequest(t, c, logical.UpdateOperation, "team/sys/mounts/secret", root, map[string]interface{}{
+		"type": "generic",
+	}, true)
+	testNamespaceRequest(t, c, logical.UpdateOperation, "team/sys/policy/reader", root, map[string]interface{}{
+		"rules": `path "secret/*" { policy = "read" }`,
+	}, true)
+	testNamespaceRequest(t, c, logical.UpdateOperation, "team/secret/foo", root, map[string]interface{}{
+		"value": "team",
+	}, true)
+	testNamespaceRequest(t, c, logical.UpdateOperation, "secret/foo", root, map[string]interface{}{
+		"value": "root",
+	}, true)
+
+	// Mount paths are relative to the namespace
+	resp = testNamespaceRequest(t, c, logical.ReadOperation, "team/sys/mounts", root, nil, true)
+	for _, path := range []string{"secret/", "sys/", "cubbyhole/"} {
+		if _, ok := resp.Data[path]; !ok {
+			t.Fatalf("missing %s: %#v", path, resp.Data)
+		}
+	}
+	resp = testNamespaceRequest(t, c, logical.ReadOperation, "sys/mounts", root, nil, true)
+	if _, ok := resp.Data["team/secret/"]; ok {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// A token issued in the namespace uses its policies
+	resp = testNamespaceRequest(t, c, logical.UpdateOperation, "team/auth/token/create", root, map[string]interface{}{
+		"policies": []string{"reader"},
+	}, true)
+	token := resp.Auth.ClientToken
+
+	resp = testNamespaceRequest(t, c, logical.ReadOperation, "team/secret/foo", token, nil, true)
+	if resp.Data["value"] != "team" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	testNamespaceRequest(t, c, logical.ReadOperation, "secret/foo", token, nil, false)
+	testNamespaceRequest(t, c, logical.ReadOperation, "team/sys/mounts", token, nil, false)
+
+	testNamespaceRequest(t, c, logical.ReadOperation, "team/auth/token/lookup-self", token, nil, true)
+
+	// Nothing is reachable outside of the namespace, even as root within it
+	resp = testNamespaceRequest(t, c, logical.UpdateOperation, "team/auth/token/create", root, map[string]interface{}{
+		"policies": []string{"root"},
+	}, true)
+	teamRoot := resp.Auth.ClientToken
+	testNamespaceRequest(t, c, logical.ReadOperation, "team/secret/foo", teamRoot, nil, true)
+	testNamespaceRequest(t, c, logical.ReadOperation, "secret/foo", teamRoot, nil, false)
+	testNamespaceRequest(t, c, logical.ReadOperation, "sys/mounts", teamRoot, nil, false)
+	testNamespaceRequest(t, c, logical.UpdateOperation, "sys/namespaces/other", teamRoot, nil, false)
+
+	// Its token store does not reach tokens of the root namespace
+	resp = testNamespaceRequest(t, c, logical.ReadOperation, "team/auth/token/lookup/"+token, teamRoot, nil, true)
+	if resp.Data["id"] != token {
+		t.Fatalf("bad: %#v", resp)
+	}
+	testNamespaceRequest(t, c, logical.ReadOperation, "team/auth/token/lookup/"+root, teamRoot, nil, false)
+
+	// The namespace survives sealing
+	conf := &CoreConfig{
+		Physical:     c.physical,
+		DisableMlock: true,
+	}
+	c2, err := NewCore(conf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal, err := c2.Unseal(key); err != nil || !unseal {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(c.namespaces, c2.namespaces) {
+		t.Fatalf("mismatch: %v %v", c.namespaces, c2.namespaces)
+	}
+	resp = testNamespaceRequest(t, c2, logical.ReadOperation, "team/secret/foo", token, nil, true)
+	if resp.Data["value"] != "team" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	testNamespaceRequest(t, c2, logical.ReadOperation, "secret/foo", token, nil, false)
+}
+
+func TestCore_Namespaces_Child(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	testNamespaceRequest(t, c, logical.UpdateOperation, "sys/namespaces/team", root, nil, true)
+	resp := testNamespaceRequest(t, c, logical.UpdateOperation, "team/auth/token/create", root, map[string]interface{}{
+		"policies": []string{"root"},
+	}, true)
+	teamRoot := resp.Auth.ClientToken
+
+	// The namespace administers the namespaces within it
+	testNamespaceRequest(t, c, logical.UpdateOperation, "team/sys/namespaces/child", teamRoot, nil, true)
+	testNamespaceRequest(t, c, logical.UpdateOperation, "team/child/sys/mounts/secret", teamRoot, map[string]interface{}{
+		"type": "generic",
+	}, true)
+	testNamespaceRequest(t, c, logical.UpdateOperation, "team/child/secret/foo", teamRoot, map[string]interface{}{
+		"value": "child",
+	}, true)
+	resp = testNamespaceRequest(t, c, logical.ListOperation, "team/sys/namespaces/", teamRoot, nil, true)
+	if !reflect.DeepEqual(resp.Data["keys"], []string{"child/"}) {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Tokens of the child namespace stay within it
+	resp = testNamespaceRequest(t, c, logical.UpdateOperation, "team/child/auth/token/create", teamRoot, map[string]interface{}{
+		"policies": []string{"root"},
+	}, true)
+	childRoot := resp.Auth.ClientToken
+	testNamespaceRequest(t, c, logical.ReadOperation, "team/child/secret/foo", childRoot, nil, true)
+	testNamespaceRequest(t, c, logical.ReadOperation, "team/sys/mounts", childRoot, nil, false)
+	testNamespaceRequest(t, c, logical.DeleteOperation, "team/sys/namespaces/child", childRoot, nil, false)
+
+	// A namespace with namespaces within it cannot be deleted
+	if _, err := c.HandleRequest(&logical.Request{
+		Operation:   logical.DeleteOperation,
+		Path:        "sys/namespaces/team",
+		ClientToken: root,
+	}); err == nil {
+		t.Fatalf("should fail")
+	}
+
+	testNamespaceRequest(t, c, logical.DeleteOperation, "team/sys/namespaces/child", teamRoot, nil, true)
+	if match := c.router.MatchingMount("team/child/secret/foo"); match != "" {
+		t.Fatalf("bad: %s", match)
+	}
+	testNamespaceRequest(t, c, logical.ReadOperation, "team/child/secret/foo", childRoot, nil, false)
+
+	// Deleting the namespace revokes its tokens
+	testNamespaceRequest(t, c, logical.DeleteOperation, "sys/namespaces/team", root, nil, true)
+	testNamespaceRequest(t, c, logical.ReadOperation, "team/sys/mounts", teamRoot, nil, false)
+	if te, err := c.tokenStore.Lookup(teamRoot); err != nil || te != nil {
+		t.Fatalf("bad: %v %v", te, err)
+	}
+}
+
+func TestCore_Namespaces_Tune(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	testNamespaceRequest(t, c, logical.UpdateOperation, "sys/namespaces/team", root, nil, true)
+	resp := testNamespaceRequest(t, c, logical.UpdateOperation, "team/auth/token/create", root, map[string]interface{}{
+		"policies": []string{"root"},
+	}, true)
+	teamRoot := resp.Auth.ClientToken
+
+	// The token backend of a namespace is the one of the root namespace, so
+	// it cannot be tuned from within the namespace
+	for _, op := range []logical.Operation{logical.ReadOperation, logical.UpdateOperation} {
+		req := logical.TestRequest(t, op, "team/sys/mounts/auth/token/tune")
+		req.ClientToken = teamRoot
+		req.Data["default_lease_ttl"] = "1h"
+		if _, err := c.HandleRequest(req); err != logical.ErrInvalidRequest {
+			t.Fatalf("%s: err: %v", op, err)
+		}
+	}
+	if entry := c.router.MatchingMountEntry("auth/token/"); entry.Config.DefaultLeaseTTL != 0 {
+		t.Fatalf("bad: %#v", entry.Config)
+	}
+
+	// Mounts of the namespace are tuned as usual
+	testNamespaceRequest(t, c, logical.UpdateOperation, "team/sys/mounts/secret", teamRoot, map[string]interface{}{
+		"type": "generic",
+	}, true)
+	testNamespaceRequest(t, c, logical.UpdateOperation, "team/sys/mounts/secret/tune", teamRoot, map[string]interface{}{
+		"default_lease_ttl": "1h",
+	}, true)
+	resp = testNamespaceRequest(t, c, logical.ReadOperation, "team/sys/mounts/secret/tune", teamRoot, nil, true)
+	if resp.Data["default_lease_ttl"] != 3600 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
+
+func TestCore_Namespaces_Conflict(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+
+	for _, name := range []string{"secret", "sys", "a/b", ""} {
+		if _, err := c.createNamespace(rootNamespace, name); err == nil {
+			t.Fatalf("should fail: %s", name)
+		}
+	}
+
+	ns, err := c.createNamespace(rootNamespace, "team")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.createNamespace(rootNamespace, "team"); err == nil {
+		t.Fatalf("should fail")
+	}
+
+	// Mounts cannot take the place of the namespace or of its builtins
+	for _, path := range []string{"team/", "team/sys/", "team/cubbyhole/"} {
+		if err := c.mount(&MountEntry{Path: path, Type: "generic"}); err == nil {
+			t.Fatalf("should fail: %s", path)
+		}
+	}
+	if err := c.disableCredential(ns.Path + "token/"); err == nil {
+		t.Fatalf("should fail")
+	}
+}
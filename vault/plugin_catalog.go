@@ -0,0 +1,227 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/plugin"
+)
+
+const (
+	// pluginCatalogPath is the barrier path of the plugin catalog
+	pluginCatalogPath = "core/plugin-catalog/"
+
+	// pluginBackendType is the type of the mounts served by a plugin
+	pluginBackendType = "plugin"
+)
+
+var (
+	// ErrNoPluginDirectory is returned when registering a plugin while no
+	// plugin directory is configured
+	ErrNoPluginDirectory = errors.New("no plugin directory configured")
+)
+
+// pluginCatalogEntry is a plugin binary registered in the catalog
+type pluginCatalogEntry struct {
+	Name    string   `json:"name"`
+	Command string   `json:"command"` // Relative to the plugin directory
+	Args    []string `json:"args"`
+	Sha256  []byte   `json:"sha256"`
+}
+
+// PluginCatalog holds the plugin binaries Vault may run. Only binaries
+// within the plugin directory can be registered, and a plugin is only run
+// if its binary matches the SHA-256 sum registered with it.
+type PluginCatalog struct {
+	directory string
+	view      *BarrierView
+}
+
+// setupPluginCatalog is invoked after unsealing to make the catalog
+// available to the mounts served by plugins. The plugin directory is
+// resolved once, so that commands resolved through symlinks can be
+// compared with it. A directory which cannot be resolved is used as
+// configured, which fails the registration of plugins rather than the
+// unseal.
+func (c *Core) setupPluginCatalog() error {
+	directory := c.pluginDirectory
+	if directory != "" {
+		directory = filepath.Clean(directory)
+		resolved, err := filepath.Abs(directory)
+		if err == nil {
+			resolved, err = filepath.EvalSymlinks(resolved)
+		}
+		if err != nil {
+			c.logger.Printf("[ERR] core: failed to resolve plugin directory: %v", err)
+		} else {
+			directory = resolved
+		}
+	}
+
+	c.pluginCatalog = &PluginCatalog{
+		directory: directory,
+		view:      NewBarrierView(c.barrier, pluginCatalogPath),
+	}
+	return nil
+}
+
+// Get returns the plugin of the given name, or nil if there is none
+func (p *PluginCatalog) Get(name string) (*pluginCatalogEntry, error) {
+	raw, err := p.view.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin: %v", err)
+	}
+	if raw == nil {
+		return nil, nil
+	}
+	entry := new(pluginCatalogEntry)
+	if err := json.Unmarshal(raw.Value, entry); err != nil {
+		return nil, fmt.Errorf("failed to decode plugin: %v", err)
+	}
+	return entry, nil
+}
+
+// Set registers the plugin of the given name, replacing any previous one
+func (p *PluginCatalog) Set(entry *pluginCatalogEntry) error {
+	if p.directory == "" {
+		return ErrNoPluginDirectory
+	}
+	if _, err := p.commandPath(entry.Command); err != nil {
+		return err
+	}
+
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode plugin: %v", err)
+	}
+	le := &logical.StorageEntry{Key: entry.Name, Value: buf}
+	if err := p.view.Put(le); err != nil {
+		return fmt.Errorf("failed to persist plugin: %v", err)
+	}
+	return nil
+}
+
+// Delete removes the plugin of the given name from the catalog. Mounts
+// served by the plugin fail to load until it is registered again.
+func (p *PluginCatalog) Delete(name string) error {
+	return p.view.Delete(name)
+}
+
+// List returns the names of the registered plugins
+func (p *PluginCatalog) List() ([]string, error) {
+	names, err := CollectKeys(p.view)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// commandPath returns the absolute path of the given command, which must
+// stay within the plugin directory once symlinks are resolved
+func (p *PluginCatalog) commandPath(command string) (string, error) {
+	if p.directory == "" {
+		return "", ErrNoPluginDirectory
+	}
+	path := filepath.Join(p.directory, command)
+	if !strings.HasPrefix(path, p.directory+string(os.PathSeparator)) {
+		return "", fmt.Errorf("plugin command must be within the plugin directory")
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		if !strings.HasPrefix(resolved, p.directory+string(os.PathSeparator)) {
+			return "", fmt.Errorf("plugin command must be within the plugin directory")
+		}
+		path = resolved
+	}
+	return path, nil
+}
+
+// newPluginBackend is the factory of the backends of type plugin, which
+// are served by the catalog plugin named in the mount configuration
+func (c *Core) newPluginBackend(config *logical.BackendConfig) (logical.Backend, error) {
+	name := config.Config["plugin_name"]
+	if name == "" {
+		return nil, fmt.Errorf("plugin_name must be set for plugin backends")
+	}
+
+	entry, err := c.pluginCatalog.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("unknown plugin: %s", name)
+	}
+	command, err := c.pluginCatalog.commandPath(entry.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	runner := &plugin.Runner{
+		Name:    name,
+		Command: command,
+		Args:    entry.Args,
+		Sha256:  entry.Sha256,
+		Wrap:    c.wrapPluginData,
+		Unwrap:  c.unwrapPluginData,
+	}
+	return plugin.NewBackend(runner, config)
+}
+
+// wrapPluginData stores the data handed to a plugin in the cubbyhole of a
+// response-wrapping token, which the plugin unwraps through the API
+func (c *Core) wrapPluginData(data map[string]interface{}, ttl time.Duration) (string, error) {
+	marshaled, err := json.Marshal(logical.SanitizeResponse(&logical.Response{Data: data}))
+	if err != nil {
+		return "", err
+	}
+	wrapInfo, err := c.createWrappingToken(ttl, string(marshaled))
+	if err != nil {
+		return "", err
+	}
+	return wrapInfo.Token, nil
+}
+
+// unwrapPluginData returns the data behind the response-wrapping token a
+// plugin presents, revoking the token. Plugins are started by a request to
+// their mount, which holds the state lock, so unlike sys/wrapping/unwrap
+// this does not take the lock.
+func (c *Core) unwrapPluginData(token string) (map[string]interface{}, error) {
+	te, err := c.lookupWrappingToken(token)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.readWrappedResponse(te)
+	if err != nil {
+		return nil, err
+	}
+
+	// The response may only be unwrapped once
+	if err := c.tokenStore.Revoke(te.ID); err != nil {
+		return nil, err
+	}
+
+	var resp logical.HTTPResponse
+	if err := json.Unmarshal([]byte(response), &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode wrapped response: %v", err)
+	}
+	return resp.Data, nil
+}
+
+// validatePluginName checks that a plugin name is given for, and only for,
+// backends of type plugin
+func validatePluginName(backendType, pluginName string) error {
+	switch {
+	case backendType == pluginBackendType && pluginName == "":
+		return fmt.Errorf("plugin_name must be set for plugin backends")
+	case backendType != pluginBackendType && pluginName != "":
+		return fmt.Errorf("plugin_name can only be set for plugin backends")
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package vault
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestSystemBackend_pluginCatalog(t *testing.T) {
+	c, b, _ := testCoreSystemBackend(t)
+
+	sum := sha256.Sum256([]byte("plugin"))
+	req := logical.TestRequest(t, logical.UpdateOperation, "plugins/catalog/foo")
+	req.Data["command"] = "foo-plugin -flag"
+	req.Data["sha_256"] = hex.EncodeToString(sum[:])
+
+	// Plugins cannot be registered without a plugin directory
+	resp, err := b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["error"] != ErrNoPluginDirectory.Error() {
+		t.Fatalf("bad: %v", resp)
+	}
+
+	dir, err := ioutil.TempDir("", "vault-plugins")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c.pluginDirectory = dir
+	c.pluginCatalog.directory = dir
+
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "plugins/catalog/foo")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	exp := map[string]interface{}{
+		"name":    "foo",
+		"command": "foo-plugin -flag",
+		"sha_256": hex.EncodeToString(sum[:]),
+	}
+	if !reflect.DeepEqual(resp.Data, exp) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	req = logical.TestRequest(t, logical.ListOperation, "plugins/catalog/")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(resp.Data["keys"], []string{"foo"}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Commands cannot leave the plugin directory
+	req = logical.TestRequest(t, logical.UpdateOperation, "plugins/catalog/bar")
+	req.Data["command"] = "../bar-plugin"
+	req.Data["sha_256"] = hex.EncodeToString(sum[:])
+	resp, err = b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+
+	// The SHA-256 sum must be valid
+	req.Data["command"] = "bar-plugin"
+	req.Data["sha_256"] = "abcd"
+	resp, err = b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+
+	// Plugins whose binary does not match the SHA-256 sum are not run
+	if err := ioutil.WriteFile(filepath.Join(dir, "foo-plugin"), []byte("tampered"), 0755); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req = logical.TestRequest(t, logical.UpdateOperation, "mounts/foo")
+	req.Data["type"] = "plugin"
+	req.Data["plugin_name"] = "foo"
+	resp, err = b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["error"] != "SHA-256 mismatch for plugin foo" {
+		t.Fatalf("bad: %v", resp)
+	}
+
+	req = logical.TestRequest(t, logical.DeleteOperation, "plugins/catalog/foo")
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req = logical.TestRequest(t, logical.ReadOperation, "plugins/catalog/foo")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp != nil {
+		t.Fatalf("bad: %v", resp)
+	}
+}
+
+func TestCore_setupPluginCatalog_symlink(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+
+	dir, err := ioutil.TempDir("", "vault-plugins")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "plugins"), 0755); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "plugins"), filepath.Join(dir, "link")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "plugins", "foo-plugin"), []byte("plugin"), 0755); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Plugins can be registered within a plugin directory reached
+	// through a symlink
+	c.pluginDirectory = filepath.Join(dir, "link")
+	if err := c.setupPluginCatalog(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sum := sha256.Sum256([]byte("plugin"))
+	entry := &pluginCatalogEntry{Name: "foo", Command: "foo-plugin", Sha256: sum[:]}
+	if err := c.pluginCatalog.Set(entry); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
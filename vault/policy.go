@@ -54,6 +54,10 @@ type Policy struct {
 	Name  string              `hcl:"name"`
 	Paths []*PathCapabilities `hcl:"-"`
 	Raw   string
+
+	// Templated is set if any path refers to the token or entity the
+	// policy applies to, such as "secret/{{token.display_name}}/*"
+	Templated bool `hcl:"-"`
 }
 
 // PathCapabilities represents a policy for a path in the namespace.
@@ -63,6 +67,44 @@ type PathCapabilities struct {
 	Capabilities       []string
 	CapabilitiesBitmap uint32 `hcl:"-"`
 	Glob               bool
+
+	// The parameters of create and update requests can be constrained. They
+	// map the name of a parameter, or "*" for any parameter, to globs of the
+	// values permitted or refused; an empty list stands for any value.
+	AllowedParameters  map[string][]string `hcl:"allowed_parameters"`
+	DeniedParameters   map[string][]string `hcl:"denied_parameters"`
+	RequiredParameters []string            `hcl:"required_parameters"`
+
+	// The capabilities can be restricted to requests from the given
+	// networks, made within the given time windows of the timezone
+	AllowedCIDRs []string        `hcl:"allowed_cidrs"`
+	AllowedTimes []string        `hcl:"allowed_times"`
+	Timezone     string          `hcl:"timezone"`
+	Conditions   *pathConditions `hcl:"-"`
+
+	// Requests to the path have to be approved by a control group before
+	// they are executed
+	ControlGroup *ControlGroup `hcl:"control_group"`
+}
+
+// prefixed returns a copy of the policy with the given prefix prepended to
+// each of its paths
+func (p *Policy) prefixed(prefix string) *Policy {
+	if p == nil {
+		return nil
+	}
+
+	out := &Policy{
+		Name:      p.Name,
+		Raw:       p.Raw,
+		Templated: p.Templated,
+	}
+	for _, pc := range p.Paths {
+		clone := *pc
+		clone.Prefix = prefix + pc.Prefix
+		out.Paths = append(out.Paths, &clone)
+	}
+	return out
 }
 
 // Parse is used to parse the specified ACL rules into an
@@ -117,6 +159,13 @@ func parsePaths(result *Policy, list *ast.ObjectList) error {
 		valid := []string{
 			"policy",
 			"capabilities",
+			"allowed_parameters",
+			"denied_parameters",
+			"required_parameters",
+			"allowed_cidrs",
+			"allowed_times",
+			"timezone",
+			"control_group",
 		}
 		if err := checkHCLKeys(item.Val, valid); err != nil {
 			return multierror.Prefix(err, fmt.Sprintf("path %q:", key))
@@ -139,6 +188,26 @@ func parsePaths(result *Policy, list *ast.ObjectList) error {
 			pc.Glob = true
 		}
 
+		// Templates are rendered for each token the policy applies to
+		if strings.Contains(pc.Prefix, templateOpen) || strings.Contains(pc.Prefix, templateClose) {
+			if _, err := templateVariables(pc.Prefix); err != nil {
+				return fmt.Errorf("path %q: %v", key, err)
+			}
+			result.Templated = true
+		}
+
+		conditions, err := parsePathConditions(pc.AllowedCIDRs, pc.AllowedTimes, pc.Timezone)
+		if err != nil {
+			return fmt.Errorf("path %q: %v", key, err)
+		}
+		pc.Conditions = conditions
+
+		if pc.ControlGroup != nil {
+			if err := pc.ControlGroup.validate(); err != nil {
+				return fmt.Errorf("path %q: %v", key, err)
+			}
+		}
+
 		// Map old-style policies into capabilities
 		if len(pc.Policy) > 0 {
 			switch pc.Policy {
@@ -0,0 +1,177 @@
+package vault
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/cidrutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+// weekdays maps the abbreviated names of the days of the week, as used in
+// time windows, to their values
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// pathConditions restrict the capabilities of a path to requests from the
+// given networks, made within the given time windows
+type pathConditions struct {
+	CIDRs    []*net.IPNet
+	Windows  []*timeWindow
+	Location *time.Location
+}
+
+// timeWindow is a range of the time of day, in minutes since midnight, on
+// the given days of the week
+type timeWindow struct {
+	Days  [7]bool
+	Start int
+	End   int
+}
+
+// parsePathConditions parses the conditions of a path of a policy. Nil is
+// returned if the path has none.
+func parsePathConditions(cidrs, windows []string, timezone string) (*pathConditions, error) {
+	if len(cidrs) == 0 && len(windows) == 0 {
+		if timezone != "" {
+			return nil, fmt.Errorf("timezone requires allowed_times")
+		}
+		return nil, nil
+	}
+
+	conditions := &pathConditions{
+		Location: time.UTC,
+	}
+	nets, err := cidrutil.ParseCIDRs(cidrs)
+	if err != nil {
+		return nil, err
+	}
+	conditions.CIDRs = nets
+	for _, raw := range windows {
+		window, err := parseTimeWindow(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time window '%s': %v", raw, err)
+		}
+		conditions.Windows = append(conditions.Windows, window)
+	}
+	if timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone '%s': %v", timezone, err)
+		}
+		conditions.Location = location
+	}
+	return conditions, nil
+}
+
+// parseTimeWindow parses a time window such as "Mon-Fri 08:00-18:00". The
+// days are optional, and can be listed separated by commas.
+func parseTimeWindow(raw string) (*timeWindow, error) {
+	fields := strings.Fields(raw)
+	var window timeWindow
+	switch len(fields) {
+	case 1:
+		for day := range window.Days {
+			window.Days[day] = true
+		}
+	case 2:
+		for _, item := range strings.Split(fields[0], ",") {
+			bounds := strings.SplitN(item, "-", 2)
+			first, ok := weekdays[strings.ToLower(bounds[0])]
+			if !ok {
+				return nil, fmt.Errorf("unknown day '%s'", bounds[0])
+			}
+			last := first
+			if len(bounds) == 2 {
+				if last, ok = weekdays[strings.ToLower(bounds[1])]; !ok {
+					return nil, fmt.Errorf("unknown day '%s'", bounds[1])
+				}
+			}
+
+			// Ranges can wrap around the end of the week, as in Sat-Sun
+			for day := first; ; day = (day + 1) % 7 {
+				window.Days[day] = true
+				if day == last {
+					break
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("expected days and a time range")
+	}
+
+	bounds := strings.SplitN(fields[len(fields)-1], "-", 2)
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("expected a time range")
+	}
+	var err error
+	if window.Start, err = parseTimeOfDay(bounds[0]); err != nil {
+		return nil, err
+	}
+	if window.End, err = parseTimeOfDay(bounds[1]); err != nil {
+		return nil, err
+	}
+	if window.End <= window.Start {
+		return nil, fmt.Errorf("time range ends before it starts")
+	}
+	return &window, nil
+}
+
+// parseTimeOfDay parses a time of day such as "08:00", up to "24:00", into
+// minutes since midnight
+func parseTimeOfDay(raw string) (int, error) {
+	parts := strings.Split(raw, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time of day '%s'", raw)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day '%s'", raw)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day '%s'", raw)
+	}
+	result := hours*60 + minutes
+	if hours < 0 || minutes < 0 || minutes >= 60 || result > 24*60 {
+		return 0, fmt.Errorf("invalid time of day '%s'", raw)
+	}
+	return result, nil
+}
+
+// met checks if a request from the given connection, made at the given
+// time, meets the conditions. Requests without a connection do not meet
+// network conditions.
+func (c *pathConditions) met(conn *logical.Connection, now time.Time) bool {
+	if len(c.CIDRs) > 0 {
+		if conn == nil || !cidrutil.IPInCIDRs(conn.RemoteAddr, c.CIDRs) {
+			return false
+		}
+	}
+
+	if len(c.Windows) > 0 {
+		now = now.In(c.Location)
+		minute := now.Hour()*60 + now.Minute()
+		allowed := false
+		for _, window := range c.Windows {
+			if window.Days[now.Weekday()] && minute >= window.Start && minute < window.End {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
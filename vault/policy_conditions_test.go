@@ -0,0 +1,59 @@
+package vault
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPathConditions_Met(t *testing.T) {
+	conditions, err := parsePathConditions(
+		[]string{"10.0.5.0/24", "192.168.1.1/32"},
+		[]string{"Mon-Fri 08:00-18:00", "Sat,Sun 10:00-12:00"},
+		"America/New_York")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Monday, 2016-05-02 at 12:00 in New York
+	monday := time.Date(2016, 5, 2, 16, 0, 0, 0, time.UTC)
+
+	type tcase struct {
+		addr string
+		now  time.Time
+		met  bool
+	}
+	tcases := []tcase{
+		{"10.0.5.12", monday, true},
+		{"192.168.1.1", monday, true},
+		{"192.168.1.2", monday, false},
+		{"not-an-ip", monday, false},
+		{"10.0.5.12", monday.Add(-4 * time.Hour), true},
+		{"10.0.5.12", monday.Add(-5 * time.Hour), false},
+		{"10.0.5.12", monday.Add(6 * time.Hour), false},
+		{"10.0.5.12", monday.Add(-48 * time.Hour), false},
+		{"10.0.5.12", monday.Add(-49 * time.Hour), true},
+	}
+	for _, tc := range tcases {
+		conn := &logical.Connection{RemoteAddr: tc.addr}
+		if met := conditions.met(conn, tc.now); met != tc.met {
+			t.Fatalf("bad: case %#v: %v", tc, met)
+		}
+	}
+
+	if conditions.met(nil, monday) {
+		t.Fatalf("should not be met without a connection")
+	}
+}
+
+func TestParseTimeWindow(t *testing.T) {
+	window, err := parseTimeWindow("Fri-Mon 22:00-24:00")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expect := [7]bool{true, true, false, false, false, true, true}
+	if window.Days != expect || window.Start != 22*60 || window.End != 24*60 {
+		t.Fatalf("bad: %#v", window)
+	}
+}
@@ -17,6 +17,23 @@ const (
 
 	// policyCacheSize is the number of policies that are kept cached
 	policyCacheSize = 1024
+
+	// responseWrappingPolicyName is the name of the fixed policy attached
+	// to response-wrapping tokens
+	responseWrappingPolicyName = "response-wrapping"
+
+	// responseWrappingPolicy is the policy that ensures that a
+	// response-wrapping token can only read its own wrapped response and
+	// unwrap it
+	responseWrappingPolicy = `
+path "cubbyhole/response" {
+    capabilities = ["create", "read"]
+}
+
+path "sys/wrapping/unwrap" {
+    capabilities = ["update"]
+}
+`
 )
 
 // PolicyStore is used to provide durable storage of policy, and to
@@ -24,6 +41,10 @@ const (
 type PolicyStore struct {
 	view *BarrierView
 	lru  *lru.TwoQueueCache
+
+	// namespace is the namespace the policies belong to, if other than
+	// the root. The paths of its policies are relative to the namespace.
+	namespace *Namespace
 }
 
 // PolicyEntry is used to store a policy by name
@@ -79,6 +100,9 @@ func (ps *PolicyStore) SetPolicy(p *Policy) error {
 	if p.Name == "root" {
 		return fmt.Errorf("cannot update root policy")
 	}
+	if p.Name == responseWrappingPolicyName {
+		return fmt.Errorf("cannot update %s policy", responseWrappingPolicyName)
+	}
 	if p.Name == "" {
 		return fmt.Errorf("policy name missing")
 	}
@@ -115,6 +139,17 @@ func (ps *PolicyStore) GetPolicy(name string) (*Policy, error) {
 		return p, nil
 	}
 
+	// Special case the response-wrapping policy, which is fixed
+	if name == responseWrappingPolicyName {
+		p, err := Parse(responseWrappingPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s policy: %v", responseWrappingPolicyName, err)
+		}
+		p.Name = responseWrappingPolicyName
+		ps.lru.Add(p.Name, p)
+		return p, nil
+	}
+
 	// Load the policy in
 	out, err := ps.view.Get(name)
 	if err != nil {
@@ -174,6 +209,9 @@ func (ps *PolicyStore) DeletePolicy(name string) error {
 	if name == "default" {
 		return fmt.Errorf("cannot delete default policy")
 	}
+	if name == responseWrappingPolicyName {
+		return fmt.Errorf("cannot delete %s policy", responseWrappingPolicyName)
+	}
 	if err := ps.view.Delete(name); err != nil {
 		return fmt.Errorf("failed to delete policy: %v", err)
 	}
@@ -184,8 +222,14 @@ func (ps *PolicyStore) DeletePolicy(name string) error {
 }
 
 // ACL is used to return an ACL which is built using the
-// named policies.
+// named policies. Templated paths of the policies grant nothing.
 func (ps *PolicyStore) ACL(names ...string) (*ACL, error) {
+	return ps.templatedACL(nil, names...)
+}
+
+// templatedACL is used to return an ACL which is built using the named
+// policies, with their templated paths rendered using the given data
+func (ps *PolicyStore) templatedACL(data *templateData, names ...string) (*ACL, error) {
 	// Fetch the policies
 	var policy []*Policy
 	for _, name := range names {
@@ -193,7 +237,14 @@ func (ps *PolicyStore) ACL(names ...string) (*ACL, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to get policy '%s': %v", name, err)
 		}
-		policy = append(policy, p)
+		policy = append(policy, p.rendered(data))
+	}
+
+	// The paths of the policies of a namespace are relative to it
+	if ps.namespace != nil {
+		for i, p := range policy {
+			policy[i] = p.prefixed(ps.namespace.Path)
+		}
 	}
 
 	// Construct the ACL
@@ -201,6 +252,9 @@ func (ps *PolicyStore) ACL(names ...string) (*ACL, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to construct ACL: %v", err)
 	}
+	if ps.namespace != nil {
+		acl.namespacePath = ps.namespace.Path
+	}
 	return acl, nil
 }
 
@@ -225,6 +279,18 @@ path "cubbyhole/*" {
 path "cubbyhole" {
     capabilities = ["list"]
 }
+
+path "sys/wrapping/lookup" {
+    capabilities = ["update"]
+}
+
+path "sys/wrapping/unwrap" {
+    capabilities = ["update"]
+}
+
+path "sys/wrapping/rewrap" {
+    capabilities = ["update"]
+}
 `)
 	if err != nil {
 		return errwrap.Wrapf("error parsing default policy: {{err}}", err)
@@ -0,0 +1,155 @@
+package vault
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	templateOpen  = "{{"
+	templateClose = "}}"
+)
+
+// templateData holds the values the templated paths of policies are
+// rendered with: the token the ACL is built for, and its entity if any
+type templateData struct {
+	Token  *TokenEntry
+	Entity *Entity
+}
+
+// templateVariables splits a templated path into its variables, returning
+// an error if a template is malformed or names an unknown variable
+func templateVariables(path string) ([]string, error) {
+	var result []string
+	for {
+		start := strings.Index(path, templateOpen)
+		if start < 0 {
+			if strings.Contains(path, templateClose) {
+				return nil, fmt.Errorf("unopened template")
+			}
+			return result, nil
+		}
+		end := strings.Index(path[start:], templateClose)
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed template")
+		}
+
+		name := strings.TrimSpace(path[start+len(templateOpen) : start+end])
+		if !validTemplateVariable(name) {
+			return nil, fmt.Errorf("invalid template variable '%s'", name)
+		}
+		result = append(result, name)
+		path = path[start+end+len(templateClose):]
+	}
+}
+
+// validTemplateVariable checks if the name is one of the variables
+// templates can refer to
+func validTemplateVariable(name string) bool {
+	switch name {
+	case "token.display_name", "token.accessor", "entity.id", "entity.name":
+		return true
+	}
+	for _, prefix := range []string{"token.meta.", "entity.metadata."} {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			return true
+		}
+	}
+	if strings.HasPrefix(name, "entity.aliases.") && strings.HasSuffix(name, ".name") {
+		return len(name) > len("entity.aliases..name")
+	}
+	return false
+}
+
+// lookup returns the value of the template variable. Variables without a
+// value, or with a value that would reach beyond a single path segment,
+// cannot be used.
+func (d *templateData) lookup(name string) (string, bool) {
+	if d == nil {
+		return "", false
+	}
+
+	var value string
+	switch {
+	case d.Token != nil && name == "token.display_name":
+		value = d.Token.DisplayName
+	case d.Token != nil && name == "token.accessor":
+		value = d.Token.Accessor
+	case d.Token != nil && strings.HasPrefix(name, "token.meta."):
+		// The metadata of tokens created through the token store is picked
+		// by whoever created them, so only login metadata is trusted
+		if d.Token.MetaFromLogin {
+			value = d.Token.Meta[strings.TrimPrefix(name, "token.meta.")]
+		}
+	case d.Entity != nil && name == "entity.id":
+		value = d.Entity.ID
+	case d.Entity != nil && name == "entity.name":
+		value = d.Entity.Name
+	case d.Entity != nil && strings.HasPrefix(name, "entity.metadata."):
+		value = d.Entity.Metadata[strings.TrimPrefix(name, "entity.metadata.")]
+	case d.Entity != nil && strings.HasPrefix(name, "entity.aliases."):
+		accessor := strings.TrimSuffix(strings.TrimPrefix(name, "entity.aliases."), ".name")
+		for _, alias := range d.Entity.Aliases {
+			if alias.MountAccessor == accessor {
+				value = alias.Name
+			}
+		}
+	}
+
+	if value == "" || strings.ContainsAny(value, "/*") || strings.Contains(value, templateOpen) {
+		return "", false
+	}
+	return value, true
+}
+
+// renderTemplate substitutes the variables of a templated path. False is
+// returned if a variable has no usable value.
+func renderTemplate(path string, data *templateData) (string, bool) {
+	var result string
+	for {
+		start := strings.Index(path, templateOpen)
+		if start < 0 {
+			return result + path, true
+		}
+		end := strings.Index(path[start:], templateClose)
+		if end < 0 {
+			return "", false
+		}
+
+		name := strings.TrimSpace(path[start+len(templateOpen) : start+end])
+		value, ok := data.lookup(name)
+		if !ok {
+			return "", false
+		}
+		result += path[:start] + value
+		path = path[start+end+len(templateClose):]
+	}
+}
+
+// rendered returns a copy of the policy with its templated paths rendered
+// with the given data. Paths whose templates cannot be rendered are left
+// out, so they grant nothing.
+func (p *Policy) rendered(data *templateData) *Policy {
+	if p == nil || !p.Templated {
+		return p
+	}
+
+	out := &Policy{
+		Name: p.Name,
+		Raw:  p.Raw,
+	}
+	for _, pc := range p.Paths {
+		if !strings.Contains(pc.Prefix, templateOpen) {
+			out.Paths = append(out.Paths, pc)
+			continue
+		}
+		prefix, ok := renderTemplate(pc.Prefix, data)
+		if !ok {
+			continue
+		}
+		clone := *pc
+		clone.Prefix = prefix
+		out.Paths = append(out.Paths, &clone)
+	}
+	return out
+}
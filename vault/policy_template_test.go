@@ -0,0 +1,174 @@
+package vault
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPolicy_ParseTemplated(t *testing.T) {
+	p, err := Parse(strings.TrimSpace(`
+path "secret/users/{{token.meta.username}}/*" {
+	policy = "write"
+}
+path "secret/shared/*" {
+	policy = "read"
+}
+`))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !p.Templated {
+		t.Fatalf("bad: %#v", p)
+	}
+
+	for _, path := range []string{
+		"secret/{{token.id}}",
+		"secret/{{token.meta.}}",
+		"secret/{{entity.aliases.name}}",
+		"secret/{{token.display_name",
+		"secret/token.display_name}}",
+	} {
+		_, err := Parse(`path "` + path + `" { policy = "read" }`)
+		if err == nil {
+			t.Fatalf("should fail: %s", path)
+		}
+	}
+}
+
+func TestPolicy_Rendered(t *testing.T) {
+	p, err := Parse(strings.TrimSpace(`
+path "secret/users/{{token.meta.username}}/*" {
+	policy = "write"
+}
+path "secret/display/{{ token.display_name }}" {
+	policy = "read"
+}
+path "secret/entities/{{entity.name}}/{{entity.aliases.auth_userpass_1234.name}}" {
+	policy = "read"
+}
+path "secret/shared/*" {
+	policy = "read"
+}
+`))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	data := &templateData{
+		Token: &TokenEntry{
+			DisplayName:   "userpass-armon",
+			Meta:          map[string]string{"username": "armon"},
+			MetaFromLogin: true,
+		},
+		Entity: &Entity{
+			Name: "armon",
+			Aliases: []*Alias{
+				&Alias{MountAccessor: "auth_userpass_1234", Name: "armon"},
+			},
+		},
+	}
+	var prefixes []string
+	for _, pc := range p.rendered(data).Paths {
+		prefixes = append(prefixes, pc.Prefix)
+	}
+	expect := []string{
+		"secret/users/armon/",
+		"secret/display/userpass-armon",
+		"secret/entities/armon/armon",
+		"secret/shared/",
+	}
+	if !reflect.DeepEqual(prefixes, expect) {
+		t.Fatalf("bad: %#v", prefixes)
+	}
+
+	// Paths without a usable value for their templates grant nothing
+	data.Token.Meta["username"] = "armon/../other"
+	data.Entity = nil
+	prefixes = nil
+	for _, pc := range p.rendered(data).Paths {
+		prefixes = append(prefixes, pc.Prefix)
+	}
+	expect = []string{
+		"secret/display/userpass-armon",
+		"secret/shared/",
+	}
+	if !reflect.DeepEqual(prefixes, expect) {
+		t.Fatalf("bad: %#v", prefixes)
+	}
+}
+
+func TestCore_TemplatedPolicy(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/policy/users")
+	req.ClientToken = root
+	req.Data["rules"] = `
+path "secret/users/{{token.meta.username}}/*" { policy = "write" }
+path "auth/token/create" { policy = "write" }
+`
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	noop := &NoopBackend{Login: []string{"login"}}
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return noop, nil
+	}
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/auth/foo")
+	req.ClientToken = root
+	req.Data["type"] = "noop"
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A single policy covers every user
+	tokens := make(map[string]string)
+	for _, username := range []string{"armon", "mitchellh"} {
+		noop.Response = &logical.Response{
+			Auth: &logical.Auth{
+				Policies: []string{"users"},
+				Metadata: map[string]string{"username": username},
+			},
+		}
+		resp, err := c.HandleRequest(&logical.Request{Path: "auth/foo/login"})
+		if err != nil {
+			t.Fatalf("err: %v %v", err, resp)
+		}
+		tokens[username] = resp.Auth.ClientToken
+	}
+
+	for username, token := range tokens {
+		for other := range tokens {
+			testTemplatedPolicyAllowed(t, c, token, "secret/users/"+other+"/foo", other == username)
+		}
+	}
+
+	// The metadata of child tokens is picked by their creator, so it does
+	// not reach the templates
+	req = logical.TestRequest(t, logical.UpdateOperation, "auth/token/create")
+	req.ClientToken = tokens["armon"]
+	req.Data["policies"] = []string{"users"}
+	req.Data["meta"] = map[string]string{"username": "mitchellh"}
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	for username := range tokens {
+		testTemplatedPolicyAllowed(t, c, resp.Auth.ClientToken, "secret/users/"+username+"/foo", false)
+	}
+}
+
+// testTemplatedPolicyAllowed checks whether the token is granted any
+// capability on the path
+func testTemplatedPolicyAllowed(t *testing.T, c *Core, token, path string, expected bool) {
+	caps, err := c.Capabilities(token, path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if allowed := caps[0] != DenyCapability; allowed != expected {
+		t.Fatalf("%s: bad: %v", path, caps)
+	}
+}
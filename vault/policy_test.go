@@ -52,7 +52,7 @@ func TestPolicy_Parse(t *testing.T) {
 		&PathCapabilities{"", "deny",
 			[]string{
 				"deny",
-			}, DenyCapabilityInt, true},
+			}, DenyCapabilityInt, true, nil, nil, nil, nil, nil, "", nil, nil},
 		&PathCapabilities{"stage/", "sudo",
 			[]string{
 				"create",
@@ -62,28 +62,114 @@ func TestPolicy_Parse(t *testing.T) {
 				"list",
 				"sudo",
 			}, CreateCapabilityInt | ReadCapabilityInt | UpdateCapabilityInt |
-				DeleteCapabilityInt | ListCapabilityInt | SudoCapabilityInt, true},
+				DeleteCapabilityInt | ListCapabilityInt | SudoCapabilityInt, true, nil, nil, nil, nil, nil, "", nil, nil},
 		&PathCapabilities{"prod/version", "read",
 			[]string{
 				"read",
 				"list",
-			}, ReadCapabilityInt | ListCapabilityInt, false},
+			}, ReadCapabilityInt | ListCapabilityInt, false, nil, nil, nil, nil, nil, "", nil, nil},
 		&PathCapabilities{"foo/bar", "read",
 			[]string{
 				"read",
 				"list",
-			}, ReadCapabilityInt | ListCapabilityInt, false},
+			}, ReadCapabilityInt | ListCapabilityInt, false, nil, nil, nil, nil, nil, "", nil, nil},
 		&PathCapabilities{"foo/bar", "",
 			[]string{
 				"create",
 				"sudo",
-			}, CreateCapabilityInt | SudoCapabilityInt, false},
+			}, CreateCapabilityInt | SudoCapabilityInt, false, nil, nil, nil, nil, nil, "", nil, nil},
 	}
 	if !reflect.DeepEqual(p.Paths, expect) {
 		t.Errorf("expected \n\n%#v\n\n to be \n\n%#v\n\n", p.Paths, expect)
 	}
 }
 
+func TestPolicy_ParseParameters(t *testing.T) {
+	p, err := Parse(strings.TrimSpace(`
+path "pki/issue/web" {
+	capabilities = ["update"]
+	allowed_parameters = {
+		"common_name" = ["*.internal"]
+		"ttl" = []
+	}
+	denied_parameters = {
+		"format" = ["der"]
+	}
+	required_parameters = ["common_name"]
+}
+`))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expect := []*PathCapabilities{
+		&PathCapabilities{"pki/issue/web", "",
+			[]string{
+				"update",
+			}, UpdateCapabilityInt, false,
+			map[string][]string{
+				"common_name": []string{"*.internal"},
+				"ttl":         []string{},
+			},
+			map[string][]string{
+				"format": []string{"der"},
+			},
+			[]string{"common_name"},
+			nil, nil, "", nil, nil,
+		},
+	}
+	if !reflect.DeepEqual(p.Paths, expect) {
+		t.Errorf("expected \n\n%#v\n\n to be \n\n%#v\n\n", p.Paths, expect)
+	}
+}
+
+func TestPolicy_ParseConditions(t *testing.T) {
+	p, err := Parse(strings.TrimSpace(`
+path "sys/raw/*" {
+	capabilities = ["read"]
+	allowed_cidrs = ["10.0.5.0/24"]
+	allowed_times = ["Mon-Fri 08:00-18:00"]
+	timezone = "America/New_York"
+}
+path "sys/mounts" {
+	capabilities = ["read"]
+}
+`))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	conditions := p.Paths[0].Conditions
+	if conditions == nil || len(conditions.CIDRs) != 1 || len(conditions.Windows) != 1 {
+		t.Fatalf("bad: %#v", conditions)
+	}
+	if conditions.CIDRs[0].String() != "10.0.5.0/24" || conditions.Location.String() != "America/New_York" {
+		t.Fatalf("bad: %#v", conditions)
+	}
+	if p.Paths[1].Conditions != nil {
+		t.Fatalf("bad: %#v", p.Paths[1].Conditions)
+	}
+
+	for _, rules := range []string{
+		`allowed_cidrs = ["10.0.5.0"]`,
+		`allowed_times = ["Mon-Fri"]`,
+		`allowed_times = ["Mon-Foo 08:00-18:00"]`,
+		`allowed_times = ["18:00-08:00"]`,
+		`allowed_times = ["08:00-25:00"]`,
+		`allowed_times = ["08:00-18:00"]
+	timezone = "Nowhere/Special"`,
+		`timezone = "UTC"`,
+	} {
+		_, err := Parse(`path "sys/raw/*" {
+	capabilities = ["read"]
+	` + rules + `
+}`)
+		if err == nil {
+			t.Fatalf("should fail: %s", rules)
+		}
+	}
+}
+
 func TestPolicy_ParseBadRoot(t *testing.T) {
 	_, err := Parse(strings.TrimSpace(`
 name = "test"
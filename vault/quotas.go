@@ -0,0 +1,563 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// rateLimitQuotaSubPath is the sub-path used for the rate limit quotas
+	// within the system view
+	rateLimitQuotaSubPath = "quotas/rate-limit/"
+
+	// leaseCountQuotaSubPath is the sub-path used for the lease count
+	// quotas within the system view
+	leaseCountQuotaSubPath = "quotas/lease-count/"
+
+	// rateLimitStateSubPath is the sub-path used for the buckets of the
+	// clients of the rate limit quotas within the system view
+	rateLimitStateSubPath = "quotas/rate-limit-state/"
+
+	// rateLimitPurgeInterval is how often the buckets of clients that
+	// have not made requests for a while are removed
+	rateLimitPurgeInterval = time.Minute
+
+	// rateLimitPersistInterval is how often the buckets of the clients
+	// are persisted, so that they survive a restart or a failover
+	rateLimitPersistInterval = 10 * time.Second
+)
+
+// RateLimitError is returned for requests exceeding a rate limit quota. It
+// is served with the 429 status code.
+type RateLimitError struct {
+	Quota      string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("request rate limit quota %q exceeded", e.Quota)
+}
+
+func (e *RateLimitError) Code() int {
+	return 429
+}
+
+// RateLimitQuota limits the rate of the requests made by each client, told
+// apart by their IP address or token, to the given path or, if the path is
+// empty, to the whole of Vault. Each client has a bucket of Burst requests
+// which is refilled at Rate requests per Interval.
+type RateLimitQuota struct {
+	Name     string        `json:"name"`
+	Path     string        `json:"path"`
+	Rate     int           `json:"rate"`
+	Interval time.Duration `json:"interval"`
+	Burst    int           `json:"burst"`
+	KeyBy    string        `json:"key_by"`
+
+	lock       sync.Mutex
+	buckets    map[string]*rateLimitBucket
+	lastPurged time.Time
+}
+
+// rateLimitBucket holds the requests a client can still make
+type rateLimitBucket struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+}
+
+// validate checks the settings of the quota and sets its defaults
+func (q *RateLimitQuota) validate() error {
+	if q.Rate <= 0 {
+		return fmt.Errorf("rate must be positive")
+	}
+	if q.Interval == 0 {
+		q.Interval = time.Second
+	}
+	if q.Interval < 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	if q.Burst == 0 {
+		q.Burst = q.Rate
+	}
+	if q.Burst < 0 {
+		return fmt.Errorf("burst must be positive")
+	}
+	switch q.KeyBy {
+	case "":
+		q.KeyBy = "ip"
+	case "ip", "token":
+	default:
+		return fmt.Errorf("key_by must be 'ip' or 'token'")
+	}
+	return nil
+}
+
+// refill returns the requests per second the buckets are refilled with
+func (q *RateLimitQuota) refill() float64 {
+	return float64(q.Rate) / q.Interval.Seconds()
+}
+
+// allow takes a request from the bucket of the client with the given key.
+// If the bucket is empty, the time until it holds a request again is
+// returned.
+func (q *RateLimitQuota) allow(key string, now time.Time) (bool, time.Duration) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.buckets == nil {
+		q.buckets = make(map[string]*rateLimitBucket)
+	}
+	if now.Sub(q.lastPurged) > rateLimitPurgeInterval {
+		q.purge(now)
+	}
+
+	bucket, ok := q.buckets[key]
+	if !ok {
+		bucket = &rateLimitBucket{Tokens: float64(q.Burst), Updated: now}
+		q.buckets[key] = bucket
+	}
+
+	bucket.Tokens = math.Min(float64(q.Burst), bucket.Tokens+now.Sub(bucket.Updated).Seconds()*q.refill())
+	bucket.Updated = now
+	if bucket.Tokens < 1 {
+		wait := (1 - bucket.Tokens) / q.refill()
+		return false, time.Duration(wait * float64(time.Second))
+	}
+	bucket.Tokens--
+	return true, 0
+}
+
+// purge removes the buckets that have been refilled completely, as these
+// are no different from new ones. The lock must be held.
+func (q *RateLimitQuota) purge(now time.Time) {
+	full := time.Duration(float64(q.Burst) / q.refill() * float64(time.Second))
+	for key, bucket := range q.buckets {
+		if now.Sub(bucket.Updated) >= full {
+			delete(q.buckets, key)
+		}
+	}
+	q.lastPurged = now
+}
+
+// snapshot returns a copy of the buckets of the clients, after purging the
+// full ones
+func (q *RateLimitQuota) snapshot(now time.Time) map[string]*rateLimitBucket {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.purge(now)
+	buckets := make(map[string]*rateLimitBucket, len(q.buckets))
+	for key, bucket := range q.buckets {
+		copied := *bucket
+		buckets[key] = &copied
+	}
+	return buckets
+}
+
+// LeaseCountQuota caps the number of leases of secrets issued under the
+// given path, or under any path if it is empty. Leases of tokens are not
+// counted.
+type LeaseCountQuota struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	MaxLeases int    `json:"max_leases"`
+
+	// count is the number of leases currently held under the path. It
+	// is protected by the quotasLock of the core.
+	count int
+}
+
+// LeaseCountError is returned for requests whose secret would exceed a
+// lease count quota. It is served with the 429 status code.
+type LeaseCountError struct {
+	Quota string
+}
+
+func (e *LeaseCountError) Error() string {
+	return fmt.Sprintf("lease count quota %q exceeded", e.Quota)
+}
+
+func (e *LeaseCountError) Code() int {
+	return 429
+}
+
+// setupQuotas loads the quotas when the vault is being unsealed. It must
+// be called after the expiration manager has been set up, as the leases
+// held under lease count quotas are counted.
+func (c *Core) setupQuotas() error {
+	rateLimitQuotas := make(map[string]*RateLimitQuota)
+	err := c.loadQuotas(rateLimitQuotaSubPath, func(value []byte) error {
+		quota := new(RateLimitQuota)
+		if err := json.Unmarshal(value, quota); err != nil {
+			return err
+		}
+		rateLimitQuotas[quota.Name] = quota
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := c.loadRateLimitState(rateLimitQuotas); err != nil {
+		return err
+	}
+
+	leaseCountQuotas := make(map[string]*LeaseCountQuota)
+	err = c.loadQuotas(leaseCountQuotaSubPath, func(value []byte) error {
+		quota := new(LeaseCountQuota)
+		if err := json.Unmarshal(value, quota); err != nil {
+			return err
+		}
+		leaseCountQuotas[quota.Name] = quota
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, quota := range leaseCountQuotas {
+		if quota.count, err = c.countLeases(quota.Path); err != nil {
+			return err
+		}
+	}
+
+	c.quotasLock.Lock()
+	c.rateLimitQuotas = rateLimitQuotas
+	c.leaseCountQuotas = leaseCountQuotas
+	c.quotasLock.Unlock()
+
+	c.quotasStopCh = make(chan struct{})
+	c.quotasDoneCh = make(chan struct{})
+	go c.persistRateLimitStateLoop(c.quotasDoneCh, c.quotasStopCh)
+	return nil
+}
+
+// loadRateLimitState restores the buckets of the clients of the quotas
+func (c *Core) loadRateLimitState(quotas map[string]*RateLimitQuota) error {
+	view := c.systemBarrierView.SubView(rateLimitStateSubPath)
+	for name, quota := range quotas {
+		entry, err := view.Get(name)
+		if err != nil {
+			return fmt.Errorf("failed to read rate limit quota state: %v", err)
+		}
+		if entry == nil {
+			continue
+		}
+		var buckets map[string]*rateLimitBucket
+		if err := entry.DecodeJSON(&buckets); err != nil {
+			return fmt.Errorf("failed to decode rate limit quota state: %v", err)
+		}
+		quota.buckets = buckets
+	}
+	return nil
+}
+
+// persistRateLimitState stores the buckets of the clients of the quotas
+func (c *Core) persistRateLimitState() error {
+	c.quotasLock.RLock()
+	defer c.quotasLock.RUnlock()
+
+	view := c.systemBarrierView.SubView(rateLimitStateSubPath)
+	now := time.Now()
+	for name, quota := range c.rateLimitQuotas {
+		entry, err := logical.StorageEntryJSON(name, quota.snapshot(now))
+		if err != nil {
+			return fmt.Errorf("failed to encode rate limit quota state: %v", err)
+		}
+		if err := view.Put(entry); err != nil {
+			return fmt.Errorf("failed to persist rate limit quota state: %v", err)
+		}
+	}
+	return nil
+}
+
+// persistRateLimitStateLoop persists the buckets of the clients of the
+// quotas periodically until stopCh is closed
+func (c *Core) persistRateLimitStateLoop(doneCh, stopCh chan struct{}) {
+	defer close(doneCh)
+	for {
+		select {
+		case <-time.After(rateLimitPersistInterval):
+			if err := c.persistRateLimitState(); err != nil {
+				c.logger.Printf("[ERR] core: %v", err)
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// loadQuotas decodes the quotas stored under the sub-path of the system
+// view with the given function
+func (c *Core) loadQuotas(subPath string, decode func([]byte) error) error {
+	view := c.systemBarrierView.SubView(subPath)
+	names, err := view.List("")
+	if err != nil {
+		return fmt.Errorf("failed to list quotas: %v", err)
+	}
+	for _, name := range names {
+		entry, err := view.Get(name)
+		if err != nil {
+			return fmt.Errorf("failed to read quota: %v", err)
+		}
+		if entry == nil {
+			continue
+		}
+		if err := decode(entry.Value); err != nil {
+			return fmt.Errorf("failed to decode quota: %v", err)
+		}
+	}
+	return nil
+}
+
+// teardownQuotas is used to reverse setupQuotas when the vault is being
+// sealed
+func (c *Core) teardownQuotas() error {
+	var result error
+	if c.quotasStopCh != nil {
+		close(c.quotasStopCh)
+		<-c.quotasDoneCh
+		c.quotasStopCh = nil
+		result = c.persistRateLimitState()
+	}
+
+	c.quotasLock.Lock()
+	c.rateLimitQuotas = nil
+	c.leaseCountQuotas = nil
+	c.quotasLock.Unlock()
+	return result
+}
+
+// validateQuotaPath checks that the path of a quota lies within a mount,
+// returning the path to use. A mount may be given without its trailing
+// slash.
+func (c *Core) validateQuotaPath(path string) (string, error) {
+	switch {
+	case path == "":
+		return path, nil
+	case c.router.MatchingMount(path+"/") == path+"/":
+		return path + "/", nil
+	case c.router.MatchingMount(path) == "":
+		return "", fmt.Errorf("no mount found at path '%s'", path)
+	}
+	return path, nil
+}
+
+// setRateLimitQuota validates and stores the quota, replacing any quota of
+// the same name. The state of the clients of a replaced quota is reset.
+func (c *Core) setRateLimitQuota(quota *RateLimitQuota) error {
+	if err := quota.validate(); err != nil {
+		return err
+	}
+
+	path, err := c.validateQuotaPath(quota.Path)
+	if err != nil {
+		return err
+	}
+	quota.Path = path
+
+	entry, err := logical.StorageEntryJSON(quota.Name, quota)
+	if err != nil {
+		return fmt.Errorf("failed to encode rate limit quota: %v", err)
+	}
+
+	c.quotasLock.Lock()
+	defer c.quotasLock.Unlock()
+	if err := c.systemBarrierView.SubView(rateLimitQuotaSubPath).Put(entry); err != nil {
+		return fmt.Errorf("failed to persist rate limit quota: %v", err)
+	}
+	if err := c.systemBarrierView.SubView(rateLimitStateSubPath).Delete(quota.Name); err != nil {
+		return fmt.Errorf("failed to reset rate limit quota state: %v", err)
+	}
+	c.rateLimitQuotas[quota.Name] = quota
+	return nil
+}
+
+// rateLimitQuota returns the quota of the given name, or nil if there is
+// none
+func (c *Core) rateLimitQuota(name string) *RateLimitQuota {
+	c.quotasLock.RLock()
+	defer c.quotasLock.RUnlock()
+	return c.rateLimitQuotas[name]
+}
+
+// deleteRateLimitQuota removes the quota of the given name
+func (c *Core) deleteRateLimitQuota(name string) error {
+	c.quotasLock.Lock()
+	defer c.quotasLock.Unlock()
+	if err := c.systemBarrierView.SubView(rateLimitQuotaSubPath).Delete(name); err != nil {
+		return fmt.Errorf("failed to delete rate limit quota: %v", err)
+	}
+	if err := c.systemBarrierView.SubView(rateLimitStateSubPath).Delete(name); err != nil {
+		return fmt.Errorf("failed to delete rate limit quota state: %v", err)
+	}
+	delete(c.rateLimitQuotas, name)
+	return nil
+}
+
+// checkRateLimitQuotas takes the request from the bucket of its client in
+// the most specific quota applying to its path, returning a RateLimitError
+// if the bucket is empty. Requests managing the quotas are exempt, so that
+// a quota can always be lifted.
+func (c *Core) checkRateLimitQuotas(req *logical.Request) error {
+	if strings.HasPrefix(req.Path, "sys/quotas/") {
+		return nil
+	}
+
+	c.quotasLock.RLock()
+	var quota *RateLimitQuota
+	for _, q := range c.rateLimitQuotas {
+		if !strings.HasPrefix(req.Path, q.Path) {
+			continue
+		}
+		if quota == nil || len(q.Path) > len(quota.Path) ||
+			(len(q.Path) == len(quota.Path) && q.Name < quota.Name) {
+			quota = q
+		}
+	}
+	c.quotasLock.RUnlock()
+	if quota == nil {
+		return nil
+	}
+
+	if ok, retryAfter := quota.allow(c.rateLimitClientKey(quota, req), time.Now()); !ok {
+		return &RateLimitError{
+			Quota:      quota.Name,
+			RetryAfter: retryAfter,
+		}
+	}
+	return nil
+}
+
+// rateLimitClientKey returns the key the bucket of the client of the
+// request is stored under. Tokens are told apart by their accessor once
+// validated, so that clients cannot get a new bucket by making up tokens.
+// Requests without a valid token are told apart by their address.
+func (c *Core) rateLimitClientKey(quota *RateLimitQuota, req *logical.Request) string {
+	if quota.KeyBy == "token" && req.ClientToken != "" {
+		te, err := c.tokenStore.Lookup(req.ClientToken)
+		if err == nil && te != nil {
+			if te.Accessor != "" {
+				return "token:" + te.Accessor
+			}
+			return "token:" + c.tokenStore.SaltID(te.ID)
+		}
+	}
+	if req.Connection != nil {
+		return "ip:" + req.Connection.RemoteAddr
+	}
+	return "ip:"
+}
+
+// setLeaseCountQuota validates and stores the quota, replacing any quota of
+// the same name. The leases already held under its path are counted.
+func (c *Core) setLeaseCountQuota(quota *LeaseCountQuota) error {
+	if quota.MaxLeases <= 0 {
+		return fmt.Errorf("max_leases must be positive")
+	}
+	path, err := c.validateQuotaPath(quota.Path)
+	if err != nil {
+		return err
+	}
+	quota.Path = path
+
+	entry, err := logical.StorageEntryJSON(quota.Name, quota)
+	if err != nil {
+		return fmt.Errorf("failed to encode lease count quota: %v", err)
+	}
+
+	c.quotasLock.Lock()
+	defer c.quotasLock.Unlock()
+	if quota.count, err = c.countLeases(quota.Path); err != nil {
+		return err
+	}
+	if err := c.systemBarrierView.SubView(leaseCountQuotaSubPath).Put(entry); err != nil {
+		return fmt.Errorf("failed to persist lease count quota: %v", err)
+	}
+	c.leaseCountQuotas[quota.Name] = quota
+	return nil
+}
+
+// leaseCountQuota returns the quota of the given name, or nil if there is
+// none. The count of leases is returned along with it.
+func (c *Core) leaseCountQuota(name string) (*LeaseCountQuota, int) {
+	c.quotasLock.RLock()
+	defer c.quotasLock.RUnlock()
+	quota, ok := c.leaseCountQuotas[name]
+	if !ok {
+		return nil, 0
+	}
+	return quota, quota.count
+}
+
+// deleteLeaseCountQuota removes the quota of the given name
+func (c *Core) deleteLeaseCountQuota(name string) error {
+	c.quotasLock.Lock()
+	defer c.quotasLock.Unlock()
+	if err := c.systemBarrierView.SubView(leaseCountQuotaSubPath).Delete(name); err != nil {
+		return fmt.Errorf("failed to delete lease count quota: %v", err)
+	}
+	delete(c.leaseCountQuotas, name)
+	return nil
+}
+
+// countLeases counts the leases of secrets held under the path
+func (c *Core) countLeases(path string) (int, error) {
+	keys, err := CollectKeys(c.expiration.idView.SubView(path))
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan for leases: %v", err)
+	}
+
+	count := 0
+	for _, key := range keys {
+		// Leases of tokens are held under the paths they were created on
+		leaseID := path + key
+		nsPath := strings.TrimPrefix(leaseID, c.namespaceByPath(leaseID).Path)
+		if !strings.HasPrefix(nsPath, credentialRoutePrefix) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// reserveLease counts a lease about to be registered for a secret issued
+// on the path against the lease count quotas of the path. A
+// LeaseCountError is returned, and nothing is counted, if any of them is
+// full.
+func (c *Core) reserveLease(path string) error {
+	c.quotasLock.Lock()
+	defer c.quotasLock.Unlock()
+
+	var quotas []*LeaseCountQuota
+	for _, quota := range c.leaseCountQuotas {
+		if !strings.HasPrefix(path, quota.Path) {
+			continue
+		}
+		if quota.count >= quota.MaxLeases {
+			return &LeaseCountError{Quota: quota.Name}
+		}
+		quotas = append(quotas, quota)
+	}
+	for _, quota := range quotas {
+		quota.count++
+	}
+	return nil
+}
+
+// releaseLease stops counting a lease of a secret against the lease count
+// quotas of its path. It is called once the lease has been revoked, or
+// could not be registered after all.
+func (c *Core) releaseLease(leaseID string) {
+	c.quotasLock.Lock()
+	defer c.quotasLock.Unlock()
+
+	for _, quota := range c.leaseCountQuotas {
+		if strings.HasPrefix(leaseID, quota.Path) && quota.count > 0 {
+			quota.count--
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestRateLimitQuota_Allow(t *testing.T) {
+	quota := &RateLimitQuota{Name: "test", Rate: 2, Interval: time.Second, Burst: 3}
+	if err := quota.validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := "ip:10.0.0.1"
+	other := "ip:10.0.0.2"
+	now := time.Now()
+
+	// The burst can be used at once
+	for i := 0; i < 3; i++ {
+		if ok, _ := quota.allow(req, now); !ok {
+			t.Fatalf("request %d refused", i)
+		}
+	}
+	ok, retryAfter := quota.allow(req, now)
+	if ok || retryAfter != 500*time.Millisecond {
+		t.Fatalf("bad: %v %v", ok, retryAfter)
+	}
+
+	// Other clients have their own bucket
+	if ok, _ := quota.allow(other, now); !ok {
+		t.Fatalf("request refused")
+	}
+
+	// The bucket is refilled at the rate
+	if ok, _ := quota.allow(req, now.Add(500*time.Millisecond)); !ok {
+		t.Fatalf("request refused")
+	}
+	if ok, _ := quota.allow(req, now.Add(500*time.Millisecond)); ok {
+		t.Fatalf("request allowed")
+	}
+
+	// Full buckets are purged
+	quota.allow(req, now.Add(2*rateLimitPurgeInterval))
+	if len(quota.buckets) != 1 {
+		t.Fatalf("bad: %#v", quota.buckets)
+	}
+}
+
+func TestRateLimitQuota_Validate(t *testing.T) {
+	quota := &RateLimitQuota{Rate: 10}
+	if err := quota.validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expect := &RateLimitQuota{Rate: 10, Interval: time.Second, Burst: 10, KeyBy: "ip"}
+	if !reflect.DeepEqual(quota, expect) {
+		t.Fatalf("bad: %#v", quota)
+	}
+
+	for _, quota := range []*RateLimitQuota{
+		&RateLimitQuota{},
+		&RateLimitQuota{Rate: 10, Interval: -time.Second},
+		&RateLimitQuota{Rate: 10, Burst: -1},
+		&RateLimitQuota{Rate: 10, KeyBy: "entity"},
+	} {
+		if err := quota.validate(); err == nil {
+			t.Fatalf("should fail: %#v", quota)
+		}
+	}
+}
+
+func TestCore_RateLimitQuota(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/rate-limit/secret")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"path":   "secret",
+		"rate":   1,
+		"key_by": "token",
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/rate-limit/nowhere")
+	req.ClientToken = root
+	req.Data["path"] = "nowhere"
+	req.Data["rate"] = 1
+	if _, err := c.HandleRequest(req); err == nil {
+		t.Fatalf("should fail")
+	}
+
+	read := func() error {
+		req := logical.TestRequest(t, logical.ReadOperation, "secret/foo")
+		req.ClientToken = root
+		_, err := c.HandleRequest(req)
+		return err
+	}
+	if err := read(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	err := read()
+	if rlErr, ok := err.(*RateLimitError); !ok || rlErr.Quota != "secret" || rlErr.RetryAfter <= 0 {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	// Other mounts are not limited
+	for i := 0; i < 3; i++ {
+		req := logical.TestRequest(t, logical.ReadOperation, "sys/mounts")
+		req.ClientToken = root
+		if _, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// The quota survives sealing
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal, err := c.Unseal(key); err != nil || !unseal {
+		t.Fatalf("err: %v", err)
+	}
+	req = logical.TestRequest(t, logical.ReadOperation, "sys/quotas/rate-limit/secret")
+	req.ClientToken = root
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expect := map[string]interface{}{
+		"name":     "secret",
+		"path":     "secret/",
+		"rate":     1,
+		"interval": int64(1),
+		"burst":    1,
+		"key_by":   "token",
+	}
+	if !reflect.DeepEqual(resp.Data, expect) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
+
+func TestCore_RateLimitQuota_keyByToken(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/rate-limit/secret")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"path":     "secret",
+		"rate":     1,
+		"interval": 3600,
+		"key_by":   "token",
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	read := func(token string) error {
+		req := logical.TestRequest(t, logical.ReadOperation, "secret/foo")
+		req.ClientToken = token
+		req.Connection = &logical.Connection{RemoteAddr: "10.0.0.1"}
+		_, err := c.HandleRequest(req)
+		return err
+	}
+	if err := read(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := read(root).(*RateLimitError); !ok {
+		t.Fatalf("should be limited")
+	}
+
+	// Made up tokens share the bucket of their address
+	if err := read("foo"); err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := read("bar").(*RateLimitError); !ok {
+		t.Fatalf("should be limited")
+	}
+
+	// The buckets survive sealing
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal, err := c.Unseal(key); err != nil || !unseal {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := read(root).(*RateLimitError); !ok {
+		t.Fatalf("should be limited")
+	}
+}
+
+func TestCore_LeaseCountQuota(t *testing.T) {
+	noop := &NoopBackend{
+		Response: &logical.Response{
+			Secret: &logical.Secret{
+				LeaseOptions: logical.LeaseOptions{
+					TTL: time.Hour,
+				},
+			},
+			Data: map[string]interface{}{},
+		},
+	}
+	c, key, root := TestCoreUnsealed(t)
+	c.logicalBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return noop, nil
+	}
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/mounts/foo")
+	req.Data["type"] = "noop"
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/lease-count/foo")
+	req.ClientToken = root
+	req.Data["path"] = "foo"
+	req.Data["max_leases"] = 2
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	read := func() (string, error) {
+		req := logical.TestRequest(t, logical.ReadOperation, "foo/bar")
+		req.ClientToken = root
+		resp, err := c.HandleRequest(req)
+		if err != nil {
+			return "", err
+		}
+		return resp.Secret.LeaseID, nil
+	}
+	leaseID, err := read()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := read(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The secret exceeding the quota is revoked rather than handed out
+	_, err = read()
+	if lcErr, ok := err.(*LeaseCountError); !ok || lcErr.Quota != "foo" {
+		t.Fatalf("bad: %#v", err)
+	}
+	last := noop.Requests[len(noop.Requests)-1]
+	if last.Operation != logical.RevokeOperation || last.Path != "bar" {
+		t.Fatalf("bad: %#v", last)
+	}
+
+	// Revoking a lease makes room for another
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/revoke/"+leaseID)
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := read(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The leases are counted again after unsealing
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal, err := c.Unseal(key); err != nil || !unseal {
+		t.Fatalf("err: %v", err)
+	}
+	req = logical.TestRequest(t, logical.ReadOperation, "sys/quotas/lease-count/foo")
+	req.ClientToken = root
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expect := map[string]interface{}{
+		"name":       "foo",
+		"path":       "foo/",
+		"max_leases": 2,
+		"count":      2,
+	}
+	if !reflect.DeepEqual(resp.Data, expect) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
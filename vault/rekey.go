@@ -58,6 +58,9 @@ func (c *Core) RekeyThreshold(recovery bool) (int, error) {
 		config, err = c.seal.RecoveryConfig()
 	} else {
 		config, err = c.seal.BarrierConfig()
+		if err == nil && c.barrierKeysStored(config) {
+			config, err = c.seal.RecoveryConfig()
+		}
 	}
 	if err != nil {
 		return 0, err
@@ -66,6 +69,14 @@ func (c *Core) RekeyThreshold(recovery bool) (int, error) {
 	return config.SecretThreshold, nil
 }
 
+// barrierKeysStored returns whether the seal holds every unseal key. Nobody
+// holds a share of the master key then, so the recovery key authorizes
+// barrier rekeys instead.
+func (c *Core) barrierKeysStored(config *SealConfig) bool {
+	return c.seal.RecoveryKeySupported() && config != nil &&
+		config.StoredShares > 0 && config.StoredShares == config.SecretShares
+}
+
 // RekeyProgress is used to return the rekey progress (num shares)
 func (c *Core) RekeyProgress(recovery bool) (int, error) {
 	c.stateLock.RLock()
@@ -267,6 +278,14 @@ func (c *Core) BarrierRekeyUpdate(key []byte, nonce string) (*RekeyResult, error
 		return nil, ErrNotInit
 	}
 
+	useRecovery := c.barrierKeysStored(existingConfig)
+	if useRecovery {
+		existingConfig, err = c.seal.RecoveryConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Ensure a rekey is in progress
 	if c.barrierRekeyConfig == nil {
 		return nil, fmt.Errorf("no rekey in progress")
@@ -306,9 +325,16 @@ func (c *Core) BarrierRekeyUpdate(key []byte, nonce string) (*RekeyResult, error
 		}
 	}
 
-	if err := c.barrier.VerifyMaster(masterKey); err != nil {
-		c.logger.Printf("[ERR] core: rekey aborted, master key verification failed: %v", err)
-		return nil, err
+	if useRecovery {
+		if err := c.seal.VerifyRecoveryKey(masterKey); err != nil {
+			c.logger.Printf("[ERR] core: rekey aborted, recovery key verification failed: %v", err)
+			return nil, err
+		}
+	} else {
+		if err := c.barrier.VerifyMaster(masterKey); err != nil {
+			c.logger.Printf("[ERR] core: rekey aborted, master key verification failed: %v", err)
+			return nil, err
+		}
 	}
 
 	// Generate a new master key
@@ -87,6 +87,14 @@ func (r *Router) Unmount(prefix string) error {
 	return nil
 }
 
+// Unalias is used to remove a prefix that serves a backend mounted under
+// another prefix as well. Unlike Unmount, the backend is not cleaned up.
+func (r *Router) Unalias(prefix string) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	r.root.Delete(prefix)
+}
+
 // Remount is used to change the mount location of a logical backend
 func (r *Router) Remount(src, dst string) error {
 	r.l.Lock()
@@ -222,6 +230,12 @@ func (r *Router) routeCommon(req *logical.Request, existenceCheck bool) (*logica
 		}
 	}
 
+	// The cubbyhole of a token is destroyed when it is revoked, which batch
+	// tokens never are
+	if re.mountEntry != nil && re.mountEntry.Type == "cubbyhole" && isBatchToken(req.ClientToken) {
+		return logical.ErrorResponse("batch tokens cannot use the cubbyhole"), false, false, logical.ErrInvalidRequest
+	}
+
 	// Adjust the path to exclude the routing prefix
 	original := req.Path
 	req.Path = strings.TrimPrefix(req.Path, mount)
@@ -233,11 +247,16 @@ func (r *Router) routeCommon(req *logical.Request, existenceCheck bool) (*logica
 	// Attach the storage view for the request
 	req.Storage = re.storageView
 
-	// Hash the request token unless this is the token backend
+	// Hash the request token unless this is the token backend. These are
+	// matched by type as they are also served within every namespace.
 	clientToken := req.ClientToken
-	switch {
-	case strings.HasPrefix(original, "auth/token/"):
-	case strings.HasPrefix(original, "cubbyhole/"):
+	var mountType string
+	if re.mountEntry != nil {
+		mountType = re.mountEntry.Type
+	}
+	switch mountType {
+	case "token":
+	case "cubbyhole":
 		// In order for the token store to revoke later, we need to have the same
 		// salted ID, so we double-salt what's going to the cubbyhole backend
 		req.ClientToken = re.SaltID(r.tokenStoreSalt.SaltID(req.ClientToken))
@@ -25,6 +25,11 @@ const (
 
 	// recoveryKeyPath is the path to the recovery key
 	recoveryKeyPath = "core/recovery-key"
+
+	// storedBarrierKeysPath is the path used by seals supporting stored keys
+	// to keep the barrier unseal keys, encrypted by the seal. It is outside
+	// of the barrier as it must be readable while sealed.
+	storedBarrierKeysPath = "core/stored-barrier-keys"
 )
 
 type Seal interface {
@@ -101,16 +106,64 @@ func (d *DefaultSeal) BarrierConfig() (*SealConfig, error) {
 		return nil, err
 	}
 
+	conf, err := readBarrierSealConfig(d.core, d.BarrierType())
+	if err != nil || conf == nil {
+		return nil, err
+	}
+
+	d.config = conf
+	return d.config.Clone(), nil
+}
+
+func (d *DefaultSeal) SetBarrierConfig(config *SealConfig) error {
+	if err := d.checkCore(); err != nil {
+		return err
+	}
+
+	config.Type = d.BarrierType()
+	if err := writeBarrierSealConfig(d.core, config); err != nil {
+		return err
+	}
+
+	d.config = config.Clone()
+
+	return nil
+}
+
+func (d *DefaultSeal) RecoveryType() string {
+	return "unsupported"
+}
+
+func (d *DefaultSeal) RecoveryConfig() (*SealConfig, error) {
+	return nil, fmt.Errorf("recovery not supported")
+}
+
+func (d *DefaultSeal) SetRecoveryConfig(config *SealConfig) error {
+	return fmt.Errorf("recovery not supported")
+}
+
+func (d *DefaultSeal) VerifyRecoveryKey([]byte) error {
+	return fmt.Errorf("recovery not supported")
+}
+
+func (d *DefaultSeal) SetRecoveryKey(key []byte) error {
+	return fmt.Errorf("recovery not supported")
+}
+
+// readBarrierSealConfig reads the barrier seal configuration from the
+// physical storage and checks that it was written by a seal of the given
+// type. It returns nil if Vault is not initialized.
+func readBarrierSealConfig(c *Core, sealType string) (*SealConfig, error) {
 	// Fetch the core configuration
-	pe, err := d.core.physical.Get(barrierSealConfigPath)
+	pe, err := c.physical.Get(barrierSealConfigPath)
 	if err != nil {
-		d.core.logger.Printf("[ERR] core: failed to read seal configuration: %v", err)
+		c.logger.Printf("[ERR] core: failed to read seal configuration: %v", err)
 		return nil, fmt.Errorf("failed to check seal configuration: %v", err)
 	}
 
 	// If the seal configuration is missing, we are not initialized
 	if pe == nil {
-		d.core.logger.Printf("[INFO] core: seal configuration missing, not initialized")
+		c.logger.Printf("[INFO] core: seal configuration missing, not initialized")
 		return nil, nil
 	}
 
@@ -118,37 +171,32 @@ func (d *DefaultSeal) BarrierConfig() (*SealConfig, error) {
 
 	// Decode the barrier entry
 	if err := json.Unmarshal(pe.Value, &conf); err != nil {
-		d.core.logger.Printf("[ERR] core: failed to decode seal configuration: %v", err)
+		c.logger.Printf("[ERR] core: failed to decode seal configuration: %v", err)
 		return nil, fmt.Errorf("failed to decode seal configuration: %v", err)
 	}
 
-	switch conf.Type {
-	// This case should not be valid for other types as only this is the default
-	case "":
-		conf.Type = d.BarrierType()
-	case d.BarrierType():
-	default:
-		d.core.logger.Printf("[ERR] core: barrier seal type of %s does not match loaded type of %s", conf.Type, d.BarrierType())
-		return nil, fmt.Errorf("barrier seal type of %s does not match loaded type of %s", conf.Type, d.BarrierType())
+	// Configurations written before the type was recorded always belong
+	// to the default seal
+	if conf.Type == "" {
+		conf.Type = "shamir"
+	}
+	if conf.Type != sealType {
+		c.logger.Printf("[ERR] core: barrier seal type of %s does not match loaded type of %s", conf.Type, sealType)
+		return nil, fmt.Errorf("barrier seal type of %s does not match loaded type of %s", conf.Type, sealType)
 	}
 
 	// Check for a valid seal configuration
 	if err := conf.Validate(); err != nil {
-		d.core.logger.Printf("[ERR] core: invalid seal configuration: %v", err)
+		c.logger.Printf("[ERR] core: invalid seal configuration: %v", err)
 		return nil, fmt.Errorf("seal validation failed: %v", err)
 	}
 
-	d.config = &conf
-	return d.config.Clone(), nil
+	return &conf, nil
 }
 
-func (d *DefaultSeal) SetBarrierConfig(config *SealConfig) error {
-	if err := d.checkCore(); err != nil {
-		return err
-	}
-
-	config.Type = d.BarrierType()
-
+// writeBarrierSealConfig stores the barrier seal configuration in the
+// physical storage
+func writeBarrierSealConfig(c *Core, config *SealConfig) error {
 	// Encode the seal configuration
 	buf, err := json.Marshal(config)
 	if err != nil {
@@ -161,36 +209,13 @@ func (d *DefaultSeal) SetBarrierConfig(config *SealConfig) error {
 		Value: buf,
 	}
 
-	if err := d.core.physical.Put(pe); err != nil {
-		d.core.logger.Printf("[ERR] core: failed to write seal configuration: %v", err)
+	if err := c.physical.Put(pe); err != nil {
+		c.logger.Printf("[ERR] core: failed to write seal configuration: %v", err)
 		return fmt.Errorf("failed to write seal configuration: %v", err)
 	}
-
-	d.config = config.Clone()
-
 	return nil
 }
 
-func (d *DefaultSeal) RecoveryType() string {
-	return "unsupported"
-}
-
-func (d *DefaultSeal) RecoveryConfig() (*SealConfig, error) {
-	return nil, fmt.Errorf("recovery not supported")
-}
-
-func (d *DefaultSeal) SetRecoveryConfig(config *SealConfig) error {
-	return fmt.Errorf("recovery not supported")
-}
-
-func (d *DefaultSeal) VerifyRecoveryKey([]byte) error {
-	return fmt.Errorf("recovery not supported")
-}
-
-func (d *DefaultSeal) SetRecoveryKey(key []byte) error {
-	return fmt.Errorf("recovery not supported")
-}
-
 // SealConfig is used to describe the seal configuration
 type SealConfig struct {
 	// The type, for sanity checking
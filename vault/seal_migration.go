@@ -0,0 +1,301 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/vault/shamir"
+)
+
+// setupSealMigration checks whether the master key is still protected by
+// the seal previously in use. If so, that seal is used until Vault is
+// unsealed with migration, which moves the master key to the configured
+// seal.
+func (c *Core) setupSealMigration(oldSeal Seal) error {
+	pe, err := c.physical.Get(barrierSealConfigPath)
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to read seal configuration: %v", err)
+		return fmt.Errorf("failed to check seal configuration: %v", err)
+	}
+
+	// Nothing to migrate until Vault is initialized
+	if pe == nil {
+		return nil
+	}
+
+	var conf SealConfig
+	if err := json.Unmarshal(pe.Value, &conf); err != nil {
+		c.logger.Printf("[ERR] core: failed to decode seal configuration: %v", err)
+		return fmt.Errorf("failed to decode seal configuration: %v", err)
+	}
+	if conf.Type == "" {
+		conf.Type = "shamir"
+	}
+
+	switch conf.Type {
+	case c.seal.BarrierType():
+		// Already migrated, or never used the previous seal
+		return nil
+	case oldSeal.BarrierType():
+	default:
+		return fmt.Errorf("stored seal type of %s matches neither the seal of type %s nor the previous seal of type %s",
+			conf.Type, c.seal.BarrierType(), oldSeal.BarrierType())
+	}
+
+	if c.seal.BarrierType() != "shamir" && oldSeal.BarrierType() != "shamir" {
+		return fmt.Errorf("seal migration is only supported to and from the shamir seal")
+	}
+	if oldSeal.StoredKeysSupported() && !oldSeal.RecoveryKeySupported() {
+		return fmt.Errorf("seal migration requires recovery key support from the previous seal")
+	}
+
+	oldSeal.SetCore(c)
+	c.newSeal = c.seal
+	c.seal = oldSeal
+	c.logger.Printf("[WARN] core: seal migration from %s to %s pending, unseal with migration enabled",
+		oldSeal.BarrierType(), c.newSeal.BarrierType())
+	return nil
+}
+
+// SealMigrationPending returns whether the master key is still protected by
+// the previous seal
+func (c *Core) SealMigrationPending() bool {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+	return c.newSeal != nil
+}
+
+// migrationUnlockConfig returns the configuration of the key parts used to
+// unseal with migration. These are the unseal keys when migrating from the
+// shamir seal and the recovery keys otherwise.
+func (c *Core) migrationUnlockConfig() (*SealConfig, error) {
+	if !c.seal.StoredKeysSupported() {
+		return c.seal.BarrierConfig()
+	}
+
+	// The recovery configuration is in the barrier
+	masterKey, err := c.storedMasterKey()
+	if err != nil {
+		return nil, err
+	}
+	defer memzero(masterKey)
+
+	if err := c.barrier.Unseal(masterKey); err != nil {
+		return nil, err
+	}
+	defer c.barrier.Seal()
+
+	config, err := c.seal.RecoveryConfig()
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, fmt.Errorf("recovery configuration not found")
+	}
+	return config, nil
+}
+
+// storedMasterKey recovers the master key from the keys stored by the seal
+func (c *Core) storedMasterKey() ([]byte, error) {
+	config, err := c.seal.BarrierConfig()
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, ErrNotInit
+	}
+
+	keys, err := c.seal.GetStoredKeys()
+	if err != nil {
+		c.logger.Printf("[ERR] core: fetching stored unseal keys failed: %v", err)
+		return nil, fmt.Errorf("fetching stored unseal keys failed: %v", err)
+	}
+	if len(keys) < config.SecretThreshold {
+		return nil, fmt.Errorf("seal stores %d of the %d keys required to unseal", len(keys), config.SecretThreshold)
+	}
+
+	if config.SecretThreshold == 1 {
+		return keys[0], nil
+	}
+	masterKey, err := shamir.Combine(keys[:config.SecretThreshold])
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute master key: %v", err)
+	}
+	return masterKey, nil
+}
+
+// migrateSeal moves the master key from the previous seal to the new seal
+// and leaves the barrier unsealed. The key is the master key when migrating
+// from the shamir seal and the recovery key otherwise: the unseal keys
+// become the recovery keys of the new seal and vice versa.
+//
+// The seal configuration is written last, so an interrupted migration keeps
+// Vault protected by the previous seal and can be run again with the same
+// key parts.
+func (c *Core) migrateSeal(key []byte) error {
+	oldSeal, newSeal := c.seal, c.newSeal
+
+	if !oldSeal.StoredKeysSupported() {
+		oldConfig, err := oldSeal.BarrierConfig()
+		if err != nil {
+			return err
+		}
+		if err := c.unsealMigratingToStoredKeys(newSeal, key); err != nil {
+			return err
+		}
+
+		err = c.migrateToStoredKeys(newSeal, key, &SealConfig{
+			SecretShares:    oldConfig.SecretShares,
+			SecretThreshold: oldConfig.SecretThreshold,
+		})
+		if err != nil {
+			c.barrier.Seal()
+			c.logger.Printf("[ERR] core: seal migration failed: %v", err)
+			return fmt.Errorf("seal migration failed: %v", err)
+		}
+	} else {
+		masterKey, err := c.storedMasterKey()
+		if err != nil {
+			return err
+		}
+		defer memzero(masterKey)
+
+		if err := c.barrier.Unseal(masterKey); err != nil {
+			return err
+		}
+
+		if err := c.migrateFromStoredKeys(oldSeal, newSeal, key); err != nil {
+			c.barrier.Seal()
+			c.logger.Printf("[ERR] core: seal migration failed: %v", err)
+			return fmt.Errorf("seal migration failed: %v", err)
+		}
+	}
+
+	c.seal = newSeal
+	c.newSeal = nil
+	c.logger.Printf("[INFO] core: seal migration from %s to %s complete",
+		oldSeal.BarrierType(), newSeal.BarrierType())
+	return nil
+}
+
+// unsealMigratingToStoredKeys unseals the barrier with the master key of
+// the shamir seal. If a previous migration was interrupted after rekeying
+// the barrier, the master key is the one stored by the new seal, and the
+// shamir master key is only accepted as its recovery key.
+func (c *Core) unsealMigratingToStoredKeys(newSeal Seal, masterKey []byte) error {
+	err := c.barrier.Unseal(masterKey)
+	if err != ErrBarrierInvalidKey {
+		return err
+	}
+
+	if err := newSeal.Init(); err != nil {
+		return fmt.Errorf("error initializing seal: %v", err)
+	}
+	keys, err := newSeal.GetStoredKeys()
+	if err != nil {
+		return err
+	}
+	if len(keys) != 1 {
+		return ErrBarrierInvalidKey
+	}
+	defer memzero(keys[0])
+	if err := c.barrier.Unseal(keys[0]); err != nil {
+		return err
+	}
+	if err := newSeal.VerifyRecoveryKey(masterKey); err != nil {
+		c.barrier.Seal()
+		return ErrBarrierInvalidKey
+	}
+	return nil
+}
+
+// migrateToStoredKeys moves to a seal storing keys. The barrier is rekeyed
+// with a new master key stored by the seal, and the shamir master key
+// becomes the recovery key, so the unseal key shares are now recovery key
+// shares. The previous master key alone no longer unseals the barrier.
+//
+// The new master key and the recovery key are stored before the barrier is
+// rekeyed, and the seal configuration is written last. Running the
+// migration again after it was interrupted either starts it over or, once
+// the barrier was rekeyed, only writes the seal configuration.
+func (c *Core) migrateToStoredKeys(newSeal Seal, masterKey []byte, recoveryConfig *SealConfig) error {
+	if err := newSeal.Init(); err != nil {
+		return fmt.Errorf("error initializing seal: %v", err)
+	}
+
+	if err := c.barrier.VerifyMaster(masterKey); err == nil {
+		newMasterKey, err := c.barrier.GenerateKey()
+		if err != nil {
+			return fmt.Errorf("failed to generate master key: %v", err)
+		}
+		defer memzero(newMasterKey)
+
+		if err := newSeal.SetStoredKeys([][]byte{newMasterKey}); err != nil {
+			return fmt.Errorf("failed to store keys: %v", err)
+		}
+		if err := newSeal.SetRecoveryConfig(recoveryConfig); err != nil {
+			return fmt.Errorf("recovery configuration saving failed: %v", err)
+		}
+		if err := newSeal.SetRecoveryKey(masterKey); err != nil {
+			return fmt.Errorf("recovery key saving failed: %v", err)
+		}
+		if err := c.barrier.Rekey(newMasterKey); err != nil {
+			return fmt.Errorf("failed to rekey barrier: %v", err)
+		}
+	} else if err != ErrBarrierInvalidKey {
+		return err
+	}
+
+	return newSeal.SetBarrierConfig(&SealConfig{
+		SecretShares:    1,
+		SecretThreshold: 1,
+		StoredShares:    1,
+	})
+}
+
+// migrateFromStoredKeys moves away from a seal storing keys. The barrier is
+// rekeyed with the recovery key, so the recovery key shares are now unseal
+// key shares.
+func (c *Core) migrateFromStoredKeys(oldSeal, newSeal Seal, recoveryKey []byte) error {
+	if err := oldSeal.VerifyRecoveryKey(recoveryKey); err != nil {
+		return fmt.Errorf("recovery key verification failed: %v", err)
+	}
+	recoveryConfig, err := oldSeal.RecoveryConfig()
+	if err != nil {
+		return err
+	}
+
+	// Keep the previous seal able to unseal, so that an interrupted
+	// migration can be started over
+	if err := c.barrier.VerifyMaster(recoveryKey); err != nil {
+		if err := c.barrier.Rekey(recoveryKey); err != nil {
+			return fmt.Errorf("failed to rekey barrier: %v", err)
+		}
+		if err := oldSeal.SetStoredKeys([][]byte{recoveryKey}); err != nil {
+			return fmt.Errorf("failed to store keys: %v", err)
+		}
+	}
+
+	if err := newSeal.Init(); err != nil {
+		return fmt.Errorf("error initializing seal: %v", err)
+	}
+	err = newSeal.SetBarrierConfig(&SealConfig{
+		SecretShares:    recoveryConfig.SecretShares,
+		SecretThreshold: recoveryConfig.SecretThreshold,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The previous seal is no longer in use; failing to clean up after it
+	// does not affect the migration
+	for _, path := range []string{recoverySealConfigPath, recoveryKeyPath} {
+		if err := c.barrier.Delete(path); err != nil {
+			c.logger.Printf("[WARN] core: failed to remove %s: %v", path, err)
+		}
+	}
+	if err := c.physical.Delete(storedBarrierKeysPath); err != nil {
+		c.logger.Printf("[WARN] core: failed to remove stored keys: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,388 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/physical"
+)
+
+const (
+	// transitDefaultMountPath is the default mount path of the transit
+	// backend on the remote Vault
+	transitDefaultMountPath = "transit"
+)
+
+// TransitSeal is a Seal storing the barrier unseal keys encrypted by the
+// transit backend of another Vault, so that Vault can be unsealed without
+// operator involvement. Recovery keys are used in place of the unseal keys
+// for operations requiring a quorum of key holders.
+type TransitSeal struct {
+	client    *http.Client
+	address   string
+	token     string
+	mountPath string
+	keyName   string
+
+	config *SealConfig
+	core   *Core
+}
+
+// NewTransitSeal creates a transit seal from the configuration of the seal
+// block. The address and token default to the VAULT_ADDR and VAULT_TOKEN
+// environment variables.
+func NewTransitSeal(conf map[string]string) (*TransitSeal, error) {
+	keyName, ok := conf["key_name"]
+	if !ok || keyName == "" {
+		return nil, fmt.Errorf("'key_name' must be set")
+	}
+
+	mountPath := transitDefaultMountPath
+	if v, ok := conf["mount_path"]; ok && v != "" {
+		mountPath = strings.Trim(v, "/")
+	}
+
+	address := os.Getenv("VAULT_ADDR")
+	if v, ok := conf["address"]; ok && v != "" {
+		address = v
+	}
+	if address == "" {
+		return nil, fmt.Errorf("'address' must be set")
+	}
+
+	token := os.Getenv("VAULT_TOKEN")
+	if v, ok := conf["token"]; ok && v != "" {
+		token = v
+	}
+	if token == "" {
+		return nil, fmt.Errorf("'token' must be set")
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if v, ok := conf["tls_ca_cert"]; ok && v != "" {
+		pem, err := ioutil.ReadFile(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read 'tls_ca_cert': %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in 'tls_ca_cert'")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if v, ok := conf["tls_server_name"]; ok && v != "" {
+		tlsConfig.ServerName = v
+	}
+	if v, ok := conf["tls_skip_verify"]; ok && v != "" {
+		skip, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse 'tls_skip_verify': %v", err)
+		}
+		tlsConfig.InsecureSkipVerify = skip
+	}
+
+	transport := cleanhttp.DefaultPooledTransport()
+	transport.TLSClientConfig = tlsConfig
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   60 * time.Second,
+	}
+
+	return &TransitSeal{
+		client:    client,
+		address:   strings.TrimSuffix(address, "/"),
+		token:     token,
+		mountPath: mountPath,
+		keyName:   keyName,
+	}, nil
+}
+
+func (t *TransitSeal) checkCore() error {
+	if t.core == nil {
+		return fmt.Errorf("seal does not have a core set")
+	}
+	return nil
+}
+
+func (t *TransitSeal) SetCore(core *Core) {
+	t.core = core
+}
+
+func (t *TransitSeal) Init() error {
+	// Make sure the transit key is usable before anything is stored
+	if _, err := t.encrypt([]byte("init")); err != nil {
+		return fmt.Errorf("failed to encrypt with transit key %q: %v", t.keyName, err)
+	}
+	return nil
+}
+
+func (t *TransitSeal) Finalize() error {
+	return nil
+}
+
+func (t *TransitSeal) BarrierType() string {
+	return "transit"
+}
+
+func (t *TransitSeal) StoredKeysSupported() bool {
+	return true
+}
+
+func (t *TransitSeal) RecoveryKeySupported() bool {
+	return true
+}
+
+func (t *TransitSeal) SetStoredKeys(keys [][]byte) error {
+	if err := t.checkCore(); err != nil {
+		return err
+	}
+
+	buf, err := json.Marshal(keys)
+	if err != nil {
+		return fmt.Errorf("failed to encode stored keys: %v", err)
+	}
+
+	ciphertext, err := t.encrypt(buf)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt stored keys: %v", err)
+	}
+
+	pe := &physical.Entry{
+		Key:   storedBarrierKeysPath,
+		Value: []byte(ciphertext),
+	}
+	if err := t.core.physical.Put(pe); err != nil {
+		t.core.logger.Printf("[ERR] core: failed to write stored keys: %v", err)
+		return fmt.Errorf("failed to write stored keys: %v", err)
+	}
+	return nil
+}
+
+func (t *TransitSeal) GetStoredKeys() ([][]byte, error) {
+	if err := t.checkCore(); err != nil {
+		return nil, err
+	}
+
+	pe, err := t.core.physical.Get(storedBarrierKeysPath)
+	if err != nil {
+		t.core.logger.Printf("[ERR] core: failed to read stored keys: %v", err)
+		return nil, fmt.Errorf("failed to read stored keys: %v", err)
+	}
+	if pe == nil {
+		return nil, nil
+	}
+
+	buf, err := t.decrypt(string(pe.Value))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt stored keys: %v", err)
+	}
+
+	var keys [][]byte
+	if err := json.Unmarshal(buf, &keys); err != nil {
+		return nil, fmt.Errorf("failed to decode stored keys: %v", err)
+	}
+	return keys, nil
+}
+
+func (t *TransitSeal) BarrierConfig() (*SealConfig, error) {
+	if t.config != nil {
+		return t.config.Clone(), nil
+	}
+
+	if err := t.checkCore(); err != nil {
+		return nil, err
+	}
+
+	conf, err := readBarrierSealConfig(t.core, t.BarrierType())
+	if err != nil || conf == nil {
+		return nil, err
+	}
+
+	t.config = conf
+	return t.config.Clone(), nil
+}
+
+func (t *TransitSeal) SetBarrierConfig(config *SealConfig) error {
+	if err := t.checkCore(); err != nil {
+		return err
+	}
+
+	config.Type = t.BarrierType()
+	if err := writeBarrierSealConfig(t.core, config); err != nil {
+		return err
+	}
+
+	t.config = config.Clone()
+	return nil
+}
+
+func (t *TransitSeal) RecoveryType() string {
+	return "shamir"
+}
+
+func (t *TransitSeal) RecoveryConfig() (*SealConfig, error) {
+	if err := t.checkCore(); err != nil {
+		return nil, err
+	}
+
+	entry, err := t.core.barrier.Get(recoverySealConfigPath)
+	if err != nil {
+		t.core.logger.Printf("[ERR] core: failed to read recovery configuration: %v", err)
+		return nil, fmt.Errorf("failed to read recovery configuration: %v", err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var conf SealConfig
+	if err := json.Unmarshal(entry.Value, &conf); err != nil {
+		t.core.logger.Printf("[ERR] core: failed to decode recovery configuration: %v", err)
+		return nil, fmt.Errorf("failed to decode recovery configuration: %v", err)
+	}
+	return &conf, nil
+}
+
+func (t *TransitSeal) SetRecoveryConfig(config *SealConfig) error {
+	if err := t.checkCore(); err != nil {
+		return err
+	}
+
+	config.Type = t.RecoveryType()
+	buf, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to encode recovery configuration: %v", err)
+	}
+
+	entry := &Entry{
+		Key:   recoverySealConfigPath,
+		Value: buf,
+	}
+	if err := t.core.barrier.Put(entry); err != nil {
+		t.core.logger.Printf("[ERR] core: failed to write recovery configuration: %v", err)
+		return fmt.Errorf("failed to write recovery configuration: %v", err)
+	}
+	return nil
+}
+
+func (t *TransitSeal) VerifyRecoveryKey(key []byte) error {
+	if err := t.checkCore(); err != nil {
+		return err
+	}
+
+	entry, err := t.core.barrier.Get(recoveryKeyPath)
+	if err != nil {
+		t.core.logger.Printf("[ERR] core: failed to read recovery key: %v", err)
+		return fmt.Errorf("failed to read recovery key: %v", err)
+	}
+	if entry == nil {
+		return fmt.Errorf("no recovery key found")
+	}
+
+	if subtle.ConstantTimeCompare(entry.Value, key) != 1 {
+		return fmt.Errorf("recovery key verification failed")
+	}
+	return nil
+}
+
+func (t *TransitSeal) SetRecoveryKey(key []byte) error {
+	if err := t.checkCore(); err != nil {
+		return err
+	}
+
+	entry := &Entry{
+		Key:   recoveryKeyPath,
+		Value: key,
+	}
+	if err := t.core.barrier.Put(entry); err != nil {
+		t.core.logger.Printf("[ERR] core: failed to write recovery key: %v", err)
+		return fmt.Errorf("failed to write recovery key: %v", err)
+	}
+	return nil
+}
+
+// encrypt encrypts the plaintext with the transit key, returning the
+// ciphertext
+func (t *TransitSeal) encrypt(plaintext []byte) (string, error) {
+	data, err := t.transitRequest("encrypt", map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(plaintext),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, ok := data["ciphertext"].(string)
+	if !ok || ciphertext == "" {
+		return "", fmt.Errorf("no ciphertext in response from transit backend")
+	}
+	return ciphertext, nil
+}
+
+// decrypt decrypts the ciphertext with the transit key
+func (t *TransitSeal) decrypt(ciphertext string) ([]byte, error) {
+	data, err := t.transitRequest("decrypt", map[string]interface{}{
+		"ciphertext": ciphertext,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, ok := data["plaintext"].(string)
+	if !ok {
+		return nil, fmt.Errorf("no plaintext in response from transit backend")
+	}
+	return base64.StdEncoding.DecodeString(plaintext)
+}
+
+// transitRequest performs an operation with the transit key on the remote
+// Vault and returns the data of the response
+func (t *TransitSeal) transitRequest(op string, body map[string]interface{}) (map[string]interface{}, error) {
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/%s/%s", t.address, t.mountPath, op, t.keyName)
+	req, err := http.NewRequest("PUT", url, bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", t.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data   map[string]interface{} `json:"data"`
+		Errors []string               `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to decode response from transit backend: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("transit backend returned %d: %s",
+			resp.StatusCode, strings.Join(result.Errors, ", "))
+	}
+	if result.Data == nil {
+		return nil, fmt.Errorf("empty response from transit backend")
+	}
+	return result.Data, nil
+}
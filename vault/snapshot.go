@@ -0,0 +1,424 @@
+package vault
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
+)
+
+const (
+	// snapshotVersion is the version of the snapshot format
+	snapshotVersion = 1
+
+	// snapshotPath is the path used to check permissions for snapshot
+	// operations
+	snapshotPath = "sys/storage/snapshot"
+)
+
+// snapshotRecord is a single line of a snapshot. A snapshot is a gzip
+// compressed stream of JSON records: a header, one record per storage
+// entry and a manifest holding the checksum of everything before it.
+type snapshotRecord struct {
+	Header   *snapshotHeader   `json:"header,omitempty"`
+	Entry    *physical.Entry   `json:"entry,omitempty"`
+	Manifest *snapshotManifest `json:"manifest,omitempty"`
+}
+
+// snapshotHeader describes the snapshot
+type snapshotHeader struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// snapshotManifest is the trailer of a snapshot used to verify its
+// integrity
+type snapshotManifest struct {
+	Entries int    `json:"entries"`
+	SHA256  string `json:"sha256"`
+}
+
+// IsLocalStorageKey returns whether the given storage key is tied to the
+// running nodes rather than to the data. These keys are left out of
+// snapshots and storage migrations.
+func IsLocalStorageKey(key string) bool {
+	return key == coreLockPath || strings.HasPrefix(key, coreLeaderPrefix)
+}
+
+// walkStorage calls fn for every key in the physical storage under the
+// given prefix, in lexical order
+func (c *Core) walkStorage(prefix string, fn func(key string) error) error {
+	keys, err := c.physical.List(prefix)
+	if err != nil {
+		return err
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		full := prefix + key
+		if strings.HasSuffix(key, "/") {
+			if err := c.walkStorage(full, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if IsLocalStorageKey(full) {
+			continue
+		}
+		if err := fn(full); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSnapshotRequest prepares the request of a snapshot operation and
+// checks it as HandleRequest would: clients exceeding their rate limit quota
+// are refused and the request is audited. Snapshots always require root
+// privileges.
+func (c *Core) checkSnapshotRequest(req *logical.Request, op logical.Operation) (*logical.Auth, error) {
+	req.Operation = op
+	req.Path = snapshotPath
+
+	// Refuse requests of clients exceeding their rate limit quota
+	if err := c.checkRateLimitQuotas(req); err != nil {
+		return nil, err
+	}
+
+	auth, err := c.checkSnapshotPermission(req)
+
+	// Create an audit trail of the request
+	if auditErr := c.auditBroker.LogRequest(auth, req, err); auditErr != nil {
+		c.logger.Printf("[ERR] core: failed to audit request with path (%s): %v",
+			req.Path, auditErr)
+		return nil, ErrInternalError
+	}
+	return auth, err
+}
+
+// checkSnapshotPermission verifies that the token of the request may
+// perform the snapshot operation and returns its auth for the audit trail
+func (c *Core) checkSnapshotPermission(req *logical.Request) (*logical.Auth, error) {
+	acl, te, err := c.fetchACLandTokenEntry(req)
+	if err != nil {
+		return nil, err
+	}
+	if te != nil {
+		if err := c.checkBoundCIDRs(req, te); err != nil {
+			return nil, err
+		}
+		if err := c.tokenStore.UseToken(te); err != nil {
+			c.logger.Printf("[ERR] core: failed to use token: %v", err)
+			return nil, ErrInternalError
+		}
+	}
+
+	allowed, rootPrivs := acl.AllowOperation(req)
+	if !allowed || !rootPrivs {
+		return nil, logical.ErrPermissionDenied
+	}
+
+	auth := &logical.Auth{ClientToken: req.ClientToken}
+	if te != nil {
+		auth.Policies = te.Policies
+		auth.Metadata = te.Meta
+		auth.DisplayName = te.DisplayName
+	}
+	return auth, nil
+}
+
+// auditSnapshotResponse creates an audit trail of the outcome of a snapshot
+// operation. The snapshot itself is not part of the response.
+func (c *Core) auditSnapshotResponse(auth *logical.Auth, req *logical.Request, err error) error {
+	if auditErr := c.auditBroker.LogResponse(auth, req, nil, err); auditErr != nil {
+		c.logger.Printf("[ERR] core: failed to audit response (request path: %s): %v",
+			req.Path, auditErr)
+		return ErrInternalError
+	}
+	return err
+}
+
+// SnapshotSave takes a snapshot of the physical storage. The entries are
+// read as stored, so they remain encrypted by the barrier. Requests are
+// served while the snapshot is taken, so entries written meanwhile may or
+// may not be part of it. The returned reader streams the snapshot and
+// removes the temporary copy on Close.
+func (c *Core) SnapshotSave(req *logical.Request) (io.ReadCloser, error) {
+	defer metrics.MeasureSince([]string{"core", "snapshot_save"}, time.Now())
+
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+	if c.sealed {
+		return nil, ErrSealed
+	}
+	if c.standby {
+		return nil, ErrStandby
+	}
+
+	auth, err := c.checkSnapshotRequest(req, logical.ReadOperation)
+	if err != nil {
+		return nil, err
+	}
+
+	snap, err := c.saveSnapshot()
+	if err := c.auditSnapshotResponse(auth, req, err); err != nil {
+		if snap != nil {
+			snap.Close()
+		}
+		return nil, err
+	}
+	return snap, nil
+}
+
+// saveSnapshot writes a snapshot to a temporary file, ready to be read
+func (c *Core) saveSnapshot() (*snapshotFile, error) {
+	tmp, err := ioutil.TempFile("", "vault-snapshot")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.writeSnapshot(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		c.logger.Printf("[ERR] core: failed to save snapshot: %v", err)
+		return nil, err
+	}
+
+	if _, err := tmp.Seek(0, 0); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return &snapshotFile{File: tmp}, nil
+}
+
+// writeSnapshot writes all the entries of the physical storage to w
+func (c *Core) writeSnapshot(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	sum := sha256.New()
+	enc := json.NewEncoder(io.MultiWriter(gz, sum))
+
+	header := &snapshotHeader{
+		Version:   snapshotVersion,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := enc.Encode(&snapshotRecord{Header: header}); err != nil {
+		return err
+	}
+
+	count := 0
+	err := c.walkStorage("", func(key string) error {
+		entry, err := c.physical.Get(key)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return nil
+		}
+		count++
+		return enc.Encode(&snapshotRecord{Entry: entry})
+	})
+	if err != nil {
+		return err
+	}
+
+	manifest := &snapshotManifest{
+		Entries: count,
+		SHA256:  hex.EncodeToString(sum.Sum(nil)),
+	}
+	if err := json.NewEncoder(gz).Encode(&snapshotRecord{Manifest: manifest}); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	c.logger.Printf("[INFO] core: saved snapshot with %d entries", count)
+	return nil
+}
+
+// SnapshotRestore replaces the physical storage with the contents of the
+// given snapshot and seals Vault, which must then be unsealed with the keys
+// matching the snapshot. The whole snapshot is verified before storage is
+// modified. A snapshot taken with a different keyring is refused unless
+// force is set.
+func (c *Core) SnapshotRestore(req *logical.Request, r io.Reader, force bool) error {
+	defer metrics.MeasureSince([]string{"core", "snapshot_restore"}, time.Now())
+
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	if c.sealed {
+		return ErrSealed
+	}
+	if c.standby {
+		return ErrStandby
+	}
+
+	auth, err := c.checkSnapshotRequest(req, logical.UpdateOperation)
+	if err != nil {
+		return err
+	}
+
+	modified, err := c.restoreSnapshot(r, force)
+
+	// Audit before sealing, which tears down the audit backends
+	err = c.auditSnapshotResponse(auth, req, err)
+
+	// Seal even if the restore failed part way, as the in-memory state no
+	// longer matches storage
+	if modified {
+		if sealErr := c.sealInternal(); sealErr != nil && err == nil {
+			err = sealErr
+		}
+	}
+	return err
+}
+
+// restoreSnapshot verifies the snapshot and writes it to the physical
+// storage, returning whether storage was modified
+func (c *Core) restoreSnapshot(r io.Reader, force bool) (bool, error) {
+	// Keep a copy of the snapshot so that it can be verified in full
+	// before anything is written
+	tmp, err := ioutil.TempFile("", "vault-snapshot")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var keyring []byte
+	err = readSnapshot(io.TeeReader(r, tmp), func(entry *physical.Entry) error {
+		if entry.Key == keyringPath {
+			keyring = entry.Value
+		}
+		return nil
+	})
+	if err != nil {
+		return false, &StatusBadRequest{Err: fmt.Sprintf("invalid snapshot: %v", err)}
+	}
+	if keyring == nil {
+		return false, &StatusBadRequest{Err: "invalid snapshot: missing keyring"}
+	}
+	if err := c.barrier.VerifyKeyring(keyring); err != nil {
+		if !force {
+			return false, &StatusBadRequest{Err: fmt.Sprintf(
+				"snapshot was taken with a different keyring (%v); use force to restore it anyway", err)}
+		}
+		c.logger.Printf("[WARN] core: restoring snapshot with a different keyring")
+	}
+
+	if _, err := tmp.Seek(0, 0); err != nil {
+		return false, err
+	}
+
+	c.logger.Printf("[INFO] core: restoring snapshot")
+	restored := make(map[string]struct{})
+	err = readSnapshot(tmp, func(entry *physical.Entry) error {
+		if IsLocalStorageKey(entry.Key) {
+			return nil
+		}
+		restored[entry.Key] = struct{}{}
+		return c.physical.Put(entry)
+	})
+	if err == nil {
+		// Remove anything written since the snapshot was taken
+		err = c.walkStorage("", func(key string) error {
+			if _, ok := restored[key]; ok {
+				return nil
+			}
+			return c.physical.Delete(key)
+		})
+	}
+
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to restore snapshot, sealing: %v", err)
+	} else {
+		c.logger.Printf("[INFO] core: restored snapshot with %d entries, sealing", len(restored))
+	}
+	return true, err
+}
+
+// readSnapshot reads and verifies a snapshot, calling fn for each entry
+func readSnapshot(r io.Reader, fn func(*physical.Entry) error) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	sum := sha256.New()
+	buf := bufio.NewReader(gz)
+	count := 0
+	first := true
+	for {
+		line, err := buf.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return fmt.Errorf("missing manifest")
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		var record snapshotRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("failed to decode record: %v", err)
+		}
+
+		switch {
+		case first:
+			if record.Header == nil {
+				return fmt.Errorf("missing header")
+			}
+			if record.Header.Version != snapshotVersion {
+				return fmt.Errorf("unsupported snapshot version %d", record.Header.Version)
+			}
+			first = false
+
+		case record.Entry != nil:
+			count++
+			if err := fn(record.Entry); err != nil {
+				return err
+			}
+
+		case record.Manifest != nil:
+			if record.Manifest.Entries != count {
+				return fmt.Errorf("manifest lists %d entries, found %d", record.Manifest.Entries, count)
+			}
+			if record.Manifest.SHA256 != hex.EncodeToString(sum.Sum(nil)) {
+				return fmt.Errorf("checksum mismatch")
+			}
+			if _, err := buf.ReadByte(); err != io.EOF {
+				return fmt.Errorf("unexpected data after manifest")
+			}
+			return nil
+
+		default:
+			return fmt.Errorf("unexpected record")
+		}
+
+		sum.Write(line)
+	}
+}
+
+// snapshotFile is a temporary snapshot file removed on Close
+type snapshotFile struct {
+	*os.File
+}
+
+func (f *snapshotFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.File.Name())
+	return err
+}
@@ -0,0 +1,218 @@
+package vault
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/logical"
+)
+
+func testSnapshotRequest(token string) *logical.Request {
+	return &logical.Request{ClientToken: token}
+}
+
+func testCoreSnapshot(t *testing.T, c *Core, token string) []byte {
+	snap, err := c.SnapshotSave(testSnapshotRequest(token))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer snap.Close()
+
+	raw, err := ioutil.ReadAll(snap)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return raw
+}
+
+func TestCore_SnapshotRestore(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+
+	if err := c.barrier.Put(&Entry{Key: "test/foo", Value: []byte("foo")}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	snap := testCoreSnapshot(t, c, root)
+
+	// Change storage after the snapshot
+	if err := c.barrier.Put(&Entry{Key: "test/foo", Value: []byte("changed")}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.barrier.Put(&Entry{Key: "test/bar", Value: []byte("bar")}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if err := c.SnapshotRestore(testSnapshotRequest(root), bytes.NewReader(snap), false); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Restoring seals Vault
+	if sealed, _ := c.Sealed(); !sealed {
+		t.Fatalf("should be sealed")
+	}
+	if unseal, err := c.Unseal(TestKeyCopy(key)); err != nil || !unseal {
+		t.Fatalf("unseal: %v %v", unseal, err)
+	}
+
+	entry, err := c.barrier.Get("test/foo")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if entry == nil || string(entry.Value) != "foo" {
+		t.Fatalf("bad: %#v", entry)
+	}
+	entry, err = c.barrier.Get("test/bar")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if entry != nil {
+		t.Fatalf("entry written after the snapshot should be removed: %#v", entry)
+	}
+}
+
+func TestCore_SnapshotRestore_differentKeyring(t *testing.T) {
+	c1, key1, root1 := TestCoreUnsealed(t)
+	snap := testCoreSnapshot(t, c1, root1)
+
+	c2, _, root2 := TestCoreUnsealed(t)
+	err := c2.SnapshotRestore(testSnapshotRequest(root2), bytes.NewReader(snap), false)
+	if err == nil || !strings.Contains(err.Error(), "different keyring") {
+		t.Fatalf("expected keyring error, got: %v", err)
+	}
+	if sealed, _ := c2.Sealed(); sealed {
+		t.Fatalf("should not be sealed")
+	}
+
+	// Forcing the restore requires the keys of the snapshot to unseal
+	if err := c2.SnapshotRestore(testSnapshotRequest(root2), bytes.NewReader(snap), true); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal, err := c2.Unseal(TestKeyCopy(key1)); err != nil || !unseal {
+		t.Fatalf("unseal: %v %v", unseal, err)
+	}
+}
+
+func TestCore_SnapshotRestore_corrupt(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	snap := testCoreSnapshot(t, c, root)
+
+	// Tamper with an entry but keep the stream well formed
+	gz, err := gzip.NewReader(bytes.NewReader(snap))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	plain, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	plain = bytes.Replace(plain, []byte(`"Key":"core/keyring"`), []byte(`"Key":"core/keyrinG"`), 1)
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(plain)
+	w.Close()
+
+	err = c.SnapshotRestore(testSnapshotRequest(root), &buf, false)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum error, got: %v", err)
+	}
+	if sealed, _ := c.Sealed(); sealed {
+		t.Fatalf("should not be sealed")
+	}
+}
+
+func TestCore_SnapshotSave_permission(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	if _, err := c.SnapshotSave(testSnapshotRequest("invalid")); err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied, got: %v", err)
+	}
+}
+
+func TestCore_Snapshot_auditTrail(t *testing.T) {
+	noop := &NoopAudit{}
+	c, key, root := TestCoreUnsealed(t)
+	c.auditBackends["noop"] = func(config *audit.BackendConfig) (audit.Backend, error) {
+		noop = &NoopAudit{
+			Config: config,
+		}
+		return noop, nil
+	}
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/audit/noop")
+	req.Data["type"] = "noop"
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	snap := testCoreSnapshot(t, c, root)
+	if _, err := c.SnapshotSave(testSnapshotRequest("invalid")); err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied, got: %v", err)
+	}
+	if err := c.SnapshotRestore(testSnapshotRequest(root), bytes.NewReader(snap), false); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The refused save is audited without auth, the others with the token
+	if len(noop.Req) != 3 {
+		t.Fatalf("bad: %#v", noop.Req)
+	}
+	expect := []logical.Operation{logical.ReadOperation, logical.ReadOperation, logical.UpdateOperation}
+	for i, req := range noop.Req {
+		if req.Path != snapshotPath || req.Operation != expect[i] {
+			t.Fatalf("bad: %d %#v", i, req)
+		}
+	}
+	if noop.ReqAuth[0] == nil || noop.ReqAuth[0].ClientToken != root || noop.ReqErrs[0] != nil {
+		t.Fatalf("bad: %#v %v", noop.ReqAuth[0], noop.ReqErrs[0])
+	}
+	if noop.ReqAuth[1] != nil || noop.ReqErrs[1] != logical.ErrPermissionDenied {
+		t.Fatalf("bad: %#v %v", noop.ReqAuth[1], noop.ReqErrs[1])
+	}
+
+	// The response of the restore is audited before sealing; the first
+	// response is the one of enabling the backend
+	if len(noop.RespReq) != 3 {
+		t.Fatalf("bad: %#v", noop.RespReq)
+	}
+	for i, req := range noop.RespReq[1:] {
+		if req.Path != snapshotPath || noop.RespErrs[i+1] != nil {
+			t.Fatalf("bad: %d %#v %v", i, req, noop.RespErrs[i+1])
+		}
+	}
+
+	if unseal, err := c.Unseal(TestKeyCopy(key)); err != nil || !unseal {
+		t.Fatalf("unseal: %v %v", unseal, err)
+	}
+}
+
+func TestCore_Snapshot_rateLimitQuota(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/rate-limit/snapshot")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"path":   snapshotPath,
+		"rate":   1,
+		"key_by": "token",
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	testCoreSnapshot(t, c, root)
+	_, err := c.SnapshotSave(testSnapshotRequest(root))
+	if rlErr, ok := err.(*RateLimitError); !ok || rlErr.Quota != "snapshot" {
+		t.Fatalf("bad: %#v", err)
+	}
+	err = c.SnapshotRestore(testSnapshotRequest(root), bytes.NewReader(nil), false)
+	if _, ok := err.(*RateLimitError); !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+	if sealed, _ := c.Sealed(); sealed {
+		t.Fatalf("should not be sealed")
+	}
+}
@@ -0,0 +1,147 @@
+package vault
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// tidyStatus tracks the progress of a tidy operation, which walks the
+// storage of the expiration manager or the token store in the background
+// to repair or remove inconsistent entries left behind by crashes
+type tidyStatus struct {
+	lock sync.Mutex
+
+	running   bool
+	startTime time.Time
+	endTime   time.Time
+
+	// scanned is the number of entries walked so far
+	scanned int
+
+	// changes counts the entries repaired or removed by kind of change
+	changes map[string]int
+
+	// errors holds the failures to repair or remove an entry. The
+	// operation carries on past these.
+	errors []string
+}
+
+// start marks the beginning of a tidy operation, returning false if one
+// is already running
+func (s *tidyStatus) start() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.running {
+		return false
+	}
+
+	s.running = true
+	s.startTime = time.Now().UTC()
+	s.endTime = time.Time{}
+	s.scanned = 0
+	s.changes = make(map[string]int)
+	s.errors = nil
+	return true
+}
+
+// scan records that an entry has been walked
+func (s *tidyStatus) scan() {
+	s.lock.Lock()
+	s.scanned++
+	s.lock.Unlock()
+}
+
+// change records that an entry has been repaired or removed
+func (s *tidyStatus) change(kind string) {
+	s.lock.Lock()
+	s.changes[kind]++
+	s.lock.Unlock()
+}
+
+// fail records that an entry could not be repaired or removed
+func (s *tidyStatus) fail(format string, args ...interface{}) {
+	s.lock.Lock()
+	s.errors = append(s.errors, fmt.Sprintf(format, args...))
+	s.lock.Unlock()
+}
+
+// finish marks the end of the tidy operation
+func (s *tidyStatus) finish() {
+	s.lock.Lock()
+	s.running = false
+	s.endTime = time.Now().UTC()
+	s.lock.Unlock()
+}
+
+// data returns the progress of the tidy operation as response data
+func (s *tidyStatus) data() map[string]interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	state := "idle"
+	switch {
+	case s.running:
+		state = "running"
+	case !s.endTime.IsZero():
+		state = "complete"
+	}
+
+	changes := make(map[string]interface{}, len(s.changes))
+	for kind, count := range s.changes {
+		changes[kind] = count
+	}
+	data := map[string]interface{}{
+		"state":      state,
+		"start_time": nil,
+		"end_time":   nil,
+		"scanned":    s.scanned,
+		"changes":    changes,
+		"errors":     append([]string{}, s.errors...),
+	}
+	if !s.startTime.IsZero() {
+		data["start_time"] = s.startTime
+	}
+	if !s.endTime.IsZero() {
+		data["end_time"] = s.endTime
+	}
+	return data
+}
+
+// runTidy runs the tidy function in the background. Once it is done the
+// changes it made are logged, and recorded in the audit log as the
+// response to the request that started it.
+func (c *Core) runTidy(req *logical.Request, status *tidyStatus, tidy func(*tidyStatus) error) (*logical.Response, error) {
+	if !status.start() {
+		return logical.ErrorResponse("tidy operation already in progress"), logical.ErrInvalidRequest
+	}
+
+	auditReq := &logical.Request{
+		Operation:  req.Operation,
+		Path:       req.MountPoint + req.Path,
+		Connection: req.Connection,
+	}
+	go func() {
+		if err := tidy(status); err != nil {
+			status.fail("tidy aborted: %v", err)
+		}
+		status.finish()
+
+		data := status.data()
+		c.logger.Printf("[INFO] core: tidy of %s complete: scanned %d entries, changes: %v, errors: %d",
+			auditReq.Path, data["scanned"], data["changes"], len(data["errors"].([]string)))
+		resp := &logical.Response{Data: data}
+		if err := c.logAuditEvent(auditReq, resp, nil); err != nil {
+			c.logger.Printf("[ERR] core: failed to audit tidy of %s: %v", auditReq.Path, err)
+		}
+	}()
+
+	resp := &logical.Response{
+		Data: status.data(),
+	}
+	resp.AddWarning(fmt.Sprintf(
+		"Tidy operation started in the background; read %s for its progress", auditReq.Path))
+	return resp, nil
+}
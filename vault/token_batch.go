@@ -0,0 +1,150 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// tokenTypeService is the type of the tokens stored in the token store.
+	// Tokens created before token types were introduced have no type, and
+	// are service tokens.
+	tokenTypeService = "service"
+
+	// tokenTypeBatch is the type of the tokens which are never written to
+	// storage. The token itself carries the encrypted token entry.
+	tokenTypeBatch = "batch"
+
+	// batchTokenPrefix tells batch tokens apart from service tokens
+	batchTokenPrefix = "b."
+
+	// batchKeyPath is the path of the key used to encrypt batch tokens
+	batchKeyPath = "batch-key"
+)
+
+// validTokenType returns whether the given token type is known. An empty
+// type leaves the choice to the defaults.
+func validTokenType(tokenType string) bool {
+	switch tokenType {
+	case "", tokenTypeService, tokenTypeBatch:
+		return true
+	}
+	return false
+}
+
+// isBatchToken returns whether the given token ID is that of a batch token
+func isBatchToken(id string) bool {
+	return strings.HasPrefix(id, batchTokenPrefix)
+}
+
+// setupBatchKey loads the key used to encrypt batch tokens, generating it
+// on first use
+func (ts *TokenStore) setupBatchKey() error {
+	raw, err := ts.view.Get(batchKeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read batch token key: %v", err)
+	}
+
+	var key []byte
+	if raw != nil {
+		key = raw.Value
+	} else {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return fmt.Errorf("failed to generate batch token key: %v", err)
+		}
+		le := &logical.StorageEntry{Key: batchKeyPath, Value: key}
+		if err := ts.view.Put(le); err != nil {
+			return fmt.Errorf("failed to persist batch token key: %v", err)
+		}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("failed to setup batch token cipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("failed to setup batch token cipher: %v", err)
+	}
+	ts.batchAEAD = gcm
+	return nil
+}
+
+// createBatch assigns the entry a batch token, which holds the entry
+// encrypted. Nothing is written to storage.
+func (ts *TokenStore) createBatch(entry *TokenEntry) error {
+	defer metrics.MeasureSince([]string{"token", "create-batch"}, time.Now())
+
+	switch {
+	case entry.ID != "":
+		return fmt.Errorf("batch tokens cannot have a specified ID")
+	case entry.NumUses > 0:
+		return fmt.Errorf("batch tokens cannot have a use limit")
+	case entry.TTL == 0:
+		return fmt.Errorf("batch tokens must have a TTL")
+	}
+
+	plaintext, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode entry: %v", err)
+	}
+
+	nonce := make([]byte, ts.batchAEAD.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	sealed := ts.batchAEAD.Seal(nonce, nonce, plaintext, nil)
+
+	entry.ID = batchTokenPrefix + base64.RawURLEncoding.EncodeToString(sealed)
+	return nil
+}
+
+// lookupBatch decrypts the entry held by a batch token. Nil is returned
+// for tokens which do not decrypt, which have expired, or whose parent
+// has been revoked.
+func (ts *TokenStore) lookupBatch(id string) (*TokenEntry, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(id, batchTokenPrefix))
+	if err != nil {
+		return nil, nil
+	}
+	nonceSize := ts.batchAEAD.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, nil
+	}
+	plaintext, err := ts.batchAEAD.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return nil, nil
+	}
+
+	entry := new(TokenEntry)
+	if err := json.Unmarshal(plaintext, entry); err != nil {
+		return nil, fmt.Errorf("failed to decode entry: %v", err)
+	}
+	entry.ID = id
+
+	// Batch tokens cannot be revoked on their own, so they are only valid
+	// until they expire or their parent is revoked
+	if time.Now().After(time.Unix(entry.CreationTime, 0).Add(entry.TTL)) {
+		return nil, nil
+	}
+	if entry.Parent != "" {
+		parent, err := ts.Lookup(entry.Parent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lookup parent: %v", err)
+		}
+		if parent == nil {
+			return nil, nil
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,272 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// revocationPrefix is the prefix used to store the token tree
+	// revocation jobs, so they resume after a seal or leader failover
+	revocationPrefix = "revocation/"
+
+	// revocationCheckpointInterval is the number of tokens revoked between
+	// two checkpoints of the progress of a job
+	revocationCheckpointInterval = 100
+
+	// revocationRetryDelay is how long a job running in the background
+	// waits before resuming after a failure
+	revocationRetryDelay = 30 * time.Second
+)
+
+// errRevocationStopped is returned by jobs interrupted by a seal
+var errRevocationStopped = fmt.Errorf("token revocation stopped")
+
+// revocationJob revokes a token and all of its descendants. The tree is
+// walked iteratively, children before their parent, so the job can pick up
+// from storage wherever it was interrupted: revoked tokens are gone from
+// the parent index, and the root is only revoked last.
+type revocationJob struct {
+	ID        string    `json:"id"`
+	Root      string    `json:"root"` // Salted ID of the root of the tree
+	StartTime time.Time `json:"start_time"`
+	Revoked   int       `json:"revoked"` // Tokens revoked as of the last checkpoint
+
+	// running is set while the job is being run, guarded by the
+	// revocationLock of the token store
+	running bool
+}
+
+// startRevocation persists a revocation job for the tree rooted at the
+// given salted token ID, returning the existing job if there is one. From
+// then on the tree is hidden from lookups.
+func (ts *TokenStore) startRevocation(saltedId string) (*revocationJob, error) {
+	ts.revocationLock.Lock()
+	defer ts.revocationLock.Unlock()
+	if job, ok := ts.revocations[saltedId]; ok {
+		return job, nil
+	}
+
+	jobID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	job := &revocationJob{
+		ID:        jobID,
+		Root:      saltedId,
+		StartTime: time.Now().UTC(),
+	}
+	if err := ts.persistRevocation(job); err != nil {
+		return nil, err
+	}
+	ts.revocations[saltedId] = job
+	return job, nil
+}
+
+// persistRevocation checkpoints the progress of the job
+func (ts *TokenStore) persistRevocation(job *revocationJob) error {
+	buf, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode revocation job: %v", err)
+	}
+	le := &logical.StorageEntry{Key: revocationPrefix + job.ID, Value: buf}
+	if err := ts.view.Put(le); err != nil {
+		return fmt.Errorf("failed to persist revocation job: %v", err)
+	}
+	return nil
+}
+
+// runRevocation runs the job to completion. If it is already running, as
+// when revoking the leases of a token leads back to revoking its tree, the
+// revocation is left to that run.
+func (ts *TokenStore) runRevocation(job *revocationJob) error {
+	defer metrics.MeasureSince([]string{"token", "revoke-tree"}, time.Now())
+
+	ts.revocationLock.Lock()
+	if job.running {
+		ts.revocationLock.Unlock()
+		return nil
+	}
+	job.running = true
+	ts.revocationLock.Unlock()
+	defer func() {
+		ts.revocationLock.Lock()
+		job.running = false
+		ts.revocationLock.Unlock()
+	}()
+
+	type node struct {
+		saltedId string
+		parent   string
+	}
+	stack := []node{{saltedId: job.Root}}
+	for len(stack) > 0 {
+		select {
+		case <-ts.revocationStopCh:
+			return errRevocationStopped
+		default:
+		}
+
+		// Revoke the children of the token first
+		n := stack[len(stack)-1]
+		children, err := ts.view.List(parentPrefix + n.saltedId + "/")
+		if err != nil {
+			return fmt.Errorf("failed to scan for children: %v", err)
+		}
+		if len(children) > 0 {
+			for _, child := range children {
+				stack = append(stack, node{saltedId: child, parent: n.saltedId})
+			}
+			continue
+		}
+
+		if err := ts.revokeSalted(n.saltedId); err != nil {
+			return fmt.Errorf("failed to revoke entry: %v", err)
+		}
+
+		// Clear the parent index, in case it outlived the token
+		if n.parent != "" {
+			if err := ts.view.Delete(parentPrefix + n.parent + "/" + n.saltedId); err != nil {
+				return fmt.Errorf("failed to delete entry: %v", err)
+			}
+		}
+		stack = stack[:len(stack)-1]
+
+		job.Revoked++
+		if job.Revoked%revocationCheckpointInterval == 0 {
+			if err := ts.persistRevocation(job); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := ts.view.Delete(revocationPrefix + job.ID); err != nil {
+		return fmt.Errorf("failed to delete revocation job: %v", err)
+	}
+	ts.revocationLock.Lock()
+	delete(ts.revocations, job.Root)
+	ts.revocationLock.Unlock()
+	return nil
+}
+
+// runRevocationBackground runs the job in the background, retrying after
+// failures until it completes or the token store is stopped
+func (ts *TokenStore) runRevocationBackground(job *revocationJob) {
+	ts.revocationWg.Add(1)
+	go func() {
+		defer ts.revocationWg.Done()
+		for {
+			err := ts.runRevocation(job)
+			if err == nil || err == errRevocationStopped {
+				return
+			}
+			ts.core.logger.Printf("[ERR] token: revocation job %s failed, retrying in %s: %v",
+				job.ID, revocationRetryDelay, err)
+
+			select {
+			case <-ts.revocationStopCh:
+				return
+			case <-time.After(revocationRetryDelay):
+			}
+		}
+	}()
+}
+
+// lookupRevocation returns the last checkpoint of the revocation job of
+// the given ID, or nil once it is done
+func (ts *TokenStore) lookupRevocation(jobID string) (*revocationJob, error) {
+	raw, err := ts.view.Get(revocationPrefix + jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revocation job: %v", err)
+	}
+	if raw == nil {
+		return nil, nil
+	}
+	job := new(revocationJob)
+	if err := json.Unmarshal(raw.Value, job); err != nil {
+		return nil, fmt.Errorf("failed to decode revocation job: %v", err)
+	}
+	return job, nil
+}
+
+// revoking returns whether the token of the given salted ID is within a
+// tree being revoked, walking up its ancestors
+func (ts *TokenStore) revoking(saltedId string, entry *TokenEntry) (bool, error) {
+	ts.revocationLock.RLock()
+	pending := len(ts.revocations) > 0
+	ts.revocationLock.RUnlock()
+	if !pending {
+		return false, nil
+	}
+
+	for {
+		ts.revocationLock.RLock()
+		_, ok := ts.revocations[saltedId]
+		ts.revocationLock.RUnlock()
+		if ok {
+			return true, nil
+		}
+
+		// Orphans, and the children of tokens revoked alone, are not part
+		// of any tree
+		if entry.Parent == "" {
+			return false, nil
+		}
+		saltedId = ts.SaltID(entry.Parent)
+		parent, err := ts.lookupSalted(saltedId)
+		if err != nil {
+			return false, err
+		}
+		if parent == nil {
+			return false, nil
+		}
+		entry = parent
+	}
+}
+
+// resumeRevocations resumes the revocation jobs interrupted by a seal or a
+// leader failover
+func (ts *TokenStore) resumeRevocations() error {
+	ts.revocationStopCh = make(chan struct{})
+
+	jobIDs, err := ts.view.List(revocationPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to scan for revocation jobs: %v", err)
+	}
+
+	var jobs []*revocationJob
+	ts.revocationLock.Lock()
+	for _, jobID := range jobIDs {
+		job, err := ts.lookupRevocation(jobID)
+		if err != nil {
+			ts.revocationLock.Unlock()
+			return err
+		}
+		if job == nil {
+			continue
+		}
+		ts.revocations[job.Root] = job
+		jobs = append(jobs, job)
+	}
+	ts.revocationLock.Unlock()
+
+	for _, job := range jobs {
+		ts.core.logger.Printf("[INFO] token: resuming revocation job %s", job.ID)
+		ts.runRevocationBackground(job)
+	}
+	return nil
+}
+
+// stopRevocations stops the revocation jobs running in the background,
+// which resume once unsealed
+func (ts *TokenStore) stopRevocations() {
+	if ts.revocationStopCh != nil {
+		close(ts.revocationStopCh)
+	}
+	ts.revocationWg.Wait()
+}
@@ -1,16 +1,19 @@
 package vault
 
 import (
+	"crypto/cipher"
 	"encoding/json"
 	"fmt"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/armon/go-metrics"
 	"github.com/fatih/structs"
 	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/cidrutil"
 	"github.com/hashicorp/vault/helper/salt"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
@@ -55,12 +58,26 @@ type TokenStore struct {
 
 	view *BarrierView
 	salt *salt.Salt
+	core *Core
 
 	expiration *ExpirationManager
 
 	cubbyholeBackend *CubbyholeBackend
 
 	policyLookupFunc func(string) (*Policy, error)
+
+	// batchAEAD encrypts the token entries carried by batch tokens
+	batchAEAD cipher.AEAD
+
+	// tidyStatus tracks the progress of the last tidy operation
+	tidyStatus *tidyStatus
+
+	// revocations holds the running token tree revocation jobs, keyed by
+	// the salted ID of the root of their tree
+	revocationLock   sync.RWMutex
+	revocations      map[string]*revocationJob
+	revocationStopCh chan struct{}
+	revocationWg     sync.WaitGroup
 }
 
 // NewTokenStore is used to construct a token store that is
@@ -71,7 +88,10 @@ func NewTokenStore(c *Core, config *logical.BackendConfig) (*TokenStore, error)
 
 	// Initialize the store
 	t := &TokenStore{
-		view: view,
+		view:        view,
+		core:        c,
+		tidyStatus:  &tidyStatus{},
+		revocations: make(map[string]*revocationJob),
 	}
 
 	if c.policyStore != nil {
@@ -87,6 +107,11 @@ func NewTokenStore(c *Core, config *logical.BackendConfig) (*TokenStore, error)
 	}
 	t.salt = salt
 
+	// Setup the batch token key
+	if err := t.setupBatchKey(); err != nil {
+		return nil, err
+	}
+
 	// Setup the framework endpoints
 	t.Backend = &framework.Backend{
 		AuthRenew: t.authRenew,
@@ -94,6 +119,8 @@ func NewTokenStore(c *Core, config *logical.BackendConfig) (*TokenStore, error)
 		PathsSpecial: &logical.Paths{
 			Root: []string{
 				"revoke-orphan/*",
+				"accessors*",
+				"tidy",
 			},
 		},
 
@@ -140,6 +167,60 @@ func NewTokenStore(c *Core, config *logical.BackendConfig) (*TokenStore, error)
 						Default:     "",
 						Description: tokenPathSuffixHelp + pathSuffixSanitize.String(),
 					},
+
+					"token_type": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Default:     "",
+						Description: tokenTypeHelp,
+					},
+
+					"bound_cidrs": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Default:     "",
+						Description: tokenBoundCIDRsHelp,
+					},
+
+					"disallowed_policies": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Default:     "",
+						Description: tokenDisallowedPoliciesHelp,
+					},
+
+					"explicit_max_ttl": &framework.FieldSchema{
+						Type:        framework.TypeDurationSecond,
+						Default:     0,
+						Description: tokenExplicitMaxTTLHelp,
+					},
+
+					"ttl": &framework.FieldSchema{
+						Type:        framework.TypeDurationSecond,
+						Default:     0,
+						Description: tokenRoleTTLHelp,
+					},
+
+					"max_ttl": &framework.FieldSchema{
+						Type:        framework.TypeDurationSecond,
+						Default:     0,
+						Description: tokenRoleMaxTTLHelp,
+					},
+
+					"renewable": &framework.FieldSchema{
+						Type:        framework.TypeBool,
+						Default:     true,
+						Description: tokenRenewableHelp,
+					},
+
+					"allowed_entity_aliases": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Default:     "",
+						Description: tokenAllowedEntityAliasesHelp,
+					},
+
+					"allowed_metadata_keys": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Default:     "",
+						Description: tokenAllowedMetadataKeysHelp,
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -230,6 +311,39 @@ func NewTokenStore(c *Core, config *logical.BackendConfig) (*TokenStore, error)
 						Type:        framework.TypeString,
 						Description: "Accessor of the token to look up (request body)",
 					},
+					"policy": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Search for the tokens with this policy, including through their entity",
+					},
+					"role": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Search for the tokens created using this role",
+					},
+					"created_after": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Search for the tokens created at or after this RFC 3339 time",
+					},
+					"created_before": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Search for the tokens created before this RFC 3339 time",
+					},
+					"display_name_prefix": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Search for the tokens whose display name has this prefix",
+					},
+					"meta_key": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Search for the tokens with this metadata key",
+					},
+					"after": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Only return the accessors listed after this one",
+					},
+					"limit": &framework.FieldSchema{
+						Type:        framework.TypeInt,
+						Default:     0,
+						Description: "The maximum number of accessors to return, 0 for all",
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -240,6 +354,29 @@ func NewTokenStore(c *Core, config *logical.BackendConfig) (*TokenStore, error)
 				HelpDescription: strings.TrimSpace(tokenLookupAccessorHelp),
 			},
 
+			&framework.Path{
+				Pattern: "accessors/?$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"after": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "Only return the accessors listed after this one",
+					},
+					"limit": &framework.FieldSchema{
+						Type:        framework.TypeInt,
+						Default:     0,
+						Description: "The maximum number of accessors to return, 0 for all",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: t.handleListAccessors,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(tokenListAccessorsHelp),
+				HelpDescription: strings.TrimSpace(tokenListAccessorsHelp),
+			},
+
 			&framework.Path{
 				Pattern: "lookup-self$",
 
@@ -313,6 +450,24 @@ func NewTokenStore(c *Core, config *logical.BackendConfig) (*TokenStore, error)
 				HelpDescription: strings.TrimSpace(tokenRevokeHelp),
 			},
 
+			&framework.Path{
+				Pattern: "revoke-status/" + framework.GenericNameRegex("job_id"),
+
+				Fields: map[string]*framework.FieldSchema{
+					"job_id": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: "ID of the revocation job",
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: t.handleRevocationStatus,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(tokenRevokeStatusHelp),
+				HelpDescription: strings.TrimSpace(tokenRevokeStatusHelp),
+			},
+
 			&framework.Path{
 				Pattern: "revoke-orphan" + framework.OptionalParamRegex("urltoken"),
 
@@ -335,6 +490,18 @@ func NewTokenStore(c *Core, config *logical.BackendConfig) (*TokenStore, error)
 				HelpDescription: strings.TrimSpace(tokenRevokeOrphanHelp),
 			},
 
+			&framework.Path{
+				Pattern: "tidy$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   t.handleTidyStatus,
+					logical.UpdateOperation: t.handleTidy,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(tokenTidyHelp),
+				HelpDescription: strings.TrimSpace(tokenTidyDesc),
+			},
+
 			&framework.Path{
 				Pattern: "renew-self$",
 
@@ -405,6 +572,18 @@ type TokenEntry struct {
 	CreationTime int64             // Time of token creation
 	TTL          time.Duration     // Duration set when token was created
 	Role         string            // If set, the role that was used for parameters at creation time
+	NamespaceID  string            // Namespace the token was issued in, the root namespace if empty
+	EntityID     string            // Entity of the client the token was issued to, if any
+	Type         string            // Type of the token, a service token if empty
+	BoundCIDRs   []string          // If set, the networks the token may be used from
+
+	// If set, Meta was set by a credential backend at login rather than by
+	// the creator of the token, so policy templates may refer to it
+	MetaFromLogin bool
+
+	// If set, the token cannot live longer than this, whatever its
+	// renewals, period or the tuning of the mount
+	ExplicitMaxTTL time.Duration
 }
 
 // tsRoleEntry contains token store role information
@@ -426,6 +605,36 @@ type tsRoleEntry struct {
 	// If set, a suffix will be set on the token path, making it easier to
 	// revoke using 'revoke-prefix'.
 	PathSuffix string `json:"path_suffix" mapstructure:"path_suffix" structs:"path_suffix"`
+
+	// If set, the type of the tokens created using this role
+	TokenType string `json:"token_type" mapstructure:"token_type" structs:"token_type"`
+
+	// If set, tokens created using this role can only be used from these
+	// networks
+	BoundCIDRs []string `json:"bound_cidrs" mapstructure:"bound_cidrs" structs:"bound_cidrs"`
+
+	// The policies that tokens created using this role can never have, even
+	// when the role allows any policy
+	DisallowedPolicies []string `json:"disallowed_policies" mapstructure:"disallowed_policies" structs:"disallowed_policies"`
+
+	// If non-zero, tokens created using this role cannot live longer than
+	// this, whatever their renewals, period or the tuning of the mount
+	ExplicitMaxTTL time.Duration `json:"explicit_max_ttl" mapstructure:"explicit_max_ttl" structs:"explicit_max_ttl"`
+
+	// If non-zero, the default and maximum TTLs of tokens created using
+	// this role, in place of those of the mount
+	TTL    time.Duration `json:"ttl" mapstructure:"ttl" structs:"ttl"`
+	MaxTTL time.Duration `json:"max_ttl" mapstructure:"max_ttl" structs:"max_ttl"`
+
+	// If false, tokens created using this role cannot be renewed
+	Renewable bool `json:"renewable" mapstructure:"renewable" structs:"renewable"`
+
+	// If set, the entity aliases tokens created using this role can be
+	// issued to. These may contain '*' globs.
+	AllowedEntityAliases []string `json:"allowed_entity_aliases" mapstructure:"allowed_entity_aliases" structs:"allowed_entity_aliases"`
+
+	// If set, the only metadata keys tokens created using this role can have
+	AllowedMetadataKeys []string `json:"allowed_metadata_keys" mapstructure:"allowed_metadata_keys" structs:"allowed_metadata_keys"`
 }
 
 // SetExpirationManager is used to provide the token store with
@@ -440,6 +649,35 @@ func (ts *TokenStore) SaltID(id string) string {
 	return ts.salt.SaltID(id)
 }
 
+// requestNamespace returns the namespace the token store was reached
+// through for the given request
+func (ts *TokenStore) requestNamespace(req *logical.Request) *Namespace {
+	return ts.core.namespaceByPath(req.MountPoint)
+}
+
+// namespaceRolesPrefix returns the storage prefix of the roles of the given
+// namespace
+func (ts *TokenStore) namespaceRolesPrefix(ns *Namespace) string {
+	if ns.ID == rootNamespaceID {
+		return rolesPrefix
+	}
+	return namespaceBarrierPrefix + ns.ID + "/" + rolesPrefix
+}
+
+// lookupInNamespace looks up a token to be managed within the namespace of
+// the request. Tokens issued outside of the namespace are not found, with
+// the exception of the client token itself.
+func (ts *TokenStore) lookupInNamespace(req *logical.Request, id string) (*TokenEntry, error) {
+	te, err := ts.Lookup(id)
+	if err != nil || te == nil {
+		return te, err
+	}
+	if id != req.ClientToken && !ts.core.tokenInNamespace(te, ts.requestNamespace(req)) {
+		return nil, nil
+	}
+	return te, nil
+}
+
 // RootToken is used to generate a new token with root privileges and no parent
 func (ts *TokenStore) rootToken() (*TokenEntry, error) {
 	te := &TokenEntry{
@@ -479,6 +717,10 @@ func (ts *TokenStore) createAccessor(entry *TokenEntry) error {
 // a newly generated ID if not provided.
 func (ts *TokenStore) create(entry *TokenEntry) error {
 	defer metrics.MeasureSince([]string{"token", "create"}, time.Now())
+	if entry.Type == tokenTypeBatch {
+		return ts.createBatch(entry)
+	}
+
 	// Generate an ID if necessary
 	if entry.ID == "" {
 		entryUUID, err := uuid.GenerateUUID()
@@ -592,7 +834,25 @@ func (ts *TokenStore) Lookup(id string) (*TokenEntry, error) {
 	if id == "" {
 		return nil, fmt.Errorf("cannot lookup blank token")
 	}
-	return ts.lookupSalted(ts.SaltID(id))
+	if isBatchToken(id) {
+		return ts.lookupBatch(id)
+	}
+
+	saltedId := ts.SaltID(id)
+	entry, err := ts.lookupSalted(saltedId)
+	if err != nil || entry == nil {
+		return entry, err
+	}
+
+	// Tokens within a tree being revoked are already gone
+	revoking, err := ts.revoking(saltedId, entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for revocation: %v", err)
+	}
+	if revoking {
+		return nil, nil
+	}
+	return entry, nil
 }
 
 // lookupSlated is used to find a token given its salted ID
@@ -624,6 +884,9 @@ func (ts *TokenStore) Revoke(id string) error {
 	if id == "" {
 		return fmt.Errorf("cannot revoke blank token")
 	}
+	if isBatchToken(id) {
+		return fmt.Errorf("batch tokens cannot be revoked")
+	}
 
 	return ts.revokeSalted(ts.SaltID(id))
 }
@@ -683,48 +946,122 @@ func (ts *TokenStore) RevokeTree(id string) error {
 	if id == "" {
 		return fmt.Errorf("cannot revoke blank token")
 	}
+	if isBatchToken(id) {
+		return fmt.Errorf("batch tokens cannot be revoked")
+	}
 
-	// Get the salted ID
-	saltedId := ts.SaltID(id)
-
-	// Nuke the entire tree recursively
-	if err := ts.revokeTreeSalted(saltedId); err != nil {
+	// Nuke the entire tree. Should this fail, the tree stays hidden and
+	// the job is resumed once unsealed.
+	job, err := ts.startRevocation(ts.SaltID(id))
+	if err != nil {
 		return err
 	}
-	return nil
+	return ts.runRevocation(job)
 }
 
-// revokeTreeSalted is used to invalide a given token and all
-// child tokens using a saltedID.
-func (ts *TokenStore) revokeTreeSalted(saltedId string) error {
-	// Scan for child tokens
-	path := parentPrefix + saltedId + "/"
-	children, err := ts.view.List(path)
+// revokeTreeAsync starts revoking the given token and all child tokens in
+// the background, returning the revocation job. The tree is hidden from
+// lookups from then on.
+func (ts *TokenStore) revokeTreeAsync(id string) (*revocationJob, error) {
+	if isBatchToken(id) {
+		return nil, fmt.Errorf("batch tokens cannot be revoked")
+	}
+
+	job, err := ts.startRevocation(ts.SaltID(id))
 	if err != nil {
-		return fmt.Errorf("failed to scan for children: %v", err)
+		return nil, err
 	}
+	ts.runRevocationBackground(job)
+	return job, nil
+}
 
-	// Recursively nuke the children. The subtle nuance here is that
-	// we don't have the acutal ID of the child, but we have the salted
-	// value. Turns out, this is good enough!
-	for _, child := range children {
-		if err := ts.revokeTreeSalted(child); err != nil {
-			return err
+// tidy walks the accessor and parent indexes, removing the entries of
+// tokens that no longer exist. The children of a parent that no longer
+// exists are left in place, as these may have been orphaned on purpose.
+func (ts *TokenStore) tidy(status *tidyStatus) error {
+	defer metrics.MeasureSince([]string{"token", "tidy"}, time.Now())
+
+	accessors, err := ts.view.List(accessorPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to scan for accessors: %v", err)
+	}
+	for _, accessor := range accessors {
+		status.scan()
+		path := accessorPrefix + accessor
+		entry, err := ts.view.Get(path)
+		if err != nil {
+			status.fail("failed to read accessor index: %v", err)
+			continue
+		}
+		if entry == nil {
+			continue
+		}
+		te, err := ts.Lookup(string(entry.Value))
+		if err != nil {
+			status.fail("failed to lookup token of accessor index: %v", err)
+			continue
+		}
+		if te != nil {
+			continue
+		}
+		if err := ts.view.Delete(path); err != nil {
+			status.fail("failed to remove accessor index: %v", err)
+		} else {
+			status.change("removed_accessor_indexes")
 		}
 	}
 
-	// Revoke this entry
-	if err := ts.revokeSalted(saltedId); err != nil {
-		return fmt.Errorf("failed to revoke entry: %v", err)
+	parents, err := ts.view.List(parentPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to scan for parents: %v", err)
+	}
+	for _, parent := range parents {
+		children, err := ts.view.List(parentPrefix + parent)
+		if err != nil {
+			return fmt.Errorf("failed to scan for children: %v", err)
+		}
+		for _, child := range children {
+			status.scan()
+			te, err := ts.lookupSalted(child)
+			if err != nil {
+				status.fail("failed to lookup child token: %v", err)
+				continue
+			}
+			if te != nil {
+				continue
+			}
+			if err := ts.view.Delete(parentPrefix + parent + child); err != nil {
+				status.fail("failed to remove parent index: %v", err)
+			} else {
+				status.change("removed_parent_indexes")
+			}
+		}
 	}
 	return nil
 }
 
+// handleTidy starts tidying the token indexes in the background
+func (ts *TokenStore) handleTidy(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if ts.requestNamespace(req).Path != "" {
+		return logical.ErrorResponse("tidy is only available in the root namespace"), logical.ErrInvalidRequest
+	}
+	return ts.core.runTidy(req, ts.tidyStatus, ts.tidy)
+}
+
+// handleTidyStatus returns the progress of the last tidy operation
+func (ts *TokenStore) handleTidyStatus(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return &logical.Response{
+		Data: ts.tidyStatus.data(),
+	}, nil
+}
+
 // handleCreateAgainstRole handles the auth/token/create path for a role
 func (ts *TokenStore) handleCreateAgainstRole(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	name := d.Get("role_name").(string)
-	roleEntry, err := ts.tokenStoreRole(name)
+	roleEntry, err := ts.tokenStoreRole(ts.requestNamespace(req), name)
 	if err != nil {
 		return nil, err
 	}
@@ -747,15 +1084,180 @@ func (ts *TokenStore) lookupByAccessor(accessor string) (string, error) {
 	return string(entry.Value), nil
 }
 
+// accessorFilter selects tokens when searching the accessor index. Every
+// criterion set must match.
+type accessorFilter struct {
+	policy            string
+	role              string
+	createdAfter      time.Time
+	createdBefore     time.Time
+	displayNamePrefix string
+	metaKey           string
+}
+
+// parseAccessorFilter builds the filter given by the request, returning nil
+// if no criterion is set
+func parseAccessorFilter(data *framework.FieldData) (*accessorFilter, error) {
+	f := &accessorFilter{
+		policy:            data.Get("policy").(string),
+		role:              data.Get("role").(string),
+		displayNamePrefix: data.Get("display_name_prefix").(string),
+		metaKey:           data.Get("meta_key").(string),
+	}
+	for field, t := range map[string]*time.Time{
+		"created_after":  &f.createdAfter,
+		"created_before": &f.createdBefore,
+	} {
+		raw := data.Get(field).(string)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", field, err)
+		}
+		*t = parsed
+	}
+
+	if *f == (accessorFilter{}) {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// matches returns whether the token meets every criterion of the filter.
+// The policy criterion is checked against the given effective policies of
+// the token, including those granted through its entity.
+func (f *accessorFilter) matches(te *TokenEntry, policies []string) bool {
+	if f == nil {
+		return true
+	}
+	created := time.Unix(te.CreationTime, 0)
+	switch {
+	case f.policy != "" && !strutil.StrListContains(policies, f.policy):
+		return false
+	case f.role != "" && te.Role != f.role:
+		return false
+	case !f.createdAfter.IsZero() && created.Before(f.createdAfter):
+		return false
+	case !f.createdBefore.IsZero() && !created.Before(f.createdBefore):
+		return false
+	case !strings.HasPrefix(te.DisplayName, f.displayNamePrefix):
+		return false
+	}
+	if f.metaKey != "" {
+		if _, ok := te.Meta[f.metaKey]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// listAccessors walks the accessor index, returning the accessors of the
+// tokens of the request namespace matching the filter, one page at a time.
+// The index is keyed by salted accessors, so every token has to be read to
+// recover its accessor; accessors are returned in the order of their
+// salted value, which lets the walk start after the cursor and stop once
+// the page is full.
+func (ts *TokenStore) listAccessors(
+	req *logical.Request, data *framework.FieldData, filter *accessorFilter) (*logical.Response, error) {
+	after := data.Get("after").(string)
+	limit := data.Get("limit").(int)
+	if limit < 0 {
+		return logical.ErrorResponse("limit cannot be negative"), logical.ErrInvalidRequest
+	}
+
+	ns := ts.requestNamespace(req)
+	saltedAccessors, err := ts.view.List(accessorPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan for accessors: %v", err)
+	}
+
+	sort.Strings(saltedAccessors)
+
+	var saltedAfter string
+	if after != "" {
+		saltedAfter = ts.SaltID(after)
+	}
+
+	var accessors []string
+	for _, saltedAccessor := range saltedAccessors {
+		if limit > 0 && len(accessors) == limit {
+			break
+		}
+		if after != "" && saltedAccessor <= saltedAfter {
+			continue
+		}
+
+		entry, err := ts.view.Get(accessorPrefix + saltedAccessor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read accessor index: %v", err)
+		}
+		if entry == nil {
+			continue
+		}
+		te, err := ts.Lookup(string(entry.Value))
+		if err != nil {
+			return nil, fmt.Errorf("failed to lookup token of accessor index: %v", err)
+		}
+
+		// Indexes left behind by crashes are cleared by tidy, skip them
+		if te == nil || te.Accessor == "" {
+			continue
+		}
+		if !ts.core.tokenInNamespace(te, ns) {
+			continue
+		}
+		policies := te.Policies
+		if filter != nil && filter.policy != "" {
+			policies = ts.effectivePolicies(te)
+		}
+		if !filter.matches(te, policies) {
+			continue
+		}
+		accessors = append(accessors, te.Accessor)
+	}
+	return logical.ListResponse(accessors), nil
+}
+
+// effectivePolicies returns the policies of the token along with those
+// granted to its entity, directly or through groups
+func (ts *TokenStore) effectivePolicies(te *TokenEntry) []string {
+	if te.EntityID == "" || ts.core.identityStore == nil {
+		return te.Policies
+	}
+	return append(append([]string{}, te.Policies...), ts.core.identityStore.EntityPolicies(te.EntityID)...)
+}
+
+// handleListAccessors handles the auth/token/accessors path for listing
+// the accessors of every token
+func (ts *TokenStore) handleListAccessors(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return ts.listAccessors(req, data, nil)
+}
+
 // handleUpdateLookupAccessor handles the auth/token/lookup-accessor path for returning
 // the properties of the token associated with the accessor
 func (ts *TokenStore) handleUpdateLookupAccessor(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	accessor := data.Get("accessor").(string)
 	if accessor == "" {
 		accessor = data.Get("urlaccessor").(string)
-		if accessor == "" {
+	}
+
+	// Without an accessor, search for the tokens matching the filters
+	filter, err := parseAccessorFilter(data)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	if accessor == "" {
+		if filter == nil {
 			return nil, &StatusBadRequest{Err: "missing accessor"}
 		}
+		if !ts.System().SudoPrivilege(req.MountPoint+req.Path, req.ClientToken) {
+			return logical.ErrorResponse("root or sudo privileges required to search tokens"),
+				logical.ErrInvalidRequest
+		}
+		return ts.listAccessors(req, data, filter)
 	}
 
 	tokenID, err := ts.lookupByAccessor(accessor)
@@ -810,6 +1312,13 @@ func (ts *TokenStore) handleUpdateRevokeAccessor(req *logical.Request, data *fra
 	if err != nil {
 		return nil, err
 	}
+	te, err := ts.lookupInNamespace(req, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	if te == nil {
+		return nil, &StatusBadRequest{Err: "invalid accessor"}
+	}
 
 	// Revoke the token and its children
 	if err := ts.RevokeTree(tokenID); err != nil {
@@ -848,9 +1357,25 @@ func (ts *TokenStore) handleCreateCommon(
 			logical.ErrInvalidRequest
 	}
 
+	// Nothing tracks the children of a batch token for revocation
+	if parent.Type == tokenTypeBatch {
+		return logical.ErrorResponse("batch tokens cannot generate child tokens"),
+			logical.ErrInvalidRequest
+	}
+
 	// Check if the client token has sudo/root privileges for the requested path
 	isSudo := ts.System().SudoPrivilege(req.MountPoint+req.Path, req.ClientToken)
 
+	// The token is issued in the namespace the token store was reached
+	// through. The policies of a parent from another namespace mean nothing
+	// there, so such a parent has to name the policies explicitly.
+	ns := ts.requestNamespace(req)
+	crossNamespace := ns.ID != rootNamespaceID && !ts.core.tokenInNamespace(parent, ns)
+	if crossNamespace && !isSudo {
+		return logical.ErrorResponse("root or sudo privileges required to create a token in another namespace"),
+			logical.ErrInvalidRequest
+	}
+
 	// Read and parse the fields
 	var data struct {
 		ID              string
@@ -862,6 +1387,13 @@ func (ts *TokenStore) handleCreateCommon(
 		TTL             string
 		DisplayName     string `mapstructure:"display_name"`
 		NumUses         int    `mapstructure:"num_uses"`
+		Type            string
+		BoundCIDRs      []string `mapstructure:"bound_cidrs"`
+		EntityAlias     string   `mapstructure:"entity_alias"`
+	}
+	// Bound CIDRs may be given as a comma-delimited string too
+	if raw, ok := req.Data["bound_cidrs"].(string); ok {
+		req.Data["bound_cidrs"] = []string{raw}
 	}
 	if err := mapstructure.WeakDecode(req.Data, &data); err != nil {
 		return logical.ErrorResponse(fmt.Sprintf(
@@ -874,19 +1406,44 @@ func (ts *TokenStore) handleCreateCommon(
 			logical.ErrInvalidRequest
 	}
 
+	// The role decides the type of the token if it sets one
+	if !validTokenType(data.Type) {
+		return logical.ErrorResponse(fmt.Sprintf("invalid token type %q", data.Type)),
+			logical.ErrInvalidRequest
+	}
+	if role != nil && role.TokenType != "" {
+		if data.Type != "" && data.Type != role.TokenType {
+			return logical.ErrorResponse("token type must match the role's token type"),
+				logical.ErrInvalidRequest
+		}
+		data.Type = role.TokenType
+	}
+	if data.Type == tokenTypeBatch && data.NumUses > 0 {
+		return logical.ErrorResponse("batch tokens cannot have a use limit"),
+			logical.ErrInvalidRequest
+	}
+
 	// Setup the token entry
 	te := TokenEntry{
-		Parent: req.ClientToken,
+		Parent:      req.ClientToken,
+		NamespaceID: ns.ID,
 
-		// The mount point is always the same since we have only one token
-		// store; using req.MountPoint causes trouble in tests since they don't
-		// have an official mount
-		Path: fmt.Sprintf("auth/token/%s", req.Path),
+		// The mount point is always the same within a namespace since we
+		// have only one token store; using req.MountPoint causes trouble in
+		// tests since they don't have an official mount
+		Path: fmt.Sprintf("%sauth/token/%s", ns.Path, req.Path),
 
 		Meta:         data.Metadata,
 		DisplayName:  "token",
 		NumUses:      data.NumUses,
 		CreationTime: time.Now().Unix(),
+		Type:         data.Type,
+	}
+
+	// The child stands for the same client as its parent, unless it is
+	// issued in another namespace
+	if !crossNamespace {
+		te.EntityID = parent.EntityID
 	}
 
 	// If the role is not nil, we add the role name as part of the token's
@@ -902,6 +1459,44 @@ func (ts *TokenStore) handleCreateCommon(
 		}
 	}
 
+	// The role may restrict the metadata keys of the token
+	if role != nil && len(role.AllowedMetadataKeys) > 0 {
+		for key := range data.Metadata {
+			if !strutil.StrListContains(role.AllowedMetadataKeys, key) {
+				return logical.ErrorResponse(fmt.Sprintf("metadata key %q is not allowed by the role", key)),
+					logical.ErrInvalidRequest
+			}
+		}
+	}
+
+	// The role may allow issuing the token to another entity, by the name
+	// of its alias on the token store mount
+	if data.EntityAlias != "" {
+		if role == nil {
+			return logical.ErrorResponse("entity_alias requires a role"), logical.ErrInvalidRequest
+		}
+		allowed := false
+		for _, pattern := range role.AllowedEntityAliases {
+			if strutil.GlobMatch(pattern, data.EntityAlias) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return logical.ErrorResponse("entity alias is not allowed by the role"), logical.ErrInvalidRequest
+		}
+
+		mount := ts.core.router.MatchingMountEntry(ns.Path + "auth/token/")
+		if ts.core.identityStore == nil || mount == nil {
+			return nil, fmt.Errorf("identity store is unavailable")
+		}
+		entity, err := ts.core.identityStore.EntityByAlias(mount, &logical.Alias{Name: data.EntityAlias}, nil)
+		if err != nil {
+			return nil, err
+		}
+		te.EntityID = entity.ID
+	}
+
 	// Attach the given display name if any
 	if data.DisplayName != "" {
 		full := "token-" + data.DisplayName
@@ -912,10 +1507,19 @@ func (ts *TokenStore) handleCreateCommon(
 
 	// Allow specifying the ID of the token if the client has root or sudo privileges
 	if data.ID != "" {
+		if te.Type == tokenTypeBatch {
+			return logical.ErrorResponse("batch tokens cannot have a specified id"),
+				logical.ErrInvalidRequest
+		}
 		if !isSudo {
 			return logical.ErrorResponse("root or sudo privileges required to specify token id"),
 				logical.ErrInvalidRequest
 		}
+		// Such an id would be taken for a batch token on lookup
+		if isBatchToken(data.ID) {
+			return logical.ErrorResponse(fmt.Sprintf("token id cannot begin with %q", batchTokenPrefix)),
+				logical.ErrInvalidRequest
+		}
 		te.ID = data.ID
 	}
 
@@ -931,9 +1535,14 @@ func (ts *TokenStore) handleCreateCommon(
 			}
 		}
 
+	case crossNamespace && len(data.Policies) == 0:
+		return logical.ErrorResponse("policies must be specified to create a token in another namespace"), logical.ErrInvalidRequest
+
 	case len(data.Policies) == 0:
 		data.Policies = parent.Policies
 
+	case crossNamespace:
+
 	// When a role is not in use, only permit policies to be a subset unless
 	// the client has root or sudo privileges
 	case !isSudo && !strutil.StrListSubset(parent.Policies, data.Policies):
@@ -955,11 +1564,57 @@ func (ts *TokenStore) handleCreateCommon(
 		policyMap["default"] = true
 	}
 
+	// The role may disallow policies whatever their source. The default
+	// policy is dropped rather than refused.
+	if role != nil && len(role.DisallowedPolicies) > 0 {
+		if strutil.StrListContains(role.DisallowedPolicies, "default") {
+			delete(policyMap, "default")
+		}
+		for policy := range policyMap {
+			if strutil.StrListContains(role.DisallowedPolicies, policy) {
+				return logical.ErrorResponse(fmt.Sprintf("policy %q is disallowed by the role", policy)),
+					logical.ErrInvalidRequest
+			}
+		}
+	}
+
 	for k, _ := range policyMap {
 		te.Policies = append(te.Policies, k)
 	}
 	sort.Strings(te.Policies)
 
+	// Bind the token to the given networks. These must lie within those
+	// the role and the parent are bound to, if any, and default to them.
+	boundCIDRs, err := cidrutil.ParseCIDRs(splitCommaList(data.BoundCIDRs))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	for _, limit := range []struct {
+		source string
+		cidrs  []string
+	}{
+		{"role", roleBoundCIDRs(role)},
+		{"parent", parent.BoundCIDRs},
+	} {
+		if len(limit.cidrs) == 0 {
+			continue
+		}
+		limitCIDRs, err := cidrutil.ParseCIDRs(limit.cidrs)
+		if err != nil {
+			return nil, err
+		}
+		if len(boundCIDRs) == 0 {
+			boundCIDRs = limitCIDRs
+		}
+		if !cidrutil.Subset(limitCIDRs, boundCIDRs) {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"bound CIDRs must lie within those of the %s", limit.source)), logical.ErrInvalidRequest
+		}
+	}
+	for _, cidr := range boundCIDRs {
+		te.BoundCIDRs = append(te.BoundCIDRs, cidr.String())
+	}
+
 	switch {
 	case role != nil:
 		if role.Orphan {
@@ -1008,14 +1663,33 @@ func (ts *TokenStore) handleCreateCommon(
 
 		sysView := ts.System()
 
+		// The default TTL of the role takes the place of that of the mount,
+		// while its max TTL can only tighten the max TTL of the mount
+		defaultTTL, maxTTL := sysView.DefaultLeaseTTL(), sysView.MaxLeaseTTL()
+		if role != nil && role.TTL > 0 {
+			defaultTTL = role.TTL
+		}
+		if role != nil && role.MaxTTL > 0 && (maxTTL == 0 || role.MaxTTL < maxTTL) {
+			maxTTL = role.MaxTTL
+		}
+
 		// Set the default lease if non-provided, root tokens are exempt
-		if te.TTL == 0 && !strutil.StrListContains(te.Policies, "root") {
-			te.TTL = sysView.DefaultLeaseTTL()
+		// unless they are batch tokens, which cannot be revoked
+		if te.TTL == 0 && (te.Type == tokenTypeBatch || !strutil.StrListContains(te.Policies, "root")) {
+			te.TTL = defaultTTL
 		}
 
 		// Limit the lease duration
-		if te.TTL > sysView.MaxLeaseTTL() && sysView.MaxLeaseTTL() != time.Duration(0) {
-			te.TTL = sysView.MaxLeaseTTL()
+		if te.TTL > maxTTL && maxTTL != time.Duration(0) {
+			te.TTL = maxTTL
+		}
+	}
+
+	// The explicit max TTL of the role caps the token whatever its period
+	if role != nil && role.ExplicitMaxTTL > 0 {
+		te.ExplicitMaxTTL = role.ExplicitMaxTTL
+		if te.TTL == 0 || te.TTL > te.ExplicitMaxTTL {
+			te.TTL = te.ExplicitMaxTTL
 		}
 	}
 
@@ -1032,16 +1706,20 @@ func (ts *TokenStore) handleCreateCommon(
 			Metadata:    te.Meta,
 			LeaseOptions: logical.LeaseOptions{
 				TTL:       te.TTL,
-				Renewable: true,
+				Renewable: te.Type != tokenTypeBatch && (role == nil || role.Renewable),
 			},
 			ClientToken: te.ID,
 			Accessor:    te.Accessor,
 		},
 	}
 
-	if ts.policyLookupFunc != nil {
+	policyLookupFunc := ts.policyLookupFunc
+	if ps := ts.core.namespacePolicyStore(ns); ns.ID != rootNamespaceID && ps != nil {
+		policyLookupFunc = ps.GetPolicy
+	}
+	if policyLookupFunc != nil {
 		for _, p := range te.Policies {
-			policy, err := ts.policyLookupFunc(p)
+			policy, err := policyLookupFunc(p)
 			if err != nil {
 				return logical.ErrorResponse(fmt.Sprintf("could not look up policy %s", p)), nil
 			}
@@ -1079,11 +1757,45 @@ func (ts *TokenStore) handleRevokeTree(
 		}
 	}
 
-	// Revoke the token and its children
-	if err := ts.RevokeTree(id); err != nil {
+	te, err := ts.lookupInNamespace(req, id)
+	if err != nil {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
-	return nil, nil
+	if te == nil {
+		return logical.ErrorResponse("token not found"), logical.ErrInvalidRequest
+	}
+
+	// Revoke the token and its children in the background, as the tree
+	// may be too large to revoke within the request
+	job, err := ts.revokeTreeAsync(id)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"job_id": job.ID,
+		},
+	}, nil
+}
+
+// handleRevocationStatus handles the auth/token/revoke-status/job_id path,
+// returning the progress of a revocation job while it runs
+func (ts *TokenStore) handleRevocationStatus(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	job, err := ts.lookupRevocation(data.Get("job_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, nil
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"job_id":     job.ID,
+			"start_time": job.StartTime,
+			"revoked":    job.Revoked,
+		},
+	}, nil
 }
 
 // handleRevokeOrphan handles the auth/token/revoke-orphan/id path for revocation of tokens
@@ -1116,6 +1828,14 @@ func (ts *TokenStore) handleRevokeOrphan(
 			logical.ErrInvalidRequest
 	}
 
+	te, err := ts.lookupInNamespace(req, id)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	if te == nil {
+		return logical.ErrorResponse("token not found"), logical.ErrInvalidRequest
+	}
+
 	// Revoke and orphan
 	if err := ts.Revoke(id); err != nil {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
@@ -1145,7 +1865,7 @@ func (ts *TokenStore) handleLookup(
 	}
 
 	// Lookup the token
-	out, err := ts.Lookup(id)
+	out, err := ts.lookupInNamespace(req, id)
 
 	if err != nil {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
@@ -1171,12 +1891,25 @@ func (ts *TokenStore) handleLookup(
 			"creation_ttl":  int64(out.TTL.Seconds()),
 			"ttl":           int64(0),
 			"role":          out.Role,
+			"entity_id":     out.EntityID,
+			"type":          tokenTypeService,
 		},
 	}
 
 	if out.Parent == "" {
 		resp.Data["orphan"] = true
 	}
+	if len(out.BoundCIDRs) > 0 {
+		resp.Data["bound_cidrs"] = out.BoundCIDRs
+	}
+
+	// Batch tokens have no lease, they expire at the end of their TTL
+	if out.Type == tokenTypeBatch {
+		expireTime := time.Unix(out.CreationTime, 0).Add(out.TTL)
+		resp.Data["type"] = tokenTypeBatch
+		resp.Data["ttl"] = int64(expireTime.Sub(time.Now().Round(time.Second)).Seconds())
+		return resp, nil
+	}
 
 	// Fetch the last renewal time
 	leaseTimes, err := ts.expiration.FetchLeaseTimesByToken(out.Path, out.ID)
@@ -1218,7 +1951,7 @@ func (ts *TokenStore) handleRenew(
 	increment := time.Duration(incrementRaw) * time.Second
 
 	// Lookup the token
-	te, err := ts.Lookup(id)
+	te, err := ts.lookupInNamespace(req, id)
 	if err != nil {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
@@ -1227,6 +1960,9 @@ func (ts *TokenStore) handleRenew(
 	if te == nil {
 		return logical.ErrorResponse("token not found"), logical.ErrInvalidRequest
 	}
+	if te.Type == tokenTypeBatch {
+		return logical.ErrorResponse("batch tokens cannot be renewed"), logical.ErrInvalidRequest
+	}
 
 	// Renew the token and its children
 	return ts.expiration.RenewToken(req, te.Path, te.ID, increment)
@@ -1257,11 +1993,39 @@ func (ts *TokenStore) authRenew(
 	}
 
 	// No role? Use normal LeaseExtend semantics
+	var resp *logical.Response
 	if te.Role == "" {
-		return f(req, d)
+		resp, err = f(req, d)
+	} else {
+		resp, err = ts.authRenewRole(req, d, te)
+	}
+	if err != nil || resp == nil || resp.IsError() {
+		return resp, err
+	}
+
+	// The explicit max TTL caps any renewal
+	if te.ExplicitMaxTTL > 0 {
+		remaining := time.Unix(te.CreationTime, 0).Add(te.ExplicitMaxTTL).Sub(time.Now())
+		if remaining <= 0 {
+			return logical.ErrorResponse("past the explicit max TTL, cannot renew"), nil
+		}
+		if resp.Auth.TTL > remaining {
+			resp.Auth.TTL = remaining
+		}
+	}
+	return resp, nil
+}
+
+// authRenewRole renews a token created using a role, following the
+// renewal settings of the role
+func (ts *TokenStore) authRenewRole(
+	req *logical.Request, d *framework.FieldData, te *TokenEntry) (*logical.Response, error) {
+	ns := ts.core.namespaceByID(te.NamespaceID)
+	if ns == nil {
+		return logical.ErrorResponse("namespace of the token could not be found, not renewing"), nil
 	}
 
-	role, err := ts.tokenStoreRole(te.Role)
+	role, err := ts.tokenStoreRole(ns, te.Role)
 	if err != nil {
 		return nil, fmt.Errorf("error looking up role %s: %s", te.Role, err)
 	}
@@ -1279,11 +2043,33 @@ func (ts *TokenStore) authRenew(
 		return &logical.Response{Auth: req.Auth}, nil
 	}
 
-	return f(req, d)
+	return framework.LeaseExtend(role.TTL, role.MaxTTL, ts.System())(req, d)
+}
+
+// roleBoundCIDRs returns the networks the tokens created using the role
+// are bound to, if any
+func roleBoundCIDRs(role *tsRoleEntry) []string {
+	if role == nil {
+		return nil
+	}
+	return role.BoundCIDRs
+}
+
+// splitCommaList splits comma-separated lists, dropping empty entries
+func splitCommaList(raw []string) []string {
+	var cidrs []string
+	for _, item := range raw {
+		for _, cidr := range strings.Split(item, ",") {
+			if cidr = strings.TrimSpace(cidr); cidr != "" {
+				cidrs = append(cidrs, cidr)
+			}
+		}
+	}
+	return cidrs
 }
 
-func (ts *TokenStore) tokenStoreRole(name string) (*tsRoleEntry, error) {
-	entry, err := ts.view.Get(fmt.Sprintf("%s%s", rolesPrefix, name))
+func (ts *TokenStore) tokenStoreRole(ns *Namespace, name string) (*tsRoleEntry, error) {
+	entry, err := ts.view.Get(fmt.Sprintf("%s%s", ts.namespaceRolesPrefix(ns), name))
 	if err != nil {
 		return nil, err
 	}
@@ -1291,7 +2077,10 @@ func (ts *TokenStore) tokenStoreRole(name string) (*tsRoleEntry, error) {
 		return nil, nil
 	}
 
-	var result tsRoleEntry
+	// Roles stored before renewability could be turned off are renewable
+	result := tsRoleEntry{
+		Renewable: true,
+	}
 	if err := entry.DecodeJSON(&result); err != nil {
 		return nil, err
 	}
@@ -1301,14 +2090,15 @@ func (ts *TokenStore) tokenStoreRole(name string) (*tsRoleEntry, error) {
 
 func (ts *TokenStore) tokenStoreRoleList(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	entries, err := ts.view.List(rolesPrefix)
+	prefix := ts.namespaceRolesPrefix(ts.requestNamespace(req))
+	entries, err := ts.view.List(prefix)
 	if err != nil {
 		return nil, err
 	}
 
 	ret := make([]string, len(entries))
 	for i, entry := range entries {
-		ret[i] = strings.TrimPrefix(entry, rolesPrefix)
+		ret[i] = strings.TrimPrefix(entry, prefix)
 	}
 
 	return logical.ListResponse(ret), nil
@@ -1316,7 +2106,7 @@ func (ts *TokenStore) tokenStoreRoleList(
 
 func (ts *TokenStore) tokenStoreRoleDelete(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	err := ts.view.Delete(fmt.Sprintf("%s%s", rolesPrefix, data.Get("role_name").(string)))
+	err := ts.view.Delete(fmt.Sprintf("%s%s", ts.namespaceRolesPrefix(ts.requestNamespace(req)), data.Get("role_name").(string)))
 	if err != nil {
 		return nil, err
 	}
@@ -1326,7 +2116,7 @@ func (ts *TokenStore) tokenStoreRoleDelete(
 
 func (ts *TokenStore) tokenStoreRoleRead(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	role, err := ts.tokenStoreRole(data.Get("role_name").(string))
+	role, err := ts.tokenStoreRole(ts.requestNamespace(req), data.Get("role_name").(string))
 	if err != nil {
 		return nil, err
 	}
@@ -1338,10 +2128,19 @@ func (ts *TokenStore) tokenStoreRoleRead(
 		Data: structs.New(role).Map(),
 	}
 
-	// Make the period nicer
+	// Make the period and TTLs nicer
 	if role.Period != 0 {
 		resp.Data["period"] = role.Period.Seconds()
 	}
+	if role.ExplicitMaxTTL != 0 {
+		resp.Data["explicit_max_ttl"] = role.ExplicitMaxTTL.Seconds()
+	}
+	if role.TTL != 0 {
+		resp.Data["ttl"] = role.TTL.Seconds()
+	}
+	if role.MaxTTL != 0 {
+		resp.Data["max_ttl"] = role.MaxTTL.Seconds()
+	}
 
 	return resp, nil
 }
@@ -1351,7 +2150,7 @@ func (ts *TokenStore) tokenStoreRoleExistenceCheck(req *logical.Request, data *f
 	if name == "" {
 		return false, fmt.Errorf("role name cannot be empty")
 	}
-	role, err := ts.tokenStoreRole(name)
+	role, err := ts.tokenStoreRole(ts.requestNamespace(req), name)
 	if err != nil {
 		return false, err
 	}
@@ -1365,7 +2164,8 @@ func (ts *TokenStore) tokenStoreRoleCreateUpdate(
 	if name == "" {
 		return logical.ErrorResponse("role name cannot be empty"), nil
 	}
-	entry, err := ts.tokenStoreRole(name)
+	ns := ts.requestNamespace(req)
+	entry, err := ts.tokenStoreRole(ns, name)
 	if err != nil {
 		return nil, err
 	}
@@ -1410,18 +2210,90 @@ func (ts *TokenStore) tokenStoreRoleCreateUpdate(
 		entry.PathSuffix = data.Get("path_suffix").(string)
 	}
 
-	allowedPoliciesInt, ok := data.GetOk("allowed_policies")
+	tokenTypeInt, ok := data.GetOk("token_type")
 	if ok {
-		allowedPolicies := allowedPoliciesInt.(string)
-		if allowedPolicies != "" {
-			entry.AllowedPolicies = strings.Split(allowedPolicies, ",")
+		tokenType := tokenTypeInt.(string)
+		if !validTokenType(tokenType) {
+			return logical.ErrorResponse(fmt.Sprintf("invalid token type %q", tokenType)), nil
 		}
+		entry.TokenType = tokenType
 	} else if req.Operation == logical.CreateOperation {
-		entry.AllowedPolicies = strings.Split(data.Get("allowed_policies").(string), ",")
+		entry.TokenType = data.Get("token_type").(string)
+	}
+
+	boundCIDRsInt, ok := data.GetOk("bound_cidrs")
+	if ok {
+		boundCIDRs := splitCommaList([]string{boundCIDRsInt.(string)})
+		if _, err := cidrutil.ParseCIDRs(boundCIDRs); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		entry.BoundCIDRs = boundCIDRs
+	}
+
+	disallowedPoliciesInt, ok := data.GetOk("disallowed_policies")
+	if ok {
+		entry.DisallowedPolicies = splitCommaList([]string{disallowedPoliciesInt.(string)})
+	}
+
+	explicitMaxTTLInt, ok := data.GetOk("explicit_max_ttl")
+	if ok {
+		entry.ExplicitMaxTTL = time.Second * time.Duration(explicitMaxTTLInt.(int))
+	} else if req.Operation == logical.CreateOperation {
+		entry.ExplicitMaxTTL = time.Second * time.Duration(data.Get("explicit_max_ttl").(int))
+	}
+
+	ttlInt, ok := data.GetOk("ttl")
+	if ok {
+		entry.TTL = time.Second * time.Duration(ttlInt.(int))
+	} else if req.Operation == logical.CreateOperation {
+		entry.TTL = time.Second * time.Duration(data.Get("ttl").(int))
+	}
+
+	maxTTLInt, ok := data.GetOk("max_ttl")
+	if ok {
+		entry.MaxTTL = time.Second * time.Duration(maxTTLInt.(int))
+	} else if req.Operation == logical.CreateOperation {
+		entry.MaxTTL = time.Second * time.Duration(data.Get("max_ttl").(int))
+	}
+
+	renewableInt, ok := data.GetOk("renewable")
+	if ok {
+		entry.Renewable = renewableInt.(bool)
+	} else if req.Operation == logical.CreateOperation {
+		entry.Renewable = data.Get("renewable").(bool)
+	}
+
+	allowedEntityAliasesInt, ok := data.GetOk("allowed_entity_aliases")
+	if ok {
+		entry.AllowedEntityAliases = splitCommaList([]string{allowedEntityAliasesInt.(string)})
+	}
+
+	allowedMetadataKeysInt, ok := data.GetOk("allowed_metadata_keys")
+	if ok {
+		entry.AllowedMetadataKeys = splitCommaList([]string{allowedMetadataKeysInt.(string)})
+	}
+
+	if entry.TTL < 0 || entry.MaxTTL < 0 || entry.ExplicitMaxTTL < 0 {
+		return logical.ErrorResponse("TTLs cannot be negative"), nil
+	}
+	if entry.MaxTTL > 0 && entry.TTL > entry.MaxTTL {
+		return logical.ErrorResponse("ttl cannot be greater than max_ttl"), nil
+	}
+
+	// Batch tokens cannot be renewed, so they cannot be periodic
+	if entry.TokenType == tokenTypeBatch && entry.Period > 0 {
+		return logical.ErrorResponse("batch tokens cannot be periodic"), nil
+	}
+
+	allowedPoliciesInt, ok := data.GetOk("allowed_policies")
+	if ok {
+		entry.AllowedPolicies = splitCommaList([]string{allowedPoliciesInt.(string)})
+	} else if req.Operation == logical.CreateOperation {
+		entry.AllowedPolicies = splitCommaList([]string{data.Get("allowed_policies").(string)})
 	}
 
 	// Store it
-	jsonEntry, err := logical.StorageEntryJSON(fmt.Sprintf("%s%s", rolesPrefix, name), entry)
+	jsonEntry, err := logical.StorageEntryJSON(fmt.Sprintf("%s%s", ts.namespaceRolesPrefix(ns), name), entry)
 	if err != nil {
 		return nil, err
 	}
@@ -1441,15 +2313,18 @@ as revocation of tokens. The tokens are renewable if associated with a lease.`
 	tokenCreateOrphanHelp    = `The token create path is used to create new orphan tokens.`
 	tokenCreateRoleHelp      = `This token create path is used to create new tokens adhering to the given role.`
 	tokenListRolesHelp       = `This endpoint lists configured roles.`
-	tokenLookupAccessorHelp  = `This endpoint will lookup a token associated with the given accessor and its properties. Response will not contain the token ID.`
+	tokenLookupAccessorHelp  = `This endpoint will lookup a token associated with the given accessor and its properties. Response will not contain the token ID. Without an accessor, the accessors of the tokens matching the given filters are listed instead; this requires sudo privileges.`
+	tokenListAccessorsHelp   = `This endpoint lists the accessors of all tokens, in pages given by 'after' and 'limit'. This requires sudo privileges.`
 	tokenLookupHelp          = `This endpoint will lookup a token and its properties.`
 	tokenPathRolesHelp       = `This endpoint allows creating, reading, and deleting roles.`
 	tokenRevokeAccessorHelp  = `This endpoint will delete the token associated with the accessor and all of its child tokens.`
-	tokenRevokeHelp          = `This endpoint will delete the given token and all of its child tokens.`
+	tokenRevokeHelp          = `This endpoint will delete the given token and all of its child tokens. The tokens are revoked in the background; the returned job ID can be passed to 'revoke-status'.`
+	tokenRevokeStatusHelp    = `This endpoint returns the progress of a token revocation job, until it completes.`
 	tokenRevokeSelfHelp      = `This endpoint will delete the token used to call it and all of its child tokens.`
 	tokenRevokeOrphanHelp    = `This endpoint will delete the token and orphan its child tokens.`
 	tokenRenewHelp           = `This endpoint will renew the given token and prevent expiration.`
 	tokenRenewSelfHelp       = `This endpoint will renew the token used to call it and prevent expiration.`
+	tokenTidyHelp            = `This endpoint removes token indexes left behind by revoked tokens.`
 	tokenAllowedPoliciesHelp = `If set, tokens created via this role
 can be created with any subset of this list,
 rather than the normal semantics of a subset
@@ -1463,10 +2338,50 @@ will have no max lifetime; instead, their
 renewal period will be fixed to this value.
 This takes an integer number of seconds,
 or a string duration (e.g. "24h").`
+	tokenTypeHelp = `If set, the type of the tokens created
+via this role, either "service" or "batch".`
+	tokenBoundCIDRsHelp = `If set, tokens created via this role
+can only be used from these networks. This
+parameter should be sent as a comma-delimited
+string of CIDR blocks.`
+	tokenDisallowedPoliciesHelp = `If set, tokens created via this role
+cannot have any of these policies, even if
+the role allows any policy. If "default" is
+listed, it is not added to the tokens. This
+parameter should be sent as a comma-delimited
+string.`
+	tokenExplicitMaxTTLHelp = `If set, tokens created via this role
+cannot live longer than this, regardless of
+renewals, period or the mount tuning. This
+takes an integer number of seconds, or a
+string duration (e.g. "24h").`
+	tokenRoleTTLHelp = `If set, the default TTL of tokens created
+via this role, in place of the mount's.`
+	tokenRoleMaxTTLHelp = `If set, the maximum TTL of tokens created
+via this role. It cannot exceed the mount's.`
+	tokenRenewableHelp = `If false, tokens created via this role
+cannot be renewed. Defaults to true.`
+	tokenAllowedEntityAliasesHelp = `If set, tokens created via this role can
+be issued to the entity of any of these
+aliases of the token store mount, given as
+"entity_alias". Entries may contain '*'
+globs. This parameter should be sent as a
+comma-delimited string.`
+	tokenAllowedMetadataKeysHelp = `If set, the only metadata keys tokens
+created via this role can have. This
+parameter should be sent as a
+comma-delimited string.`
 	tokenPathSuffixHelp = `If set, tokens created via this role
 will contain the given suffix as a part of
 their path. This can be used to assist use
 of the 'revoke-prefix' endpoint later on.
 The given suffix must match the regular
 expression `
+	tokenTidyDesc = `
+This endpoint starts a background walk of the accessor and parent indexes of
+the token store, removing the entries that refer to tokens which no longer
+exist. Writing to it starts the walk; reading it returns the progress of the
+last walk and the changes it made. The outcome is also recorded in the audit
+log once the walk is done.
+`
 )
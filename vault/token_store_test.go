@@ -3,10 +3,12 @@ package vault
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 )
 
@@ -447,6 +449,151 @@ func TestTokenStore_RevokeTree(t *testing.T) {
 	}
 }
 
+func TestTokenStore_RevokeTreeAsync(t *testing.T) {
+	_, ts, _, _ := TestCoreWithTokenStore(t)
+
+	ent1 := &TokenEntry{}
+	if err := ts.create(ent1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	lookup := []string{ent1.ID}
+	parents := []string{ent1.ID}
+	for i := 0; i < 3*revocationCheckpointInterval; i++ {
+		ent := &TokenEntry{Parent: parents[i%len(parents)]}
+		if err := ts.create(ent); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		lookup = append(lookup, ent.ID)
+		parents = append(parents, ent.ID)
+	}
+
+	// An interrupted job hides the tree until it is resumed
+	job, err := ts.startRevocation(ts.SaltID(ent1.ID))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, id := range lookup {
+		out, err := ts.Lookup(id)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if out != nil {
+			t.Fatalf("bad: %#v", out)
+		}
+	}
+
+	ts.revocations = make(map[string]*revocationJob)
+	if err := ts.resumeRevocations(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer ts.stopRevocations()
+
+	for i := 0; ; i++ {
+		out, err := ts.lookupRevocation(job.ID)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if out == nil {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("revocation job did not complete: %#v", out)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, id := range lookup {
+		out, err := ts.lookupSalted(ts.SaltID(id))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if out != nil {
+			t.Fatalf("bad: %#v", out)
+		}
+	}
+	children, err := ts.view.List(parentPrefix)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(children) != 0 {
+		t.Fatalf("bad: %v", children)
+	}
+}
+
+func TestTokenStore_Tidy(t *testing.T) {
+	_, ts, _, _ := TestCoreWithTokenStore(t)
+
+	ent1 := &TokenEntry{}
+	if err := ts.create(ent1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ent2 := &TokenEntry{Parent: ent1.ID}
+	if err := ts.create(ent2); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ent3 := &TokenEntry{Parent: ent2.ID}
+	if err := ts.create(ent3); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Lose the primary entry of the middle token, as a crash during its
+	// revocation would
+	if err := ts.view.Delete(lookupPrefix + ts.SaltID(ent2.ID)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "tidy")
+	resp, err := ts.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if len(resp.Warnings()) != 1 {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "tidy")
+	for i := 0; ; i++ {
+		resp, err = ts.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v %v", err, resp)
+		}
+		if resp.Data["state"] == "complete" {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("tidy did not complete: %#v", resp.Data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	expected := map[string]interface{}{
+		"removed_accessor_indexes": 1,
+		"removed_parent_indexes":   1,
+	}
+	if !reflect.DeepEqual(resp.Data["changes"], expected) || len(resp.Data["errors"].([]string)) != 0 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// The child of the lost token is left in place
+	if _, err := ts.lookupByAccessor(ent2.Accessor); err == nil {
+		t.Fatalf("accessor index should be removed")
+	}
+	out, err := ts.Lookup(ent3.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out == nil {
+		t.Fatalf("child should remain")
+	}
+	children, err := ts.view.List(parentPrefix + ts.SaltID(ent1.ID) + "/")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(children) != 0 {
+		t.Fatalf("bad: %v", children)
+	}
+}
+
 func TestTokenStore_RevokeSelf(t *testing.T) {
 	_, ts, _, _ := TestCoreWithTokenStore(t)
 
@@ -859,7 +1006,7 @@ func TestTokenStore_HandleRequest_Revoke(t *testing.T) {
 	if err != nil {
 		t.Fatalf("err: %v %v", err, resp)
 	}
-	if resp != nil {
+	if resp == nil || resp.Data["job_id"] == "" {
 		t.Fatalf("bad: %#v", resp)
 	}
 
@@ -966,6 +1113,8 @@ func TestTokenStore_HandleRequest_Lookup(t *testing.T) {
 		"creation_ttl": int64(0),
 		"ttl":          int64(0),
 		"role":         "",
+		"entity_id":    "",
+		"type":         "service",
 	}
 
 	if resp.Data["creation_time"].(int64) == 0 {
@@ -1001,6 +1150,8 @@ func TestTokenStore_HandleRequest_Lookup(t *testing.T) {
 		"creation_ttl": int64(3600),
 		"ttl":          int64(3600),
 		"role":         "",
+		"entity_id":    "",
+		"type":         "service",
 	}
 
 	if resp.Data["creation_time"].(int64) == 0 {
@@ -1042,6 +1193,8 @@ func TestTokenStore_HandleRequest_Lookup(t *testing.T) {
 		"creation_ttl": int64(3600),
 		"ttl":          int64(3600),
 		"role":         "",
+		"entity_id":    "",
+		"type":         "service",
 	}
 
 	if resp.Data["creation_time"].(int64) == 0 {
@@ -1106,6 +1259,8 @@ func TestTokenStore_HandleRequest_LookupSelf(t *testing.T) {
 		"creation_ttl": int64(0),
 		"ttl":          int64(0),
 		"role":         "",
+		"entity_id":    "",
+		"type":         "service",
 	}
 
 	if resp.Data["creation_time"].(int64) == 0 {
@@ -1250,11 +1405,20 @@ func TestTokenStore_RoleCRUD(t *testing.T) {
 	}
 
 	expected := map[string]interface{}{
-		"name":             "test",
-		"orphan":           true,
-		"period":           float64(259200),
-		"allowed_policies": []string{"test1", "test2"},
-		"path_suffix":      "happenin",
+		"name":                   "test",
+		"orphan":                 true,
+		"period":                 float64(259200),
+		"allowed_policies":       []string{"test1", "test2"},
+		"path_suffix":            "happenin",
+		"token_type":             "",
+		"bound_cidrs":            []string(nil),
+		"disallowed_policies":    []string(nil),
+		"explicit_max_ttl":       time.Duration(0),
+		"ttl":                    time.Duration(0),
+		"max_ttl":                time.Duration(0),
+		"renewable":              true,
+		"allowed_entity_aliases": []string(nil),
+		"allowed_metadata_keys":  []string(nil),
 	}
 
 	if !reflect.DeepEqual(expected, resp.Data) {
@@ -1290,11 +1454,20 @@ func TestTokenStore_RoleCRUD(t *testing.T) {
 	}
 
 	expected = map[string]interface{}{
-		"name":             "test",
-		"orphan":           true,
-		"period":           float64(284400),
-		"allowed_policies": []string{"test3"},
-		"path_suffix":      "happenin",
+		"name":                   "test",
+		"orphan":                 true,
+		"period":                 float64(284400),
+		"allowed_policies":       []string{"test3"},
+		"path_suffix":            "happenin",
+		"token_type":             "",
+		"bound_cidrs":            []string(nil),
+		"disallowed_policies":    []string(nil),
+		"explicit_max_ttl":       time.Duration(0),
+		"ttl":                    time.Duration(0),
+		"max_ttl":                time.Duration(0),
+		"renewable":              true,
+		"allowed_entity_aliases": []string(nil),
+		"allowed_metadata_keys":  []string(nil),
 	}
 
 	if !reflect.DeepEqual(expected, resp.Data) {
@@ -1422,6 +1595,184 @@ func TestTokenStore_RoleOrphan(t *testing.T) {
 	}
 }
 
+func TestTokenStore_BatchToken(t *testing.T) {
+	_, ts, _, root := TestCoreWithTokenStore(t)
+
+	// A service token to parent the batch token
+	parent := &TokenEntry{Parent: root, Policies: []string{"root"}}
+	if err := ts.create(parent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	stored, err := ts.view.List(lookupPrefix)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "create")
+	req.ClientToken = parent.ID
+	req.Data["type"] = "batch"
+	req.Data["ttl"] = "1h"
+	resp, err := ts.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	batch := resp.Auth.ClientToken
+	if !isBatchToken(batch) || resp.Auth.Accessor != "" || resp.Auth.Renewable {
+		t.Fatalf("bad: %#v", resp.Auth)
+	}
+
+	// Nothing was written to storage
+	after, err := ts.view.List(lookupPrefix)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(after) != len(stored) {
+		t.Fatalf("bad: %v %v", stored, after)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "lookup-self")
+	req.ClientToken = batch
+	resp, err = ts.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if resp.Data["type"] != "batch" || resp.Data["id"] != batch || resp.Data["ttl"].(int64) < 3599 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// A tampered token is not found
+	tampered := batch[:len(batch)-2] + "xx"
+	if batch[len(batch)-2:] == "xx" {
+		tampered = batch[:len(batch)-2] + "yy"
+	}
+	if te, err := ts.Lookup(tampered); err != nil || te != nil {
+		t.Fatalf("bad: %v %#v", err, te)
+	}
+
+	// Batch tokens cannot be renewed, revoked or create children
+	for _, path := range []string{"renew-self", "revoke-self", "create"} {
+		req = logical.TestRequest(t, logical.UpdateOperation, path)
+		req.ClientToken = batch
+		if resp, err := ts.HandleRequest(req); err != logical.ErrInvalidRequest {
+			t.Fatalf("%s: %v %v", path, err, resp)
+		}
+	}
+
+	// Revoking the parent revokes the batch token
+	if err := ts.RevokeTree(parent.ID); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if te, err := ts.Lookup(batch); err != nil || te != nil {
+		t.Fatalf("bad: %v %#v", err, te)
+	}
+
+	// Service tokens cannot pass for batch tokens
+	req = logical.TestRequest(t, logical.UpdateOperation, "create")
+	req.ClientToken = root
+	req.Data["id"] = batchTokenPrefix + "foo"
+	if resp, err := ts.HandleRequest(req); err != logical.ErrInvalidRequest {
+		t.Fatalf("bad: %v %v", err, resp)
+	}
+
+	// Batch tokens cannot be periodic
+	req = logical.TestRequest(t, logical.UpdateOperation, "roles/test")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"token_type": "batch",
+		"period":     3600,
+	}
+	resp, err = ts.HandleRequest(req)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("bad: %v %#v", err, resp)
+	}
+}
+
+func TestTokenStore_BoundCIDRs(t *testing.T) {
+	_, ts, _, root := TestCoreWithTokenStore(t)
+
+	create := func(token, path string, data map[string]interface{}) (*logical.Response, error) {
+		req := logical.TestRequest(t, logical.UpdateOperation, path)
+		req.ClientToken = token
+		req.Data = data
+		return ts.HandleRequest(req)
+	}
+
+	resp, err := create(root, "create", map[string]interface{}{
+		"bound_cidrs": "10.0.0.0/8, 192.168.0.0/16",
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	parent := resp.Auth.ClientToken
+	te, err := ts.Lookup(parent)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(te.BoundCIDRs, []string{"10.0.0.0/8", "192.168.0.0/16"}) {
+		t.Fatalf("bad: %#v", te.BoundCIDRs)
+	}
+
+	// Children default to the networks of their parent, and cannot
+	// escape them
+	resp, err = create(parent, "create", nil)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	te, err = ts.Lookup(resp.Auth.ClientToken)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(te.BoundCIDRs, []string{"10.0.0.0/8", "192.168.0.0/16"}) {
+		t.Fatalf("bad: %#v", te.BoundCIDRs)
+	}
+
+	resp, err = create(parent, "create", map[string]interface{}{
+		"bound_cidrs": []string{"10.1.0.0/16"},
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	resp, err = create(parent, "create", map[string]interface{}{
+		"bound_cidrs": "172.16.0.0/12",
+	})
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+
+	// Roles bind the tokens created using them in the same way
+	resp, err = create(root, "roles/test", map[string]interface{}{
+		"bound_cidrs": "10.0.0.0/8",
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	resp, err = create(root, "create/test", nil)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	te, err = ts.Lookup(resp.Auth.ClientToken)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(te.BoundCIDRs, []string{"10.0.0.0/8"}) {
+		t.Fatalf("bad: %#v", te.BoundCIDRs)
+	}
+	resp, err = create(root, "create/test", map[string]interface{}{
+		"bound_cidrs": "192.168.0.0/16",
+	})
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+
+	resp, err = create(root, "roles/test", map[string]interface{}{
+		"bound_cidrs": "10.0.0.1",
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("bad: %v %#v", err, resp)
+	}
+}
+
 func TestTokenStore_RolePathSuffix(t *testing.T) {
 	_, ts, _, root := TestCoreWithTokenStore(t)
 
@@ -1610,3 +1961,286 @@ func TestTokenStore_RolePeriod(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenStore_RoleConstraints(t *testing.T) {
+	core, ts, _, root := TestCoreWithTokenStore(t)
+
+	handle := func(token, path string, data map[string]interface{}) (*logical.Response, error) {
+		req := logical.TestRequest(t, logical.UpdateOperation, path)
+		req.ClientToken = token
+		req.Data = data
+		return core.HandleRequest(req)
+	}
+
+	resp, err := handle(root, "auth/token/roles/test", map[string]interface{}{
+		"disallowed_policies":    "default,secret",
+		"period":                 300,
+		"explicit_max_ttl":       60,
+		"allowed_entity_aliases": "app-*",
+		"allowed_metadata_keys":  "env",
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+
+	// The default policy is dropped, other disallowed policies are refused
+	resp, err = handle(root, "auth/token/create/test", map[string]interface{}{
+		"policies": []string{"foo"},
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if !reflect.DeepEqual(resp.Auth.Policies, []string{"foo"}) {
+		t.Fatalf("bad: %#v", resp.Auth.Policies)
+	}
+	resp, err = handle(root, "auth/token/create/test", map[string]interface{}{
+		"policies": []string{"foo", "secret"},
+	})
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+
+	// The explicit max TTL caps the period, and any renewal
+	resp, err = handle(root, "auth/token/create/test", map[string]interface{}{
+		"policies": []string{"foo"},
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if resp.Auth.TTL != 60*time.Second {
+		t.Fatalf("bad: %v", resp.Auth.TTL)
+	}
+	resp, err = handle(root, "auth/token/renew/"+resp.Auth.ClientToken, nil)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if resp.Auth.TTL > 60*time.Second {
+		t.Fatalf("bad: %v", resp.Auth.TTL)
+	}
+
+	// Metadata keys must be allowed by the role
+	resp, err = handle(root, "auth/token/create/test", map[string]interface{}{
+		"meta": map[string]string{"env": "prod"},
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	resp, err = handle(root, "auth/token/create/test", map[string]interface{}{
+		"meta": map[string]string{"user": "bob"},
+	})
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+
+	// Entity aliases must match those allowed by the role
+	resp, err = handle(root, "auth/token/create/test", map[string]interface{}{
+		"entity_alias": "app-web",
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	te, err := ts.Lookup(resp.Auth.ClientToken)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if te.EntityID == "" {
+		t.Fatalf("expected an entity: %#v", te)
+	}
+	resp, err = handle(root, "auth/token/create/test", map[string]interface{}{
+		"entity_alias": "db",
+	})
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	resp, err = handle(root, "auth/token/create", map[string]interface{}{
+		"entity_alias": "app-web",
+	})
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+
+	// Tokens of non-renewable roles cannot be renewed
+	resp, err = handle(root, "auth/token/roles/fixed", map[string]interface{}{
+		"renewable": false,
+		"ttl":       "1h",
+		"max_ttl":   "2h",
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	resp, err = handle(root, "auth/token/create/fixed", map[string]interface{}{
+		"policies": []string{"foo"},
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if resp.Auth.Renewable || resp.Auth.TTL != time.Hour {
+		t.Fatalf("bad: %#v", resp.Auth)
+	}
+	resp, err = handle(resp.Auth.ClientToken, "auth/token/renew-self", nil)
+	if err == nil {
+		t.Fatalf("expected renewal to fail: %#v", resp)
+	}
+
+	resp, err = handle(root, "auth/token/roles/fixed", map[string]interface{}{
+		"ttl": "3h",
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("bad: %v %#v", err, resp)
+	}
+
+	// The max TTL of a role cannot exceed that of the mount
+	resp, err = handle(root, "sys/mounts/auth/token/tune", map[string]interface{}{
+		"max_lease_ttl": "30m",
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	resp, err = handle(root, "auth/token/create/fixed", map[string]interface{}{
+		"policies": []string{"foo"},
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if resp.Auth.TTL != 30*time.Minute {
+		t.Fatalf("bad: %#v", resp.Auth)
+	}
+}
+
+func TestTokenStore_Accessors(t *testing.T) {
+	core, ts, _, root := TestCoreWithTokenStore(t)
+
+	handle := func(token string, op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		req := logical.TestRequest(t, op, path)
+		req.ClientToken = token
+		req.Data = data
+		return core.HandleRequest(req)
+	}
+
+	created := make(map[string]string)
+	for name, data := range map[string]map[string]interface{}{
+		"web": map[string]interface{}{
+			"policies":     []string{"web"},
+			"display_name": "web-1",
+		},
+		"db": map[string]interface{}{
+			"policies": []string{"db"},
+			"meta":     map[string]string{"owner": "ops"},
+		},
+	} {
+		resp, err := handle(root, logical.UpdateOperation, "auth/token/create", data)
+		if err != nil {
+			t.Fatalf("err: %v %v", err, resp)
+		}
+		created[name] = resp.Auth.Accessor
+	}
+	rootEntry, err := ts.Lookup(root)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Every accessor is listed in the order of the index, one page at a time
+	bySalted := make(map[string]string)
+	var salted []string
+	for _, accessor := range []string{rootEntry.Accessor, created["web"], created["db"]} {
+		bySalted[ts.SaltID(accessor)] = accessor
+		salted = append(salted, ts.SaltID(accessor))
+	}
+	sort.Strings(salted)
+	var expected []string
+	for _, s := range salted {
+		expected = append(expected, bySalted[s])
+	}
+	resp, err := handle(root, logical.ListOperation, "auth/token/accessors", nil)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if !reflect.DeepEqual(resp.Data["keys"], expected) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	resp, err = handle(root, logical.ListOperation, "auth/token/accessors", map[string]interface{}{
+		"after": expected[0],
+		"limit": 1,
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if !reflect.DeepEqual(resp.Data["keys"], expected[1:2]) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Lookups without an accessor search by the given filters
+	for accessor, data := range map[string]map[string]interface{}{
+		created["web"]: map[string]interface{}{
+			"policy":              "web",
+			"display_name_prefix": "token-web",
+		},
+		created["db"]: map[string]interface{}{"meta_key": "owner"},
+		"": map[string]interface{}{
+			"policy":        "web",
+			"created_after": time.Now().Add(time.Hour).Format(time.RFC3339),
+		},
+	} {
+		resp, err = handle(root, logical.UpdateOperation, "auth/token/lookup-accessor", data)
+		if err != nil {
+			t.Fatalf("err: %v %v", err, resp)
+		}
+		keys, _ := resp.Data["keys"].([]string)
+		if accessor == "" && len(keys) != 0 ||
+			accessor != "" && !reflect.DeepEqual(keys, []string{accessor}) {
+			t.Fatalf("bad: %v %#v", data, resp.Data)
+		}
+	}
+
+	// Policies granted through the entity of a token are matched as well
+	testIdentityLoginBackend(t, core, root, &logical.Auth{
+		Alias: &logical.Alias{Name: "armon"},
+	})
+	auth := testIdentityLogin(t, core)
+	testIdentityRequest(t, core, logical.UpdateOperation, "identity/entity/id/"+auth.EntityID, root, map[string]interface{}{
+		"policies": "web",
+	})
+	resp, err = handle(root, logical.UpdateOperation, "auth/token/lookup-accessor", map[string]interface{}{
+		"policy": "web",
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	keys, _ := resp.Data["keys"].([]string)
+	if len(keys) != 2 || !strutil.StrListContains(keys, created["web"]) || !strutil.StrListContains(keys, auth.Accessor) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	resp, err = handle(root, logical.UpdateOperation, "auth/token/lookup-accessor", map[string]interface{}{
+		"created_before": "yesterday",
+	})
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+
+	// Both listing and searching require sudo
+	resp, err = handle(root, logical.UpdateOperation, "sys/policy/search", map[string]interface{}{
+		"rules": `path "auth/token/*" { policy = "write" }`,
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	resp, err = handle(root, logical.UpdateOperation, "auth/token/create", map[string]interface{}{
+		"policies": []string{"search"},
+	})
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	token := resp.Auth.ClientToken
+	resp, err = handle(token, logical.ListOperation, "auth/token/accessors", nil)
+	if err != logical.ErrPermissionDenied {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	resp, err = handle(token, logical.UpdateOperation, "auth/token/lookup-accessor", map[string]interface{}{
+		"policy": "web",
+	})
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+}
@@ -0,0 +1,177 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// wrappingTokenPath is the creation path used for response-wrapping
+	// tokens; it is also the source of their leases
+	wrappingTokenPath = "sys/wrapping/wrap"
+
+	// wrappingResponsePath is the cubbyhole path under which a wrapped
+	// response is stored
+	wrappingResponsePath = "cubbyhole/response"
+)
+
+// wrapInCubbyhole stores the HTTP representation of the given response in the
+// cubbyhole of a new single-use token and returns a response containing only
+// the information about that token.
+func (c *Core) wrapInCubbyhole(req *logical.Request, resp *logical.Response) (*logical.Response, error) {
+	// Because of the way that JSON encodes (likely just in Go) we actually
+	// get mixed-up values for ints if we simply put the response object in
+	// the cubbyhole and encode the whole thing later; so instead we marshal
+	// it first and store the resulting string.
+	marshaled, err := json.Marshal(logical.SanitizeResponse(resp))
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to marshal wrapped response: %v", err)
+		return nil, ErrInternalError
+	}
+
+	wrapInfo, err := c.createWrappingToken(req.WrapTTL, string(marshaled))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Auth != nil {
+		wrapInfo.WrappedAccessor = resp.Auth.Accessor
+	}
+
+	return &logical.Response{
+		WrapInfo: wrapInfo,
+	}, nil
+}
+
+// createWrappingToken creates a single-use, orphan token carrying the
+// response-wrapping policy and stores the given response in its cubbyhole.
+// If the response is empty, it is left to be stored later.
+func (c *Core) createWrappingToken(ttl time.Duration, response string) (*logical.WrapInfo, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("wrapping TTL must be positive")
+	}
+
+	// Cap the TTL to the system maximum
+	if c.maxLeaseTTL != 0 && ttl > c.maxLeaseTTL {
+		ttl = c.maxLeaseTTL
+	}
+
+	creationTime := time.Now().UTC()
+	te := TokenEntry{
+		Path:         wrappingTokenPath,
+		Policies:     []string{responseWrappingPolicyName},
+		DisplayName:  "response-wrapping",
+		NumUses:      1,
+		CreationTime: creationTime.Unix(),
+		TTL:          ttl,
+	}
+	if err := c.tokenStore.create(&te); err != nil {
+		c.logger.Printf("[ERR] core: failed to create wrapping token: %v", err)
+		return nil, ErrInternalError
+	}
+
+	if response != "" {
+		if err := c.storeWrappedResponse(te.ID, response); err != nil {
+			c.tokenStore.Revoke(te.ID)
+			return nil, err
+		}
+	}
+
+	// Register the token with the expiration manager so that it, and
+	// thereby the wrapped response, is removed once the TTL is up
+	auth := &logical.Auth{
+		ClientToken: te.ID,
+		Policies:    te.Policies,
+		DisplayName: te.DisplayName,
+		LeaseOptions: logical.LeaseOptions{
+			TTL:       ttl,
+			Renewable: false,
+		},
+	}
+	if err := c.expiration.RegisterAuth(te.Path, auth); err != nil {
+		c.tokenStore.Revoke(te.ID)
+		c.logger.Printf("[ERR] core: failed to register wrapping token lease: %v", err)
+		return nil, ErrInternalError
+	}
+
+	return &logical.WrapInfo{
+		Token:        te.ID,
+		TTL:          ttl,
+		CreationTime: creationTime,
+		Accessor:     te.Accessor,
+	}, nil
+}
+
+// storeWrappedResponse stores the response in the cubbyhole of the given
+// response-wrapping token
+func (c *Core) storeWrappedResponse(token, response string) error {
+	cubbyReq := &logical.Request{
+		Operation:   logical.CreateOperation,
+		Path:        wrappingResponsePath,
+		ClientToken: token,
+		Data: map[string]interface{}{
+			"response": response,
+		},
+	}
+	cubbyResp, err := c.router.Route(cubbyReq)
+	if err == nil && cubbyResp != nil && cubbyResp.IsError() {
+		err = fmt.Errorf("%v", cubbyResp.Data["error"])
+	}
+	if err != nil {
+		c.logger.Printf("[ERR] core: failed to store wrapped response: %v", err)
+		return ErrInternalError
+	}
+	return nil
+}
+
+// lookupWrappingToken fetches the token entry for the given token and
+// verifies that it is a response-wrapping token.
+func (c *Core) lookupWrappingToken(token string) (*TokenEntry, error) {
+	if token == "" {
+		return nil, fmt.Errorf("missing wrapping token")
+	}
+
+	te, err := c.tokenStore.Lookup(token)
+	if err != nil {
+		return nil, err
+	}
+	if te == nil || te.Path != wrappingTokenPath ||
+		len(te.Policies) != 1 || te.Policies[0] != responseWrappingPolicyName {
+		return nil, fmt.Errorf("token is not a valid response-wrapping token")
+	}
+
+	return te, nil
+}
+
+// readWrappedResponse returns the wrapped response stored in the cubbyhole
+// of the given response-wrapping token, without revoking the token.
+func (c *Core) readWrappedResponse(te *TokenEntry) (string, error) {
+	cubbyReq := &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        wrappingResponsePath,
+		ClientToken: te.ID,
+	}
+	cubbyResp, err := c.router.Route(cubbyReq)
+	if err != nil {
+		return "", fmt.Errorf("error looking up wrapped response: %v", err)
+	}
+	if cubbyResp != nil && cubbyResp.IsError() {
+		return "", fmt.Errorf("%v", cubbyResp.Data["error"])
+	}
+	var response string
+	if cubbyResp != nil && cubbyResp.Data != nil {
+		response, _ = cubbyResp.Data["response"].(string)
+	}
+	if response == "" {
+		// The response of a request awaiting approval is only stored
+		// once the request has been executed
+		if c.awaitingControlGroup(te) {
+			return "", fmt.Errorf("request is awaiting control group approval")
+		}
+		return "", fmt.Errorf("no wrapped response found")
+	}
+
+	return response, nil
+}
@@ -0,0 +1,155 @@
+package vault
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestCore_HandleRequest_Wrapping(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "secret/test",
+		Data: map[string]interface{}{
+			"foo": "bar",
+		},
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Read the key, wrapping the response
+	req = &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "secret/test",
+		ClientToken: root,
+		WrapTTL:     5 * time.Minute,
+	}
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.WrapInfo == nil || resp.WrapInfo.Token == "" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if resp.Data != nil || resp.Secret != nil {
+		t.Fatalf("wrapped response leaked data: %#v", resp)
+	}
+	if resp.WrapInfo.TTL != 5*time.Minute {
+		t.Fatalf("bad: %#v", resp.WrapInfo)
+	}
+	wrapToken := resp.WrapInfo.Token
+
+	// The wrapping token should be single-use with the fixed policy
+	te, err := c.tokenStore.Lookup(wrapToken)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if te == nil || te.NumUses != 1 || len(te.Policies) != 1 || te.Policies[0] != responseWrappingPolicyName {
+		t.Fatalf("bad: %#v", te)
+	}
+
+	// Look it up
+	req = &logical.Request{
+		Operation:   logical.UpdateOperation,
+		Path:        "sys/wrapping/lookup",
+		ClientToken: root,
+		Data: map[string]interface{}{
+			"token": wrapToken,
+		},
+	}
+	resp, err = c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Data["creation_ttl"] != int64(300) {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Rewrap it
+	req.Path = "sys/wrapping/rewrap"
+	resp, err = c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.WrapInfo == nil || resp.WrapInfo.Token == "" || resp.WrapInfo.Token == wrapToken {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if te, err := c.tokenStore.Lookup(wrapToken); err != nil || te != nil {
+		t.Fatalf("old wrapping token not revoked: %#v, %v", te, err)
+	}
+	wrapToken = resp.WrapInfo.Token
+
+	// Unwrap using the wrapping token itself
+	req = &logical.Request{
+		Operation:   logical.UpdateOperation,
+		Path:        "sys/wrapping/unwrap",
+		ClientToken: wrapToken,
+		Data: map[string]interface{}{
+			"token": wrapToken,
+		},
+	}
+	resp, err = c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil {
+		t.Fatalf("bad: nil response")
+	}
+	var unwrapped logical.HTTPResponse
+	if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &unwrapped); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unwrapped.Data["foo"] != "bar" {
+		t.Fatalf("bad: %#v", unwrapped)
+	}
+
+	// A second unwrap must fail
+	req.ClientToken = root
+	resp, err = c.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("expected invalid request, got: %#v, %v", resp, err)
+	}
+}
+
+func TestCore_HandleRequest_WrappingCubbyholeIsolated(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	req := &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "auth/token/lookup-self",
+		ClientToken: root,
+		WrapTTL:     time.Minute,
+	}
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.WrapInfo == nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// The wrapping token must not be able to do anything besides reading
+	// its own response
+	req = &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "secret/foo",
+		ClientToken: resp.WrapInfo.Token,
+	}
+	if _, err := c.HandleRequest(req); err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied, got: %v", err)
+	}
+
+	// The wrapping policy cannot be overwritten or deleted
+	if err := c.policyStore.SetPolicy(&Policy{Name: responseWrappingPolicyName}); err == nil {
+		t.Fatalf("expected error updating %s policy", responseWrappingPolicyName)
+	}
+	if err := c.policyStore.DeletePolicy(responseWrappingPolicyName); err == nil {
+		t.Fatalf("expected error deleting %s policy", responseWrappingPolicyName)
+	}
+}